@@ -1,23 +1,511 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/attestation"
+	"github.com/deis/duffle/pkg/build"
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/claim"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/manifest"
+	"github.com/deis/duffle/pkg/registry"
+	"github.com/deis/duffle/pkg/signature"
+	"github.com/deis/duffle/pkg/sigstore"
 )
 
-// TODO
 func newBuildCmd(w io.Writer) *cobra.Command {
-	const usage = `TODO`
+	const usage = `Builds a bundle from a bundle project.
+
+DIR, the current directory by default, holds a duffle.toml manifest describing the
+bundle and the images to build for it:
+
+    name = "hello"
+    version = "0.1.0"
+    description = "Says hello"
+    registry = "example.com/org"
+
+    [invocationImages.cnab]
+    path = "cnab"
+
+    [parameters.greeting]
+    type = "string"
+    defaultValue = "hello"
+
+Components, the other images of the bundle such as those of the application it installs,
+are declared like invocation images and recorded in the bundle's images. Their location
+refs tell tools relocating the bundle where the invocation image refers to them:
+
+    [components.web]
+    path = "web"
+    refs = [{ path = "/cnab/app/charts/hello/values.yaml", field = "image" }]
+
+Each image is built with Docker from its build context, which is the path
+given in the manifest or the directory named after the image, using the Dockerfile at its
+root unless 'dockerfile' names another one. Images are tagged as REGISTRY/BUNDLE-IMAGE
+unless 'repository' is set, with the bundle's version as the tag, which --version
+overrides. Invocation images declaring 'os' and 'arch' are built for that platform.
+Images are recorded in the bundle with their registry digest when the tag they were built
+as holds the same image in the registry, such as after pushing them, so that the bundle
+refers to exactly the images that were built.
+
+Images may be built without a Dockerfile by selecting another builder. The buildpacks
+builder runs Cloud Native Buildpacks with the pack CLI, which detect how to build the
+sources; build arguments are passed to the buildpacks as environment variables. The ko
+builder builds a Go program into an image with ko, from the main package given by
+'package', the build context by default. Both build into the Docker daemon:
+
+    [components.api]
+    builder = "buildpacks"
+    builderImage = "heroku/buildpacks:18"
+
+    [components.controller]
+    builder = "ko"
+    package = "./cmd/controller"
+
+Like with docker build, images built from a Dockerfile may declare build arguments, the stage to build in a
+multi-stage Dockerfile, and labels:
+
+    [invocationImages.cnab]
+    target = "release"
+    args = { HELM_VERSION = "2.11.0" }
+    labels = { "org.example.team" = "platform" }
+
+--build-arg KEY=VALUE sets a build argument for every image, overriding the manifest.
+
+Images are built with BuildKit when the manifest enables it, or with --buildkit:
+
+    [build]
+    buildkit = true
+
+BuildKit runs independent build stages in parallel, and supports cache mounts and build
+secrets. Secrets are files made available to RUN --mount=type=secret instructions without
+being stored in the image, declared per image by ID and path relative to the project:
+
+    [invocationImages.cnab]
+    secrets = { npmrc = ".npmrc" }
+
+Images are built with the local Docker daemon unless the build settings name a remote
+Docker host, or select kaniko to build without a daemon:
+
+    [build]
+    host = "tcp://builder.example.com:2376"
+
+    [build.kaniko]
+    namespace = "builds"
+    secret = "registry-credentials"
+
+kaniko pushes images to their registry as it builds them, so their digests are always
+recorded. It runs the executor given by 'executor', /kaniko/executor by default, which
+suits builds running inside a kaniko container. With a namespace, it runs as a pod in that
+namespace of the current kubectl context instead, using 'image' as the executor image,
+with the build context streamed to it; 'secret' names a docker-registry secret holding the
+credentials to push with. kaniko does not support build secrets.
+
+Build settings shared by every project go in build.toml in duffle home (in
+$XDG_CONFIG_HOME/duffle with the XDG layout), which takes the keys of the [build] table. Settings in duffle.toml take precedence.
+
+Parameters and credentials may also be declared next to the code using them, by
+annotations in the files of invocation images, so that the bundle stays in sync with it.
+The run script, app/run within the build context, is scanned unless 'declarations' lists
+other files. Annotations are comments of the form:
+
+    # duffle:parameter port type=int default=8080 description="port to listen on"
+    # duffle:parameter tier allowed=dev,prod applyTo=install,upgrade
+    # duffle:credential kubeconfig path=/root/.kube/config
+    # duffle:credential token env=API_TOKEN
+
+Parameters are strings unless 'type' says otherwise. Definitions in duffle.toml take
+precedence over annotations.
+
+Images whose build context, Dockerfile and build options did not change since they were
+last built, and which are still present in the Docker daemon or were pushed by kaniko, are
+not built again; only the bundle is regenerated. --force builds every image.
+
+The resulting bundle.json, referring to the built images, is written to DIR unless
+--file names another path. Before it is written, every image it refers to is looked up
+in the Docker daemon and then in its registry, by digest when the bundle records one, and
+the build fails listing the images that cannot be found, unless --skip-image-check is
+given.
+
+With --push, every image is pushed to its registry once built, and the bundle records its
+digest. An image may be pushed to another registry than the manifest's:
+
+    [components.web]
+    registry = "mirror.example.com/team"
+
+With --push-bundle, the bundle itself is then pushed to the manifest's registry, as
+REGISTRY/NAME:VERSION, like 'duffle push' does. Credentials saved with 'duffle registry
+login' are used for the bundle, and Docker's for the images.
+
+With --sign, the bundle is clearsigned with a key from the secret keyring, the first one
+unless --signer names another, and written to DIR/bundle.cnab instead. It is also stored
+in the local store, with a provenance file signed with the same key, so that it can be
+installed or pushed by name right away.
+
+With --sign --keyless, no key is needed: the bundle is written to DIR/bundle.json and
+signed with sigstore, using a short-lived certificate for the OIDC identity cosign finds,
+such as the identity of the CI job. The signature is recorded in the Rekor transparency
+log and written to DIR/bundle.json.sigstore, and the bundle is stored in the local store
+signed the same way. Installing it requires the identity to be trusted with 'duffle key
+trust --identity'.
+
+With --sign --attest, the build is also described by an in-toto attestation carrying SLSA
+provenance: the git commit and remote the project was built from, the builder (see
+--builder-id), the build options, and how each image was built, with the digests of their
+build inputs. It covers the bundle documents, exactly as they are written, stored and
+pushed, and the images whose digest is known, and is signed like the bundle, then written next to it as bundle.cnab.intoto.jsonl (or
+bundle.json.intoto.jsonl) and stored with the bundle in the local store. 'duffle verify
+--provenance' checks it.
+
+With --watch, the project is rebuilt whenever its files change, until duffle is
+interrupted. Only the images whose inputs changed are rebuilt. With --deploy NAME, each
+bundle built is installed as the installation NAME, or upgrades it when it already exists,
+using the driver given by --driver; the debug driver shows what would be run:
+
+    $ duffle build --watch --deploy hello-dev -d debug
+`
+
+	var (
+		output         string
+		version        string
+		buildKit       bool
+		buildArgs      []string
+		force          bool
+		watch          bool
+		deployName     string
+		driverName     string
+		sign           bool
+		signer         string
+		keyless        bool
+		attest         bool
+		builderID      string
+		push           bool
+		pushBundle     bool
+		skipImageCheck bool
+	)
 
 	cmd := &cobra.Command{
-		Use:   "build",
-		Short: usage,
+		Use:   "build [DIR]",
+		Short: "build a bundle from a bundle project",
 		Long:  usage,
-		Run: func(cmd *cobra.Command, args []string) {
-			unimplemented("duffle build")
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) == 1 {
+				dir = args[0]
+			}
+			if attest && !sign {
+				return errors.New("--attest requires --sign")
+			}
+			if output == "" {
+				output = filepath.Join(dir, "bundle.json")
+				if sign && !keyless {
+					output = filepath.Join(dir, "bundle.cnab")
+				}
+			}
+			overrides := map[string]string{}
+			for _, a := range buildArgs {
+				parts := strings.SplitN(a, "=", 2)
+				if len(parts) != 2 {
+					return fmt.Errorf("malformed build argument %q, expected KEY=VALUE", a)
+				}
+				overrides[parts[0]] = parts[1]
+			}
+
+			run := func() error {
+				started := time.Now()
+				var src *attestation.Source
+				if attest {
+					src = attestation.DetectSource(dir)
+				}
+				m, err := manifest.Load(dir)
+				if err != nil {
+					return err
+				}
+				dh := home.Home(homePath())
+				global, err := manifest.LoadBuildConfig(dh.BuildConfig())
+				if err != nil {
+					return err
+				}
+				m.Build = m.Build.Merge(global)
+				if version != "" {
+					m.Version = version
+				}
+				if buildKit {
+					m.Build.BuildKit = true
+				}
+				m.SetBuildArgs(overrides)
+				if err := m.Validate(); err != nil {
+					return err
+				}
+				cache, err := build.NewCache(dh.BuildCache(), dir)
+				if err != nil {
+					return err
+				}
+				client, err := registryClient(dh)
+				if err != nil {
+					return err
+				}
+				record := &build.Record{}
+				b, err := build.Build(dir, m, build.Options{Out: w, Cache: cache, Force: force, Registry: client, Push: push || pushBundle, Record: record})
+				if err != nil {
+					return err
+				}
+				if !skipImageCheck {
+					if err := build.VerifyImages(b, client); err != nil {
+						return err
+					}
+				}
+				var stored string
+				if sign {
+					stored, err = signBuiltBundle(w, dh, b, signer, keyless, output)
+				} else {
+					err = b.WriteFile(output, 0644)
+				}
+				if err != nil {
+					return err
+				}
+				// the document pushed is encoded before the build is attested, to cover its bytes
+				var pushed [][]byte
+				if pushBundle {
+					data, err := json.MarshalIndent(b, "", "    ")
+					if err != nil {
+						return err
+					}
+					pushed = [][]byte{data}
+				}
+				if attest {
+					params := map[string]string{"version": m.Version}
+					for k, v := range overrides {
+						params["build-arg:"+k] = v
+					}
+					if m.Build.BuildKit {
+						params["buildkit"] = "true"
+					}
+					if push || pushBundle {
+						params["push"] = "true"
+					}
+					bd := attestation.Build{
+						BuilderID:  builderID,
+						Source:     src,
+						Manifest:   src.Prefix + manifest.FileName,
+						Parameters: params,
+						Record:     record,
+						Started:    started,
+						Finished:   time.Now(),
+					}
+					if err := attestBuild(w, dh, b, bd, signer, keyless, pushed, output, stored); err != nil {
+						return fmt.Errorf("cannot attest the build: %v", err)
+					}
+				}
+				fmt.Fprintf(w, "Built bundle %s %s to %s\n", b.Name, b.Version, output)
+				if pushBundle {
+					if err := pushBuiltBundle(w, dh, client, m, b, pushed[0]); err != nil {
+						return err
+					}
+				}
+				if deployName == "" {
+					return nil
+				}
+				return deploy(w, deployName, b, driverName)
+			}
+
+			err := run()
+			if !watch {
+				return err
+			}
+			if err != nil {
+				fmt.Fprintf(w, "Error: %v\n", err)
+			}
+			// images are only rebuilt when their own inputs change
+			force = false
+			out, err := filepath.Abs(output)
+			if err != nil {
+				return err
+			}
+			root, err := filepath.Abs(dir)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Watching %s for changes\n", dir)
+			return build.Watch(dir, time.Second, func(rel string) bool {
+				return filepath.Join(root, filepath.FromSlash(rel)) == out
+			}, func() {
+				if err := run(); err != nil {
+					fmt.Fprintf(w, "Error: %v\n", err)
+				}
+				fmt.Fprintf(w, "Watching %s for changes\n", dir)
+			})
 		},
 	}
 
+	flags := cmd.Flags()
+	flags.StringVarP(&output, "file", "f", "", "path to write the bundle to (default DIR/bundle.json, or DIR/bundle.cnab with --sign and a key)")
+	flags.StringVar(&version, "version", "", "version to build, overriding the version in duffle.toml")
+	flags.BoolVar(&buildKit, "buildkit", false, "build images with BuildKit")
+	flags.BoolVar(&force, "force", false, "build every image, even those unchanged since their last build")
+	flags.StringArrayVar(&buildArgs, "build-arg", []string{}, "set a build argument for every image (KEY=VALUE)")
+	flags.BoolVar(&skipImageCheck, "skip-image-check", false, "write the bundle even if some of its images cannot be found")
+	flags.BoolVar(&push, "push", false, "push the images to their registries once built")
+	flags.BoolVar(&pushBundle, "push-bundle", false, "push the images, then the bundle to REGISTRY/NAME:VERSION")
+	flags.BoolVar(&sign, "sign", false, "clearsign the bundle and store it in the local store")
+	flags.StringVar(&signer, "signer", "", "ID of the key used to sign the bundle with --sign")
+	flags.BoolVar(&keyless, "keyless", false, "sign with sigstore instead of a key from the secret keyring")
+	flags.BoolVar(&attest, "attest", false, "write a signed provenance attestation of the build next to the bundle, with --sign")
+	flags.StringVar(&builderID, "builder-id", attestation.DefaultBuilderID, "URI identifying what runs the build, such as a CI workflow, recorded with --attest")
+	flags.BoolVar(&watch, "watch", false, "rebuild the bundle whenever the project changes")
+	flags.StringVar(&deployName, "deploy", "", "install the built bundle as the installation NAME, or upgrade it if it exists")
+	flags.StringVarP(&driverName, "driver", "d", "docker", "driver used by --deploy")
+	outputPathAlias(cmd, "file")
+
 	return cmd
 }
+
+// deploy installs b as the installation name with the driver driverName, or upgrades the
+// installation to b when it already exists, reusing its parameter values
+func deploy(w io.Writer, name string, b *bundle.Bundle, driverName string) error {
+	d, err := lookupDriver(driverName, false)
+	if err != nil {
+		return err
+	}
+	claims, err := claimStore()
+	if err != nil {
+		return err
+	}
+	action := "upgrade"
+	c, err := claims.Read(name)
+	if err != nil {
+		action = "install"
+		c = claim.New(name)
+		if c.Dependencies, err = resolveDependencies(claims, b); err != nil {
+			return err
+		}
+	}
+	c.Bundle = b
+	c.Source = nil
+	params, err := resolveParameters(b, action, nil, c.Parameters)
+	if err != nil {
+		return err
+	}
+	for k, v := range params {
+		c.Parameters[k] = v
+	}
+	op, err := newOperation(c, action, params, d, "", w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Running %s of %s\n", action, name)
+	runErr := d.Run(op)
+	c.Update(op.Action, runErr)
+	if err := claims.Store(c); err != nil {
+		return fmt.Errorf("cannot record claim for %s: %v", c.Name, err)
+	}
+	return runErr
+}
+
+// signBuiltBundle writes b, clearsigned with the key signer, to path and stores it in the
+// local store, returning the path it was stored as. With keyless, b is written as is and
+// signed with sigstore instead.
+func signBuiltBundle(w io.Writer, h home.Home, b *bundle.Bundle, signer string, keyless bool, path string) (string, error) {
+	data, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	if keyless {
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return "", err
+		}
+		if err := sigstore.Sign(path); err != nil {
+			return "", err
+		}
+	} else {
+		s, err := loadSigner(h, signer)
+		if err != nil {
+			return "", err
+		}
+		signed, err := s.Clearsign(data)
+		if err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(path, signed, 0644); err != nil {
+			return "", err
+		}
+	}
+	stored, err := LocalStore{home: h, signer: signer, keyless: keyless}.Store(b)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(w, "Stored %s %s in the local store as %s\n", b.Name, b.Version, stored)
+	return stored, nil
+}
+
+// attestBuild writes the attestation of the build bd of b next to each of the bundle
+// files paths, signed with the key signer, or with sigstore when keyless. It covers the
+// files as they were written, and the documents pushed, which are not written anywhere.
+func attestBuild(w io.Writer, h home.Home, b *bundle.Bundle, bd attestation.Build, signer string, keyless bool, pushed [][]byte, paths ...string) error {
+	docs := pushed
+	for _, p := range paths {
+		doc, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+	var (
+		s   *signature.Signer
+		err error
+	)
+	if !keyless {
+		if s, err = loadSigner(h, signer); err != nil {
+			return err
+		}
+	}
+	env, err := attestation.Seal(attestation.NewStatement(b, docs, bd), s)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		att := attestation.Path(p)
+		if err := env.WriteFile(att); err != nil {
+			return err
+		}
+		if keyless {
+			if err := sigstore.Sign(att); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(w, "Wrote provenance attestation %s\n", att)
+	}
+	return nil
+}
+
+// pushBuiltBundle pushes data, the document of b, to the manifest's registry, tagged with
+// its version
+func pushBuiltBundle(w io.Writer, h home.Home, client *registry.Client, m *manifest.Manifest, b *bundle.Bundle, data []byte) error {
+	if m.Registry == "" {
+		return errors.New("cannot push the bundle: the manifest does not set a registry")
+	}
+	ref, err := registry.ParseReference(m.Registry + "/" + b.Name + ":" + build.Tag(b.Version))
+	if err != nil {
+		return err
+	}
+	d, err := client.PushBundle(ref, data)
+	if err != nil {
+		return err
+	}
+	if err := signBundle(h, client, ref, d); err != nil {
+		return fmt.Errorf("pushed %s@%s, but could not sign it: %v", ref, d, err)
+	}
+	fmt.Fprintf(w, "Pushed bundle %s %s to %s@%s\n", b.Name, b.Version, ref, d)
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+func newBundleCmd(w io.Writer) *cobra.Command {
+	const usage = `Work with bundle documents`
+
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: usage,
+		Long:  usage,
+	}
+
+	cmd.AddCommand(newBundleConvertCmd(w))
+	cmd.AddCommand(newBundlePatchCmd(w))
+	cmd.AddCommand(newBundleShowCmd(w))
+	cmd.AddCommand(newBundleSignCmd(w))
+	cmd.AddCommand(newBundleValidateCmd(w))
+
+	return cmd
+}
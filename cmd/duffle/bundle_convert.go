@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/loader"
+)
+
+func newBundleConvertCmd(w io.Writer) *cobra.Command {
+	const usage = `Converts a bundle manifest into canonical bundle.json.
+
+Bundle manifests may be authored in YAML (bundle.yaml or bundle.yml) using the same
+field names as bundle.json. Other duffle commands accept YAML manifests directly;
+this command writes the canonical JSON document for publishing.
+`
+
+	var dest string
+
+	cmd := &cobra.Command{
+		Use:   "convert BUNDLE_FILE",
+		Short: "convert a bundle manifest to canonical bundle.json",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			b, err := loader.Load(args[0])
+			if err != nil {
+				return err
+			}
+			if err := b.WriteFile(dest, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Wrote %s\n", dest)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dest, "destination", "d", "bundle.json", "path of the bundle.json to write")
+
+	return cmd
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/loader"
+)
+
+func newBundlePatchCmd(w io.Writer) *cobra.Command {
+	const usage = `Applies an overlay to an upstream bundle.
+
+The overlay is a partial bundle document (JSON or YAML) holding the fields to change:
+parameter defaults, image overrides, metadata and so on. Fields it leaves out are kept
+from the base bundle. The patched bundle is printed, or written to --destination.
+`
+
+	var dest string
+
+	cmd := &cobra.Command{
+		Use:   "patch BASE_FILE OVERLAY_FILE",
+		Short: "apply an overlay to a bundle",
+		Long:  usage,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base, err := loader.Load(args[0])
+			if err != nil {
+				return err
+			}
+			overlay, err := loader.Load(args[1])
+			if err != nil {
+				return err
+			}
+			b := bundle.Merge(base, overlay)
+			if findings := bundle.Validate(b); findings.HasErrors() {
+				for _, f := range findings {
+					fmt.Fprintln(w, f)
+				}
+				return fmt.Errorf("patched bundle is not valid")
+			}
+
+			if dest != "" {
+				return b.WriteFile(dest, 0644)
+			}
+			data, err := json.MarshalIndent(b, "", "    ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, string(data))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dest, "destination", "d", "", "path to write the patched bundle to")
+
+	return cmd
+}
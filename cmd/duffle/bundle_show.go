@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/duffle/home"
+)
+
+func newBundleShowCmd(w io.Writer) *cobra.Command {
+	const usage = `Shows the contents of a bundle.
+
+BUNDLE is either a path to a bundle file or a bundle in the local store, given as
+NAME or NAME:VERSION. Without a version, the highest stored version is shown.
+
+The bundle is described as tables, or printed as JSON or YAML with --output.
+`
+
+	return &cobra.Command{
+		Use:   "show BUNDLE",
+		Short: "show the contents of a bundle",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			b, err := loadBundleRef(home.Home(homePath()), args[0])
+			if err != nil {
+				return err
+			}
+			return p.Print(b, func(w io.Writer) error {
+				showBundle(w, b)
+				return nil
+			})
+		},
+	}
+}
+
+// showBundle describes b as tables, aligned by the tabwriter w
+func showBundle(w io.Writer, b *bundle.Bundle) {
+	fmt.Fprintf(w, "Name:\t%s\n", b.Name)
+	fmt.Fprintf(w, "Version:\t%s\n", b.Version)
+	if b.Description != "" {
+		fmt.Fprintf(w, "Description:\t%s\n", b.Description)
+	}
+
+	fmt.Fprintln(w, "\nINVOCATION IMAGE\tTYPE\tPLATFORM\tDIGEST")
+	for _, img := range b.InvocationImages {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", img.Image, img.ImageType, img.Platform(), img.Digest)
+	}
+
+	if len(b.Images) > 0 {
+		fmt.Fprintln(w, "\nIMAGE\tURI\tTYPE\tDIGEST")
+		for _, img := range b.Images {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", img.Name, img.URI, img.ImageType, img.Digest)
+		}
+	}
+
+	if len(b.Parameters) > 0 {
+		fmt.Fprintln(w, "\nPARAMETER\tTYPE\tDEFAULT\tALLOWED VALUES\tACTIONS")
+		for _, name := range sortedNames(b.Parameters) {
+			p := b.Parameters[name]
+			def := "<required>"
+			if p.DefaultValue != nil {
+				def = fmt.Sprintf("%v", p.DefaultValue)
+			}
+			var allowed []string
+			for _, v := range p.AllowedValues {
+				allowed = append(allowed, fmt.Sprintf("%v", v))
+			}
+			actions := "*"
+			if len(p.ApplyTo) > 0 {
+				actions = strings.Join(p.ApplyTo, ",")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, p.DataType, def, strings.Join(allowed, ","), actions)
+		}
+	}
+
+	if len(b.Credentials) > 0 {
+		fmt.Fprintln(w, "\nCREDENTIAL\tPATH\tENV")
+		for _, name := range sortedNames(b.Credentials) {
+			c := b.Credentials[name]
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, c.Path, c.EnvironmentVariable)
+		}
+	}
+}
+
+// sortedNames returns the keys of a bundle's parameter or credential map in order
+func sortedNames(m interface{}) []string {
+	var names []string
+	switch m := m.(type) {
+	case map[string]bundle.ParameterDefinition:
+		for k := range m {
+			names = append(names, k)
+		}
+	case map[string]bundle.CredentialLocation:
+		for k := range m {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
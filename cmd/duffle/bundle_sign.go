@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newBundleSignCmd(w io.Writer) *cobra.Command {
+	const usage = `Adds a signature to a bundle.
+
+BUNDLE is a bundle file, or a bundle of the local store given as NAME[:VERSION]. Signed
+bundle documents, and the provenance files of stored bundles, can carry signatures by
+several keys, such as the key of a build system and the key of a release manager. The
+signature by the key selected with --signer is added to the signatures already present;
+an unsigned bundle file is clearsigned. The file is updated in place, or written to
+--destination.
+
+'duffle key trust --threshold' makes installs from a repository or registry require
+signatures by several of the keys trusted for it.
+`
+
+	var (
+		signer string
+		dest   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sign BUNDLE",
+		Short: "add a signature to a bundle",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			path := args[0]
+			local, _, err := resolveLocalReference(h, path)
+			if err != nil {
+				return err
+			}
+			if local != "" {
+				// the bundles of the local store are signed through their provenance file
+				path = signature.ProvenancePath(local)
+				if _, err := os.Stat(path); os.IsNotExist(err) {
+					return fmt.Errorf("%s has no provenance file; store it again with --signer to sign it", args[0])
+				}
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			s, err := loadSigner(h, signer)
+			if err != nil {
+				return err
+			}
+			if signature.IsClearsigned(data) {
+				data, err = s.Cosign(data)
+			} else {
+				data, err = s.Clearsign(data)
+			}
+			if err != nil {
+				return fmt.Errorf("cannot sign %s: %v", args[0], err)
+			}
+			if dest == "" {
+				dest = path
+			}
+			if err := atomicfile.WriteFile(dest, data, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Signed %s with %s\n", args[0], s.Info())
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&signer, "signer", "", "ID of the key used to sign the bundle")
+	flags.StringVarP(&dest, "destination", "d", "", "path to write the signed bundle to")
+
+	return cmd
+}
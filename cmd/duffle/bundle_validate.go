@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+)
+
+func newBundleValidateCmd(w io.Writer) *cobra.Command {
+	const usage = `Checks a bundle document for problems.
+
+Validation covers required fields, the semantic version, image reference syntax,
+parameter definitions and credential locations. Findings are printed one per line,
+or as a JSON or YAML array with --output. The command fails if any finding is an error.
+`
+
+	var loadOpts loader.Options
+
+	cmd := &cobra.Command{
+		Use:   "validate BUNDLE_FILE",
+		Short: "check a bundle document for problems",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			h := home.Home(homePath())
+			if loadOpts.Keys, err = loadKeyRing(h.PublicKeyring()); err != nil {
+				return err
+			}
+			loadOpts.Cache = loader.HomeCache(h)
+			l, err := loader.NewWithOptions(args[0], loadOpts)
+			if err != nil {
+				return err
+			}
+			b, err := l.Load(args[0])
+			if err != nil {
+				return err
+			}
+			findings := bundle.Validate(b)
+			if findings == nil {
+				findings = bundle.Findings{}
+			}
+			if err := p.Print(findings, func(w io.Writer) error {
+				for _, f := range findings {
+					fmt.Fprintln(w, f)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if findings.HasErrors() {
+				return errors.New("bundle is not valid")
+			}
+			if p.IsTable() {
+				fmt.Fprintf(w, "%s is valid\n", args[0])
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	addLoaderFlags(flags, &loadOpts)
+
+	return cmd
+}
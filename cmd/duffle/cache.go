@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/progress"
+)
+
+func newCacheCmd(w io.Writer) *cobra.Command {
+	const usage = `Manage the cache of downloaded bundles and repository indexes.
+
+Bundles fetched from registries, and from URLs pinned with a '#sha256=' checksum, are
+cached by digest so that repeated installs do not download them again. Other bundles
+fetched over HTTP are cached along with their ETag or Last-Modified date, and only
+downloaded again when the server reports that they changed.
+
+The indexes of repositories are cached when repositories are added or updated, along with
+the documents fetched to build them and the checkouts of git repositories. Removing them
+is safe: they are fetched again when next needed.
+`
+
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "manage the cache of downloaded bundles and repository indexes",
+		Long:  usage,
+	}
+
+	cmd.AddCommand(newCacheCleanCmd(w))
+	cmd.AddCommand(newCacheListCmd(w))
+	cmd.AddCommand(newCachePurgeCmd(w))
+
+	return cmd
+}
+
+func newCacheListCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list cached bundles and repository indexes",
+		Long: `Lists the entries of the cache, most recently modified first, with their size:
+
+    bundle    a bundle document, by digest
+    index     the index of a repository, by repository name
+    document  a document fetched to build a repository index, by digest
+    checkout  the checkout of a git repository, by repository name
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			entries, err := cacheEntries(home.Home(homePath()))
+			if err != nil {
+				return err
+			}
+			if entries == nil {
+				entries = []cacheEntry{}
+			}
+			if err := p.Print(entries, func(tw io.Writer) error {
+				fmt.Fprintln(tw, "KIND\tENTRY\tSIZE\tMODIFIED")
+				for _, e := range entries {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Kind, e.Name, progress.HumanSize(e.Size), e.Modified.Format("2006-01-02 15:04:05"))
+				}
+				return nil
+			}); err != nil || !p.IsTable() {
+				return err
+			}
+			var total int64
+			for _, e := range entries {
+				total += e.Size
+			}
+			fmt.Fprintf(w, "%d entries, %s\n", len(entries), progress.HumanSize(total))
+			return nil
+		},
+	}
+}
+
+func newCacheCleanCmd(w io.Writer) *cobra.Command {
+	const usage = `Removes entries from the cache.
+
+Every entry is removed unless --older-than or --max-size is given. --older-than AGE removes
+the entries not modified within AGE, such as 30d, 12h or 2w. --max-size SIZE then removes
+the least recently modified entries until the cache takes at most SIZE, such as 1g or
+500m. Together, they remove the entries either of them selects.
+
+With --dry-run, the entries that would be removed are printed, and nothing is removed.
+`
+
+	var (
+		olderThan, maxSize string
+		dryRun             bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "clean",
+		Short: "remove old entries from the cache, or all of them",
+		Long:  usage,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all := olderThan == "" && maxSize == ""
+			var (
+				age   time.Duration
+				limit int64 = -1
+				err   error
+			)
+			if olderThan != "" {
+				if age, err = parseAge(olderThan); err != nil {
+					return err
+				}
+			}
+			if maxSize != "" {
+				if limit, err = parseSize(maxSize); err != nil {
+					return err
+				}
+			}
+
+			entries, err := cacheEntries(home.Home(homePath()))
+			if err != nil {
+				return err
+			}
+			var (
+				cutoff = time.Now().Add(-age)
+				kept   int64
+				freed  int64
+				count  int
+			)
+			// entries are sorted most recently modified first, so the oldest go first
+			// when the cache is over its maximum size
+			for _, e := range entries {
+				remove := all || (olderThan != "" && e.Modified.Before(cutoff))
+				if !remove && limit >= 0 && kept+e.Size > limit {
+					remove = true
+				}
+				if !remove {
+					kept += e.Size
+					continue
+				}
+				if dryRun {
+					fmt.Fprintf(w, "Would remove %s %s (%s)\n", e.Kind, e.Name, progress.HumanSize(e.Size))
+				} else if err := e.remove(); err != nil {
+					return err
+				}
+				freed += e.Size
+				count++
+			}
+			if dryRun {
+				fmt.Fprintf(w, "Would remove %d entries, freeing %s\n", count, progress.HumanSize(freed))
+				return nil
+			}
+			fmt.Fprintf(w, "Removed %d entries, freeing %s\n", count, progress.HumanSize(freed))
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&olderThan, "older-than", "", "remove entries not modified within this age, such as 30d")
+	flags.StringVar(&maxSize, "max-size", "", "remove the oldest entries until the cache takes at most this size, such as 1g")
+	flags.BoolVar(&dryRun, "dry-run", false, "print the entries that would be removed without removing them")
+
+	return cmd
+}
+
+func newCachePurgeCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "purge [DIGEST...]",
+		Short: "remove cached bundles",
+		Long:  "Removes the cache entries with the given digests, such as sha256:<64 hex digits>, or every entry when no digest is given.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := loader.HomeCache(home.Home(homePath()))
+			if len(args) == 0 {
+				return c.Purge()
+			}
+			for _, d := range args {
+				if err := c.Remove(d); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// cacheEntry is an entry of the cache of duffle home
+type cacheEntry struct {
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	remove   func() error
+}
+
+// cacheEntries lists the cached bundles, repository indexes, documents fetched for them
+// and repository checkouts of h, most recently modified first
+func cacheEntries(h home.Home) ([]cacheEntry, error) {
+	var entries []cacheEntry
+	caches := []struct {
+		kind  string
+		cache *loader.Cache
+	}{
+		{"bundle", loader.HomeCache(h)},
+		{"document", loader.NewCache(filepath.Join(h.RepositoryCache(), "http"))},
+	}
+	for _, c := range caches {
+		cached, err := c.cache.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range cached {
+			cache, d := c.cache, e.Digest
+			entries = append(entries, cacheEntry{
+				Kind:     c.kind,
+				Name:     e.Digest,
+				Size:     e.Size,
+				Modified: e.Modified,
+				remove:   func() error { return cache.Remove(d) },
+			})
+		}
+	}
+
+	indexes, err := filepath.Glob(filepath.Join(h.RepositoryCache(), "*-index.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range indexes {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		path := path
+		entries = append(entries, cacheEntry{
+			Kind:     "index",
+			Name:     strings.TrimSuffix(filepath.Base(path), "-index.json"),
+			Size:     fi.Size(),
+			Modified: fi.ModTime(),
+			remove:   func() error { return os.Remove(path) },
+		})
+	}
+
+	checkouts, err := ioutil.ReadDir(filepath.Join(h.RepositoryCache(), "git"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, fi := range checkouts {
+		if !fi.IsDir() {
+			continue
+		}
+		path := filepath.Join(h.RepositoryCache(), "git", fi.Name())
+		size, modified, err := treeSize(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, cacheEntry{
+			Kind:     "checkout",
+			Name:     fi.Name(),
+			Size:     size,
+			Modified: modified,
+			remove:   func() error { return os.RemoveAll(path) },
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Modified.After(entries[j].Modified) })
+	return entries, nil
+}
+
+// treeSize returns the total size of the files under dir, and when the most recently
+// modified of them was modified
+func treeSize(dir string) (int64, time.Time, error) {
+	var (
+		size     int64
+		modified time.Time
+	)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		if fi.ModTime().After(modified) {
+			modified = fi.ModTime()
+		}
+		return nil
+	})
+	return size, modified, err
+}
+
+// parseAge parses an age such as 30d, 2w or 12h: a Go duration, or a number of days (d)
+// or weeks (w)
+func parseAge(s string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if n, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64); strings.HasSuffix(s, suffix) && err == nil && n >= 0 {
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q: expected a duration such as 30d, 2w or 12h", s)
+	}
+	return d, nil
+}
+
+// parseSize parses a size such as 1g or 500MiB: a number of bytes, optionally with a
+// binary unit k, m, g or t
+func parseSize(s string) (int64, error) {
+	invalid := fmt.Errorf("invalid size %q: expected a size such as 1g or 500m", s)
+	num := strings.TrimRight(strings.ToLower(s), "bi")
+	if num == "" {
+		return 0, invalid
+	}
+	var unit int64 = 1
+	if i := strings.IndexByte("kmgt", num[len(num)-1]); i != -1 {
+		unit = 1 << (10 * uint(i+1))
+		num = num[:len(num)-1]
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, invalid
+	}
+	return int64(n * float64(unit)), nil
+}
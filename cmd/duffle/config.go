@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/claim"
+	"github.com/deis/duffle/pkg/duffle/config"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/printer"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+func newConfigCmd(w io.Writer) *cobra.Command {
+	const usage = `Manages the defaults of duffle commands.
+
+The defaults are kept in config.yaml in duffle home, and apply to every command that has
+the matching flag, unless the flag is given on the command line:
+
+    driver              driver bundles are run with (--driver)
+    repository          repository bundles given as NAME[:VERSION] are looked up in when
+                        the local store does not hold them
+    output              table, json or yaml; the output format of results, as with --output
+    claims.backend      claim store: filesystem, the only one
+    claims.path         directory of the filesystem claim store (default: claims in
+                        duffle home)
+    registry.chunkSize  size in bytes of the chunks large blobs are uploaded in
+    registry.plainHTTP  comma-separated registries, as HOST[:PORT], accessed over plain
+                        HTTP rather than HTTPS
+    credentials         file holding the credentials saved with 'duffle registry login'
+                        (default: registries.json in duffle home)
+    telemetry           on to report anonymous usage, off (the default) not to
+    telemetry.endpoint  URL usage reports are sent to, such as a self-hosted collector
+                        (default: https://telemetry.duffle.sh/v1/events)
+    releaseKeys         comma-separated fingerprints of the keys allowed to sign the
+                        releases 'duffle self-update' installs (default: the release keys
+                        built into duffle)
+
+Usage is only reported once turned on with 'duffle config set telemetry=on'. Each command
+then posts, as JSON, its name (such as "duffle repo add"), the platform, how long it ran,
+whether it succeeded, and the class of its error: usage, network, filesystem, process or
+other. Bundle names, references, parameters, paths and error messages are never sent.
+The DUFFLE_TELEMETRY environment variable, on or off, and DUFFLE_TELEMETRY_ENDPOINT take
+precedence over the configuration.
+
+The driver, repository, claim store and credentials of the current context, selected with
+'duffle context use', take precedence over these defaults.
+`
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "manage the defaults of duffle commands",
+		Long:  usage,
+	}
+	cmd.AddCommand(newConfigGetCmd(w))
+	cmd.AddCommand(newConfigSetCmd(w))
+	cmd.AddCommand(newConfigUnsetCmd(w))
+
+	return cmd
+}
+
+func newConfigGetCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get [KEY]",
+		Short: "print a default, or every default that is set",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			c, err := config.Load(home.Home(homePath()).Config())
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				v, err := c.Get(args[0])
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(w, v)
+				return nil
+			}
+			settings := map[string]string{}
+			for _, k := range config.Keys() {
+				if v, _ := c.Get(k); v != "" {
+					settings[k] = v
+				}
+			}
+			return p.Print(settings, func(w io.Writer) error {
+				for _, k := range config.Keys() {
+					if v, ok := settings[k]; ok {
+						fmt.Fprintf(w, "%s=%s\n", k, v)
+					}
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func newConfigSetCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set KEY VALUE",
+		Short: "set a default",
+		Long:  "Sets a default, given as KEY VALUE or KEY=VALUE.",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				i := strings.Index(args[0], "=")
+				if i == -1 {
+					return fmt.Errorf("no value given for %s: expected KEY VALUE or KEY=VALUE", args[0])
+				}
+				args = []string{args[0][:i], args[0][i+1:]}
+			}
+			return updateConfig(args[0], args[1])
+		},
+	}
+}
+
+func newConfigUnsetCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unset KEY",
+		Short: "remove a default",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateConfig(args[0], "")
+		},
+	}
+}
+
+// updateConfig sets key to value in the configuration of duffle home. A configuration that
+// does not parse is started over, so that it can be repaired.
+func updateConfig(key, value string) error {
+	path := home.Home(homePath()).Config()
+	c, err := config.Load(path)
+	if err != nil {
+		c = &config.Config{}
+	}
+	if err := c.Set(key, value); err != nil {
+		return err
+	}
+	return c.WriteFile(path)
+}
+
+// applyConfig sets the flags of cmd that were not given on the command line to the
+// defaults of the configuration in duffle home
+func applyConfig(cmd *cobra.Command) error {
+	// the configuration commands must work even when the configuration does not
+	if p := cmd.Parent(); p != nil && (p.Name() == "config" || p.Name() == "context") {
+		return nil
+	}
+	c, err := currentConfig(home.Home(homePath()))
+	if err != nil {
+		return fmt.Errorf("%v; fix it with 'duffle config set'", err)
+	}
+	defaults := map[string]string{"driver": c.Driver}
+	for name, value := range defaults {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed || value == "" {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("invalid default for --%s: %v", name, err)
+		}
+	}
+	if err := applyOutputPathAlias(cmd); err != nil {
+		return err
+	}
+	if flags := cmd.Flags(); c.Output != "" && !flags.Changed("output") {
+		outputFormat = c.Output
+	}
+	if _, err := printer.ParseFormat(outputFormat); err != nil {
+		return err
+	}
+	registry.PlainHTTPRegistries = c.Registry.PlainHTTP
+	return nil
+}
+
+// currentConfig returns the defaults in effect in h: those of its configuration, overridden
+// by those of the current context
+func currentConfig(h home.Home) (*config.Config, error) {
+	c, err := config.Load(h.Config())
+	if err != nil {
+		return nil, err
+	}
+	return c.Current(), nil
+}
+
+// registriesFile returns the file holding the saved registry credentials of the current
+// context, or of h
+func registriesFile(h home.Home) (string, error) {
+	c, err := currentConfig(h)
+	if err != nil {
+		return "", err
+	}
+	if c.Credentials != "" {
+		return c.Credentials, nil
+	}
+	return h.Registries(), nil
+}
+
+// claimStore returns the claim store the configuration of duffle home selects
+func claimStore() (claim.Store, error) {
+	h := home.Home(homePath())
+	c, err := currentConfig(h)
+	if err != nil {
+		return claim.Store{}, err
+	}
+	if c.Claims.Path != "" {
+		return claim.NewStore(c.Claims.Path), nil
+	}
+	return claim.NewStore(h.Claims()), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+func newContextCmd(w io.Writer) *cobra.Command {
+	const usage = `Manage contexts.
+
+A context is a named set of defaults for an environment, such as dev, staging or prod:
+the driver bundles are run with, the default repository, the claim store, and the file
+holding saved registry credentials. While a context is in use, its defaults take
+precedence over those of 'duffle config', and flags given on the command line over both,
+so that switching environments is a single 'duffle context use'.
+
+Contexts are kept in config.yaml in duffle home.
+`
+
+	cmd := &cobra.Command{
+		Use:   "context",
+		Short: "manage named sets of defaults for environments",
+		Long:  usage,
+	}
+
+	cmd.AddCommand(newContextCreateCmd(w))
+	cmd.AddCommand(newContextListCmd(w))
+	cmd.AddCommand(newContextRemoveCmd(w))
+	cmd.AddCommand(newContextUseCmd(w))
+
+	return cmd
+}
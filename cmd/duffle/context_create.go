@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/config"
+	"github.com/deis/duffle/pkg/duffle/home"
+)
+
+func newContextCreateCmd(w io.Writer) *cobra.Command {
+	const usage = `Creates a context, or updates the defaults of an existing one.
+
+Only the defaults given as flags are set; pass an empty value, such as --driver "", to
+unset one. With --use, the context is put in use.
+`
+
+	var (
+		ctx config.Context
+		use bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create NAME",
+		Short: "create or update a context",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !config.ValidContextName(name) {
+				return fmt.Errorf("invalid context name %q: use letters, digits, '.', '-' and '_'", name)
+			}
+			h := home.Home(homePath())
+			c, err := config.Load(h.Config())
+			if err != nil {
+				return err
+			}
+			if c.Contexts == nil {
+				c.Contexts = map[string]*config.Context{}
+			}
+			existing, updated := c.Contexts[name], true
+			if existing == nil {
+				existing, updated = &config.Context{}, false
+				c.Contexts[name] = existing
+			}
+			flags := cmd.Flags()
+			if flags.Changed("driver") {
+				existing.Driver = ctx.Driver
+			}
+			if flags.Changed("repository") {
+				existing.Repository = ctx.Repository
+			}
+			if flags.Changed("claims-backend") {
+				existing.Claims.Backend = ctx.Claims.Backend
+			}
+			if flags.Changed("claims-path") {
+				existing.Claims.Path = ctx.Claims.Path
+			}
+			if flags.Changed("credentials") {
+				existing.Credentials = ctx.Credentials
+			}
+			if use {
+				c.CurrentContext = name
+			}
+			if err := c.Validate(); err != nil {
+				return err
+			}
+			if err := c.WriteFile(h.Config()); err != nil {
+				return err
+			}
+			if updated {
+				fmt.Fprintf(w, "Updated context %s\n", name)
+			} else {
+				fmt.Fprintf(w, "Created context %s\n", name)
+			}
+			if use {
+				fmt.Fprintf(w, "Switched to context %s\n", name)
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&ctx.Driver, "driver", "", "driver bundles are run with")
+	flags.StringVar(&ctx.Repository, "repository", "", "repository bundles given as NAME[:VERSION] are looked up in")
+	flags.StringVar(&ctx.Claims.Backend, "claims-backend", "", "claim store backend: filesystem")
+	flags.StringVar(&ctx.Claims.Path, "claims-path", "", "directory of the filesystem claim store")
+	flags.StringVar(&ctx.Credentials, "credentials", "", "file holding the credentials saved with 'duffle registry login'")
+	flags.BoolVar(&use, "use", false, "put the context in use")
+
+	return cmd
+}
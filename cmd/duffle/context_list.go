@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/config"
+	"github.com/deis/duffle/pkg/duffle/home"
+)
+
+func newContextListCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list contexts, marking the one in use",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			c, err := config.Load(home.Home(homePath()).Config())
+			if err != nil {
+				return err
+			}
+			contexts := make([]contextListing, 0, len(c.Contexts))
+			for name, ctx := range c.Contexts {
+				contexts = append(contexts, contextListing{Name: name, Current: name == c.CurrentContext, Context: ctx})
+			}
+			sort.Slice(contexts, func(i, j int) bool { return contexts[i].Name < contexts[j].Name })
+
+			return p.Print(contexts, func(tw io.Writer) error {
+				fmt.Fprintln(tw, "CURRENT\tNAME\tDRIVER\tREPOSITORY\tCLAIMS\tCREDENTIALS")
+				for _, ctx := range contexts {
+					current := ""
+					if ctx.Current {
+						current = "*"
+					}
+					claims := ctx.Claims.Path
+					if ctx.Claims.Backend != "" {
+						claims = ctx.Claims.Backend + ":" + claims
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", current, ctx.Name, ctx.Driver, ctx.Repository, claims, ctx.Credentials)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// contextListing is a context listed by 'duffle context list'
+type contextListing struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+	*config.Context
+}
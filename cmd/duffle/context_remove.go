@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/config"
+	"github.com/deis/duffle/pkg/duffle/home"
+)
+
+func newContextRemoveCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove NAME",
+		Short: "remove a context",
+		Long:  "Removes a context. When it is in use, only the defaults of 'duffle config' apply afterwards.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			c, err := config.Load(h.Config())
+			if err != nil {
+				return err
+			}
+			if c.Contexts[args[0]] == nil {
+				return fmt.Errorf("context %s does not exist", args[0])
+			}
+			delete(c.Contexts, args[0])
+			if c.CurrentContext == args[0] {
+				c.CurrentContext = ""
+			}
+			if err := c.WriteFile(h.Config()); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Removed context %s\n", args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/config"
+	"github.com/deis/duffle/pkg/duffle/home"
+)
+
+func newContextUseCmd(w io.Writer) *cobra.Command {
+	var none bool
+
+	cmd := &cobra.Command{
+		Use:   "use NAME",
+		Short: "put a context in use",
+		Long:  "Puts a context in use, or with --none stops using any, so that only the defaults of 'duffle config' apply.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if none {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			c, err := config.Load(h.Config())
+			if err != nil {
+				return err
+			}
+			if none {
+				c.CurrentContext = ""
+			} else {
+				if c.Contexts[args[0]] == nil {
+					return fmt.Errorf("context %s does not exist; create it with 'duffle context create'", args[0])
+				}
+				c.CurrentContext = args[0]
+			}
+			if err := c.WriteFile(h.Config()); err != nil {
+				return err
+			}
+			if none {
+				fmt.Fprintln(w, "No context in use")
+			} else {
+				fmt.Fprintf(w, "Switched to context %s\n", c.CurrentContext)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&none, "none", false, "stop using any context")
+
+	return cmd
+}
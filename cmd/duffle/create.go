@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/scaffold"
+)
+
+func newCreateCmd(w io.Writer) *cobra.Command {
+	const usage = `Creates a new bundle project.
+
+A directory called NAME, or --dir, is created with everything 'duffle build' needs:
+a duffle.toml manifest, a cnab/ directory holding the Dockerfile of the invocation image
+and its run script, example parameter values in parameters.yaml, and a README.
+
+The project is generated from the template selected with --template:
+
+    default  an invocation image running a shell script for each action
+    helm     an invocation image installing a Helm chart into a Kubernetes cluster
+
+Templates may also be shared as directories of a git repository, so that an organization
+can standardize the layout of its bundles. --template then names either:
+
+    a directory                  ./templates/terraform
+    a directory on a git host    github.com/org/templates/terraform
+    a git URL                    git::https://example.com/org/templates.git//terraform?ref=v1
+    REPO/PATH                    acme/terraform
+
+where REPO is a template repository configured in templates.json in duffle home, which maps
+names to git URLs:
+
+    {"acme": "git::https://github.com/acme/bundle-templates.git?ref=stable"}
+
+Every file of the template's directory is copied into the project. Paths, and files
+ending in .tmpl, are rendered as Go templates with {{.Name}} set to NAME, and files ending
+in .tmpl are written without the suffix. Git repositories are fetched into the cache of
+duffle home.
+
+Existing files are never overwritten.
+`
+
+	var (
+		dir      string
+		tmplName string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create NAME",
+		Short: "create a new bundle project",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dh := home.Home(homePath())
+			repos, err := scaffold.LoadRepositories(dh.TemplateRepositories())
+			if err != nil {
+				return err
+			}
+			t, err := scaffold.Find(tmplName, repos, dh.TemplateCache())
+			if err != nil {
+				return err
+			}
+			if dir == "" {
+				dir = args[0]
+			}
+			files, err := scaffold.Create(dir, t, scaffold.Values{Name: args[0]})
+			if err != nil {
+				return err
+			}
+			for _, f := range files {
+				rel, err := filepath.Rel(dir, f)
+				if err != nil {
+					rel = f
+				}
+				fmt.Fprintf(w, "  %s\n", filepath.ToSlash(rel))
+			}
+			fmt.Fprintf(w, "Created bundle project %s in %s from the %s template\n", args[0], dir, t.Name)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&dir, "dir", "", "directory to create the project in (default NAME)")
+	flags.StringVarP(&tmplName, "template", "t", "default", "template to generate the project from")
+
+	return cmd
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/claim"
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/duffle/config"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/repo"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// Results of the checks of 'duffle doctor'
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// diagnosis is the result of a check of 'duffle doctor'
+type diagnosis struct {
+	// Check names what was checked, such as docker or keyring
+	Check string `json:"check"`
+	// Status is one of pass, warn or fail
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// Hint tells how to fix what failed or was warned about
+	Hint string `json:"hint,omitempty"`
+}
+
+// diagnoses collects the results of 'duffle doctor'
+type diagnoses []diagnosis
+
+func (d *diagnoses) pass(check, format string, args ...interface{}) {
+	*d = append(*d, diagnosis{Check: check, Status: checkPass, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *diagnoses) warn(check, hint, format string, args ...interface{}) {
+	*d = append(*d, diagnosis{Check: check, Status: checkWarn, Message: fmt.Sprintf(format, args...), Hint: hint})
+}
+
+func (d *diagnoses) fail(check, hint, format string, args ...interface{}) {
+	*d = append(*d, diagnosis{Check: check, Status: checkFail, Message: fmt.Sprintf(format, args...), Hint: hint})
+}
+
+// count returns how many results have status
+func (d diagnoses) count(status string) int {
+	n := 0
+	for _, r := range d {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func newDoctorCmd(w io.Writer) *cobra.Command {
+	const usage = `Diagnoses problems with the environment of duffle.
+
+The following is checked:
+
+- docker: that the docker CLI is installed and the Docker daemon answers, unless the
+  configured driver does not use Docker;
+- home: that duffle home exists, has the layout of this version of duffle, that its
+  directories are writable and its configuration is valid;
+- keyring: that the keyrings can be read, that a signing key is available, that no key
+  has expired or been revoked, and that secrets are only readable by their owner;
+- repository: that every configured repository can be reached, and its index verified;
+- claims: that every claim of the claim store can be read and names its installation.
+
+Every result is printed as pass, warn or fail, with a hint on how to fix what is wrong,
+or as a JSON or YAML array with --output. The command fails when any check fails.
+Unlike other commands, doctor does not migrate duffle home, nor require a valid
+configuration.
+`
+
+	var skipRepos bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "diagnose problems with the environment of duffle",
+		Long:  usage,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			h := home.Home(homePath())
+			var d diagnoses
+			c := checkHome(&d, h)
+			checkDocker(&d, c)
+			checkKeyrings(&d, h)
+			if !skipRepos {
+				checkRepositories(&d, h)
+			}
+			checkClaims(&d, h, c)
+
+			if err := p.Print(d, func(w io.Writer) error {
+				printDiagnoses(w, d)
+				return nil
+			}); err != nil {
+				return err
+			}
+			if n := d.count(checkFail); n > 0 {
+				return fmt.Errorf("%d of %d checks failed", n, len(d))
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&skipRepos, "skip-repos", false, "do not check that the repositories can be reached")
+
+	return cmd
+}
+
+// printDiagnoses prints the results for people, followed by a summary
+func printDiagnoses(w io.Writer, d diagnoses) {
+	for _, r := range d {
+		fmt.Fprintf(w, "[%s] %-10s %s\n", strings.ToUpper(r.Status), r.Check, r.Message)
+		if r.Hint != "" {
+			fmt.Fprintf(w, "       %-10s hint: %s\n", "", r.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", d.count(checkPass), d.count(checkWarn), d.count(checkFail))
+}
+
+// checkHome checks the layout, permissions and configuration of h, returning the
+// configuration in effect, or the default one when it is not valid
+func checkHome(d *diagnoses, h home.Home) *config.Config {
+	const check = "home"
+	c := &config.Config{}
+	if fi, err := os.Stat(h.String()); err != nil || !fi.IsDir() {
+		d.fail(check, "run 'duffle init'", "duffle home %s does not exist", h)
+		return c
+	}
+	d.pass(check, "duffle home is %s", h)
+
+	switch v, err := h.Version(); {
+	case err != nil:
+		d.fail(check, "remove the version file and run 'duffle migrate'", "cannot read the layout version: %v", err)
+	case v > len(migrations):
+		d.fail(check, "upgrade duffle, with 'duffle self-update'", "layout version %d was written by a later version of duffle, which supports up to version %d", v, len(migrations))
+	case v < len(migrations):
+		d.warn(check, "run 'duffle migrate'", "layout version %d is older than version %d of this version of duffle", v, len(migrations))
+	default:
+		d.pass(check, "layout version %d is current", v)
+	}
+
+	for _, dir := range []string{h.ConfigPath(), h.Bundles(), h.Claims(), h.Cache(), h.RepositoryCache()} {
+		fi, err := os.Stat(dir)
+		switch {
+		case os.IsNotExist(err):
+			d.warn(check, "run 'duffle init'", "directory %s does not exist", dir)
+			continue
+		case err != nil:
+			d.fail(check, "fix the permissions of duffle home", "cannot access %s: %v", dir, err)
+			continue
+		case !fi.IsDir():
+			d.fail(check, fmt.Sprintf("move %s away and run 'duffle init'", dir), "%s is not a directory", dir)
+			continue
+		}
+		if err := checkWritable(dir); err != nil {
+			d.fail(check, fmt.Sprintf("give your user write access to %s", dir), "directory %s is not writable: %v", dir, err)
+		}
+	}
+
+	loaded, err := config.Load(h.Config())
+	if err != nil {
+		d.fail(check, "fix the file, or reset the invalid settings with 'duffle config unset'", "%v", err)
+		return c
+	}
+	c = loaded.Current()
+	if loaded.CurrentContext != "" {
+		d.pass(check, "configuration is valid (context %s)", loaded.CurrentContext)
+	} else {
+		d.pass(check, "configuration is valid")
+	}
+	return c
+}
+
+// checkWritable reports why files cannot be created in dir
+func checkWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".doctor-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkDocker checks that the Docker daemon answers, when the driver of c uses it
+func checkDocker(d *diagnoses, c *config.Config) {
+	const check = "docker"
+	if c.Driver != "" && c.Driver != "docker" {
+		d.pass(check, "skipped: the configured driver %s does not use Docker", c.Driver)
+		return
+	}
+	if _, err := exec.LookPath(docker.Command); err != nil {
+		d.fail(check, "install Docker, or configure another driver with 'duffle config set driver'", "the docker CLI was not found: %v", err)
+		return
+	}
+	goos, arch, err := docker.ServerPlatform()
+	if err != nil {
+		d.fail(check, "start the Docker daemon, or point DOCKER_HOST at a running one", "cannot reach the Docker daemon: %v", err)
+		return
+	}
+	d.pass(check, "the Docker daemon answers (%s/%s)", goos, arch)
+}
+
+// checkKeyrings checks the keyrings of h and the keys they hold
+func checkKeyrings(d *diagnoses, h home.Home) {
+	const check = "keyring"
+	now := time.Now()
+	for _, kr := range []struct {
+		name, path string
+		secret     bool
+	}{
+		{"public", h.PublicKeyring(), false},
+		{"secret", h.SecretKeyring(), true},
+	} {
+		ring, err := signature.LoadKeyRing(kr.path)
+		switch {
+		case os.IsNotExist(err) && kr.secret:
+			d.warn(check, "generate a signing key with 'duffle key generate'", "there is no secret keyring: bundles cannot be signed")
+			continue
+		case os.IsNotExist(err):
+			d.warn(check, "import the keys of the publishers you trust with 'duffle key import' or 'duffle key fetch'", "there is no public keyring: signed bundles cannot be verified")
+			continue
+		case err != nil:
+			d.fail(check, fmt.Sprintf("restore %s from a backup, or remove it and import the keys again", kr.path), "cannot read the %s keyring: %v", kr.name, err)
+			continue
+		}
+		keys := ring.Entities()
+		switch {
+		case len(keys) == 0 && kr.secret:
+			d.warn(check, "generate a signing key with 'duffle key generate'", "the secret keyring holds no key: bundles cannot be signed")
+		case len(keys) == 0:
+			d.warn(check, "import the keys of the publishers you trust with 'duffle key import' or 'duffle key fetch'", "the public keyring holds no key")
+		default:
+			d.pass(check, "the %s keyring holds %d keys", kr.name, len(keys))
+		}
+		for _, e := range keys {
+			if err := ring.Check(e, now); err != nil {
+				hint := "remove it with 'duffle key remove'"
+				if kr.secret {
+					hint = "generate a new signing key with 'duffle key generate' and remove this one with 'duffle key remove'"
+				}
+				d.warn(check, hint, "%v", err)
+			}
+		}
+		if kr.secret {
+			checkPrivate(d, check, kr.path)
+		}
+	}
+	if file, err := registriesFile(h); err == nil {
+		checkPrivate(d, check, file)
+	}
+	checkPrivate(d, check, h.Repositories())
+}
+
+// checkPrivate warns when the file at path, which holds secrets, is readable by others
+// than its owner
+func checkPrivate(d *diagnoses, check, path string) {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm()&0077 == 0 {
+		return
+	}
+	d.warn(check, fmt.Sprintf("run 'chmod 600 %s'", path), "%s holds secrets but is readable by other users (mode %04o)", path, fi.Mode().Perm())
+}
+
+// checkRepositories checks that the repositories of h can be reached
+func checkRepositories(d *diagnoses, h home.Home) {
+	const check = "repository"
+	repos, err := repo.LoadRepositoryFile(h.Repositories())
+	if err != nil {
+		d.fail(check, fmt.Sprintf("fix %s, or remove it and add the repositories again", h.Repositories()), "%v", err)
+		return
+	}
+	if len(repos.Repositories) == 0 {
+		d.pass(check, "no repositories are configured")
+		return
+	}
+	for _, r := range repos.Repositories {
+		if err := r.Ping(); err != nil {
+			d.fail(check, fmt.Sprintf("check the URL and credentials of the repository, or remove it with 'duffle repo remove %s'", r.Name), "repository %s cannot be reached: %v", r.Name, err)
+			continue
+		}
+		d.pass(check, "repository %s can be reached", r.Name)
+	}
+}
+
+// checkClaims checks that the claims of the claim store of c, or of h, can be read
+func checkClaims(d *diagnoses, h home.Home, c *config.Config) {
+	const check = "claims"
+	s := claim.NewStore(h.Claims())
+	if c.Claims.Path != "" {
+		s = claim.NewStore(c.Claims.Path)
+	}
+	names, err := s.List()
+	if err != nil {
+		d.fail(check, "fix the permissions of the claim store", "cannot list the claims: %v", err)
+		return
+	}
+	bad := 0
+	for _, name := range names {
+		cl, err := s.Read(name)
+		switch {
+		case err != nil:
+			d.fail(check, "restore the claim from a backup, or remove it if the installation is gone", "%v", err)
+		case cl.Name != name:
+			d.fail(check, fmt.Sprintf("rename the claim to %s.json, or set its name to %s", cl.Name, name), "the claim of %s names the installation %q", name, cl.Name)
+		case cl.Bundle == nil:
+			d.fail(check, "restore the claim from a backup, or remove it if the installation is gone", "the claim of %s records no bundle", name)
+		default:
+			continue
+		}
+		bad++
+	}
+	if bad == 0 {
+		d.pass(check, "the claim store holds %d valid claims", len(names))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/packager"
+	"github.com/deis/duffle/pkg/progress"
+)
+
+func newExportCmd(w io.Writer) *cobra.Command {
+	const usage = `Packages a bundle and all of its images into a single compressed archive.
+
+The resulting archive contains bundle.json plus the invocation image and every component
+image, suitable for transferring a bundle into an air-gapped environment. Image files are
+stored by content digest, so layers shared by several images are stored only once.
+Images missing from the local Docker daemon are pulled before being saved. Each step is
+reported on standard error, with progress bars when it is a terminal.
+`
+
+	var dest string
+
+	cmd := &cobra.Command{
+		Use:   "export BUNDLE_FILE",
+		Short: "package a bundle and its images into a single archive",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dest == "" {
+				b, err := loader.Load(args[0])
+				if err != nil {
+					return err
+				}
+				dest = fmt.Sprintf("%s-%s.tgz", b.Name, b.Version)
+			}
+			ex := &packager.Exporter{
+				Source:      args[0],
+				Destination: dest,
+				Progress:    progress.New(os.Stderr),
+			}
+			if err := ex.Export(); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Exported bundle to %s\n", dest)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dest, "destination", "d", "", "path of the archive to write (default NAME-VERSION.tgz)")
+
+	return cmd
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/packager"
+	"github.com/deis/duffle/pkg/progress"
+	"github.com/deis/duffle/pkg/repo"
+)
+
+func newImportCmd(w io.Writer) *cobra.Command {
+	const usage = `Imports a bundle archive created by 'duffle export'.
+
+The images contained in the archive are loaded into the local Docker daemon. When
+--target-registry is set, each image is also pushed to that registry and the bundle's
+image references are rewritten to point at it. The bundle is then added to the local store,
+together with a provenance file signed with a key from the secret keyring in duffle home.
+With --keyless, it is signed with sigstore instead, using a short-lived certificate for
+the OIDC identity cosign finds, and must then be signed by an identity trusted with
+'duffle key trust --identity' to be installed.
+`
+
+	var (
+		registry string
+		signer   string
+		insecure bool
+		keyless  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import ARCHIVE",
+		Short: "import a bundle archive and its images",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			im := &packager.Importer{
+				Source:         args[0],
+				TargetRegistry: registry,
+				Progress:       progress.New(os.Stderr),
+			}
+			b, err := im.Import()
+			if err != nil {
+				return err
+			}
+			source, err := filepath.Abs(args[0])
+			if err != nil {
+				return err
+			}
+			store := LocalStore{
+				home:     home.Home(homePath()),
+				signer:   signer,
+				insecure: insecure,
+				keyless:  keyless,
+				origin:   &repo.Origin{URL: source},
+			}
+			dest, err := store.Store(b)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Imported bundle %s %s to %s\n", b.Name, b.Version, dest)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&registry, "target-registry", "", "registry to push imported images to")
+	flags.StringVar(&signer, "signer", "", "ID of the key used to sign the bundle's provenance file")
+	flags.BoolVar(&insecure, "insecure", false, "store the bundle without a provenance file")
+	flags.BoolVar(&keyless, "keyless", false, "sign the bundle with sigstore instead of a key from the secret keyring")
+
+	return cmd
+}
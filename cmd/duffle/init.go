@@ -1,23 +1,173 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"os/user"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
 )
 
-// TODO
 func newInitCmd(w io.Writer) *cobra.Command {
-	const usage = `TODO`
+	const usage = `Sets up duffle home.
+
+The directories of duffle home are created, and a signing key is generated into the
+secret keyring, which signs the bundles duffle builds and stores. The name and email
+address of the key are asked on the terminal, unless given with --name and --email.
+
+Running init again only creates what is missing: existing directories and keys are left
+as they are, and no key is generated when the secret keyring already holds one.
+
+With --non-interactive, nothing is asked: a key is generated for --name, and init fails
+when it is not given. Pass --skip-keys to set up duffle home without a signing key, for
+instance on machines that only install bundles.
+`
+
+	var (
+		nonInteractive bool
+		skipKeys       bool
+		name, email    string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "init",
-		Short: usage,
+		Short: "set up duffle home",
 		Long:  usage,
-		Run: func(cmd *cobra.Command, args []string) {
-			unimplemented("duffle init")
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			_, err := os.Stat(h.String())
+			fresh := os.IsNotExist(err)
+			if err := ensureHome(w, h); err != nil {
+				return err
+			}
+			// new homes start with the current layout; existing ones are migrated to it
+			if fresh {
+				err = h.SetVersion(len(migrations))
+			} else {
+				_, err = migrateHome(w, h, false)
+			}
+			if err != nil {
+				return err
+			}
+			if !skipKeys {
+				interactive := !nonInteractive && terminal.IsTerminal(int(os.Stdin.Fd()))
+				if err := ensureSigningKey(w, h, name, email, interactive); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(w, "duffle home is set up at %s\n", h)
+			return nil
 		},
 	}
 
+	flags := cmd.Flags()
+	flags.BoolVar(&nonInteractive, "non-interactive", false, "never prompt; fail when a required value is not given as a flag")
+	flags.BoolVar(&skipKeys, "skip-keys", false, "do not generate a signing key")
+	flags.StringVar(&name, "name", "", "name of the generated signing key")
+	flags.StringVar(&email, "email", "", "email address of the generated signing key")
+
 	return cmd
 }
+
+// ensureHome creates the directories of duffle home that do not exist
+func ensureHome(w io.Writer, h home.Home) error {
+	dirs := []string{
+		h.String(),
+		h.ConfigPath(),
+		h.Bundles(),
+		h.Claims(),
+		h.Cache(),
+		h.RepositoryCache(),
+	}
+	for _, dir := range dirs {
+		fi, err := os.Stat(dir)
+		if err == nil {
+			if !fi.IsDir() {
+				return fmt.Errorf("%s exists and is not a directory", dir)
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Created %s\n", dir)
+	}
+	return nil
+}
+
+// ensureSigningKey generates a signing key for name and email unless the secret keyring of
+// h already holds one. The name and email are asked when interactive and not given.
+func ensureSigningKey(w io.Writer, h home.Home, name, email string, interactive bool) error {
+	secret, err := loadKeyRing(h.SecretKeyring())
+	if err != nil {
+		return err
+	}
+	if keys := secret.Entities(); len(keys) > 0 {
+		fmt.Fprintf(w, "Using signing key %s\n", signature.Info(keys[0]))
+		return nil
+	}
+	if name == "" {
+		if !interactive {
+			return errors.New("the secret keyring holds no signing key: pass --name to generate one, or --skip-keys")
+		}
+		in := bufio.NewReader(os.Stdin)
+		if name, err = ask(w, in, "Name of the signing key", defaultKeyName()); err != nil {
+			return err
+		}
+		if email == "" {
+			if email, err = ask(w, in, "Email address of the signing key", ""); err != nil {
+				return err
+			}
+		}
+	}
+	if name == "" {
+		return errors.New("a signing key needs a name")
+	}
+	e, err := generateKey(h, name, "", email, 0)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Generated key %s\n", signature.Info(e))
+	return nil
+}
+
+// ask reads the answer to question from in, returning def when the answer is empty
+func ask(w io.Writer, in *bufio.Reader, question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(w, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(w, "%s: ", question)
+	}
+	answer, err := in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// defaultKeyName returns the full name of the current user, or their login name
+func defaultKeyName() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.Username
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/claim"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/registry"
+	"github.com/deis/duffle/pkg/repo"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newInstallCmd(w io.Writer) *cobra.Command {
+	const usage = `Installs a bundle.
+
+The bundle's invocation image is run with the 'install' action using the selected driver.
+Parameter values are passed with --set NAME=VALUE; parameters not set fall back to their defaults.
+Parameters that declare 'applyTo' are only required and injected for the listed actions.
+
+With --relocation-mapping, image references in the bundle are rewritten according to the
+given JSON file (a map of original to relocated references) before execution, and the
+mapping is made available to the invocation image at /cnab/app/relocation-mapping.json.
+
+Bundles that declare dependencies can only be installed once every dependency has a
+successful installation whose version satisfies the declared range. The installations
+that satisfied each dependency are recorded in the claim.
+
+The bundle file may also be a bundle directory, an http(s) URL, or '-' to read it from
+standard input. Append '#sha256=<hex>' to a URL to verify the checksum of the downloaded
+document. Parameter value templates in a bundle directory's parameters.yaml take
+precedence over the bundle's defaults, and --set takes precedence over both.
+
+Bundles in the local store, such as those fetched with 'duffle pull', are installed by
+passing NAME or NAME:VERSION as BUNDLE or to -f, where VERSION may be a semver constraint
+or a digest. The local store is looked up first, so no repository or registry is
+contacted for them.
+
+Bundles in a configured repository (see 'duffle repo') are installed by passing
+REPO/BUNDLE or REPO/BUNDLE@VERSION as BUNDLE or to -f, where VERSION may be a semver
+constraint such as ^1.2; the newest matching version in the repository's index is used.
+The repository, the digest of its index, the URL the bundle was loaded from and the
+exact version the constraint resolved to are printed and recorded in the claim.
+
+To pin exact content, VERSION may also be a digest, as REPO/BUNDLE@sha256:<hex>, and
+registry references may name a digest, as example.com/org/bundle@sha256:<hex>. The
+digest of the bundle (the manifest digest for registries) is recorded in the claim.
+
+Clearsigned bundle files are verified against the public keyring in duffle home, and
+the signer is reported. If a provenance file (BUNDLE_FILE.prov) is present, it is also
+verified against the public keyring before the bundle is installed. Without one, a
+sigstore bundle (BUNDLE_FILE.sigstore) is verified against the transparency log and the
+identities trusted with 'duffle key trust --identity'.
+
+Bundles from the local store must have a provenance file, and bundles from repositories
+must be clearsigned, by a key in the public keyring. Unsigned bundles and bundles whose
+signature cannot be verified are refused unless --insecure is passed, in which case they
+are installed without verification. Registry-backed repositories cannot hold clearsigned
+bundles: their registry must have content trust enabled instead (see 'duffle registry
+trust'), so that the tags of their bundles are verified against signed trust data.
+
+The trust policy (see 'duffle key trust') restricts which keys may sign the bundles of a
+repository, registry or web server. Bundles from a source with a policy entry are refused
+unless one of its keys signed them, or --insecure is passed. Bundles of the local store
+are held to the entry for the registry or archive they were pulled or imported from.
+`
+
+	var (
+		bundleFile        string
+		driverName        string
+		invocationImage   string
+		skipDigestCheck   bool
+		relocationMapping string
+		loadOpts          loader.Options
+		values            []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "install NAME [BUNDLE]",
+		Short: "install a bundle",
+		Long:  usage,
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 2 {
+				if cmd.Flags().Changed("file") {
+					return errors.New("cannot pass both BUNDLE and --file")
+				}
+				bundleFile = args[1]
+			}
+			h, src, err := loadBundleHandle(w, bundleFile, loadOpts)
+			if err != nil {
+				return err
+			}
+			b := h.Bundle
+			d, err := lookupDriver(driverName, skipDigestCheck)
+			if err != nil {
+				return err
+			}
+
+			claims, err := claimStore()
+			if err != nil {
+				return err
+			}
+			if _, err := claims.Read(args[0]); err == nil {
+				return fmt.Errorf("installation %q already exists", args[0])
+			}
+			c := claim.New(args[0])
+			c.Bundle = b
+			c.Source = src
+			if c.Dependencies, err = resolveDependencies(claims, b); err != nil {
+				return err
+			}
+
+			params, err := resolveParameters(b, "install", values, h.Parameters)
+			if err != nil {
+				return err
+			}
+			c.Parameters = params
+
+			var mapping []byte
+			if relocationMapping != "" {
+				m, err := bundle.LoadRelocationMap(relocationMapping)
+				if err != nil {
+					return err
+				}
+				b.Relocate(m)
+				if mapping, err = json.Marshal(m); err != nil {
+					return err
+				}
+			}
+
+			op, err := newOperation(c, "install", params, d, invocationImage, w)
+			if err != nil {
+				return err
+			}
+			if mapping != nil {
+				op.Files[bundle.RelocationMappingPath] = string(mapping)
+			}
+
+			runErr := d.Run(op)
+			c.Update(op.Action, runErr)
+			if err := claims.Store(c); err != nil {
+				return fmt.Errorf("cannot record claim for %s: %v", c.Name, err)
+			}
+			return runErr
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&bundleFile, "file", "f", "bundle.json", "path or URL of the bundle file to install, or - for standard input")
+	addLoaderFlags(flags, &loadOpts)
+	flags.BoolVar(&loadOpts.Insecure, "insecure", false, "install unsigned bundles and bundles whose signature cannot be verified")
+	flags.StringVarP(&driverName, "driver", "d", "docker", "specify a driver name")
+	flags.StringVar(&invocationImage, "invocation-image", "", "invocation image to run when the bundle declares several")
+	flags.BoolVar(&skipDigestCheck, "skip-digest-check", false, "run the invocation image even if its pinned digest does not match the registry")
+	flags.StringVar(&relocationMapping, "relocation-mapping", "", "path to a relocation mapping file")
+	flags.StringArrayVar(&values, "set", []string{}, "set a parameter value (NAME=VALUE)")
+
+	return cmd
+}
+
+// resolveDependencies finds an existing installation for each of the bundle's dependencies
+func resolveDependencies(claims claim.Store, b *bundle.Bundle) (map[string]string, error) {
+	if len(b.Dependencies) == 0 {
+		return nil, nil
+	}
+	names, err := claims.List()
+	if err != nil {
+		return nil, err
+	}
+	resolved := map[string]string{}
+	for _, dep := range b.Dependencies {
+		for _, name := range names {
+			c, err := claims.Read(name)
+			if err != nil {
+				return nil, err
+			}
+			if c.Bundle == nil || c.Result.Status != claim.StatusSuccess {
+				continue
+			}
+			ok, err := dep.SatisfiedBy(c.Bundle.Name, c.Bundle.Version)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				resolved[dep.Name] = c.Name
+				break
+			}
+		}
+		if _, ok := resolved[dep.Name]; !ok {
+			return nil, fmt.Errorf("dependency %s %s is not installed", dep.Name, dep.Version)
+		}
+	}
+	return resolved, nil
+}
+
+// addLoaderFlags registers the flags controlling how bundle documents are parsed
+func addLoaderFlags(flags *pflag.FlagSet, opts *loader.Options) {
+	flags.StringVar(&opts.Format, "bundle-format", "", "format of the bundle document (json, yaml or signed); detected when unset")
+	flags.BoolVar(&opts.Strict, "strict", false, "reject bundle documents containing unknown fields")
+}
+
+// loadBundle loads the bundle at source, reporting the signer when the bundle is signed
+func loadBundle(w io.Writer, source string, opts loader.Options) (*bundle.Bundle, error) {
+	h, _, err := loadBundleHandle(w, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	return h.Bundle, nil
+}
+
+// loadBundleHandle loads the bundle at source along with any auxiliary files, reporting the
+// signer when the bundle is signed. Only bundle directories carry auxiliary files.
+//
+// A source naming a bundle in the index of the local store, as NAME[:VERSION], is loaded
+// from the store before repositories and registries are considered. Bundles in
+// repositories with mirrors are loaded from the first mirror that serves them.
+// When source resolves through the local store or a repository, or names a registry, where
+// the bundle was loaded from and its digest are reported and returned; otherwise the source
+// is nil.
+//
+// Bundles from the local store must carry a verified provenance file, and bundles from
+// http(s) and git repositories a verified signature, unless opts.Insecure is set. The
+// provenance file of a bundle file on disk, if there is one, must verify too. Bundles
+// must also be signed by a key the trust policy allows for their source; for bundles of the
+// local store, that is the source they were pulled or imported from.
+func loadBundleHandle(w io.Writer, source string, opts loader.Options) (*loader.Handle, *claim.Source, error) {
+	dh := home.Home(homePath())
+	local, src, err := resolveLocalReference(dh, source)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		r    *repo.Repository
+		urls []string
+	)
+	if local == "" {
+		if r, src, urls, err = resolveRepoReference(dh, source); err != nil {
+			return nil, nil, err
+		}
+	}
+	var (
+		h *loader.Handle
+		d string
+	)
+	switch {
+	case local != "":
+		signers, perr := verifyProvenance(dh, local)
+		if perr != nil && !opts.Insecure {
+			return nil, nil, fmt.Errorf("cannot verify provenance of %s: %v", local, perr)
+		}
+		if signers == nil && perr == nil && !opts.Insecure {
+			return nil, nil, fmt.Errorf("%s has no provenance file (pass --insecure to install it anyway)", source)
+		}
+		if h, _, err = loadSource(nil, local, opts); err == nil && h.Signers == nil {
+			h.Signers = signers
+		}
+	case r == nil:
+		if loader.IsRegistryReference(source) {
+			src = &claim.Source{URL: source}
+		}
+		// bundle files on disk may have a provenance file next to them
+		var signers []*signature.KeyInfo
+		if fi, serr := os.Stat(source); serr == nil && fi.Mode().IsRegular() {
+			var perr error
+			if signers, perr = verifyProvenance(dh, source); perr != nil && !opts.Insecure {
+				return nil, nil, fmt.Errorf("cannot verify provenance of %s: %v", source, perr)
+			}
+		}
+		if h, d, err = loadSource(nil, source, opts); err == nil && h.Signers == nil {
+			h.Signers = signers
+		}
+	default:
+		var errs []string
+		for _, u := range urls {
+			if h, d, err = loadSource(r, u, opts); err == nil {
+				src.URL = u
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if err != nil {
+			err = fmt.Errorf("cannot load %s: %s", source, strings.Join(errs, "; "))
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case r == nil || opts.Insecure:
+	case repo.IsOCIURL(r.URL):
+		// registries store bundles as plain JSON: their tags are signed with content trust instead
+		trusted, err := contentTrust(dh, src.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !trusted {
+			return nil, nil, fmt.Errorf("%s is not signed: content trust is not enabled for the registry of repository %s (enable it with 'duffle registry trust', or pass --insecure to install it anyway)", source, r.Name)
+		}
+	case h.Signers == nil:
+		return nil, nil, fmt.Errorf("%s is not signed by a trusted key (pass --insecure to install it anyway)", source)
+	}
+	if !opts.Insecure {
+		// the provenance of stored bundles is signed locally: the policy applies to their origin
+		if local != "" {
+			err = checkOrigin(dh, h.Bundle.Name, src.Digest)
+		} else {
+			err = checkTrustPolicy(dh, source, r, h.Signers)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v (pass --insecure to install it anyway)", err)
+		}
+	}
+	if src != nil {
+		if src.Digest == "" {
+			src.Digest = d
+		}
+		if src.Version == "" {
+			src.Version = h.Bundle.Version
+		}
+		printSource(w, h.Bundle.Name, src)
+	}
+	for _, s := range h.Signers {
+		fmt.Fprintf(w, "Bundle signed by %s\n", s)
+	}
+	return h, src, nil
+}
+
+// printSource reports where the bundle name was resolved from
+func printSource(w io.Writer, name string, src *claim.Source) {
+	switch {
+	case src.Repository == "":
+		fmt.Fprintf(w, "Resolved %s %s from %s\n", name, src.Version, src.URL)
+	case src.Constraint != "":
+		fmt.Fprintf(w, "Resolved %s@%s to %s from repository %s\n", name, src.Constraint, src.Version, src.Repository)
+	default:
+		fmt.Fprintf(w, "Resolved %s %s from repository %s\n", name, src.Version, src.Repository)
+	}
+	if src.IndexDigest != "" {
+		fmt.Fprintf(w, "  index:  %s\n", src.IndexDigest)
+	}
+	if src.Repository != "" {
+		fmt.Fprintf(w, "  url:    %s\n", src.URL)
+	}
+	if src.Digest != "" {
+		fmt.Fprintf(w, "  digest: %s\n", src.Digest)
+	}
+	if src.Deprecated {
+		fmt.Fprintf(w, "WARNING: %s %s is deprecated in repository %s\n", name, src.Version, src.Repository)
+	}
+}
+
+// loadSource loads the bundle at source, authenticating with the credentials of r when
+// the source was resolved from a repository, or with the saved registry credentials for
+// other registry references. The manifest digest is returned for bundles
+// loaded from registries.
+func loadSource(r *repo.Repository, source string, opts loader.Options) (*loader.Handle, string, error) {
+	dh := home.Home(homePath())
+	opts.Cache = loader.HomeCache(dh)
+	l, err := loader.NewWithOptions(source, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	keyring := dh.PublicKeyring()
+	h := &loader.Handle{}
+	switch l := l.(type) {
+	case *loader.SignedLoader:
+		l.Keyring = keyring
+	case *loader.StdinLoader:
+		l.Keyring = keyring
+	case *loader.URLLoader:
+		l.Keyring = keyring
+		if r != nil {
+			if l.Client, err = r.Client(); err != nil {
+				return nil, "", err
+			}
+		}
+	case *loader.OCILoader:
+		if r != nil {
+			l.Client, err = r.RegistryClient()
+		} else {
+			l.Client, err = registryClient(dh)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		data, d, err := l.Pull(source)
+		if err != nil {
+			return nil, "", err
+		}
+		ref, err := registry.ParseReference(strings.TrimPrefix(source, loader.OCIPrefix))
+		if err != nil {
+			return nil, "", err
+		}
+		if err := verifyTrust(dh, ref, d); err != nil {
+			return nil, "", err
+		}
+		if h.Bundle, err = l.LoadData(data); err != nil {
+			return nil, "", err
+		}
+		h.Document = data
+		return h, d, nil
+	case *loader.DirLoader:
+		l.Keyring = keyring
+		if h, err = l.LoadHandle(source); err != nil {
+			return nil, "", err
+		}
+	}
+	if h.Bundle == nil {
+		if vl, ok := l.(loader.VerifyingLoader); ok {
+			h.Bundle, h.Signers, err = vl.LoadSigned(source)
+		} else {
+			h.Bundle, err = l.Load(source)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return h, "", nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newKeyCmd(w io.Writer) *cobra.Command {
+	const usage = `Manage signing keys.
+
+Duffle signs bundles with the OpenPGP keys of the secret keyring in duffle home
+(secret.ring), and verifies signed bundles against the keys of the public keyring
+(public.ring). These commands manage both keyrings, so that no external tooling such as
+gpg is needed. Keys are identified by their fingerprint, their long or short key ID, one of
+their identities, or its email address. An ID selecting the key to sign with, to trust or
+to remove must name exactly one key; listing and exporting also match parts of identities.
+
+Secret keys may be protected by a passphrase, which is needed to sign with them. It is
+read from the DUFFLE_KEY_PASSPHRASE environment variable when it is set, or else asked
+of the gpg-agent whose socket is given by DUFFLE_AGENT_SOCKET, such as the one printed
+by 'gpgconf --list-dirs agent-socket', which prompts for it with its pinentry program and
+caches it. Otherwise it is prompted for on the terminal. Keys generated by duffle are not
+protected by a passphrase: generate them with gpg to protect them, and import them.
+
+Signatures by keys that have expired, or that were revoked by their owner or locally with
+'duffle key revoke', are rejected.
+
+Keys may also live on a hardware token, such as a smart card or a YubiKey's PIV applet,
+so that they never exist on disk. Import the key's OpenPGP public key into the public
+keyring, then pass a PKCS#11 URI naming the key on the token wherever a signer is
+selected with --signer, for instance:
+
+    --signer 'pkcs11:id=%02;token=YubiKey?module-path=/usr/lib/libykcs11.so'
+
+Signing runs OpenSC's pkcs11-tool, which must be installed. The token's PIN is read from
+the pin-value attribute of the URI or the DUFFLE_PKCS11_PIN environment variable, and
+prompted for otherwise.
+
+Keys of GnuPG can be signed with as they are, through a running gpg-agent, rather than
+exported into the secret keyring; this includes keys on smart cards that gpg manages.
+Import the key's public key, then select it with the gpg-agent: prefix:
+
+    gpg --export 0x52904D8120B5649A | duffle key import -
+    duffle build --sign --signer gpg-agent:0x52904D8120B5649A
+
+gpg-agent signs with the key's signing subkey when it holds one, and prompts for its
+passphrase with its pinentry program. Its socket is found with gpgconf, unless
+DUFFLE_AGENT_SOCKET names it.
+`
+
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "manage signing keys",
+		Long:  usage,
+	}
+
+	cmd.AddCommand(newKeyExportCmd(w))
+	cmd.AddCommand(newKeyFetchCmd(w))
+	cmd.AddCommand(newKeyGenerateCmd(w))
+	cmd.AddCommand(newKeyImportCmd(w))
+	cmd.AddCommand(newKeyListCmd(w))
+	cmd.AddCommand(newKeyRemoveCmd(w))
+	cmd.AddCommand(newKeyRevokeCmd(w))
+	cmd.AddCommand(newKeyTrustCmd(w))
+
+	return cmd
+}
+
+// loadKeyRing reads the keyring at path, which is empty when the file does not exist
+func loadKeyRing(path string) (*signature.KeyRing, error) {
+	kr, err := signature.LoadKeyRing(path)
+	if os.IsNotExist(err) {
+		return signature.NewKeyRing(), nil
+	}
+	return kr, err
+}
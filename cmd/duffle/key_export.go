@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+)
+
+func newKeyExportCmd(w io.Writer) *cobra.Command {
+	const usage = `Exports keys, ASCII-armored.
+
+The public keys matching KEY, or every public key when KEY is omitted, are written to
+standard output or to --file, for others to import into their public keyring. With
+--secret, the keys of the secret keyring are exported with their private keys, to move
+them to another machine; keep the output safe.
+`
+
+	var (
+		secret bool
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export [KEY]",
+		Short: "export keys",
+		Long:  usage,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			id := ""
+			if len(args) == 1 {
+				id = args[0]
+			}
+			path := h.PublicKeyring()
+			if secret {
+				path = h.SecretKeyring()
+			}
+			kr, err := loadKeyRing(path)
+			if err != nil {
+				return err
+			}
+			data, err := kr.Export(id, secret)
+			if err != nil {
+				return err
+			}
+			if output != "" {
+				mode := os.FileMode(0644)
+				if secret {
+					mode = 0600
+				}
+				return ioutil.WriteFile(output, data, mode)
+			}
+			_, err = w.Write(data)
+			return err
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&secret, "secret", false, "export private keys from the secret keyring")
+	flags.StringVarP(&output, "file", "f", "", "file to write the keys to (default standard output)")
+	outputPathAlias(cmd, "file")
+
+	return cmd
+}
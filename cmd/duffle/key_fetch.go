@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newKeyFetchCmd(w io.Writer) *cobra.Command {
+	const usage = `Fetches public keys and imports them into the public keyring.
+
+QUERY is a mail address, a fingerprint or a key ID. Keys of mail addresses are looked up
+in the Web Key Directory (WKD) of the address's domain, and on the keyserver when the
+domain publishes none; keys named by fingerprint or key ID are looked up on the
+keyserver, hkps://keys.openpgp.org unless --keyserver names another. Only keys with a
+user ID of the mail address are kept.
+
+Since whoever controls the domain or the keyserver controls what is fetched, the
+fingerprints of the keys found are printed and confirmed before they are imported. Pass
+the fingerprint you expect, obtained from the key's owner through another channel, with
+--fingerprint to check it without prompting, or --yes to import whatever is found.
+
+Fetched keys are only added to the public keyring; trust them for a repository or a
+registry with 'duffle key trust'.
+`
+
+	var (
+		keyserver   string
+		from        string
+		fingerprint string
+		yes         bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fetch QUERY",
+		Short: "fetch public keys from a Web Key Directory or a keyserver",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := args[0]
+			fetched, err := fetchKeys(w, query, from, keyserver)
+			if err != nil {
+				return err
+			}
+
+			keys := fetched.Entities()
+			if fingerprint != "" {
+				want := strings.ToUpper(strings.Replace(strings.TrimPrefix(fingerprint, "0x"), " ", "", -1))
+				match := fetched.Find(want)
+				if len(match) != 1 || signature.Fingerprint(match[0]) != want {
+					return fmt.Errorf("none of the keys found for %s has the fingerprint %s", query, want)
+				}
+				keys = match
+			}
+			for _, e := range keys {
+				fmt.Fprintf(w, "Found key %s\n", signature.Info(e))
+			}
+			if fingerprint == "" && !yes {
+				ok, err := confirm(w, "Import these keys into the public keyring?", "pass --fingerprint or --yes")
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return errors.New("no key imported")
+				}
+			}
+
+			h := home.Home(homePath())
+			public, err := loadKeyRing(h.PublicKeyring())
+			if err != nil {
+				return err
+			}
+			for _, e := range keys {
+				if err := public.Add(e, false); err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "Imported public key %s\n", signature.Info(e))
+			}
+			return public.WriteFile(h.PublicKeyring(), 0644)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&keyserver, "keyserver", signature.DefaultKeyserver, "keyserver to look keys up on, as hkps://, hkp://, https:// or http:// URL")
+	flags.StringVar(&from, "from", "", "only look keys up in the Web Key Directory (wkd) or on the keyserver (keyserver)")
+	flags.StringVar(&fingerprint, "fingerprint", "", "expected fingerprint of the key; the key is imported without prompting when it matches")
+	flags.BoolVarP(&yes, "yes", "y", false, "import the keys found without prompting")
+
+	return cmd
+}
+
+// fetchKeys looks the keys matching query up in the Web Key Directory of mail addresses
+// and on keyserver, or only in the source named by from
+func fetchKeys(w io.Writer, query, from, keyserver string) (*signature.KeyRing, error) {
+	email := strings.Contains(query, "@")
+	switch from {
+	case "":
+	case "wkd":
+		if !email {
+			return nil, fmt.Errorf("%s is not a mail address; keys are looked up by mail address in Web Key Directories", query)
+		}
+	case "keyserver":
+	default:
+		return nil, fmt.Errorf("unknown key source %q: expected wkd or keyserver", from)
+	}
+	if email && from != "keyserver" {
+		kr, err := signature.FetchWKD(nil, query)
+		if err == nil || from == "wkd" {
+			return kr, err
+		}
+		fmt.Fprintf(w, "%v; trying %s\n", err, keyserver)
+	}
+	return signature.FetchKeyserver(nil, keyserver, query)
+}
+
+// confirm asks a yes or no question on the terminal, failing with hint, which tells how to
+// answer without one, when standard input is not a terminal
+func confirm(w io.Writer, question, hint string) (bool, error) {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("cannot confirm: standard input is not a terminal; %s", hint)
+	}
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
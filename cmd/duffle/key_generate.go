@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newKeyGenerateCmd(w io.Writer) *cobra.Command {
+	const usage = `Generates a signing key.
+
+The key, for the identity "NAME (COMMENT) <EMAIL>", is added to the secret keyring, and its
+public key to the public keyring, so that bundles signed with it are trusted locally.
+Share the public key with 'duffle key export' for others to verify your bundles.
+
+With --expires DAYS, the key expires after that many days, after which bundles it signed
+are no longer trusted.
+`
+
+	var (
+		email, comment string
+		expires        int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "generate NAME",
+		Short: "generate a signing key",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if expires < 0 {
+				return fmt.Errorf("--expires must not be negative")
+			}
+			e, err := generateKey(home.Home(homePath()), args[0], comment, email, time.Duration(expires)*24*time.Hour)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Generated key %s\n", signature.Info(e))
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&email, "email", "", "email address of the key's identity")
+	flags.StringVar(&comment, "comment", "", "comment of the key's identity")
+	flags.IntVar(&expires, "expires", 0, "number of days the key is valid for; it never expires when 0")
+
+	return cmd
+}
+
+// generateKey generates a signing key, adding it to the secret keyring of h and its public
+// key to the public keyring
+func generateKey(h home.Home, name, comment, email string, lifetime time.Duration) (*openpgp.Entity, error) {
+	secret, err := loadKeyRing(h.SecretKeyring())
+	if err != nil {
+		return nil, err
+	}
+	public, err := loadKeyRing(h.PublicKeyring())
+	if err != nil {
+		return nil, err
+	}
+	e, err := signature.Generate(name, comment, email, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	if err := secret.Add(e, true); err != nil {
+		return nil, err
+	}
+	if err := public.Add(e, false); err != nil {
+		return nil, err
+	}
+	if err := secret.WriteFile(h.SecretKeyring(), 0600); err != nil {
+		return nil, err
+	}
+	if err := public.WriteFile(h.PublicKeyring(), 0644); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
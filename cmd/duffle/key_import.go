@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newKeyImportCmd(w io.Writer) *cobra.Command {
+	const usage = `Imports keys from a file.
+
+FILE, or standard input when it is '-', holds OpenPGP keys, ASCII-armored or binary, such
+as the output of 'duffle key export' or 'gpg --export'. Public keys are added to the
+public keyring, so that bundles they signed are trusted. Private keys are added to the
+secret keyring, to sign with, and their public keys to the public keyring. Keys already
+in a keyring are replaced.
+
+Private keys protected by a passphrase, as exported by 'gpg --export-secret-keys', stay
+encrypted in the secret keyring; their passphrase is asked for when signing.
+`
+
+	return &cobra.Command{
+		Use:   "import FILE",
+		Short: "import keys into the keyrings",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			var in io.Reader = os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			imported, err := signature.ReadKeyRing(in)
+			if err != nil {
+				return fmt.Errorf("cannot read keys from %s: %v", args[0], err)
+			}
+			secret, err := loadKeyRing(h.SecretKeyring())
+			if err != nil {
+				return err
+			}
+			public, err := loadKeyRing(h.PublicKeyring())
+			if err != nil {
+				return err
+			}
+
+			for _, e := range imported.Entities() {
+				if err := public.Add(e, false); err != nil {
+					return err
+				}
+				kind := "public"
+				if e.PrivateKey != nil {
+					kind = "secret"
+				}
+				fmt.Fprintf(w, "Imported %s key %s\n", kind, signature.Info(e))
+			}
+			if private := imported.Private(); len(private.Entities()) > 0 {
+				secret.Merge(private)
+				if err := secret.WriteFile(h.SecretKeyring(), 0600); err != nil {
+					return err
+				}
+			}
+			return public.WriteFile(h.PublicKeyring(), 0644)
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newKeyListCmd(w io.Writer) *cobra.Command {
+	var secret bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list the keys of the public keyring, or of the secret keyring with --secret",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			path := h.PublicKeyring()
+			if secret {
+				path = h.SecretKeyring()
+			}
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			kr, err := loadKeyRing(path)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			keys := []keyListing{}
+			for _, e := range kr.Entities() {
+				keys = append(keys, keyListing{
+					KeyInfo: *signature.Info(e),
+					Created: e.PrimaryKey.CreationTime.UTC(),
+					Status:  keyStatus(kr, e, now),
+				})
+			}
+			return p.Print(keys, func(tw io.Writer) error {
+				fmt.Fprintln(tw, "FINGERPRINT\tIDENTITY\tCREATED\tSTATUS")
+				for _, k := range keys {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k.Fingerprint, k.Identity, k.Created.Format("2006-01-02"), k.Status)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&secret, "secret", false, "list the keys of the secret keyring")
+
+	return cmd
+}
+
+// keyListing is a key listed by 'duffle key list'
+type keyListing struct {
+	signature.KeyInfo
+	Created time.Time `json:"created"`
+	// Status tells whether the key is valid, revoked or expired
+	Status string `json:"status"`
+}
+
+// keyStatus describes whether the key e of kr is revoked or expired at time now, or when it
+// expires
+func keyStatus(kr *signature.KeyRing, e *openpgp.Entity, now time.Time) string {
+	if r, ok := kr.Revocation(e); ok {
+		return "revoked " + r.Date.UTC().Format("2006-01-02")
+	}
+	if len(e.Revocations) > 0 {
+		return "revoked by its owner"
+	}
+	expiry, ok := signature.Expiry(e)
+	switch {
+	case !ok:
+		return "valid"
+	case now.After(expiry):
+		return "expired " + expiry.UTC().Format("2006-01-02")
+	default:
+		return "expires " + expiry.UTC().Format("2006-01-02")
+	}
+}
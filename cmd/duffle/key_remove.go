@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newKeyRemoveCmd(w io.Writer) *cobra.Command {
+	const usage = `Removes keys from the keyrings.
+
+The key named by KEY is removed from the public keyring, so that bundles it signed are
+no longer trusted, and from the secret keyring, so that it no longer signs. With
+--public-only, the secret keyring is left alone.
+
+KEY must name the key exactly: by its fingerprint, its long or short key ID, a full
+identity such as "Jane Doe <jane@example.com>", or the email address of an identity.
+When KEY names several keys, nothing is removed unless --all is passed to remove them
+all. Secret keys cannot be recovered once removed, unless they were exported: removing
+one is confirmed on the terminal first, unless --yes is passed.
+`
+
+	var (
+		publicOnly bool
+		all        bool
+		yes        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "remove KEY",
+		Short: "remove keys from the keyrings",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			public, err := loadKeyRing(h.PublicKeyring())
+			if err != nil {
+				return err
+			}
+			secret := signature.NewKeyRing()
+			if !publicOnly {
+				if secret, err = loadKeyRing(h.SecretKeyring()); err != nil {
+					return err
+				}
+			}
+
+			matches := distinctKeys(append(public.FindExact(args[0]), secret.FindExact(args[0])...))
+			switch {
+			case len(matches) == 0:
+				return fmt.Errorf("key %q not found: pass its fingerprint, key ID, identity or email address", args[0])
+			case len(matches) > 1 && !all:
+				return fmt.Errorf("%q names %d keys: %s; pass the fingerprint of the one to remove, or --all to remove them all", args[0], len(matches), joinSigners(signature.Infos(matches)))
+			}
+			if !yes {
+				for _, e := range secret.FindExact(args[0]) {
+					ok, err := confirm(w, fmt.Sprintf("Remove the secret key %s? It cannot be recovered unless it was exported.", signature.Info(e)), "pass --yes to remove secret keys")
+					if err != nil {
+						return err
+					}
+					if !ok {
+						return errors.New("no key removed")
+					}
+				}
+			}
+
+			if removed := public.Remove(args[0]); len(removed) > 0 {
+				if err := public.WriteFile(h.PublicKeyring(), 0644); err != nil {
+					return err
+				}
+				for _, e := range removed {
+					fmt.Fprintf(w, "Removed public key %s\n", signature.Info(e))
+				}
+			}
+			if removed := secret.Remove(args[0]); len(removed) > 0 {
+				if err := secret.WriteFile(h.SecretKeyring(), 0600); err != nil {
+					return err
+				}
+				for _, e := range removed {
+					fmt.Fprintf(w, "Removed secret key %s\n", signature.Info(e))
+				}
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&publicOnly, "public-only", false, "only remove the key from the public keyring")
+	flags.BoolVar(&all, "all", false, "remove every key KEY names when it names several")
+	flags.BoolVarP(&yes, "yes", "y", false, "remove secret keys without prompting")
+
+	return cmd
+}
+
+// distinctKeys returns keys without the duplicates of a key found in both keyrings
+func distinctKeys(keys []*openpgp.Entity) []*openpgp.Entity {
+	var distinct []*openpgp.Entity
+	seen := map[string]bool{}
+	for _, e := range keys {
+		if fp := signature.Fingerprint(e); !seen[fp] {
+			seen[fp] = true
+			distinct = append(distinct, e)
+		}
+	}
+	return distinct
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newKeyRevokeCmd(w io.Writer) *cobra.Command {
+	const usage = `Revokes keys of the public keyring.
+
+Every key matching KEY is added to the revocation list in duffle home (revoked.json).
+Revoked keys stay in the keyrings, but bundles, provenance files and repository indexes
+they signed are rejected, and they can no longer sign. This is meant for keys that were
+compromised or retired, including keys of others whose owners have not published a
+revocation certificate. Revocation certificates imported with 'duffle key import' are
+honored as well.
+
+With --undo, KEY is removed from the revocation list instead. 'duffle key list' shows which
+keys are revoked or expired.
+`
+
+	var (
+		reason string
+		undo   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "revoke KEY",
+		Short: "revoke keys so that their signatures are no longer trusted",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			path := signature.RevocationListPath(h.PublicKeyring())
+			list, err := signature.LoadRevocations(path)
+			if err != nil {
+				return err
+			}
+			if undo {
+				var kept []signature.Revocation
+				for _, r := range list {
+					if signature.Allows([]string{args[0]}, &signature.KeyInfo{Fingerprint: r.Fingerprint}) || strings.Contains(r.Identity, args[0]) {
+						fmt.Fprintf(w, "Key %s (%s) is no longer revoked\n", r.Identity, r.Fingerprint)
+						continue
+					}
+					kept = append(kept, r)
+				}
+				if len(kept) == len(list) {
+					return fmt.Errorf("key %q is not revoked", args[0])
+				}
+				return signature.WriteRevocations(path, kept)
+			}
+
+			public, err := loadKeyRing(h.PublicKeyring())
+			if err != nil {
+				return err
+			}
+			keys := public.Find(args[0])
+			if len(keys) == 0 {
+				return fmt.Errorf("key %q not found", args[0])
+			}
+			revoked := 0
+			for _, e := range keys {
+				if _, ok := public.Revocation(e); ok {
+					fmt.Fprintf(w, "Key %s is already revoked\n", signature.Info(e))
+					continue
+				}
+				list = append(list, signature.Revocation{
+					Fingerprint: signature.Fingerprint(e),
+					Identity:    signature.Identity(e),
+					Reason:      reason,
+					Date:        time.Now().UTC(),
+				})
+				fmt.Fprintf(w, "Revoked key %s\n", signature.Info(e))
+				revoked++
+			}
+			if revoked == 0 {
+				return nil
+			}
+			return signature.WriteRevocations(path, list)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&reason, "reason", "", "why the key is revoked, reported when its signatures are rejected")
+	flags.BoolVar(&undo, "undo", false, "remove the key from the revocation list")
+
+	return cmd
+}
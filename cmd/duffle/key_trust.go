@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/registry"
+	"github.com/deis/duffle/pkg/repo"
+	"github.com/deis/duffle/pkg/signature"
+	"github.com/deis/duffle/pkg/sigstore"
+)
+
+func newKeyTrustCmd(w io.Writer) *cobra.Command {
+	const usage = `Restricts which keys may sign the bundles of a repository or registry.
+
+By default, bundles installed from repositories are accepted when any key of the public
+keyring signed them. The trust policy in duffle home (trust-policy.json) tightens this per
+source: once a key is trusted for a repository (--repo NAME) or for a registry or web
+server host (--registry HOST), bundles from it are only installed when one of the keys
+trusted for it signed them. Repository entries take precedence over host entries.
+
+KEY is looked up in the public keyring and recorded by fingerprint. It must name one key
+exactly: by its fingerprint, its long or short key ID, a full identity, or the email
+address of an identity. With --remove, KEY
+is removed from the entry instead, and the entry is dropped once it is empty; KEY may
+then also be a key no longer in the public keyring, given by fingerprint or key ID.
+Without KEY, the policy is listed.
+
+Bundles can carry signatures by several keys (see 'duffle bundle sign'). With --threshold N,
+bundles from the repository or registry are only installed once N distinct keys trusted for
+it signed them, for instance both the build system and a release manager:
+
+    $ duffle key trust ci@example.com --repo stable
+    $ duffle key trust releases@example.com --repo stable --threshold 2
+
+--threshold may be given without KEY to change the threshold of an existing entry.
+
+Bundles from registries are stored unsigned, so a policy entry for a registry refuses
+the bundles pulled from it unless --insecure is passed to 'duffle install'.
+
+Bundles signed without keys, with sigstore (see --keyless on 'duffle build', 'duffle pull'
+and 'duffle import'), are verified against the identities of the policy instead of the
+public keyring. With --identity (or --identity-regexp) and --issuer, and no KEY, the
+identity is trusted, or no longer trusted with --remove:
+
+    $ duffle key trust --issuer https://token.actions.githubusercontent.com \
+        --identity-regexp '^https://github.com/example/app/'
+`
+
+	var (
+		repoName  string
+		host      string
+		remove    bool
+		threshold int
+		id        sigstore.Identity
+	)
+
+	cmd := &cobra.Command{
+		Use:   "trust [KEY]",
+		Short: "restrict the keys trusted for a repository or registry, or trust sigstore identities",
+		Long:  usage,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			p, err := signature.LoadPolicy(h.TrustPolicy())
+			if err != nil {
+				return err
+			}
+			if id.Subject != "" || id.SubjectRegexp != "" || id.Issuer != "" {
+				if len(args) > 0 || repoName != "" || host != "" {
+					return errors.New("identities cannot be combined with KEY, --repo or --registry")
+				}
+				return trustIdentity(w, h, p, id, remove)
+			}
+			setThreshold := cmd.Flags().Changed("threshold")
+			if len(args) == 0 && !setThreshold {
+				printPolicy(w, p)
+				return nil
+			}
+			if setThreshold && threshold < 1 {
+				return errors.New("--threshold must be at least 1")
+			}
+			if (repoName == "") == (host == "") {
+				return errors.New("exactly one of --repo and --registry is required")
+			}
+			entries, source := &p.Repositories, "repository "+repoName
+			name := repoName
+			if host != "" {
+				name = strings.ToLower(registry.NormalizeRegistry(host))
+				entries, source = &p.Registries, name
+			}
+
+			rule := (*entries)[name]
+			var k *openpgp.Entity
+			if len(args) > 0 {
+				id := args[0]
+				public, err := loadKeyRing(h.PublicKeyring())
+				if err != nil {
+					return err
+				}
+				switch matches := public.FindExact(id); {
+				case len(matches) == 1:
+					k = matches[0]
+					id = signature.Fingerprint(k)
+				case len(matches) > 1:
+					return fmt.Errorf("%q names %d keys: %s; pass the fingerprint of the one to trust", id, len(matches), joinSigners(signature.Infos(matches)))
+				case !remove:
+					return fmt.Errorf("key %q not found: pass its fingerprint, key ID, identity or email address", id)
+				}
+				if rule.Keys, err = updateTrustedKeys(rule.Keys, id, remove); err != nil {
+					return fmt.Errorf("key %s %v for %s", args[0], err, source)
+				}
+			} else if len(rule.Keys) == 0 {
+				return fmt.Errorf("no key is trusted for %s", source)
+			}
+			if setThreshold {
+				rule.Threshold = threshold
+			}
+			if len(rule.Keys) == 0 {
+				delete(*entries, name)
+			} else {
+				if err := rule.Validate(); err != nil {
+					return fmt.Errorf("%s: %v", source, err)
+				}
+				if *entries == nil {
+					*entries = map[string]signature.Rule{}
+				}
+				(*entries)[name] = rule
+			}
+			if err := p.WriteFile(h.TrustPolicy()); err != nil {
+				return err
+			}
+			switch {
+			case len(args) == 0:
+			case remove:
+				fmt.Fprintf(w, "Key %s is no longer trusted for %s\n", args[0], source)
+			default:
+				fmt.Fprintf(w, "Key %s is trusted for %s\n", signature.Info(k), source)
+			}
+			if setThreshold && len(rule.Keys) > 0 {
+				fmt.Fprintf(w, "Bundles from %s must be signed by %d of its %d trusted keys\n", source, rule.Required(), len(rule.Keys))
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&repoName, "repo", "", "name of the repository whose bundles KEY may sign")
+	flags.StringVar(&host, "registry", "", "registry or web server host whose bundles KEY may sign")
+	flags.BoolVar(&remove, "remove", false, "stop trusting KEY for the repository or registry, or the identity")
+	flags.IntVar(&threshold, "threshold", 1, "number of distinct trusted keys that must sign bundles from the repository or registry")
+	flags.StringVar(&id.Subject, "identity", "", "identity trusted to sign bundles with sigstore, such as an email address")
+	flags.StringVar(&id.SubjectRegexp, "identity-regexp", "", "regular expression matching the identities trusted to sign bundles with sigstore")
+	flags.StringVar(&id.Issuer, "issuer", "", "OIDC issuer of the identity")
+
+	return cmd
+}
+
+// updateTrustedKeys adds the key id to keys, or removes it. Removed keys may be given by
+// key ID rather than by fingerprint.
+func updateTrustedKeys(keys []string, id string, remove bool) ([]string, error) {
+	if !remove {
+		if signature.Allows(keys, &signature.KeyInfo{Fingerprint: id}) {
+			return nil, errors.New("is already trusted")
+		}
+		return append(keys, id), nil
+	}
+	var kept []string
+	for _, key := range keys {
+		if !signature.Allows([]string{id}, &signature.KeyInfo{Fingerprint: strings.ToUpper(key)}) {
+			kept = append(kept, key)
+		}
+	}
+	if len(kept) == len(keys) {
+		return nil, errors.New("is not trusted")
+	}
+	return kept, nil
+}
+
+// trustIdentity adds id to the identities of the policy trusted for keyless signatures, or
+// removes it
+func trustIdentity(w io.Writer, h home.Home, p *signature.Policy, id sigstore.Identity, remove bool) error {
+	if err := id.Validate(); err != nil {
+		return err
+	}
+	i := -1
+	for j, trusted := range p.Identities {
+		if trusted == id {
+			i = j
+		}
+	}
+	switch {
+	case remove && i == -1:
+		return fmt.Errorf("identity %s is not trusted", id)
+	case remove:
+		p.Identities = append(p.Identities[:i], p.Identities[i+1:]...)
+	case i != -1:
+		return fmt.Errorf("identity %s is already trusted", id)
+	default:
+		p.Identities = append(p.Identities, id)
+	}
+	if err := p.WriteFile(h.TrustPolicy()); err != nil {
+		return err
+	}
+	if remove {
+		fmt.Fprintf(w, "Identity %s is no longer trusted\n", id)
+	} else {
+		fmt.Fprintf(w, "Identity %s is trusted to sign bundles with sigstore\n", id)
+	}
+	return nil
+}
+
+// printPolicy lists the keys trusted for each source of the policy, and the identities
+// trusted for keyless signatures
+func printPolicy(w io.Writer, p *signature.Policy) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tKEYS")
+	for _, name := range sortedPolicyNames(p.Repositories) {
+		fmt.Fprintf(tw, "repository %s\t%s\n", name, p.Repositories[name])
+	}
+	for _, name := range sortedPolicyNames(p.Registries) {
+		fmt.Fprintf(tw, "%s\t%s\n", name, p.Registries[name])
+	}
+	for _, id := range p.Identities {
+		fmt.Fprintf(tw, "keyless\t%s\n", id)
+	}
+	tw.Flush()
+}
+
+func sortedPolicyNames(m map[string]signature.Rule) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkTrustPolicy verifies that the bundle loaded from source, through the repository r
+// if there is one, was signed by as many of the keys the trust policy allows for it as the
+// policy requires
+func checkTrustPolicy(h home.Home, source string, r *repo.Repository, signers []*signature.KeyInfo) error {
+	p, err := signature.LoadPolicy(h.TrustPolicy())
+	if err != nil {
+		return err
+	}
+	name, host := "", sourceHost(source)
+	if r != nil {
+		name, host = r.Name, sourceHost(r.URL)
+	}
+	rule := p.RuleFor(name, host)
+	if rule == nil || rule.Satisfied(signers) {
+		return nil
+	}
+	keys := strings.Join(rule.Keys, ", ")
+	switch {
+	case len(signers) == 0 && rule.Required() == 1:
+		return fmt.Errorf("%s is not signed, but the trust policy requires a signature by one of %s", source, keys)
+	case len(signers) == 0:
+		return fmt.Errorf("%s is not signed, but the trust policy requires signatures by %s", source, rule)
+	case rule.Required() == 1:
+		return fmt.Errorf("%s is signed by %s, which the trust policy does not allow (expected one of %s)", source, joinSigners(signers), keys)
+	}
+	approvals := rule.Approvals(signers)
+	return fmt.Errorf("%s is signed by %d of the %d trusted keys the trust policy requires (signed by %s; expected %s)", source, len(approvals), rule.Required(), joinSigners(signers), rule)
+}
+
+// joinSigners lists signers for messages
+func joinSigners(signers []*signature.KeyInfo) string {
+	s := make([]string, len(signers))
+	for i, signer := range signers {
+		s[i] = signer.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// sourceHost returns the host serving the bundle at source, or an empty string for local
+// sources
+func sourceHost(source string) string {
+	switch {
+	case loader.IsURL(source):
+		if u, err := url.Parse(source); err == nil {
+			return strings.ToLower(registry.NormalizeRegistry(u.Host))
+		}
+	case repo.IsOCIURL(source):
+		return sourceHost("https://" + strings.TrimPrefix(source, repo.OCIPrefix))
+	case loader.IsRegistryReference(source):
+		if ref, err := registry.ParseReference(strings.TrimPrefix(source, loader.OCIPrefix)); err == nil {
+			return strings.ToLower(registry.NormalizeRegistry(ref.Registry))
+		}
+	}
+	return ""
+}
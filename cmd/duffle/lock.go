@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/lock"
+)
+
+// defaultLockTimeout is how long commands wait for the lock of duffle home by default
+const defaultLockTimeout = time.Minute
+
+// lockTimeout is how long commands wait for the lock of duffle home, given with
+// --lock-timeout
+var lockTimeout time.Duration
+
+// homeLock is the lock of duffle home held by the running command, if any
+var homeLock *lock.Lock
+
+// readOnlyCommands only read duffle home, so they run while another duffle process holds
+// its lock. Files of duffle home are written with atomicfile.WriteFile, or into the cache
+// under unique names, so that they never see a partly written one.
+var readOnlyCommands = map[string]bool{
+	"duffle":                   true,
+	"duffle help":              true,
+	"duffle bundle convert":    true,
+	"duffle bundle patch":      true,
+	"duffle bundle show":       true,
+	"duffle bundle validate":   true,
+	"duffle cache list":        true,
+	"duffle config get":        true,
+	"duffle context list":      true,
+	"duffle create":            true,
+	"duffle doctor":            true,
+	"duffle key export":        true,
+	"duffle key list":          true,
+	"duffle plugin list":       true,
+	"duffle repo generate":     true,
+	"duffle repo index verify": true,
+	"duffle repo list":         true,
+	"duffle repo serve":        true,
+	"duffle search":            true,
+	"duffle self-update":       true,
+	"duffle verify":            true,
+}
+
+// lockHome takes the lock of duffle home for cmd, unless cmd only reads it, waiting up to
+// --lock-timeout for another duffle process holding it. Homes that do not exist yet have
+// nothing to protect.
+func lockHome(cmd *cobra.Command) error {
+	if readOnlyCommands[cmd.CommandPath()] {
+		return nil
+	}
+	return takeHomeLock(cmd)
+}
+
+// takeHomeLock takes the lock of duffle home for cmd, whatever the command, such as when
+// duffle home must be migrated before it runs. It does nothing when the lock is held.
+func takeHomeLock(cmd *cobra.Command) error {
+	if homeLock != nil {
+		return nil
+	}
+	h := home.Home(homePath())
+	if _, err := os.Stat(h.String()); os.IsNotExist(err) {
+		return nil
+	}
+	l := lock.New(h.Lock())
+	err := l.Lock(cmd.CommandPath(), lockTimeout, func(holder string) {
+		fmt.Fprintf(os.Stderr, "Waiting for another duffle process (%s) to finish...\n", describeHolder(holder))
+	})
+	if held, ok := err.(*lock.HeldError); ok {
+		return fmt.Errorf("duffle is already running (%s) and holds the lock of duffle home %s: try again once it finishes, or wait longer with --lock-timeout", describeHolder(held.Holder), h)
+	}
+	if err != nil {
+		return err
+	}
+	homeLock = l
+	return nil
+}
+
+// unlockHome releases the lock of duffle home, if the running command holds it
+func unlockHome() {
+	if homeLock != nil {
+		homeLock.Unlock()
+		homeLock = nil
+	}
+}
+
+// describeHolder describes the process holding the lock, as it recorded itself
+func describeHolder(holder string) string {
+	if holder == "" {
+		return "unknown process"
+	}
+	return holder
+}
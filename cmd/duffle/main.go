@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
+
+	"github.com/deis/duffle/pkg/duffle/home"
 )
 
 func unimplemented(msg string) {
@@ -24,5 +27,26 @@ func main() {
 			}
 		}
 	}()
-	must(newRootCmd(os.Stdout).Execute())
+	cmd := newRootCmd(os.Stdout)
+	if p, args, ok := findPlugin(cmd, os.Args[1:]); ok {
+		must(runPlugin(p, args))
+		return
+	}
+	start := time.Now()
+	c, err := cmd.ExecuteC()
+	unlockHome()
+	reportUsage(c, time.Since(start), err)
+	must(err)
+}
+
+// homeFlag is the duffle home given with --home
+var homeFlag string
+
+// homePath returns the duffle home: the one given with --home, or the default home, which
+// $DUFFLE_HOME selects
+func homePath() string {
+	if homeFlag != "" {
+		return homeFlag
+	}
+	return home.DefaultHome()
 }
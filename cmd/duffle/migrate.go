@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/repo"
+)
+
+// migration upgrades duffle home from the layout version before it to the next one,
+// reporting what it cannot migrate to w
+type migration struct {
+	description string
+	migrate     func(w io.Writer, h home.Home) error
+}
+
+// migrations are the changes to the layout of duffle home, in order: the layout version of
+// a home is the number of migrations applied to it. Append to the list, never reorder it.
+var migrations = []migration{
+	{"index the bundles stored by name in the local store", indexLegacyBundles},
+}
+
+func newMigrateCmd(w io.Writer) *cobra.Command {
+	const usage = `Migrates duffle home to the layout of this version of duffle.
+
+The layout of duffle home is versioned, in the version file of duffle home, and every
+command migrates it when it was written by an earlier version of duffle. This command runs
+the migrations explicitly, or lists them with --dry-run.
+
+Homes written by a later version of duffle are not touched: commands fail on them until
+duffle is upgraded.
+`
+
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "migrate duffle home to the layout of this version of duffle",
+		Long:  usage,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			n, err := migrateHome(w, h, dryRun)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				fmt.Fprintf(w, "duffle home is up to date (layout version %d)\n", len(migrations))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the pending migrations without running them")
+
+	return cmd
+}
+
+// migrateHome runs the migrations h is missing, reporting them to w, and returns how many
+// there were. Nothing is done to homes that do not exist yet.
+func migrateHome(w io.Writer, h home.Home, dryRun bool) (int, error) {
+	if _, err := os.Stat(h.String()); os.IsNotExist(err) {
+		return 0, nil
+	}
+	v, err := h.Version()
+	if err != nil {
+		return 0, err
+	}
+	if v > len(migrations) {
+		return 0, fmt.Errorf("duffle home %s has layout version %d, written by a later version of duffle, which supports up to version %d: upgrade duffle", h, v, len(migrations))
+	}
+	for i, m := range migrations[v:] {
+		to := v + i + 1
+		if dryRun {
+			fmt.Fprintf(w, "Would migrate duffle home to layout version %d: %s\n", to, m.description)
+			continue
+		}
+		if err := m.migrate(w, h); err != nil {
+			return i, fmt.Errorf("cannot migrate duffle home to layout version %d (%s): %v", to, m.description, err)
+		}
+		// the version is recorded after each migration, so that a failure resumes from it
+		if err := h.SetVersion(to); err != nil {
+			return i, err
+		}
+		fmt.Fprintf(w, "Migrated duffle home to layout version %d: %s\n", to, m.description)
+	}
+	return len(migrations) - v, nil
+}
+
+// migrationPending reports whether h misses migrations. Homes whose version cannot be read
+// are left for migrateHome to report.
+func migrationPending(h home.Home) bool {
+	if _, err := os.Stat(h.String()); err != nil {
+		return false
+	}
+	v, err := h.Version()
+	return err == nil && v < len(migrations)
+}
+
+// indexLegacyBundles records the bundles stored as NAME-VERSION.json by earlier versions
+// of duffle in the index of the local store. The documents stay where they are, so that
+// their provenance files, which name them, still verify. Documents that cannot be loaded
+// are reported to w and left out of the index, rather than failing every command.
+func indexLegacyBundles(w io.Writer, h home.Home) error {
+	s := LocalStore{home: h}
+	i, err := s.index()
+	if err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(h.Bundles(), "*.json"))
+	if err != nil {
+		return err
+	}
+	added := false
+	for _, path := range files {
+		if path == h.BundleIndex() {
+			continue
+		}
+		b, err := loader.Load(path)
+		if err != nil {
+			fmt.Fprintf(w, "WARNING: not indexing %s, which cannot be loaded: %v\n", path, err)
+			continue
+		}
+		if _, err := i.Get(b.Name, b.Version); err == nil {
+			continue
+		}
+		d, err := digest.OfFile(path)
+		if err != nil {
+			return err
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		i.Entries[b.Name] = append(i.Entries[b.Name], &repo.BundleVersion{
+			Name:        b.Name,
+			Version:     b.Version,
+			Description: b.Description,
+			URLs:        []string{filepath.Base(path)},
+			Digest:      d,
+			Created:     fi.ModTime().UTC().Truncate(time.Second),
+		})
+		added = true
+	}
+	if !added {
+		return nil
+	}
+	i.SortEntries()
+	i.Generated = time.Now().UTC()
+	return i.WriteFile(h.BundleIndex())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/claim"
+	"github.com/deis/duffle/pkg/driver"
+)
+
+// newOperation prepares the driver operation that runs action against the installation described by c.
+//
+// Only the parameters in params that apply to the action are injected.
+func newOperation(c *claim.Claim, action string, params map[string]interface{}, d driver.Driver, invocationImage string, w io.Writer) (*driver.Operation, error) {
+	var err error
+	b := c.Bundle
+	selector := bundle.ImageSelector{
+		Types:     driver.SupportedTypes(d),
+		Preferred: invocationImage,
+	}
+	if p, ok := d.(driver.Platformer); ok {
+		if selector.OS, selector.Arch, err = p.Platform(); err != nil {
+			return nil, err
+		}
+	}
+	img, err := b.SelectInvocationImage(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &driver.Operation{
+		Installation: c.Name,
+		Action:       action,
+		Image:        img.Image,
+		Digest:       img.Digest,
+		ImageType:    img.ImageType,
+		Environment:  map[string]string{},
+		Files:        map[string]string{},
+		Out:          w,
+	}
+	for name, value := range params {
+		if def, ok := b.Parameters[name]; !ok || !def.AppliesTo(action) {
+			continue
+		}
+		op.Environment[bundle.EnvironmentVariable(name)] = fmt.Sprintf("%v", value)
+	}
+	op.Environment["CNAB_INSTALLATION_NAME"] = c.Name
+	op.Environment["CNAB_ACTION"] = action
+	op.Environment["CNAB_BUNDLE_NAME"] = b.Name
+	op.Environment["CNAB_BUNDLE_VERSION"] = b.Version
+	return op, nil
+}
+
+// lookupDriver resolves the named driver and applies the digest check setting to it
+func lookupDriver(name string, skipDigestCheck bool) (driver.Driver, error) {
+	d, err := driver.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := d.(driver.Configurable); ok {
+		c.SetConfig(map[string]string{
+			"SKIP_DIGEST_CHECK": fmt.Sprintf("%t", skipDigestCheck),
+		})
+	}
+	return d, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/printer"
+)
+
+// outputFormat is the output format of results, given with --output or configured
+var outputFormat = printer.Table
+
+// newPrinter returns a printer writing results to w in the output format of the command line
+func newPrinter(w io.Writer) (*printer.Printer, error) {
+	return printer.New(w, outputFormat)
+}
+
+// outputPathAnnotation names the flag of a command that took its output path from
+// --output before --output named the output format
+const outputPathAnnotation = "duffle/output-path"
+
+// outputPathAlias makes --output an alias of the path flag name of cmd when its value is
+// not an output format
+func outputPathAlias(cmd *cobra.Command, name string) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[outputPathAnnotation] = name
+}
+
+// applyOutputPathAlias moves a path given with --output to the path flag of cmd, warning
+// that this use of --output is deprecated
+func applyOutputPathAlias(cmd *cobra.Command) error {
+	name := cmd.Annotations[outputPathAnnotation]
+	f := cmd.Flags().Lookup("output")
+	if name == "" || f == nil || !f.Changed {
+		return nil
+	}
+	_, err := printer.ParseFormat(outputFormat)
+	if err == nil || cmd.Flags().Changed(name) {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Flag --output has been deprecated as the path to write to, use --%s instead\n", name)
+	if err := cmd.Flags().Set(name, outputFormat); err != nil {
+		return err
+	}
+	f.Changed = false
+	outputFormat = printer.Table
+	return nil
+}
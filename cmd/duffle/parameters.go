@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deis/duffle/pkg/bundle"
+)
+
+// resolveParameters computes the parameter values for running action.
+//
+// Only parameters that apply to the action are considered. Values given with --set take
+// precedence over current values (those recorded in the claim), which in turn take
+// precedence over the bundle's defaults.
+func resolveParameters(b *bundle.Bundle, action string, values []string, current map[string]interface{}) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	for name, value := range current {
+		if def, ok := b.Parameters[name]; ok && def.AppliesTo(action) {
+			params[name] = value
+		}
+	}
+
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed parameter value %q, expected NAME=VALUE", v)
+		}
+		def, ok := b.Parameters[parts[0]]
+		if !ok {
+			return nil, fmt.Errorf("bundle does not declare parameter %q", parts[0])
+		}
+		if !def.AppliesTo(action) {
+			return nil, fmt.Errorf("parameter %s does not apply to action %s", parts[0], action)
+		}
+		value, err := def.ConvertValue(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %v", parts[0], err)
+		}
+		params[parts[0]] = value
+	}
+
+	for name, def := range b.Parameters {
+		if !def.AppliesTo(action) {
+			continue
+		}
+		value, ok := params[name]
+		if !ok {
+			if def.DefaultValue == nil {
+				return nil, fmt.Errorf("parameter %s is required", name)
+			}
+			value = def.DefaultValue
+			params[name] = value
+		}
+		if err := def.ValidateValue(value); err != nil {
+			return nil, fmt.Errorf("parameter %s: %v", name, err)
+		}
+	}
+	return params, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/plugin"
+)
+
+func newPluginCmd(w io.Writer) *cobra.Command {
+	const usage = `Manage plugins.
+
+Plugins are executables named duffle-NAME that add the command NAME to duffle: running
+'duffle NAME ARGS...' runs 'duffle-NAME ARGS...'. They are found in the plugins directory
+of duffle home, where 'duffle plugin install' puts them, and on $PATH. Commands of duffle
+cannot be replaced by plugins.
+
+Plugins run with the environment of duffle, plus:
+
+    DUFFLE_HOME  the duffle home duffle runs with
+    DUFFLE_BIN   the path of the duffle executable
+`
+
+	cmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "manage plugins",
+		Long:  usage,
+	}
+
+	cmd.AddCommand(newPluginInstallCmd(w))
+	cmd.AddCommand(newPluginListCmd(w))
+	cmd.AddCommand(newPluginRemoveCmd(w))
+
+	return cmd
+}
+
+// isBuiltin reports whether name is a command of root, which plugins may not replace
+func isBuiltin(root *cobra.Command, name string) bool {
+	if name == "help" {
+		return true
+	}
+	for _, c := range root.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// findPlugin returns the plugin args run, and the arguments to run it with, when they
+// name a command that is not one of root. Only --home, which the plugin is looked up in,
+// may precede the command.
+func findPlugin(root *cobra.Command, args []string) (*plugin.Plugin, []string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--home" && i+1 < len(args):
+			homeFlag = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--home="):
+			homeFlag = strings.TrimPrefix(arg, "--home=")
+		case strings.HasPrefix(arg, "-"):
+			return nil, nil, false
+		default:
+			if isBuiltin(root, arg) || !plugin.ValidName(arg) {
+				return nil, nil, false
+			}
+			p, err := plugin.Lookup(home.Home(homePath()).Plugins(), arg)
+			if err != nil {
+				return nil, nil, false
+			}
+			return p, args[i+1:], true
+		}
+	}
+	return nil, nil, false
+}
+
+// runPlugin runs p with args, exiting with its exit code when it fails
+func runPlugin(p *plugin.Plugin, args []string) error {
+	env := []string{"DUFFLE_HOME=" + homePath()}
+	if bin, err := os.Executable(); err == nil {
+		env = append(env, "DUFFLE_BIN="+bin)
+	}
+	err := p.Command(args, env...).Run()
+	if exit, ok := err.(*exec.ExitError); ok {
+		// the plugin reported its own error
+		if code := exit.ExitCode(); code > 0 {
+			os.Exit(code)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("plugin %s: %v", p.Name, err)
+	}
+	return nil
+}
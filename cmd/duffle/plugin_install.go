@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/plugin"
+)
+
+func newPluginInstallCmd(w io.Writer) *cobra.Command {
+	const usage = `Installs a plugin into the plugins directory of duffle home.
+
+SOURCE is one of:
+
+  - an https URL or a local path of the plugin's executable, or of a .tar.gz or .tgz
+    archive holding the executable duffle-NAME at its root along with the files it needs.
+    Append '#sha256=<hex>' to a URL to check the checksum of what is downloaded; plain
+    http URLs must have one. On Windows, executables must be .exe, .bat or .cmd files.
+  - a git URL, such as git::https://github.com/org/duffle-foo.git?ref=v1.0.0, whose
+    repository holds the executable duffle-NAME at its root. ref may name a branch or tag.
+
+The plugin adds the command NAME, which is the base name of SOURCE without the duffle-
+prefix and archive or .git extension, unless given with --name. Plugins run with the
+privileges of the user: only install plugins from sources you trust.
+`
+
+	var (
+		name  string
+		force bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "install SOURCE",
+		Short: "install a plugin from a URL, a file or a git repository",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n := name
+			if n == "" {
+				n = plugin.NameOf(args[0])
+			}
+			if isBuiltin(cmd.Root(), n) {
+				return fmt.Errorf("%s is a duffle command; install the plugin under another name with --name", n)
+			}
+			h := home.Home(homePath())
+			p, err := plugin.Install(h.Plugins(), args[0], plugin.InstallOptions{Name: n, Force: force})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Installed plugin %s at %s\n", p.Name, p.Path)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&name, "name", "", "command the plugin adds, instead of the one derived from SOURCE")
+	flags.BoolVar(&force, "force", false, "replace an installed plugin of the same name")
+
+	return cmd
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/plugin"
+)
+
+func newPluginListCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list installed plugins and plugins on $PATH",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pr, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			found, err := plugin.Find(home.Home(homePath()).Plugins())
+			if err != nil {
+				return err
+			}
+			plugins := make([]pluginListing, 0, len(found))
+			for _, p := range found {
+				plugins = append(plugins, pluginListing{Plugin: p, Shadowed: isBuiltin(cmd.Root(), p.Name)})
+			}
+			return pr.Print(plugins, func(tw io.Writer) error {
+				fmt.Fprintln(tw, "NAME\tSOURCE\tPATH")
+				for _, p := range plugins {
+					source := "PATH"
+					if p.Installed {
+						source = "installed"
+					}
+					if p.Shadowed {
+						source += " (shadowed by a duffle command)"
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Name, source, p.Path)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// pluginListing is a plugin listed by 'duffle plugin list'
+type pluginListing struct {
+	*plugin.Plugin
+	// Shadowed is set for plugins named like a duffle command, which runs instead
+	Shadowed bool `json:"shadowed"`
+}
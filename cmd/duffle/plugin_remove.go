@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/plugin"
+)
+
+func newPluginRemoveCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove NAME",
+		Short: "remove an installed plugin",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := plugin.Remove(home.Home(homePath()).Plugins(), args[0])
+			if os.IsNotExist(err) {
+				return fmt.Errorf("plugin %s is not installed; plugins on $PATH are not managed by duffle", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Removed plugin %s\n", args[0])
+			return nil
+		},
+	}
+}
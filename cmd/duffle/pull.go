@@ -1,23 +1,96 @@
 package main
 
 import (
+	"fmt"
 	"io"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/progress"
+	"github.com/deis/duffle/pkg/registry"
+	"github.com/deis/duffle/pkg/repo"
 )
 
-// TODO
 func newPullCmd(w io.Writer) *cobra.Command {
-	const usage = `TODO`
+	const usage = `Pulls a bundle from an OCI registry into the local store.
+
+The bundle is identified by a registry reference such as example.com/org/bundle:1.0.0,
+or example.com/org/bundle@sha256:<hex> to pin the digest of its manifest.
+Bundles are read in the cnab-to-oci layout, an image index whose config manifest holds
+the bundle.json, as published by 'duffle push' and other CNAB tools. Bundles published
+as a single manifest by earlier versions of duffle are read as well.
+
+The bundle is stored under the digest of its document and recorded in the index of the
+local store, replacing any stored bundle with the same name and version. It can then be
+installed by NAME or NAME:VERSION without contacting the registry again. A provenance
+file signed with a key from the secret keyring is stored with it or, with --keyless, a
+sigstore bundle signed for the OIDC identity cosign finds (see 'duffle key trust').
+The registry it was pulled from is recorded as well: the trust policy rules for the
+registry still apply when the bundle is installed from the local store.
+
+Private registries are accessed with the credentials saved with 'duffle registry login'.
+When content trust is enabled for the registry (see 'duffle registry trust'), bundles
+pulled by tag must match the trust data signed for the tag.
+`
+
+	var (
+		signer   string
+		insecure bool
+		keyless  bool
+	)
 
 	cmd := &cobra.Command{
-		Use:   "pull",
-		Short: usage,
+		Use:   "pull REFERENCE",
+		Short: "pull a bundle from a registry",
 		Long:  usage,
-		Run: func(cmd *cobra.Command, args []string) {
-			unimplemented("duffle pull")
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			client, err := registryClient(h)
+			if err != nil {
+				return err
+			}
+			client.Progress = progress.New(os.Stderr)
+			l := &loader.OCILoader{Client: client, Cache: loader.HomeCache(h)}
+			data, d, err := l.Pull(args[0])
+			if err != nil {
+				return err
+			}
+			ref, err := registry.ParseReference(strings.TrimPrefix(args[0], loader.OCIPrefix))
+			if err != nil {
+				return err
+			}
+			if err := verifyTrust(h, ref, d); err != nil {
+				return err
+			}
+			b, err := l.LoadData(data)
+			if err != nil {
+				return err
+			}
+			store := LocalStore{
+				home:     h,
+				signer:   signer,
+				insecure: insecure,
+				keyless:  keyless,
+				origin:   &repo.Origin{URL: loader.OCIPrefix + ref.String()},
+			}
+			dest, err := store.Store(b)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Pulled bundle %s %s (%s) to %s\n", b.Name, b.Version, d, dest)
+			return nil
 		},
 	}
 
+	flags := cmd.Flags()
+	flags.StringVar(&signer, "signer", "", "ID of the key used to sign the bundle's provenance file")
+	flags.BoolVar(&insecure, "insecure", false, "store the bundle without a provenance file")
+	flags.BoolVar(&keyless, "keyless", false, "sign the bundle with sigstore instead of a key from the secret keyring")
+
 	return cmd
 }
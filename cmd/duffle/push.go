@@ -1,23 +1,127 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/progress"
+	"github.com/deis/duffle/pkg/registry"
 )
 
-// TODO
 func newPushCmd(w io.Writer) *cobra.Command {
-	const usage = `TODO`
+	const usage = `Publishes a bundle to an OCI registry.
+
+BUNDLE is a bundle file, or NAME[:VERSION] of a bundle in the local store. It is stored
+at REFERENCE, such as example.com/org/bundle:1.0.0, in the cnab-to-oci layout: an image
+index referencing a manifest whose config blob is the bundle.json, along with the bundle's
+images stored by digest in the same repository. The tag defaults to the bundle's version.
+The published bundle can be installed with 'duffle install NAME REFERENCE' and pulled with
+'duffle pull', and is readable by other CNAB tools that follow cnab-to-oci.
+
+With --with-invocation-images, each invocation image is copied from its registry into
+the repository of REFERENCE, and the published bundle refers to the copies by digest,
+so that the registry holds everything needed to install the bundle. Images are copied
+from registry to registry, without a Docker daemon. The transfer of each layer is shown
+on standard error: as a progress bar on a terminal, or otherwise as JSON events, one per
+line, so that CI logs stay readable. Large layers are uploaded in chunks: a chunk that
+fails is retried, and an upload interrupted for good resumes from the last chunk the
+registry received the next time the bundle is pushed.
+
+Credentials saved with 'duffle registry login' are used for every registry involved.
+When content trust is enabled for the registry (see 'duffle registry trust'), the pushed
+tag is signed once the bundle is published.
+`
+
+	var withImages bool
 
 	cmd := &cobra.Command{
-		Use:   "push",
-		Short: usage,
+		Use:   "push BUNDLE REFERENCE",
+		Short: "publish a bundle to a registry",
 		Long:  usage,
-		Run: func(cmd *cobra.Command, args []string) {
-			unimplemented("duffle push")
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			b, err := loadBundleRef(h, args[0])
+			if err != nil {
+				return err
+			}
+			ref, err := registry.ParseReference(strings.TrimPrefix(args[1], loader.OCIPrefix))
+			if err != nil {
+				return err
+			}
+			if ref.Digest != "" {
+				return fmt.Errorf("cannot push to %s: bundles are pushed to a tag", args[1])
+			}
+			if !hasTag(strings.TrimPrefix(args[1], loader.OCIPrefix)) {
+				ref = ref.WithTag(b.Version)
+			}
+
+			client, err := registryClient(h)
+			if err != nil {
+				return err
+			}
+			client.Progress = progress.New(os.Stderr)
+			if withImages {
+				for i, img := range b.InvocationImages {
+					src, err := registry.ParseReference(img.Image)
+					if err != nil {
+						return err
+					}
+					d, err := client.CopyImage(src, ref.WithTag(""))
+					if err != nil {
+						return fmt.Errorf("cannot copy invocation image %s: %v", img.Image, err)
+					}
+					b.InvocationImages[i].Image = fmt.Sprintf("%s/%s@%s", ref.Registry, ref.Repository, d)
+					b.InvocationImages[i].Digest = d
+					fmt.Fprintf(w, "Copied invocation image %s to %s\n", img.Image, b.InvocationImages[i].Image)
+				}
+			}
+
+			data, err := json.MarshalIndent(b, "", "    ")
+			if err != nil {
+				return err
+			}
+			d, err := client.PushBundle(ref, data)
+			if err != nil {
+				return err
+			}
+			if err := signBundle(h, client, ref, d); err != nil {
+				return fmt.Errorf("pushed %s@%s, but could not sign it: %v", ref, d, err)
+			}
+			fmt.Fprintf(w, "Pushed bundle %s %s to %s@%s\n", b.Name, b.Version, ref, d)
+			return nil
 		},
 	}
 
+	cmd.Flags().BoolVar(&withImages, "with-invocation-images", false, "copy the invocation images into the registry alongside the bundle")
+
 	return cmd
 }
+
+// hasTag reports whether the registry reference ref names a tag or digest explicitly
+func hasTag(ref string) bool {
+	_, remainder := docker.SplitDomain(ref)
+	return strings.ContainsAny(remainder, ":@")
+}
+
+// signBundle records the bundle pushed to ref with digest d in the trust data of the
+// registry, when content trust is enabled for it
+func signBundle(h home.Home, client *registry.Client, ref registry.Reference, d string) error {
+	trust, err := trustClient(h, ref)
+	if err != nil || trust == nil {
+		return err
+	}
+	m, _, _, err := client.GetManifest(ref.WithDigest(d))
+	if err != nil {
+		return err
+	}
+	return trust.Sign(ref.Registry+"/"+ref.Repository, ref.Tag, d, int64(len(m)))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+func newRegistryCmd(w io.Writer) *cobra.Command {
+	const usage = `Manage credentials and content trust for OCI registries.
+
+Credentials saved with 'duffle registry login' are used whenever duffle talks to the
+registry: to push and pull bundles, to install bundles from registry references, and to
+resolve the digests of images.
+
+Registries without saved credentials are accessed with the credentials of the docker CLI,
+as found in its configuration (~/.docker/config.json, or $DOCKER_CONFIG/config.json):
+the credential helper configured for the registry (such as ecr-login, gcr or acr-env),
+the credentials saved by 'docker login', or the default credential store. Registries
+docker has no credentials for either are accessed anonymously.
+
+Bundles in registries set up with 'duffle registry trust' are signed when pushed and
+verified when pulled.
+`
+
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "manage registry credentials and content trust",
+		Long:  usage,
+	}
+
+	cmd.AddCommand(newRegistryLoginCmd(w))
+	cmd.AddCommand(newRegistryLogoutCmd(w))
+	cmd.AddCommand(newRegistryTrustCmd(w))
+
+	return cmd
+}
+
+// registryClient returns a registry client authenticating with the saved credentials of the
+// current context or duffle home, which resumes uploads left unfinished by earlier runs
+func registryClient(h home.Home) (*registry.Client, error) {
+	file, err := registriesFile(h)
+	if err != nil {
+		return nil, err
+	}
+	regs, err := registry.LoadRegistryFile(file)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := currentConfig(h)
+	if err != nil {
+		return nil, err
+	}
+	c := registry.NewClient(regs.Credentials())
+	c.Sessions = registry.NewUploadSessions(h.UploadSessions())
+	c.ChunkSize = cfg.Registry.ChunkSize
+	return c, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+func newRegistryLoginCmd(w io.Writer) *cobra.Command {
+	const usage = `Saves credentials for an OCI registry.
+
+The credentials are checked against REGISTRY, such as example.com or docker.io, before
+they are saved. The password is read from standard input with --password-stdin, or
+prompted for when --password is not given.
+
+By default credentials are stored in duffle home, readable only by the current user.
+With --credential-helper, they are handed to a docker credential helper instead (e.g.
+'pass' for docker-credential-pass, or 'desktop'), and duffle only records which helper
+holds them. When the docker configuration (~/.docker/config.json) names a credential
+helper for REGISTRY, or a default credential store, that helper is used unless
+--credential-helper is given.
+`
+
+	var (
+		username      string
+		password      string
+		passwordStdin bool
+		helper        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "login REGISTRY",
+		Short: "save credentials for a registry",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if username == "" {
+				return fmt.Errorf("--username is required")
+			}
+			if passwordStdin && password != "" {
+				return fmt.Errorf("--password and --password-stdin are mutually exclusive")
+			}
+			if passwordStdin {
+				data, err := ioutil.ReadAll(os.Stdin)
+				if err != nil {
+					return err
+				}
+				password = strings.TrimRight(string(data), "\r\n")
+			} else if password == "" {
+				fmt.Fprint(w, "Password: ")
+				data, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+				fmt.Fprintln(w)
+				if err != nil {
+					return fmt.Errorf("cannot read password: %v", err)
+				}
+				password = string(data)
+			}
+			if password == "" {
+				return fmt.Errorf("a password is required")
+			}
+
+			reg := registry.NormalizeRegistry(args[0])
+			client := registry.NewClient(func(string) (string, string, error) { return username, password, nil })
+			if err := client.Ping(reg); err != nil {
+				return err
+			}
+
+			h := home.Home(homePath())
+			file, err := registriesFile(h)
+			if err != nil {
+				return err
+			}
+			creds, err := registry.LoadRegistryFile(file)
+			if err != nil {
+				return err
+			}
+			if !cmd.Flags().Changed("credential-helper") {
+				helper = registry.DockerCredentialHelper(reg)
+			}
+			if err := creds.Login(reg, username, password, helper); err != nil {
+				return err
+			}
+			if err := creds.WriteFile(file); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Login to %s succeeded\n", reg)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&username, "username", "u", "", "registry username")
+	flags.StringVarP(&password, "password", "p", "", "registry password or token")
+	flags.BoolVar(&passwordStdin, "password-stdin", false, "read the password from standard input")
+	flags.StringVar(&helper, "credential-helper", "", "docker credential helper storing the credentials")
+
+	return cmd
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+func newRegistryLogoutCmd(w io.Writer) *cobra.Command {
+	const usage = `Removes the saved credentials of an OCI registry.
+
+Credentials held by a docker credential helper are erased from it as well.
+`
+
+	return &cobra.Command{
+		Use:   "logout REGISTRY",
+		Short: "remove saved credentials for a registry",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			file, err := registriesFile(h)
+			if err != nil {
+				return err
+			}
+			creds, err := registry.LoadRegistryFile(file)
+			if err != nil {
+				return err
+			}
+			reg := registry.NormalizeRegistry(args[0])
+			ok, err := creds.Logout(reg)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("not logged in to %s", reg)
+			}
+			if err := creds.WriteFile(file); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Removed credentials for %s\n", reg)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/notary"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+func newRegistryTrustCmd(w io.Writer) *cobra.Command {
+	const usage = `Enables content trust for bundles in an OCI registry.
+
+With content trust, every bundle pushed to REGISTRY is signed with Docker Content Trust:
+its tag and digest are recorded in the trust data held by the Notary server given with
+--server. Bundles pulled or installed by tag from REGISTRY are verified against that trust
+data, and rejected unless the registry serves exactly the signed bundle.
+
+Signing and verification use the notary CLI, which must be installed. Signing keys are
+kept in the notary trust directory (--trust-dir, or the notary default), and their
+passphrases are prompted for, or read from the NOTARY_*_PASSPHRASE environment variables.
+
+--disable turns content trust off for REGISTRY.
+`
+
+	var (
+		t       registry.Trust
+		disable bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "trust REGISTRY",
+		Short: "enable content trust for a registry",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			file, err := registriesFile(h)
+			if err != nil {
+				return err
+			}
+			regs, err := registry.LoadRegistryFile(file)
+			if err != nil {
+				return err
+			}
+			reg := registry.NormalizeRegistry(args[0])
+			if disable {
+				delete(regs.Trust, reg)
+			} else {
+				if t.Server == "" {
+					return fmt.Errorf("--server is required")
+				}
+				if t.TrustDir != "" {
+					if t.TrustDir, err = filepath.Abs(t.TrustDir); err != nil {
+						return err
+					}
+				}
+				if regs.Trust == nil {
+					regs.Trust = map[string]registry.Trust{}
+				}
+				regs.Trust[reg] = t
+			}
+			if err := regs.WriteFile(file); err != nil {
+				return err
+			}
+			if disable {
+				fmt.Fprintf(w, "Content trust disabled for %s\n", reg)
+			} else {
+				fmt.Fprintf(w, "Content trust enabled for %s\n", reg)
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&t.Server, "server", "", "URL of the Notary server holding the registry's trust data")
+	flags.StringVar(&t.TrustDir, "trust-dir", "", "directory holding trust data and signing keys")
+	flags.BoolVar(&disable, "disable", false, "disable content trust for the registry")
+
+	return cmd
+}
+
+// trustClient returns the notary client for the registry of ref, or nil when content
+// trust is not enabled for it
+func trustClient(h home.Home, ref registry.Reference) (*notary.Client, error) {
+	file, err := registriesFile(h)
+	if err != nil {
+		return nil, err
+	}
+	regs, err := registry.LoadRegistryFile(file)
+	if err != nil {
+		return nil, err
+	}
+	t := regs.TrustFor(ref.Registry)
+	if t == nil {
+		return nil, nil
+	}
+	return &notary.Client{Server: t.Server, TrustDir: t.TrustDir}, nil
+}
+
+// contentTrust reports whether content trust is enabled for the registry of the bundle
+// reference source
+func contentTrust(h home.Home, source string) (bool, error) {
+	ref, err := registry.ParseReference(strings.TrimPrefix(source, loader.OCIPrefix))
+	if err != nil {
+		return false, err
+	}
+	c, err := trustClient(h, ref)
+	return c != nil, err
+}
+
+// verifyTrust checks that the bundle with digest d served for ref is the one signed for
+// its tag, when content trust is enabled for the registry. References pinned by digest
+// identify their content already and are not checked.
+func verifyTrust(h home.Home, ref registry.Reference, d string) error {
+	if ref.Digest != "" {
+		return nil
+	}
+	c, err := trustClient(h, ref)
+	if err != nil || c == nil {
+		return err
+	}
+	return c.Verify(ref.Registry+"/"+ref.Repository, ref.Tag, d)
+}
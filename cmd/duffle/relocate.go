@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/progress"
+)
+
+func newRelocateCmd(w io.Writer) *cobra.Command {
+	const usage = `Copies the images of a bundle to another registry.
+
+BUNDLE is a bundle file, or NAME[:VERSION] of a bundle in the local store. Its invocation
+images and component images are copied from their registries into PREFIX, a registry
+namespace such as example.com/mirror, keeping their repository paths and tags: for
+instance, docker.io/library/alpine:3.8 is copied to example.com/mirror/library/alpine:3.8.
+Images are copied from registry to registry, without a Docker daemon, and blobs already
+present in the target registry are not transferred again, and interrupted uploads of large
+layers resume where they stopped when relocating again.
+
+The bundle is rewritten to refer to the copies by digest, and printed, or written to
+--destination. With --relocation-mapping, a JSON file mapping each original image
+reference to its copy is written as well.
+
+Credentials saved with 'duffle registry login' are used for every registry involved.
+`
+
+	var (
+		dest    string
+		mapping string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "relocate BUNDLE PREFIX",
+		Short: "copy a bundle's images to another registry",
+		Long:  usage,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			b, err := loadBundleRef(h, args[0])
+			if err != nil {
+				return err
+			}
+			client, err := registryClient(h)
+			if err != nil {
+				return err
+			}
+			client.Progress = progress.New(os.Stderr)
+
+			// progress is only reported when the bundle is not printed
+			out := w
+			if dest == "" {
+				out = ioutil.Discard
+			}
+			relocated := map[string]string{}
+			relocate := func(ref string) (string, error) {
+				if r, ok := relocated[ref]; ok {
+					return r, nil
+				}
+				r, err := client.Relocate(ref, args[1])
+				if err != nil {
+					return "", fmt.Errorf("cannot relocate %s: %v", ref, err)
+				}
+				relocated[ref] = r
+				fmt.Fprintf(out, "Copied %s to %s\n", ref, r)
+				return r, nil
+			}
+			for i, img := range b.InvocationImages {
+				r, err := relocate(img.Ref())
+				if err != nil {
+					return err
+				}
+				b.InvocationImages[i].Image, b.InvocationImages[i].Digest = r, digestOf(r)
+			}
+			for i, img := range b.Images {
+				r, err := relocate(img.Ref())
+				if err != nil {
+					return err
+				}
+				b.Images[i].URI, b.Images[i].Digest = r, digestOf(r)
+			}
+
+			if mapping != "" {
+				data, err := json.MarshalIndent(relocated, "", "    ")
+				if err != nil {
+					return err
+				}
+				if err := ioutil.WriteFile(mapping, data, 0644); err != nil {
+					return err
+				}
+			}
+			if dest != "" {
+				return b.WriteFile(dest, 0644)
+			}
+			data, err := json.MarshalIndent(b, "", "    ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, string(data))
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&dest, "destination", "d", "", "path to write the relocated bundle to")
+	flags.StringVar(&mapping, "relocation-mapping", "", "path to write the mapping of original to relocated images to")
+
+	return cmd
+}
+
+// digestOf returns the digest a reference is pinned to
+func digestOf(ref string) string {
+	if i := strings.LastIndex(ref, "@"); i != -1 {
+		return ref[i+1:]
+	}
+	return ""
+}
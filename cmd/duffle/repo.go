@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/claim"
+	"github.com/deis/duffle/pkg/crypto/digest"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/repo"
+)
+
+func newRepoCmd(w io.Writer) *cobra.Command {
+	const usage = `Manage bundle repositories.
+
+Repositories are named remote locations serving an index.json (or index.yaml) that
+lists their bundles.
+Once a repository is added, its bundles can be installed as REPO/BUNDLE,
+REPO/BUNDLE@VERSION or REPO/BUNDLE:VERSION, where VERSION may be a semver constraint
+such as ^1.2.
+
+Repository indexes are cached in duffle home when a repository is added; run
+'duffle repo update' to refresh them.
+`
+
+	cmd := &cobra.Command{
+		Use:   "repo",
+		Short: "manage bundle repositories",
+		Long:  usage,
+	}
+
+	cmd.AddCommand(newRepoAddCmd(w))
+	cmd.AddCommand(newRepoGenerateCmd(w))
+	cmd.AddCommand(newRepoIndexCmd(w))
+	cmd.AddCommand(newRepoListCmd(w))
+	cmd.AddCommand(newRepoPushCmd(w))
+	cmd.AddCommand(newRepoRemoveCmd(w))
+	cmd.AddCommand(newRepoServeCmd(w))
+	cmd.AddCommand(newRepoUpdateCmd(w))
+
+	return cmd
+}
+
+// resolveRepoReference turns a REPO/BUNDLE[@VERSION] reference to a configured repository
+// into the URLs of the bundle document, one per mirror, and the provenance to record for it.
+// VERSION may be a semver constraint or a sha256:<hex> digest.
+// A BUNDLE[@VERSION] reference is looked up in the default repository of the configuration.
+// The repository is nil when source does not name one.
+func resolveRepoReference(h home.Home, source string) (*repo.Repository, *claim.Source, []string, error) {
+	if _, err := os.Stat(source); err == nil {
+		return nil, nil, nil, nil
+	}
+	i := strings.Index(source, "/")
+	if i == -1 {
+		cfg, err := currentConfig(h)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if cfg.Repository == "" {
+			return nil, nil, nil, nil
+		}
+		source = cfg.Repository + "/" + source
+		i = len(cfg.Repository)
+	}
+	repos, err := repo.LoadRepositoryFile(h.Repositories())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	r := repos.Get(source[:i])
+	if r == nil {
+		return nil, nil, nil, nil
+	}
+	name, version := source[i+1:], ""
+	if j := strings.IndexAny(name, "@:"); j != -1 {
+		name, version = name[:j], name[j+1:]
+	}
+	v, err := r.Find(h.RepositoryCache(), name, version)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %v", r.Name, err)
+	}
+	d, err := r.IndexDigest(h.RepositoryCache())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %v", r.Name, err)
+	}
+	urls, err := r.BundleURLs(v)
+	src := &claim.Source{
+		Repository:  r.Name,
+		IndexDigest: d,
+		Digest:      v.Digest,
+		Version:     v.Version,
+		Deprecated:  v.Deprecated,
+	}
+	if strings.HasPrefix(version, digest.Algorithm+":") {
+		src.Digest = version
+	} else {
+		src.Constraint = version
+	}
+	return r, src, urls, err
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/repo"
+)
+
+func newRepoAddCmd(w io.Writer) *cobra.Command {
+	const usage = `Adds a bundle repository.
+
+The repository's index.json (or index.yaml, when it serves no index.json) is fetched to
+check that URL serves a repository, and cached for later use.
+
+URL may also point into a git repository, as git::https://host/org/bundles.git//path?ref=v1.
+The given ref (or the default branch) is fetched with git into duffle home, and bundles
+are read from the directory at path: its index.json or index.yaml when present, or else
+every bundle.json, bundle.yaml or bundle.cnab found below it. Git handles authentication
+for such repositories itself.
+
+URL may also name a namespace in an OCI registry, as oci://registry/namespace. Bundles
+are the artifacts pushed to registry/namespace/NAME, and their semver tags are their
+versions. The registry catalog, when available, is used to list them.
+
+Mirrors of an http(s) repository are given with --mirror, once per mirror. When the
+repository URL cannot be reached, or does not serve a bundle, each mirror is tried in turn
+for both the index and the bundles.
+
+Repositories are reached through the proxy given by the HTTP_PROXY, HTTPS_PROXY and
+NO_PROXY environment variables. --proxy overrides them for this repository with the URL
+of an http(s) or socks5 proxy, or with 'direct' to connect without a proxy.
+
+Index and bundle downloads that fail with a network error or a temporary server error
+are retried with exponential backoff, up to --retries times. Each attempt is bounded by
+--timeout.
+
+With --verify, the repository's index must carry a detached signature (index.json.asc,
+see 'duffle repo generate --sign') by a key in the public keyring of duffle home, or in
+the keyring given with --keyring. The signature is checked every time the index is updated.
+
+Repositories requiring authentication accept either --username and --password for
+HTTP basic auth, or --token for a bearer token. Credentials are stored in duffle home,
+readable only by the current user. Alternatively, --credential-helper names a docker
+credential helper (e.g. 'pass' for docker-credential-pass) that is asked for the
+credentials each time the repository is accessed, so that none are stored.
+
+Repositories served with certificates from a private PKI are trusted with --ca-file.
+--cert-file and --key-file present a client certificate to the server, and
+--insecure-skip-tls-verify disables certificate verification altogether.
+`
+
+	var (
+		r             repo.Repository
+		passwordStdin bool
+		retries       int
+		timeout       time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add NAME URL",
+		Short: "add a bundle repository",
+		Long:  usage,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r.Name, r.URL = args[0], args[1]
+			r.Verify = r.Verify || r.Keyring != ""
+			if cmd.Flags().Changed("retries") {
+				r.Retries = &retries
+			}
+			if cmd.Flags().Changed("timeout") {
+				r.Timeout = timeout.String()
+			}
+			if passwordStdin {
+				data, err := ioutil.ReadAll(os.Stdin)
+				if err != nil {
+					return err
+				}
+				r.Password = strings.TrimRight(string(data), "\r\n")
+			}
+			if r.Password != "" && r.Username == "" {
+				return fmt.Errorf("--password requires --username")
+			}
+			if r.CredentialHelper != "" && (r.Username != "" || r.Token != "") {
+				return fmt.Errorf("--credential-helper cannot be combined with other credentials")
+			}
+			if r.Username != "" && r.Token != "" {
+				return fmt.Errorf("--username and --token are mutually exclusive")
+			}
+
+			for _, p := range []*string{&r.CAFile, &r.CertFile, &r.KeyFile, &r.Keyring} {
+				if *p == "" {
+					continue
+				}
+				abs, err := filepath.Abs(*p)
+				if err != nil {
+					return err
+				}
+				*p = abs
+			}
+
+			h := home.Home(homePath())
+			repos, err := repo.LoadRepositoryFile(h.Repositories())
+			if err != nil {
+				return err
+			}
+			if err := repos.Add(&r); err != nil {
+				return err
+			}
+			if _, err := r.Update(nil, h.RepositoryCache()); err != nil {
+				return fmt.Errorf("%s is not a valid bundle repository: %v", r.URL, err)
+			}
+			if err := repos.WriteFile(h.Repositories()); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%q has been added to your repositories\n", r.Name)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVar(&r.Mirrors, "mirror", []string{}, "base URL of a mirror of the repository")
+	flags.StringVar(&r.Username, "username", "", "username for HTTP basic auth")
+	flags.StringVar(&r.Password, "password", "", "password for HTTP basic auth")
+	flags.BoolVar(&passwordStdin, "password-stdin", false, "read the password from standard input")
+	flags.StringVar(&r.Token, "token", "", "bearer token")
+	flags.StringVar(&r.CredentialHelper, "credential-helper", "", "docker credential helper supplying the credentials")
+	flags.StringVar(&r.CAFile, "ca-file", "", "verify the repository's certificate using this CA bundle")
+	flags.StringVar(&r.CertFile, "cert-file", "", "client certificate presented to the repository")
+	flags.StringVar(&r.KeyFile, "key-file", "", "key of the client certificate")
+	flags.StringVar(&r.Proxy, "proxy", "", "proxy URL for the repository, or 'direct'; overrides the proxy environment variables")
+	flags.IntVar(&retries, "retries", repo.DefaultRetries, "number of times failed requests to the repository are retried")
+	flags.DurationVar(&timeout, "timeout", repo.DefaultTimeout, "time limit of each attempt of a request to the repository")
+	flags.BoolVar(&r.Verify, "verify", false, "require the repository index to be signed by a trusted key")
+	flags.StringVar(&r.Keyring, "keyring", "", "keyring holding the keys trusted to sign the index (implies --verify)")
+	flags.BoolVar(&r.InsecureSkipTLSVerify, "insecure-skip-tls-verify", false, "skip verification of the repository's certificate")
+
+	return cmd
+}
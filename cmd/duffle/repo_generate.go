@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/repo"
+)
+
+func newRepoGenerateCmd(w io.Writer) *cobra.Command {
+	const usage = `Generates the index of a bundle repository.
+
+Every bundle.json, bundle.yaml or bundle.cnab found below DIR is added to DIR/index.json,
+which can then be served together with the bundles as a repository. Bundle URLs in the
+index are relative to DIR, unless --url gives the base URL the bundles are served from.
+
+With --merge, an existing DIR/index.json is updated instead: only bundle files changed
+since it was generated are read again, entries keep the time they were first added, and
+entries pointing outside DIR are preserved. Versions are deprecated by setting
+"deprecated": true on their entry; merging keeps the flag.
+
+With --yaml, the index is written to DIR/index.yaml instead, which is easier to maintain
+by hand. Repositories serve index.yaml when they have no index.json; --merge reads
+either. Comments in an existing index.yaml are not preserved.
+
+With --shard, the versions of each bundle are written to DIR/index/NAME.json, and
+index.json only lists these shards along with their digests. Clients then download the
+shard of a bundle only when they resolve it, which keeps updates of very large
+repositories fast. Signing the index covers the shards through their digests.
+
+With --sign, the index is signed with a key from the secret keyring in duffle home and
+the detached signature written next to it, to DIR/index.json.asc. Clients that add the repository
+with --verify check this signature on every update. The signer's public key is recorded
+in the index for convenience, but clients only trust keys from their own keyring.
+`
+
+	var (
+		baseURL string
+		merge   bool
+		shard   bool
+		sign    bool
+		signer  string
+		asYAML  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "generate DIR",
+		Short: "generate the index of a bundle repository",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := args[0]
+			var existing *repo.IndexFile
+			if merge {
+				i, err := repo.LoadIndexDir(dir)
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				existing = i
+			}
+			index, err := repo.MergeDirectory(existing, dir, baseURL)
+			if err != nil {
+				return err
+			}
+			if shard {
+				if err := index.WriteShards(dir); err != nil {
+					return err
+				}
+			}
+			if !sign {
+				_, err := index.WriteDir(dir, asYAML, nil)
+				return err
+			}
+			s, err := loadSigner(home.Home(homePath()), signer)
+			if err != nil {
+				return err
+			}
+			path, err := index.WriteDir(dir, asYAML, s)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Index signed and written to %s\n", path)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&baseURL, "url", "", "base URL the bundles are served from")
+	flags.BoolVar(&merge, "merge", false, "update the existing index rather than regenerating it")
+	flags.BoolVar(&shard, "shard", false, "write the versions of each bundle to a separate index shard")
+	flags.BoolVar(&sign, "sign", false, "sign the index")
+	flags.StringVar(&signer, "signer", "", "ID of the key used to sign the index")
+	flags.BoolVar(&asYAML, "yaml", false, "write the index as index.yaml")
+
+	return cmd
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+func newRepoIndexCmd(w io.Writer) *cobra.Command {
+	const usage = `Work with repository indexes`
+
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: usage,
+		Long:  usage,
+	}
+
+	cmd.AddCommand(newRepoIndexVerifyCmd(w))
+
+	return cmd
+}
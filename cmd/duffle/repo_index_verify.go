@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/repo"
+)
+
+func newRepoIndexVerifyCmd(w io.Writer) *cobra.Command {
+	const usage = `Checks a repository directory for problems.
+
+DIR is a repository directory as written by 'duffle repo generate'. Every version in its
+index must be a valid semantic version, and every bundle URL within DIR must name an
+existing file whose digest matches the index. Pass --url if the index was generated with
+one; URLs outside of DIR are not checked.
+
+If DIR has a repositories/NAME/tags/VERSION tree, each tag file must be the indexed
+bundle of that name and version, and every indexed version must have a tag file.
+
+Problems are printed one per line, or as a JSON or YAML array with --output. The command
+fails if any problem is found.
+`
+
+	var baseURL string
+
+	cmd := &cobra.Command{
+		Use:   "verify DIR",
+		Short: "check a repository directory for problems",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pr, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			problems, err := repo.VerifyDirectory(args[0], baseURL)
+			if err != nil {
+				return err
+			}
+			if problems == nil {
+				problems = []repo.Problem{}
+			}
+			if err := pr.Print(problems, func(w io.Writer) error {
+				for _, p := range problems {
+					fmt.Fprintln(w, p)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if len(problems) > 0 {
+				return errors.New("repository is not consistent with its index")
+			}
+			if pr.IsTable() {
+				fmt.Fprintf(w, "%s is consistent with its index\n", args[0])
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&baseURL, "url", "", "base URL the index was generated with")
+
+	return cmd
+}
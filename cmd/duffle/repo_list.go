@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/repo"
+)
+
+func newRepoListCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list bundle repositories",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			repos, err := repo.LoadRepositoryFile(home.Home(homePath()).Repositories())
+			if err != nil {
+				return err
+			}
+			// credentials are left out, so that listings can be shared
+			listed := make([]repoListing, 0, len(repos.Repositories))
+			for _, r := range repos.Repositories {
+				listed = append(listed, repoListing{Name: r.Name, URL: r.URL, Mirrors: r.Mirrors, Verify: r.Verify})
+			}
+			return p.Print(listed, func(tw io.Writer) error {
+				fmt.Fprintln(tw, "NAME\tURL")
+				for _, r := range listed {
+					fmt.Fprintf(tw, "%s\t%s\n", r.Name, r.URL)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// repoListing is a repository listed by 'duffle repo list'
+type repoListing struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Mirrors []string `json:"mirrors,omitempty"`
+	// Verify is set for repositories whose index must be signed
+	Verify bool `json:"verify"`
+}
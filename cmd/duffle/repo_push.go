@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/repo"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newRepoPushCmd(w io.Writer) *cobra.Command {
+	const usage = `Publishes a bundle to a repository.
+
+The repository must be served by 'duffle repo serve --allow-upload'. The bundle is
+uploaded to /repositories/NAME/tags/VERSION, and the server adds it to the repository's
+index in the same step.
+
+Unless BUNDLE_FILE is already clearsigned, the bundle is signed with a key from the
+secret keyring in duffle home before it is uploaded; pass --insecure to publish it
+unsigned. Publishing a version that already exists fails unless --force is given.
+`
+
+	var (
+		signer   string
+		insecure bool
+		force    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "push REPO BUNDLE_FILE",
+		Short: "publish a bundle to a repository",
+		Long:  usage,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			repos, err := repo.LoadRepositoryFile(h.Repositories())
+			if err != nil {
+				return err
+			}
+			r := repos.Get(args[0])
+			if r == nil {
+				return fmt.Errorf("repository %q not found", args[0])
+			}
+
+			data, err := ioutil.ReadFile(args[1])
+			if err != nil {
+				return err
+			}
+			b, err := loadBundle(w, args[1], loader.Options{})
+			if err != nil {
+				return err
+			}
+			if !signature.IsClearsigned(data) {
+				if data, err = json.MarshalIndent(b, "", "    "); err != nil {
+					return err
+				}
+				if !insecure {
+					s, err := loadSigner(h, signer)
+					if err != nil {
+						return fmt.Errorf("%v (pass --insecure to publish the bundle unsigned)", err)
+					}
+					if data, err = s.Clearsign(data); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := r.Push(b.Name, b.Version, data, force); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Pushed %s %s to %s\n", b.Name, b.Version, r.Name)
+			if _, err := r.Update(nil, h.RepositoryCache()); err != nil {
+				return fmt.Errorf("cannot refresh the index of %s: %v", r.Name, err)
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&signer, "signer", "", "ID of the key used to sign the bundle")
+	flags.BoolVar(&insecure, "insecure", false, "publish the bundle without signing it")
+	flags.BoolVar(&force, "force", false, "replace the version if it already exists")
+
+	return cmd
+}
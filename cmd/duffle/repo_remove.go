@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/repo"
+)
+
+func newRepoRemoveCmd(w io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove NAME",
+		Short: "remove a bundle repository",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			repos, err := repo.LoadRepositoryFile(h.Repositories())
+			if err != nil {
+				return err
+			}
+			if !repos.Remove(args[0]) {
+				return fmt.Errorf("repository %q not found", args[0])
+			}
+			if err := repos.WriteFile(h.Repositories()); err != nil {
+				return err
+			}
+			if err := (&repo.Repository{Name: args[0]}).ClearCache(h.RepositoryCache()); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%q has been removed from your repositories\n", args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/repo"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newRepoServeCmd(w io.Writer) *cobra.Command {
+	const usage = `Serves a bundle repository over HTTP.
+
+DIR is a repository directory as written by 'duffle repo generate'. Its index, shards
+and bundle files are served at their paths within DIR, and every indexed bundle is also
+served at /repositories/NAME/tags/VERSION. The index is reloaded whenever the index file
+changes, so the repository can be regenerated while it is served.
+
+With --username and --password, every request must authenticate with HTTP basic auth.
+With --tls-cert and --tls-key, the repository is served over HTTPS.
+
+With --allow-upload, bundles published with 'duffle repo push' are stored in DIR as
+NAME/VERSION/bundle.cnab (or bundle.json when unsigned) and added to the index.
+Uploads require --username and --password. With --upload-keyring, only bundles signed
+by a key in that keyring are accepted. With --sign-index, the index is signed with a key
+from the secret keyring in duffle home after every upload.
+`
+
+	var (
+		s       repo.Server
+		address string
+		tlsCert string
+		tlsKey  string
+
+		uploadKeyring string
+		signIndex     bool
+		signer        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve DIR",
+		Short: "serve a bundle repository over HTTP",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s.Dir = args[0]
+			if (s.Username == "") != (s.Password == "") {
+				return fmt.Errorf("--username and --password must be given together")
+			}
+			if (tlsCert == "") != (tlsKey == "") {
+				return fmt.Errorf("--tls-cert and --tls-key must be given together")
+			}
+			if s.AllowUpload && s.Username == "" {
+				return fmt.Errorf("--allow-upload requires --username and --password")
+			}
+			var err error
+			if uploadKeyring != "" {
+				if s.Keyring, err = signature.LoadKeyRing(uploadKeyring); err != nil {
+					return err
+				}
+			}
+			if signIndex {
+				if s.Signer, err = loadSigner(home.Home(homePath()), signer); err != nil {
+					return err
+				}
+			}
+			if tlsCert != "" {
+				fmt.Fprintf(w, "Serving %s on https://%s\n", s.Dir, address)
+				return http.ListenAndServeTLS(address, tlsCert, tlsKey, &s)
+			}
+			fmt.Fprintf(w, "Serving %s on http://%s\n", s.Dir, address)
+			return http.ListenAndServe(address, &s)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&address, "address", "127.0.0.1:8080", "address to listen on")
+	flags.StringVar(&s.Username, "username", "", "username required for HTTP basic auth")
+	flags.StringVar(&s.Password, "password", "", "password required for HTTP basic auth")
+	flags.StringVar(&tlsCert, "tls-cert", "", "TLS certificate to serve HTTPS with")
+	flags.StringVar(&tlsKey, "tls-key", "", "key of the TLS certificate")
+	flags.BoolVar(&s.AllowUpload, "allow-upload", false, "accept bundles published with 'duffle repo push'")
+	flags.StringVar(&uploadKeyring, "upload-keyring", "", "only accept uploaded bundles signed by a key in this keyring")
+	flags.BoolVar(&signIndex, "sign-index", false, "sign the index after every upload")
+	flags.StringVar(&signer, "signer", "", "ID of the key used to sign the index")
+
+	return cmd
+}
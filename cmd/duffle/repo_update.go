@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/repo"
+)
+
+func newRepoUpdateCmd(w io.Writer) *cobra.Command {
+	const usage = `Refreshes the cached indexes of bundle repositories.
+
+Every configured repository is updated unless names are given. Repositories that
+cannot be reached keep their previously cached index.
+
+Progress is reported as repositories are updated, or the results are printed as JSON or
+YAML once all are with --output.
+`
+
+	return &cobra.Command{
+		Use:   "update [NAME...]",
+		Short: "refresh cached repository indexes",
+		Long:  usage,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			h := home.Home(homePath())
+			repos, err := repo.LoadRepositoryFile(h.Repositories())
+			if err != nil {
+				return err
+			}
+			targets := repos.Repositories
+			if len(args) > 0 {
+				targets = nil
+				for _, name := range args {
+					r := repos.Get(name)
+					if r == nil {
+						return fmt.Errorf("repository %q not found", name)
+					}
+					targets = append(targets, r)
+				}
+			}
+			failed := 0
+			results := make([]repoUpdate, 0, len(targets))
+			for _, r := range targets {
+				u := repoUpdate{Name: r.Name, Updated: true}
+				if _, err := r.Update(nil, h.RepositoryCache()); err != nil {
+					u.Updated, u.Error = false, err.Error()
+					failed++
+				}
+				results = append(results, u)
+				if !p.IsTable() {
+					continue
+				}
+				if u.Updated {
+					fmt.Fprintf(w, "...successfully updated %q\n", r.Name)
+				} else {
+					fmt.Fprintf(w, "...unable to update %q: %v\n", r.Name, u.Error)
+				}
+			}
+			if !p.IsTable() {
+				if err := p.Print(results, nil); err != nil {
+					return err
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d repositories could not be updated", failed, len(targets))
+			}
+			return nil
+		},
+	}
+}
+
+// repoUpdate is the result of updating a repository
+type repoUpdate struct {
+	Name    string `json:"name"`
+	Updated bool   `json:"updated"`
+	// Error is why the repository could not be updated
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+func newResignCmd(w io.Writer) *cobra.Command {
+	const usage = `Replaces the signature of a bundle by one key with a signature by another.
+
+This is meant for rotating release keys: the signature by the old key, selected with --key,
+is verified against the public keyring and replaced with a signature by the key selected
+with --signer. Signatures by other keys are kept. --key may be left out when the bundle
+is signed by a single key.
+
+BUNDLE is a signed bundle file, updated in place, or a bundle of the local store given as
+NAME[:VERSION]. Stored bundles are re-signed through their provenance file, which is first
+checked against the stored document, so that the digest recorded in the index of the
+local store keeps matching the document it signs.
+
+Since signatures by expired or revoked keys are rejected, rotate keys before they expire.
+Re-signed bundle files can be published again with 'duffle repo push --force'.
+`
+
+	var (
+		key    string
+		signer string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "resign BUNDLE",
+		Short: "replace the signature of a bundle by one key with a signature by another",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			local, _, err := resolveLocalReference(h, args[0])
+			if err != nil {
+				return err
+			}
+			path := args[0]
+			if local != "" {
+				path = signature.ProvenancePath(local)
+			}
+			data, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) && local != "" {
+				return fmt.Errorf("%s has no provenance file to re-sign", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			if !signature.IsClearsigned(data) {
+				return fmt.Errorf("%s is not signed; sign it with 'duffle bundle sign'", args[0])
+			}
+
+			public, err := signature.LoadKeyRing(h.PublicKeyring())
+			if err != nil {
+				return err
+			}
+			v := signature.NewVerifier(public)
+			var signers []*openpgp.Entity
+			if local != "" {
+				doc, err := ioutil.ReadFile(local)
+				if err != nil {
+					return err
+				}
+				signers, err = v.VerifyProvenance(data, local, doc)
+			} else {
+				signers, _, err = v.VerifyAll(data)
+			}
+			if err != nil {
+				return fmt.Errorf("cannot verify %s: %v", args[0], err)
+			}
+			old, err := replacedKey(public, signers, key)
+			if err != nil {
+				return fmt.Errorf("cannot re-sign %s: %v", args[0], err)
+			}
+
+			s, err := loadSigner(h, signer)
+			if err != nil {
+				return err
+			}
+			if data, err = s.Resign(data, old); err != nil {
+				return fmt.Errorf("cannot re-sign %s: %v", args[0], err)
+			}
+			if err := atomicfile.WriteFile(path, data, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Re-signed %s with %s, replacing the signature by %s\n", args[0], s.Info(), signature.Info(old))
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&key, "key", "", "ID of the key whose signature is replaced; required when the bundle is signed by several keys")
+	flags.StringVar(&signer, "signer", "", "ID of the key used to sign the bundle")
+
+	return cmd
+}
+
+// replacedKey returns the key among the verified signers whose signature is replaced: the
+// key id of the public keyring, or the only signer when id is empty
+func replacedKey(public *signature.KeyRing, signers []*openpgp.Entity, id string) (*openpgp.Entity, error) {
+	if id == "" {
+		if len(signers) > 1 {
+			return nil, errors.New("it is signed by several keys; select the one to replace with --key")
+		}
+		return signers[0], nil
+	}
+	k, err := public.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range signers {
+		if signature.Fingerprint(e) == signature.Fingerprint(k) {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("it carries no valid signature by %s", signature.Info(k))
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"io"
+	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/printer"
 )
 
 // TODO
@@ -14,16 +18,74 @@ func newRootCmd(w io.Writer) *cobra.Command {
 		Use:   "duffle",
 		Short: usage,
 		Long:  usage,
+		// errors are reported by main
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if homeFlag != "" {
+				// packages defaulting to the duffle home, and the processes duffle
+				// starts, find it in the environment
+				if err := os.Setenv("DUFFLE_HOME", homeFlag); err != nil {
+					return err
+				}
+			}
+			// doctor diagnoses duffle home as it is, including what stops other commands
+			if cmd.Name() == "doctor" {
+				return nil
+			}
+			if err := lockHome(cmd); err != nil {
+				return err
+			}
+			if cmd.Name() != "migrate" {
+				h := home.Home(homePath())
+				// migrations write duffle home, even before commands that only read it
+				if migrationPending(h) {
+					if err := takeHomeLock(cmd); err != nil {
+						return err
+					}
+				}
+				if _, err := migrateHome(os.Stderr, h, false); err != nil {
+					return err
+				}
+			}
+			return applyConfig(cmd)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			unimplemented("duffle")
 		},
 	}
 
+	cmd.PersistentFlags().StringVar(&homeFlag, "home", "", "location of duffle home (default: $DUFFLE_HOME, ~/.duffle if it exists, or the XDG base directories)")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", printer.Table, "output format of results: table, json or yaml; the output setting applies when not given")
+	cmd.PersistentFlags().DurationVar(&lockTimeout, "lock-timeout", defaultLockTimeout, "how long to wait for another duffle process modifying duffle home to finish")
+
 	cmd.AddCommand(newBuildCmd(w))
+	cmd.AddCommand(newBundleCmd(w))
+	cmd.AddCommand(newCacheCmd(w))
+	cmd.AddCommand(newConfigCmd(w))
+	cmd.AddCommand(newContextCmd(w))
+	cmd.AddCommand(newCreateCmd(w))
+	cmd.AddCommand(newDoctorCmd(w))
+	cmd.AddCommand(newExportCmd(w))
+	cmd.AddCommand(newImportCmd(w))
 	cmd.AddCommand(newInitCmd(w))
+	cmd.AddCommand(newInstallCmd(w))
+	cmd.AddCommand(newKeyCmd(w))
+	cmd.AddCommand(newMigrateCmd(w))
+	cmd.AddCommand(newPluginCmd(w))
 	cmd.AddCommand(newPullCmd(w))
 	cmd.AddCommand(newPushCmd(w))
+	cmd.AddCommand(newRegistryCmd(w))
+	cmd.AddCommand(newRelocateCmd(w))
+	cmd.AddCommand(newRepoCmd(w))
+	cmd.AddCommand(newResignCmd(w))
 	cmd.AddCommand(newRunCmd(w))
+	cmd.AddCommand(newSearchCmd(w))
+	cmd.AddCommand(newSelfUpdateCmd(w))
+	cmd.AddCommand(newTagCmd(w))
+	cmd.AddCommand(newUninstallCmd(w))
+	cmd.AddCommand(newUpgradeCmd(w))
+	cmd.AddCommand(newVerifyCmd(w))
 
 	return cmd
 }
@@ -1,23 +1,83 @@
 package main
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/bundle"
 )
 
-// TODO
 func newRunCmd(w io.Writer) *cobra.Command {
-	const usage = `TODO`
+	const usage = `Runs a custom action against an existing installation.
+
+Custom actions are declared in the 'actions' section of the bundle. The action is run
+with the parameters recorded when the bundle was installed, which may be overridden
+or supplemented with --set NAME=VALUE. Actions that declare 'modifies: true' update
+the installation's claim with their result.
+`
+
+	var (
+		driverName      string
+		invocationImage string
+		skipDigestCheck bool
+		values          []string
+	)
 
 	cmd := &cobra.Command{
-		Use:   "run",
-		Short: usage,
+		Use:   "run ACTION NAME",
+		Short: "run a custom action against an installation",
 		Long:  usage,
-		Run: func(cmd *cobra.Command, args []string) {
-			unimplemented("duffle run")
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			action, name := args[0], args[1]
+
+			claims, err := claimStore()
+			if err != nil {
+				return err
+			}
+			c, err := claims.Read(name)
+			if err != nil {
+				return err
+			}
+			if bundle.IsCoreAction(action) {
+				return fmt.Errorf("%s is a core action; use 'duffle %s' instead", action, action)
+			}
+			a, ok := c.Bundle.Actions[action]
+			if !ok {
+				return fmt.Errorf("bundle %s does not declare action %q", c.Bundle.Name, action)
+			}
+
+			d, err := lookupDriver(driverName, skipDigestCheck)
+			if err != nil {
+				return err
+			}
+			params, err := resolveParameters(c.Bundle, action, values, c.Parameters)
+			if err != nil {
+				return err
+			}
+			op, err := newOperation(c, action, params, d, invocationImage, w)
+			if err != nil {
+				return err
+			}
+
+			runErr := d.Run(op)
+			if a.Modifies {
+				c.Update(action, runErr)
+				if err := claims.Store(c); err != nil {
+					return fmt.Errorf("cannot record claim for %s: %v", c.Name, err)
+				}
+			}
+			return runErr
 		},
 	}
 
+	flags := cmd.Flags()
+	flags.StringVarP(&driverName, "driver", "d", "docker", "specify a driver name")
+	flags.StringVar(&invocationImage, "invocation-image", "", "invocation image to run when the bundle declares several")
+	flags.BoolVar(&skipDigestCheck, "skip-digest-check", false, "run the invocation image even if its pinned digest does not match the registry")
+	flags.StringArrayVar(&values, "set", []string{}, "set a parameter value (NAME=VALUE)")
+
 	return cmd
 }
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/repo"
+)
+
+// searchResult is a bundle found by search, with where it was found
+type searchResult struct {
+	// Repository is the configured repository serving the bundle, or empty for the local store
+	Repository string `json:"repository,omitempty"`
+	*repo.BundleVersion
+}
+
+func newSearchCmd(w io.Writer) *cobra.Command {
+	const usage = `Searches for bundles.
+
+Bundles in the local store and in the cached index of every configured repository are
+searched. A bundle matches when each KEYWORD appears in its name, description or
+keywords, ignoring case; every bundle matches when no keyword is given. With --regexp,
+each KEYWORD is a regular expression that must match one of those fields instead.
+
+Only the newest version of each bundle is listed, unless --versions is given. With
+--version, only versions satisfying the semver constraint are considered, so that
+'duffle search NAME --versions --version ">1.2.0"' lists the versions newer than 1.2.0.
+Deprecated versions are hidden unless --include-deprecated is given, so bundles whose
+newest version is deprecated are not listed at all.
+Bundles listed in a separate shard of a repository's index are only matched by name.
+
+Repository indexes are cached when a repository is added; run 'duffle repo update' to
+refresh them before searching.
+
+Results are listed as a table, or printed as JSON or YAML with --output.
+`
+
+	var (
+		allVersions       bool
+		useRegexp         bool
+		includeDeprecated bool
+		constraint        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "search [KEYWORD...]",
+		Short: "search for bundles",
+		Long:  usage,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			match := repo.MatchTerms(args)
+			if useRegexp {
+				var exprs []*regexp.Regexp
+				for _, a := range args {
+					re, err := regexp.Compile(a)
+					if err != nil {
+						return fmt.Errorf("invalid regular expression %q: %v", a, err)
+					}
+					exprs = append(exprs, re)
+				}
+				match = repo.MatchRegexps(exprs)
+			}
+			if !includeDeprecated {
+				match = repo.NotDeprecated(match)
+			}
+			if constraint != "" {
+				if _, err := semver.NewConstraint(constraint); err != nil {
+					return fmt.Errorf("invalid version constraint %q: %v", constraint, err)
+				}
+			}
+			results, err := search(home.Home(homePath()), match, constraint, allVersions)
+			if err != nil {
+				return err
+			}
+			return p.Print(results, func(tw io.Writer) error {
+				fmt.Fprintln(tw, "NAME\tVERSION\tREPOSITORY\tDESCRIPTION")
+				for _, r := range results {
+					name, repository := r.Name, "(local)"
+					if r.Repository != "" {
+						name, repository = r.Repository+"/"+r.Name, r.Repository
+					}
+					description := r.Description
+					if r.Deprecated {
+						description = strings.TrimSpace("DEPRECATED " + description)
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, r.Version, repository, description)
+				}
+				return nil
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&allVersions, "versions", false, "list every version of matching bundles")
+	flags.BoolVarP(&useRegexp, "regexp", "r", false, "treat keywords as regular expressions")
+	flags.BoolVar(&includeDeprecated, "include-deprecated", false, "list deprecated versions too")
+	flags.StringVar(&constraint, "version", "", "only consider versions satisfying this semver constraint")
+
+	return cmd
+}
+
+// search finds the bundles matching in the local store and in every configured repository,
+// considering only versions satisfying constraint unless it is empty
+func search(h home.Home, match repo.Matcher, constraint string, allVersions bool) ([]searchResult, error) {
+	local, err := LocalStore{home: h}.List()
+	if err != nil {
+		return nil, err
+	}
+	index := repo.NewIndexFile()
+	for _, b := range local {
+		index.Entries[b.Name] = append(index.Entries[b.Name], &repo.BundleVersion{
+			Name:        b.Name,
+			Version:     b.Version,
+			Description: b.Description,
+		})
+	}
+	index.SortEntries()
+	constrain(index, constraint)
+	results := []searchResult{}
+	for _, v := range index.Search(match, allVersions) {
+		results = append(results, searchResult{BundleVersion: v})
+	}
+
+	repos, err := repo.LoadRepositoryFile(h.Repositories())
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range repos.Repositories {
+		index, err := r.Index(nil, h.RepositoryCache())
+		if err != nil {
+			return nil, fmt.Errorf("cannot read index of repository %s: %v", r.Name, err)
+		}
+		for name := range index.Shards {
+			if !match(&repo.BundleVersion{Name: name}) {
+				continue
+			}
+			if err := r.FetchShard(index, name, h.RepositoryCache()); err != nil {
+				return nil, err
+			}
+		}
+		constrain(index, constraint)
+		for _, v := range index.Search(match, allVersions) {
+			results = append(results, searchResult{Repository: r.Name, BundleVersion: v})
+		}
+	}
+	return results, nil
+}
+
+// constrain restricts the versions of every bundle in index to those satisfying the version
+// constraint, dropping bundles without any. An empty constraint leaves index unchanged.
+func constrain(index *repo.IndexFile, constraint string) {
+	if constraint == "" {
+		return
+	}
+	for name := range index.Entries {
+		versions, err := index.GetAll(name, constraint)
+		if err != nil {
+			delete(index.Entries, name)
+			continue
+		}
+		index.Entries[name] = versions
+	}
+}
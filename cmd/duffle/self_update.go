@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/selfupdate"
+	"github.com/deis/duffle/pkg/signature"
+	"github.com/deis/duffle/pkg/version"
+)
+
+func newSelfUpdateCmd(w io.Writer) *cobra.Command {
+	const usage = `Updates duffle to the latest release of a release channel.
+
+The manifest of the channel, stable unless --channel names another, names its latest
+version along with the URL and checksum of the binary for each platform. The manifest must
+be signed by a release key: one of the keys whose fingerprints were built into duffle, or
+those listed in the releaseKeys setting (see 'duffle config'). The release key must be in
+the public keyring: import it with 'duffle key fetch' or 'duffle key import' first. Other
+keys of the public keyring are not trusted to sign releases. Trust policy rules for the
+host of the releases apply as well, as to repositories and registries.
+
+When the latest release is newer than the running duffle, the binary for this platform is
+downloaded, its checksum is checked, and it atomically replaces the running executable,
+which must be writable. Pass --check-only to only report whether an update is available.
+
+Releases are fetched from https://releases.duffle.sh, unless $DUFFLE_RELEASES_URL names
+a mirror.
+`
+
+	var (
+		channel   string
+		checkOnly bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "update duffle to the latest release",
+		Long:  usage,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h := home.Home(homePath())
+			kr, err := loadKeyRing(h.PublicKeyring())
+			if err != nil {
+				return err
+			}
+			c, err := currentConfig(h)
+			if err != nil {
+				return err
+			}
+			u := &selfupdate.Updater{Keyring: kr, ReleaseKeys: c.ReleaseKeys}
+			r, err := u.Latest(channel)
+			if err != nil {
+				return err
+			}
+			manifest := u.ManifestURL(channel)
+			if err := checkTrustPolicy(h, manifest, nil, []*signature.KeyInfo{signature.Info(r.Signer)}); err != nil {
+				return err
+			}
+			newer, err := selfupdate.Newer(version.Version, r.Version)
+			if err != nil {
+				return err
+			}
+			if !newer {
+				fmt.Fprintf(w, "duffle %s is up to date (latest on channel %s: %s)\n", version.Version, channel, r.Version)
+				return nil
+			}
+			if checkOnly {
+				fmt.Fprintf(w, "duffle %s is available on channel %s (running %s); run 'duffle self-update' to install it\n", r.Version, channel, version.Version)
+				return nil
+			}
+
+			path, err := selfupdate.Executable()
+			if err != nil {
+				return fmt.Errorf("cannot locate the duffle executable: %v", err)
+			}
+			data, err := u.Download(r)
+			if err != nil {
+				return err
+			}
+			if err := selfupdate.Replace(path, data); err != nil {
+				return fmt.Errorf("cannot replace %s: %v", path, err)
+			}
+			fmt.Fprintf(w, "Updated duffle at %s from %s to %s (signed by %s)\n", path, version.Version, r.Version, signature.Info(r.Signer))
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&channel, "channel", selfupdate.DefaultChannel, "release channel to update from, such as stable or canary")
+	flags.BoolVar(&checkOnly, "check-only", false, "only report whether an update is available")
+
+	return cmd
+}
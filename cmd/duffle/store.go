@@ -0,0 +1,482 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/claim"
+	"github.com/deis/duffle/pkg/crypto/digest"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/repo"
+	"github.com/deis/duffle/pkg/signature"
+	"github.com/deis/duffle/pkg/sigstore"
+)
+
+// LocalStore keeps bundles in the duffle home directory.
+//
+// Bundle documents are stored under their content digest, as sha256/<hex>.json, and an
+// index in the format of repository indexes maps names and versions to them. Bundles
+// stored as NAME-VERSION.json by earlier versions of duffle are still found.
+type LocalStore struct {
+	home home.Home
+	// signer is the ID of the key used to sign provenance files; the first signing key is used when empty
+	signer string
+	// insecure skips writing a provenance file
+	insecure bool
+	// keyless signs bundles with sigstore instead of writing a provenance file
+	keyless bool
+	// origin is where stored bundles come from; bundles built locally have none
+	origin *repo.Origin
+}
+
+// Store writes b into the local store under its digest, records it in the index as the
+// stored version of b.Name and b.Version, and returns the path it was written to.
+//
+// Unless the store is insecure, a signed provenance file is written alongside the bundle,
+// or a sigstore bundle when the store is keyless. The origin of the store is recorded in
+// the index, as the local signature does not vouch for where the bundle came from.
+func (s LocalStore) Store(b *bundle.Bundle) (string, error) {
+	if b.SchemaVersion == "" {
+		b.SchemaVersion = bundle.SchemaVersion
+	}
+	data, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	d := digest.OfBuffer(data)
+	rel := filepath.ToSlash(filepath.Join(digest.Algorithm, digest.Hex(d)+".json"))
+	dest := filepath.Join(s.home.Bundles(), filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+
+	var prov []byte
+	if !s.insecure && !s.keyless {
+		signer, err := loadSigner(s.home, s.signer)
+		if err != nil {
+			return "", fmt.Errorf("%v (pass --insecure to store the bundle without provenance)", err)
+		}
+		if prov, err = signer.CreateProvenance(b, dest, data); err != nil {
+			return "", err
+		}
+	}
+
+	if err := atomicfile.WriteFile(dest, data, 0644); err != nil {
+		return "", err
+	}
+	// a bundle stored before may have been signed the other way; the new signature replaces it
+	if prov != nil {
+		if err := atomicfile.WriteFile(signature.ProvenancePath(dest), prov, 0644); err != nil {
+			return "", err
+		}
+		os.Remove(sigstore.BundlePath(dest))
+	}
+	if s.keyless && !s.insecure {
+		os.Remove(signature.ProvenancePath(dest))
+		if err := sigstore.Sign(dest); err != nil {
+			os.Remove(dest)
+			return "", err
+		}
+	}
+
+	i, err := s.index()
+	if err != nil {
+		return "", err
+	}
+	var versions repo.BundleVersions
+	for _, v := range i.Entries[b.Name] {
+		if v.Version != b.Version {
+			versions = append(versions, v)
+		}
+	}
+	i.Entries[b.Name] = append(versions, &repo.BundleVersion{
+		Name:        b.Name,
+		Version:     b.Version,
+		Description: b.Description,
+		URLs:        []string{rel},
+		Digest:      d,
+		Created:     time.Now().UTC().Truncate(time.Second),
+		Origin:      s.origin,
+	})
+	i.SortEntries()
+	i.Generated = time.Now().UTC()
+	if err := i.WriteFile(s.home.BundleIndex()); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// index returns the index of the local store, which is empty until a bundle is stored
+func (s LocalStore) index() (*repo.IndexFile, error) {
+	i, err := repo.LoadIndexFile(s.home.BundleIndex())
+	if os.IsNotExist(err) {
+		return repo.NewIndexFile(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the index of the local store: %v", err)
+	}
+	return i, nil
+}
+
+// Find returns the entry of the local index for the newest version of the named bundle
+// satisfying the version constraint, along with the path of its document. version may
+// also be a digest.
+func (s LocalStore) Find(name, version string) (*repo.BundleVersion, string, error) {
+	i, err := s.index()
+	if err != nil {
+		return nil, "", err
+	}
+	var v *repo.BundleVersion
+	if strings.HasPrefix(version, digest.Algorithm+":") {
+		v, err = i.GetDigest(name, version)
+	} else {
+		v, err = i.Get(name, version)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if len(v.URLs) == 0 {
+		return nil, "", fmt.Errorf("bundle %s %s has no document in the local store", name, v.Version)
+	}
+	return v, filepath.Join(s.home.Bundles(), filepath.FromSlash(v.URLs[0])), nil
+}
+
+// loadSigner returns a signer for the key id in the secret keyring, or for the key held by a
+// hardware token when id is a PKCS#11 URI
+func loadSigner(h home.Home, id string) (*signature.Signer, error) {
+	if signature.IsPKCS11URI(id) {
+		k, err := signature.ParsePKCS11URI(id)
+		if err != nil {
+			return nil, err
+		}
+		kr, err := signature.LoadKeyRing(h.PublicKeyring())
+		if err != nil {
+			return nil, err
+		}
+		return signature.NewPKCS11Signer(kr, k)
+	}
+	if signature.IsAgentKey(id) {
+		kr, err := signature.LoadKeyRing(h.PublicKeyring())
+		if err != nil {
+			return nil, err
+		}
+		key, err := kr.Key(strings.TrimPrefix(id, signature.AgentKeyPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if err := kr.Check(key, time.Now()); err != nil {
+			return nil, fmt.Errorf("cannot sign: %v", err)
+		}
+		socket, err := signature.AgentSocket()
+		if err != nil {
+			return nil, err
+		}
+		return signature.NewAgentSigner(kr, id, socket)
+	}
+	kr, err := signature.LoadKeyRing(h.SecretKeyring())
+	if err != nil {
+		return nil, err
+	}
+	key, err := kr.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := kr.Check(key, time.Now()); err != nil {
+		return nil, fmt.Errorf("cannot sign: %v", err)
+	}
+	if signature.Encrypted(key) {
+		if err := unlockKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return signature.NewSigner(key)
+}
+
+// unlockKey decrypts the passphrase-protected secret key e with the passphrase in
+// signature.PassphraseEnv, or else the one returned by the gpg-agent at
+// signature.AgentSocketEnv, or else one read from the terminal
+func unlockKey(e *openpgp.Entity) error {
+	info := signature.Info(e)
+	if p, ok := os.LookupEnv(signature.PassphraseEnv); ok {
+		if err := signature.Decrypt(e, []byte(p)); err != nil {
+			return fmt.Errorf("cannot unlock key %s with %s: %v", info, signature.PassphraseEnv, err)
+		}
+		return nil
+	}
+	if socket := os.Getenv(signature.AgentSocketEnv); socket != "" {
+		return unlockKeyWithAgent(e, socket)
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("key %s is protected by a passphrase; set %s or %s, or sign from a terminal", info, signature.PassphraseEnv, signature.AgentSocketEnv)
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		fmt.Fprintf(os.Stderr, "Passphrase for key %s: ", info)
+		p, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("cannot read passphrase: %v", err)
+		}
+		if err = signature.Decrypt(e, p); err != signature.ErrPassphrase {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return fmt.Errorf("cannot unlock key %s: %v", info, signature.ErrPassphrase)
+}
+
+// unlockKeyWithAgent decrypts e with the passphrase the gpg-agent at socket returns, from
+// its cache or its pinentry program; wrong passphrases are cleared from the cache
+func unlockKeyWithAgent(e *openpgp.Entity, socket string) error {
+	info := signature.Info(e)
+	a, err := signature.DialAgent(socket)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	var (
+		cacheID = "duffle:" + info.Fingerprint
+		desc    = fmt.Sprintf("Enter the passphrase of the duffle signing key %s", info)
+		errMsg  string
+	)
+	for attempt := 0; attempt < 3; attempt++ {
+		p, err := a.Passphrase(cacheID, errMsg, desc)
+		if err != nil {
+			return fmt.Errorf("cannot unlock key %s: %v", info, err)
+		}
+		if err = signature.Decrypt(e, p); err != signature.ErrPassphrase {
+			return err
+		}
+		if err := a.ClearPassphrase(cacheID); err != nil {
+			return err
+		}
+		errMsg = "Incorrect passphrase"
+	}
+	return fmt.Errorf("cannot unlock key %s: %v", info, signature.ErrPassphrase)
+}
+
+// verifyProvenance checks the provenance file next to the bundle file at path, if there is
+// one, and returns its signers. Without a provenance file, the sigstore bundle next to it
+// is checked against the identities of the trust policy instead. No signers are returned
+// when there is neither.
+func verifyProvenance(h home.Home, path string) ([]*signature.KeyInfo, error) {
+	prov, err := ioutil.ReadFile(signature.ProvenancePath(path))
+	if os.IsNotExist(err) {
+		return verifyKeyless(h, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kr, err := signature.LoadKeyRing(h.PublicKeyring())
+	if err != nil {
+		return nil, err
+	}
+	signers, err := signature.NewVerifier(kr).VerifyProvenance(prov, path, data)
+	if err != nil {
+		return nil, err
+	}
+	return signature.Infos(signers), nil
+}
+
+// verifyKeyless checks the sigstore bundle next to the file at path, if there is one, and
+// returns the identity of the trust policy that signed it
+func verifyKeyless(h home.Home, path string) ([]*signature.KeyInfo, error) {
+	if _, err := os.Stat(sigstore.BundlePath(path)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	p, err := signature.LoadPolicy(h.TrustPolicy())
+	if err != nil {
+		return nil, err
+	}
+	id, err := sigstore.Verify(path, p.Identities)
+	if err != nil {
+		return nil, err
+	}
+	return []*signature.KeyInfo{{Identity: id.String()}}, nil
+}
+
+// checkOrigin applies the trust policy for the source the stored bundle name with digest d
+// was pulled or imported from, if the index recorded one, to the keys verified then. Keys
+// since removed from the public keyring, revoked or expired no longer count.
+func checkOrigin(h home.Home, name, d string) error {
+	v, _, err := LocalStore{home: h}.Find(name, d)
+	if err != nil {
+		return err
+	}
+	o := v.Origin
+	if o == nil {
+		return nil
+	}
+	var signers []*signature.KeyInfo
+	if len(o.Signers) > 0 {
+		kr, err := loadKeyRing(h.PublicKeyring())
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, fp := range o.Signers {
+			if k, err := kr.Key(fp); err == nil && kr.Check(k, now) == nil {
+				signers = append(signers, signature.Info(k))
+			}
+		}
+	}
+	var r *repo.Repository
+	if o.Repository != "" {
+		r = &repo.Repository{Name: o.Repository, URL: o.URL}
+	}
+	return checkTrustPolicy(h, o.URL, r, signers)
+}
+
+// Path returns the location of the stored bundle matching name and version.
+//
+// When version is empty, the highest stored version of the bundle is returned. Bundles in
+// the index are preferred over bundles stored by earlier versions of duffle.
+func (s LocalStore) Path(name, version string) (string, error) {
+	if _, p, err := s.Find(name, version); err == nil {
+		return p, nil
+	}
+	if version != "" {
+		p := filepath.Join(s.home.Bundles(), fmt.Sprintf("%s-%s.json", name, version))
+		if _, err := os.Stat(p); err != nil {
+			return "", fmt.Errorf("bundle %s %s not found in the local store", name, version)
+		}
+		return p, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(s.home.Bundles(), name+"-*.json"))
+	if err != nil {
+		return "", err
+	}
+	var (
+		best     string
+		bestVers *semver.Version
+	)
+	for _, m := range matches {
+		v, err := semver.NewVersion(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), name+"-"), ".json"))
+		if err != nil {
+			continue
+		}
+		if bestVers == nil || v.GreaterThan(bestVers) {
+			best, bestVers = m, v
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("bundle %s not found in the local store", name)
+	}
+	return best, nil
+}
+
+// resolveLocalReference resolves source, as NAME, NAME:VERSION or NAME@VERSION, to the
+// path of a bundle in the index of the local store. VERSION may be a semver constraint or
+// a digest. An empty path is returned when source is a file or does not name an indexed
+// bundle.
+func resolveLocalReference(h home.Home, source string) (string, *claim.Source, error) {
+	if source == "-" || strings.Contains(source, "/") {
+		return "", nil, nil
+	}
+	if _, err := os.Stat(source); err == nil {
+		return "", nil, nil
+	}
+	name, version := parseStoreReference(source)
+	s := LocalStore{home: h}
+	i, err := s.index()
+	if err != nil {
+		return "", nil, err
+	}
+	if _, ok := i.Entries[name]; !ok {
+		return "", nil, nil
+	}
+	v, p, err := s.Find(name, version)
+	if err != nil {
+		return "", nil, fmt.Errorf("local store: %v", err)
+	}
+	src := &claim.Source{URL: p, Digest: v.Digest, Version: v.Version}
+	if !strings.HasPrefix(version, digest.Algorithm+":") {
+		src.Constraint = version
+	}
+	return p, src, nil
+}
+
+// parseStoreReference splits a reference to a bundle of the local store, NAME, NAME:VERSION
+// or NAME@VERSION, into its name and version. Bundle names contain neither ':' nor '@', so
+// that VERSION may be a digest such as sha256:<hex>.
+func parseStoreReference(ref string) (name, version string) {
+	if i := strings.IndexAny(ref, "@:"); i != -1 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// loadBundleRef loads a bundle from a file path, or from the local store given NAME,
+// NAME:VERSION or NAME@VERSION
+func loadBundleRef(h home.Home, ref string) (*bundle.Bundle, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return loader.Load(ref)
+	}
+	name, version := parseStoreReference(ref)
+	p, err := LocalStore{home: h}.Path(name, version)
+	if err != nil {
+		return nil, err
+	}
+	return loader.Load(p)
+}
+
+// List returns every bundle in the local store. Files stored by earlier versions of duffle
+// that cannot be loaded are left out, as the migration indexing them reported.
+func (s LocalStore) List() ([]*bundle.Bundle, error) {
+	i, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	indexed := map[string]bool{}
+	for name, versions := range i.Entries {
+		for _, v := range versions {
+			if len(v.URLs) > 0 {
+				p := filepath.Join(s.home.Bundles(), filepath.FromSlash(v.URLs[0]))
+				paths = append(paths, p)
+				indexed[p] = true
+				indexed[filepath.Join(s.home.Bundles(), fmt.Sprintf("%s-%s.json", name, v.Version))] = true
+			}
+		}
+	}
+	legacy, err := filepath.Glob(filepath.Join(s.home.Bundles(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range legacy {
+		if m != s.home.BundleIndex() && !indexed[m] {
+			paths = append(paths, m)
+		}
+	}
+	sort.Strings(paths)
+
+	bundles := []*bundle.Bundle{}
+	for _, p := range paths {
+		b, err := loader.Load(p)
+		if err != nil && !indexed[p] {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot load %s: %v", p, err)
+		}
+		bundles = append(bundles, b)
+	}
+	return bundles, nil
+}
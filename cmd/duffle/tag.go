@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+func newTagCmd(w io.Writer) *cobra.Command {
+	const usage = `Tags a bundle published in an OCI registry.
+
+SOURCE is the registry reference of a published bundle, such as
+example.com/org/bundle:candidate or example.com/org/bundle@sha256:<hex>. TARGET is either
+a tag, which is added in the same repository, or a full reference such as
+example.com/org/bundle:stable.
+
+Tags within a repository are added by pointing the new tag at the bundle's manifest,
+without transferring the bundle or its images again, so that releases can be promoted
+with 'duffle tag example.com/org/bundle:candidate stable'. Tagging into another repository
+of a registry copies the bundle there.
+`
+
+	return &cobra.Command{
+		Use:   "tag SOURCE TARGET",
+		Short: "tag a published bundle",
+		Long:  usage,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, err := registry.ParseReference(strings.TrimPrefix(args[0], loader.OCIPrefix))
+			if err != nil {
+				return err
+			}
+			target := strings.TrimPrefix(args[1], loader.OCIPrefix)
+			if !strings.ContainsAny(target, "/:@") {
+				target = fmt.Sprintf("%s/%s:%s", src.Registry, src.Repository, target)
+			}
+			dst, err := registry.ParseReference(target)
+			if err != nil {
+				return err
+			}
+			if dst.Digest != "" || !hasTag(target) {
+				return fmt.Errorf("invalid target %q: expected a tag or a reference with a tag", args[1])
+			}
+
+			client, err := registryClient(home.Home(homePath()))
+			if err != nil {
+				return err
+			}
+			if _, _, err := client.BundleManifest(src); err != nil {
+				return err
+			}
+			d, err := client.Tag(src, dst)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Tagged %s as %s (%s)\n", src, dst, d)
+			return nil
+		},
+	}
+}
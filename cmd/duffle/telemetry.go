@@ -0,0 +1,21 @@
+package main
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/telemetry"
+)
+
+// reportUsage reports the run of cmd, which took d and failed with err unless it is nil,
+// when the user turned telemetry on. Reporting never fails a command: its errors are
+// ignored.
+func reportUsage(cmd *cobra.Command, d time.Duration, err error) {
+	c, cerr := currentConfig(home.Home(homePath()))
+	if cerr != nil || cmd == nil || !telemetry.Enabled(c.Telemetry.Mode) {
+		return
+	}
+	telemetry.Send(telemetry.Endpoint(c.Telemetry.Endpoint), telemetry.NewEvent(cmd.CommandPath(), d, err))
+}
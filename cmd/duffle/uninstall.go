@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+func newUninstallCmd(w io.Writer) *cobra.Command {
+	const usage = `Uninstalls an installation.
+
+The invocation image is run with the 'uninstall' action. When it succeeds, the installation's
+claim is removed; when it fails, the failure is recorded in the claim.
+`
+
+	var (
+		driverName      string
+		invocationImage string
+		skipDigestCheck bool
+		values          []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "uninstall NAME",
+		Short: "uninstall an installation",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			claims, err := claimStore()
+			if err != nil {
+				return err
+			}
+			c, err := claims.Read(args[0])
+			if err != nil {
+				return err
+			}
+			d, err := lookupDriver(driverName, skipDigestCheck)
+			if err != nil {
+				return err
+			}
+
+			params, err := resolveParameters(c.Bundle, "uninstall", values, c.Parameters)
+			if err != nil {
+				return err
+			}
+			op, err := newOperation(c, "uninstall", params, d, invocationImage, w)
+			if err != nil {
+				return err
+			}
+
+			if runErr := d.Run(op); runErr != nil {
+				c.Update(op.Action, runErr)
+				if err := claims.Store(c); err != nil {
+					return fmt.Errorf("cannot record claim for %s: %v", c.Name, err)
+				}
+				return runErr
+			}
+			return claims.Delete(c.Name)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&driverName, "driver", "d", "docker", "specify a driver name")
+	flags.StringVar(&invocationImage, "invocation-image", "", "invocation image to run when the bundle declares several")
+	flags.BoolVar(&skipDigestCheck, "skip-digest-check", false, "run the invocation image even if its pinned digest does not match the registry")
+	flags.StringArrayVar(&values, "set", []string{}, "set a parameter value (NAME=VALUE)")
+
+	return cmd
+}
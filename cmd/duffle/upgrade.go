@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/loader"
+)
+
+func newUpgradeCmd(w io.Writer) *cobra.Command {
+	const usage = `Upgrades an existing installation.
+
+The invocation image is run with the 'upgrade' action. By default the installed bundle is
+reused; pass -f to upgrade to a different bundle. Parameter values recorded in the claim are
+reused unless overridden with --set NAME=VALUE.
+
+As with 'duffle install', bundles from the local store or a repository must be signed by a
+key in the public keyring unless --insecure is passed.
+`
+
+	var (
+		bundleFile      string
+		loadOpts        loader.Options
+		driverName      string
+		invocationImage string
+		skipDigestCheck bool
+		values          []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "upgrade NAME",
+		Short: "upgrade an installation",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			claims, err := claimStore()
+			if err != nil {
+				return err
+			}
+			c, err := claims.Read(args[0])
+			if err != nil {
+				return err
+			}
+			if bundleFile != "" {
+				h, src, err := loadBundleHandle(w, bundleFile, loadOpts)
+				if err != nil {
+					return err
+				}
+				c.Bundle, c.Source = h.Bundle, src
+			}
+			d, err := lookupDriver(driverName, skipDigestCheck)
+			if err != nil {
+				return err
+			}
+
+			params, err := resolveParameters(c.Bundle, "upgrade", values, c.Parameters)
+			if err != nil {
+				return err
+			}
+			for name, value := range params {
+				c.Parameters[name] = value
+			}
+			op, err := newOperation(c, "upgrade", params, d, invocationImage, w)
+			if err != nil {
+				return err
+			}
+
+			runErr := d.Run(op)
+			c.Update(op.Action, runErr)
+			if err := claims.Store(c); err != nil {
+				return fmt.Errorf("cannot record claim for %s: %v", c.Name, err)
+			}
+			return runErr
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&bundleFile, "file", "f", "", "path or URL of the bundle file to upgrade to, or - for standard input")
+	addLoaderFlags(flags, &loadOpts)
+	flags.BoolVar(&loadOpts.Insecure, "insecure", false, "upgrade to unsigned bundles and bundles whose signature cannot be verified")
+	flags.StringVarP(&driverName, "driver", "d", "docker", "specify a driver name")
+	flags.StringVar(&invocationImage, "invocation-image", "", "invocation image to run when the bundle declares several")
+	flags.BoolVar(&skipDigestCheck, "skip-digest-check", false, "run the invocation image even if its pinned digest does not match the registry")
+	flags.StringArrayVar(&values, "set", []string{}, "set a parameter value (NAME=VALUE)")
+
+	return cmd
+}
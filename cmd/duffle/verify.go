@@ -0,0 +1,514 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deis/duffle/pkg/attestation"
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/crypto/digest"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/registry"
+	"github.com/deis/duffle/pkg/repo"
+	"github.com/deis/duffle/pkg/signature"
+	"github.com/deis/duffle/pkg/sigstore"
+)
+
+func newVerifyCmd(w io.Writer) *cobra.Command {
+	const usage = `Verifies a bundle and reports who signed it and whether it is trusted.
+
+BUNDLE is a bundle file, a bundle of the local store given as NAME[:VERSION], a bundle of a
+repository given as REPO/NAME[:VERSION], or the URL or registry reference of a bundle.
+The following is checked:
+
+- the signatures of the bundle: signed bundle documents are checked against the public
+  keyring, and other bundles against their provenance file or sigstore bundle;
+- the digest of the bundle document against the entry of the local store's or the
+  repository's index it was resolved through;
+- that the signers satisfy the trust policy for the repository or host the bundle is
+  from, as set with 'duffle key trust';
+- that the registries of the images of the bundle still serve the digests it pins, unless
+  --skip-images is given.
+
+With --provenance, the build provenance attestation written by 'duffle build --sign
+--attest' is checked as well: it must be signed by a key of the public keyring, or with
+sigstore by an identity trusted with 'duffle key trust', and cover the bundle document.
+It is found next to bundle files, as BUNDLE.intoto.jsonl, unless --attestation names it.
+
+The results are printed for people, or as a JSON or YAML object with --output. The
+command fails when any check fails or the bundle is not signed.
+`
+
+	var (
+		provenance bool
+		attPath    string
+		skipImages bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify BUNDLE",
+		Short: "verify the signatures, digests and build provenance of a bundle",
+		Long:  usage,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := newPrinter(w)
+			if err != nil {
+				return err
+			}
+			h := home.Home(homePath())
+			v, path, b, err := verifyBundle(h, args[0])
+			if err != nil {
+				return err
+			}
+			if !skipImages {
+				if err := v.checkImages(h, b); err != nil {
+					return err
+				}
+			}
+			if provenance {
+				if attPath == "" && path != "" {
+					attPath = attestation.Path(path)
+				}
+				if attPath == "" {
+					v.problem("no attestation to check: pass --attestation for bundles that are not files")
+				} else if err := v.checkAttestation(h, attPath); err != nil {
+					return err
+				}
+			}
+			v.Verified = len(v.Problems) == 0
+
+			if err := p.Print(v, func(w io.Writer) error {
+				v.print(w)
+				return nil
+			}); err != nil {
+				return err
+			}
+			if !v.Verified {
+				return fmt.Errorf("%s failed verification", args[0])
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&provenance, "provenance", false, "also verify the build provenance attestation of the bundle")
+	flags.StringVar(&attPath, "attestation", "", "path of the attestation to verify with --provenance (default BUNDLE.intoto.jsonl)")
+	flags.BoolVar(&skipImages, "skip-images", false, "do not check the image digests against their registries")
+
+	return cmd
+}
+
+// verification holds the results of 'duffle verify'
+type verification struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Source is where the bundle was loaded from
+	Source     string `json:"source"`
+	Repository string `json:"repository,omitempty"`
+	// Digest is the digest of the bundle document, and IndexDigest the one recorded in the
+	// index it was resolved through
+	Digest      string               `json:"digest,omitempty"`
+	IndexDigest string               `json:"indexDigest,omitempty"`
+	Signers     []*signature.KeyInfo `json:"signers"`
+	Trust       trustStatus          `json:"trust"`
+	Images      []imageStatus        `json:"images,omitempty"`
+	Attestation *attestationStatus   `json:"attestation,omitempty"`
+	Problems    []string             `json:"problems"`
+	Verified    bool                 `json:"verified"`
+
+	// doc is the bundle document exactly as it was read, which attestations cover
+	doc []byte
+}
+
+// trustStatus tells whether the signers satisfy the trust policy
+type trustStatus struct {
+	// Policy describes the keys the trust policy requires; it is empty when no rule of the
+	// policy applies to the bundle
+	Policy  string `json:"policy,omitempty"`
+	Trusted bool   `json:"trusted"`
+}
+
+// imageStatus tells whether the registry of an image serves its pinned digest
+type imageStatus struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest,omitempty"`
+	// Status is one of verified, missing, error, unpinned or skipped
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// attestationStatus describes a verified build provenance attestation
+type attestationStatus struct {
+	Path           string               `json:"path"`
+	Signers        []*signature.KeyInfo `json:"signers"`
+	Builder        string               `json:"builder"`
+	Source         string               `json:"source,omitempty"`
+	Commit         string               `json:"commit,omitempty"`
+	SourceModified bool                 `json:"sourceModified,omitempty"`
+	Manifest       string               `json:"manifest"`
+	BuiltOn        time.Time            `json:"builtOn"`
+	Images         []attestation.Image  `json:"images,omitempty"`
+}
+
+func (v *verification) problem(format string, args ...interface{}) {
+	v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+}
+
+// verifyBundle loads the bundle ref and verifies its signatures and digest, returning the
+// results, the path of the bundle file when there is one, and the bundle. Bundles whose
+// signature is not valid fail to load.
+func verifyBundle(h home.Home, ref string) (*verification, string, *bundle.Bundle, error) {
+	v := &verification{Source: ref, Signers: []*signature.KeyInfo{}, Problems: []string{}}
+	local, src, err := resolveLocalReference(h, ref)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var (
+		r    *repo.Repository
+		urls []string
+	)
+	if local == "" {
+		if r, src, urls, err = resolveRepoReference(h, ref); err != nil {
+			return nil, "", nil, err
+		}
+	}
+
+	var (
+		path string
+		b    *bundle.Bundle
+	)
+	switch {
+	case local != "" || (r == nil && !loader.IsURL(ref) && !loader.IsRegistryReference(ref)):
+		path = ref
+		if local != "" {
+			path = local
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if b, err = v.checkDocument(h, path, data); err != nil {
+			return nil, "", nil, fmt.Errorf("cannot verify %s: %v", ref, err)
+		}
+	case r != nil && !repo.IsOCIURL(r.URL):
+		v.Repository = r.Name
+		var errs []string
+		for _, u := range urls {
+			if b, err = v.fetchDocument(h, r, u); err == nil {
+				v.Source = stripFragment(u)
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if b == nil {
+			return nil, "", nil, fmt.Errorf("cannot verify %s: %s", ref, strings.Join(errs, "; "))
+		}
+	default:
+		// registries store bundles as plain JSON: there is no signature to check, only the
+		// digest of the manifest
+		u := ref
+		if r != nil {
+			v.Repository = r.Name
+			u = urls[0]
+		}
+		lh, d, err := loadSource(r, u, loader.Options{})
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("cannot verify %s: %v", ref, err)
+		}
+		b, v.Digest, v.Source, v.doc = lh.Bundle, d, u, lh.Document
+		if lh.Signers != nil {
+			v.Signers = lh.Signers
+		}
+	}
+	v.Name, v.Version = b.Name, b.Version
+
+	if src != nil && src.Digest != "" {
+		v.IndexDigest = src.Digest
+		if v.Digest != "" && v.Digest != v.IndexDigest {
+			v.problem("the digest of the bundle, %s, does not match the digest in the index, %s", v.Digest, v.IndexDigest)
+		}
+	}
+	if len(v.Signers) == 0 {
+		v.problem("the bundle is not signed")
+	}
+	v.checkTrust(h, ref, r)
+	return v, path, b, nil
+}
+
+// checkDocument verifies the bundle document data, read from the file at path, returning
+// the bundle
+func (v *verification) checkDocument(h home.Home, path string, data []byte) (*bundle.Bundle, error) {
+	v.doc, v.Digest = data, digest.OfBuffer(data)
+	if signature.IsClearsigned(data) {
+		sl := &loader.SignedLoader{Keyring: h.PublicKeyring()}
+		b, signers, err := sl.LoadSignedData(data)
+		if err != nil {
+			return nil, err
+		}
+		v.Signers = signers
+		return b, nil
+	}
+	signers, err := verifyProvenance(h, path)
+	if err != nil {
+		return nil, err
+	}
+	if signers != nil {
+		v.Signers = signers
+	}
+	return loader.Load(path)
+}
+
+// fetchDocument reads the bundle document at u from the repository r and verifies it.
+// Documents served over HTTP are downloaded again rather than read from the cache of
+// downloaded documents; those of git repositories are read from their checkout.
+func (v *verification) fetchDocument(h home.Home, r *repo.Repository, u string) (*bundle.Bundle, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if loader.IsURL(u) {
+		client, err := r.Client()
+		if err != nil {
+			return nil, err
+		}
+		var cache *loader.Cache
+		data, err = cache.Fetch(client, stripFragment(u))
+	} else {
+		data, err = ioutil.ReadFile(u)
+	}
+	if err != nil {
+		return nil, err
+	}
+	v.doc, v.Digest = data, digest.OfBuffer(data)
+	if signature.IsClearsigned(data) {
+		sl := &loader.SignedLoader{Keyring: h.PublicKeyring()}
+		b, signers, err := sl.LoadSignedData(data)
+		if err != nil {
+			return nil, err
+		}
+		v.Signers = signers
+		return b, nil
+	}
+	return (&loader.URLLoader{}).LoadData(data)
+}
+
+func stripFragment(u string) string {
+	if i := strings.Index(u, "#"); i != -1 {
+		return u[:i]
+	}
+	return u
+}
+
+// checkTrust checks the signers against the trust policy for the bundle loaded from ref,
+// through the repository r if there is one. Bundles no rule applies to, such as local ones,
+// are trusted when they are signed by a key of the public keyring.
+func (v *verification) checkTrust(h home.Home, ref string, r *repo.Repository) {
+	p, err := signature.LoadPolicy(h.TrustPolicy())
+	if err != nil {
+		v.problem("cannot load the trust policy: %v", err)
+		return
+	}
+	name, host := "", sourceHost(ref)
+	if r != nil {
+		name, host = r.Name, sourceHost(r.URL)
+	}
+	rule := p.RuleFor(name, host)
+	if rule == nil {
+		v.Trust.Trusted = len(v.Signers) > 0
+		return
+	}
+	v.Trust.Policy = rule.String()
+	if err := checkTrustPolicy(h, ref, r, v.Signers); err != nil {
+		v.problem("%v", err)
+		return
+	}
+	v.Trust.Trusted = true
+}
+
+// checkImages checks that the registries of the images of b serve the digests b pins
+func (v *verification) checkImages(h home.Home, b *bundle.Bundle) error {
+	var c *registry.Client
+	check := func(image, imageType, d string) error {
+		s := imageStatus{Image: image, Digest: d}
+		switch {
+		case imageType != "" && imageType != "docker" && imageType != "oci":
+			s.Status = "skipped"
+		case d == "":
+			s.Status = "unpinned"
+		default:
+			if c == nil {
+				var err error
+				if c, err = registryClient(h); err != nil {
+					return err
+				}
+			}
+			ref, err := registry.ParseReference(image)
+			if err != nil {
+				return err
+			}
+			ok, err := c.ManifestExists(ref.WithDigest(d))
+			switch {
+			case err != nil:
+				s.Status, s.Error = "error", err.Error()
+				v.problem("cannot check image %s: %v", image, err)
+			case !ok:
+				s.Status = "missing"
+				v.problem("the registry of image %s does not serve its pinned digest %s", image, d)
+			default:
+				s.Status = "verified"
+			}
+		}
+		v.Images = append(v.Images, s)
+		return nil
+	}
+	for _, img := range b.InvocationImages {
+		if err := check(img.Image, img.ImageType, img.Digest); err != nil {
+			return err
+		}
+	}
+	for _, img := range b.Images {
+		if err := check(img.URI, img.ImageType, img.Digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAttestation checks the attestation at path and that it covers the bundle document
+// exactly as it was read
+func (v *verification) checkAttestation(h home.Home, path string) error {
+	env, err := attestation.ReadFile(path)
+	if os.IsNotExist(err) {
+		v.problem("the bundle has no provenance attestation (%s not found)", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var signers []*signature.KeyInfo
+	if len(env.Signatures) > 0 {
+		kr, err := signature.LoadKeyRing(h.PublicKeyring())
+		if err != nil {
+			return err
+		}
+		es, err := env.Verify(signature.NewVerifier(kr))
+		if err != nil {
+			v.problem("%v", err)
+			return nil
+		}
+		signers = signature.Infos(es)
+	} else {
+		if _, err := os.Stat(sigstore.BundlePath(path)); os.IsNotExist(err) {
+			v.problem("the attestation is not signed")
+			return nil
+		}
+		if signers, err = verifyKeyless(h, path); err != nil {
+			v.problem("attestation signature is not valid: %v", err)
+			return nil
+		}
+	}
+	st, err := env.Statement()
+	if err != nil {
+		v.problem("%v", err)
+		return nil
+	}
+	if err := st.Check(v.doc); err != nil {
+		v.problem("%v", err)
+		return nil
+	}
+
+	p := st.Predicate
+	src := p.Invocation.ConfigSource
+	a := &attestationStatus{
+		Path:     path,
+		Signers:  signers,
+		Builder:  p.Builder.ID,
+		Source:   src.URI,
+		Commit:   src.Digest["sha1"],
+		Manifest: src.EntryPoint,
+		BuiltOn:  p.Metadata.BuildFinishedOn,
+		Images:   p.BuildConfig.Images,
+	}
+	a.SourceModified, _ = p.Invocation.Environment["sourceModified"].(bool)
+	v.Attestation = a
+	return nil
+}
+
+// print describes the results for people
+func (v *verification) print(w io.Writer) {
+	fmt.Fprintf(w, "Bundle %s %s\n", v.Name, v.Version)
+	if v.Repository != "" {
+		fmt.Fprintf(w, "  repository: %s\n", v.Repository)
+	}
+	fmt.Fprintf(w, "  source:     %s\n", redactSource(v.Source))
+	switch {
+	case v.Digest != "" && v.IndexDigest == v.Digest:
+		fmt.Fprintf(w, "  digest:     %s (matches the index)\n", v.Digest)
+	case v.Digest != "":
+		fmt.Fprintf(w, "  digest:     %s\n", v.Digest)
+	}
+	for _, s := range v.Signers {
+		fmt.Fprintf(w, "  signed by:  %s\n", s)
+	}
+	switch {
+	case v.Trust.Trusted && v.Trust.Policy != "":
+		fmt.Fprintf(w, "  trust:      trusted (trust policy: %s)\n", v.Trust.Policy)
+	case v.Trust.Trusted:
+		fmt.Fprintf(w, "  trust:      signed by a key of the public keyring; no trust policy applies\n")
+	default:
+		fmt.Fprintf(w, "  trust:      NOT TRUSTED\n")
+	}
+	for _, img := range v.Images {
+		ref := img.Image
+		if img.Digest != "" {
+			ref += "@" + img.Digest
+		}
+		fmt.Fprintf(w, "  image:      %s (%s)\n", ref, img.Status)
+	}
+
+	if a := v.Attestation; a != nil {
+		for _, s := range a.Signers {
+			fmt.Fprintf(w, "Provenance attestation signed by %s\n", s)
+		}
+		fmt.Fprintf(w, "  builder:  %s\n", a.Builder)
+		if a.Commit != "" {
+			fmt.Fprintf(w, "  source:   %s@%s\n", a.Source, a.Commit)
+		} else {
+			fmt.Fprintf(w, "  source:   %s\n", a.Source)
+		}
+		if a.SourceModified {
+			fmt.Fprintf(w, "  WARNING: the bundle was built with uncommitted changes\n")
+		}
+		fmt.Fprintf(w, "  manifest: %s\n", a.Manifest)
+		fmt.Fprintf(w, "  built:    %s\n", a.BuiltOn.Format("2006-01-02 15:04:05 MST"))
+		for _, img := range a.Images {
+			d := img.Digest
+			if d == "" {
+				d = "no digest"
+			}
+			fmt.Fprintf(w, "  image:    %s (%s, %s)\n", img.Ref, d, img.Builder)
+		}
+	}
+
+	for _, p := range v.Problems {
+		fmt.Fprintf(w, "FAILED: %s\n", p)
+	}
+}
+
+// redactSource drops the credentials a source URL may embed
+func redactSource(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.User == nil {
+		return source
+	}
+	u.User = nil
+	return u.String()
+}
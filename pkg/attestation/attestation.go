@@ -0,0 +1,223 @@
+// Package attestation records how bundles were built, as in-toto attestations carrying
+// SLSA provenance: the source the bundle was built from, the builder that built it and
+// the images it produced.
+//
+// Attestations are wrapped in DSSE envelopes, signed with OpenPGP keys, and written as a
+// single line of JSON next to the bundle they describe, in an .intoto.jsonl file.
+package attestation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deis/duffle/pkg/build"
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/crypto/digest"
+)
+
+const (
+	// StatementType is the type of in-toto statements
+	StatementType = "https://in-toto.io/Statement/v0.1"
+	// ProvenanceType is the predicate type of SLSA provenance
+	ProvenanceType = "https://slsa.dev/provenance/v0.2"
+	// BuildType identifies builds run by 'duffle build'
+	BuildType = "https://github.com/deis/duffle/build@v1"
+	// DefaultBuilderID identifies duffle as the builder when no other ID is given
+	DefaultBuilderID = "https://github.com/deis/duffle"
+)
+
+// Path returns the path of the attestation of the bundle file at path
+func Path(path string) string {
+	return path + ".intoto.jsonl"
+}
+
+// DigestSet maps digest algorithms to hex-encoded digests
+type DigestSet map[string]string
+
+// Statement is an in-toto statement that its subjects were produced as its predicate
+// describes
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+// Subject is an artifact produced by the build
+type Subject struct {
+	Name   string    `json:"name"`
+	Digest DigestSet `json:"digest"`
+}
+
+// Provenance is a SLSA provenance predicate
+type Provenance struct {
+	Builder     Builder     `json:"builder"`
+	BuildType   string      `json:"buildType"`
+	Invocation  Invocation  `json:"invocation"`
+	BuildConfig BuildConfig `json:"buildConfig"`
+	Metadata    Metadata    `json:"metadata"`
+	Materials   []Material  `json:"materials,omitempty"`
+}
+
+// Builder identifies what ran the build
+type Builder struct {
+	ID string `json:"id"`
+}
+
+// Invocation describes how the build was started
+type Invocation struct {
+	ConfigSource ConfigSource           `json:"configSource"`
+	Parameters   map[string]string      `json:"parameters,omitempty"`
+	Environment  map[string]interface{} `json:"environment,omitempty"`
+}
+
+// ConfigSource locates the manifest the build was run from
+type ConfigSource struct {
+	URI        string    `json:"uri,omitempty"`
+	Digest     DigestSet `json:"digest,omitempty"`
+	EntryPoint string    `json:"entryPoint"`
+}
+
+// BuildConfig lists how each image of the bundle was built
+type BuildConfig struct {
+	Images []Image `json:"images,omitempty"`
+}
+
+// Image describes the build of an image of the bundle
+type Image struct {
+	Name    string `json:"name"`
+	Ref     string `json:"ref"`
+	Digest  string `json:"digest,omitempty"`
+	Builder string `json:"builder"`
+	// Inputs is the digest of the image's build context, Dockerfile and build options
+	Inputs string `json:"inputs"`
+	// Reused is set when the image was not rebuilt, its inputs being unchanged
+	Reused bool `json:"reused,omitempty"`
+}
+
+// Metadata records when the build ran
+type Metadata struct {
+	BuildStartedOn  time.Time `json:"buildStartedOn"`
+	BuildFinishedOn time.Time `json:"buildFinishedOn"`
+	Reproducible    bool      `json:"reproducible"`
+}
+
+// Material is an input of the build
+type Material struct {
+	URI    string    `json:"uri"`
+	Digest DigestSet `json:"digest,omitempty"`
+}
+
+// Build describes a run of 'duffle build'
+type Build struct {
+	// BuilderID identifies what ran the build, such as a CI workflow; DefaultBuilderID
+	// when empty
+	BuilderID string
+	// Source is the checkout the bundle was built from
+	Source *Source
+	// Manifest is the path of duffle.toml within Source
+	Manifest string
+	// Parameters are the options the build was run with
+	Parameters map[string]string
+	// Record describes how the images were built
+	Record *build.Record
+	// Started and Finished are when the build ran
+	Started, Finished time.Time
+}
+
+// NewStatement returns the statement that the bundle documents docs, each holding b as it
+// was written, stored or pushed, and the images b refers to were produced by the build bd.
+// Every distinct document is a subject, as the digest of each covers its exact bytes.
+func NewStatement(b *bundle.Bundle, docs [][]byte, bd Build) *Statement {
+	st := &Statement{
+		Type:          StatementType,
+		PredicateType: ProvenanceType,
+		Predicate: Provenance{
+			Builder:   Builder{ID: bd.BuilderID},
+			BuildType: BuildType,
+			Invocation: Invocation{
+				ConfigSource: ConfigSource{EntryPoint: bd.Manifest},
+				Parameters:   bd.Parameters,
+			},
+			Metadata: Metadata{
+				BuildStartedOn:  bd.Started.UTC().Truncate(time.Second),
+				BuildFinishedOn: bd.Finished.UTC().Truncate(time.Second),
+			},
+		},
+	}
+	seen := map[string]bool{}
+	for _, doc := range docs {
+		if d := digest.OfBuffer(doc); !seen[d] {
+			seen[d] = true
+			st.Subject = append(st.Subject, Subject{Name: SubjectName(b), Digest: digestSet(d)})
+		}
+	}
+	if st.Predicate.Builder.ID == "" {
+		st.Predicate.Builder.ID = DefaultBuilderID
+	}
+	if s := bd.Source; s != nil {
+		st.Predicate.Invocation.ConfigSource.URI = s.URI
+		if s.Commit != "" {
+			st.Predicate.Invocation.ConfigSource.Digest = DigestSet{"sha1": s.Commit}
+			st.Predicate.Materials = append(st.Predicate.Materials, Material{URI: s.URI, Digest: DigestSet{"sha1": s.Commit}})
+		}
+		if s.Modified {
+			// the build also depends on changes that were never committed
+			st.Predicate.Invocation.Environment = map[string]interface{}{"sourceModified": true}
+		}
+	}
+	if bd.Record != nil {
+		for _, img := range bd.Record.Images {
+			st.Predicate.BuildConfig.Images = append(st.Predicate.BuildConfig.Images, Image{
+				Name:    img.Name,
+				Ref:     img.Ref,
+				Digest:  img.Digest,
+				Builder: img.Builder,
+				Inputs:  img.Inputs,
+				Reused:  img.Reused,
+			})
+			if img.Digest != "" {
+				st.Subject = append(st.Subject, Subject{Name: img.Ref, Digest: digestSet(img.Digest)})
+			}
+		}
+	}
+	return st
+}
+
+// SubjectName names the bundle b among the subjects of a statement
+func SubjectName(b *bundle.Bundle) string {
+	return b.Name + ":" + b.Version
+}
+
+// digestSet converts a digest of the form ALGORITHM:HEX
+func digestSet(d string) DigestSet {
+	parts := strings.SplitN(d, ":", 2)
+	if len(parts) != 2 {
+		return DigestSet{digest.Algorithm: d}
+	}
+	return DigestSet{parts[0]: parts[1]}
+}
+
+// Check verifies that the statement is SLSA provenance for the bundle document doc
+func (st *Statement) Check(doc []byte) error {
+	if st.Type != StatementType {
+		return fmt.Errorf("unsupported statement type %q", st.Type)
+	}
+	if st.PredicateType != ProvenanceType {
+		return fmt.Errorf("unsupported predicate type %q", st.PredicateType)
+	}
+	d := digestSet(digest.OfBuffer(doc))
+	for _, s := range st.Subject {
+		if s.Digest[digest.Algorithm] == d[digest.Algorithm] {
+			return nil
+		}
+	}
+	names := make([]string, len(st.Subject))
+	for i, s := range st.Subject {
+		names[i] = s.Name
+	}
+	sort.Strings(names)
+	return fmt.Errorf("the attestation does not cover this bundle (it covers %s)", strings.Join(names, ", "))
+}
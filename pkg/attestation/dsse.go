@@ -0,0 +1,126 @@
+package attestation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// PayloadType is the DSSE payload type of in-toto statements
+const PayloadType = "application/vnd.in-toto+json"
+
+// Envelope is a DSSE envelope carrying a signed statement
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     []byte              `json:"payload"`
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+// EnvelopeSignature is a binary OpenPGP signature of the envelope's payload
+type EnvelopeSignature struct {
+	// KeyID is the fingerprint of the signing key
+	KeyID string `json:"keyid"`
+	Sig   []byte `json:"sig"`
+}
+
+// pae returns the DSSE pre-authentication encoding of payload, which is what is signed
+func pae(payloadType string, payload []byte) []byte {
+	return append([]byte(fmt.Sprintf("DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))), payload...)
+}
+
+// Seal wraps st in an envelope, signed by s unless s is nil
+func Seal(st *Statement, s *signature.Signer) (*Envelope, error) {
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return nil, err
+	}
+	e := &Envelope{PayloadType: PayloadType, Payload: payload, Signatures: []EnvelopeSignature{}}
+	if s == nil {
+		return e, nil
+	}
+	sig, err := s.DetachSignBinary(pae(e.PayloadType, e.Payload))
+	if err != nil {
+		return nil, err
+	}
+	e.Signatures = append(e.Signatures, EnvelopeSignature{KeyID: s.Info().Fingerprint, Sig: sig})
+	return e, nil
+}
+
+// Verify checks the signatures of the envelope, returning the keys whose signature
+// verified. It fails when none did.
+func (e *Envelope) Verify(v *signature.Verifier) ([]*openpgp.Entity, error) {
+	if len(e.Signatures) == 0 {
+		return nil, errors.New("the attestation is not signed")
+	}
+	var (
+		signers []*openpgp.Entity
+		err     error
+	)
+	for _, s := range e.Signatures {
+		signer, serr := v.VerifyDetachedBinary(pae(e.PayloadType, e.Payload), s.Sig)
+		if serr != nil {
+			err = serr
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("attestation signature is not valid: %v", err)
+	}
+	return signers, nil
+}
+
+// Statement decodes the statement carried by the envelope
+func (e *Envelope) Statement() (*Statement, error) {
+	if e.PayloadType != PayloadType {
+		return nil, fmt.Errorf("unsupported payload type %q", e.PayloadType)
+	}
+	st := &Statement{}
+	if err := json.Unmarshal(e.Payload, st); err != nil {
+		return nil, fmt.Errorf("cannot parse the attestation: %v", err)
+	}
+	return st, nil
+}
+
+// WriteFile saves the envelope to path, as a single line of JSON
+func (e *Envelope) WriteFile(path string) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadFile reads the envelope at path, the first one when it holds several lines
+func ReadFile(path string) (*Envelope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 16<<20)
+	for s.Scan() {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		e := &Envelope{}
+		if err := json.Unmarshal(line, e); err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+		}
+		return e, nil
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%s holds no attestation", path)
+}
@@ -0,0 +1,63 @@
+package attestation
+
+import (
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitCommand is the git executable used to describe the source of builds
+var GitCommand = "git"
+
+// Source is the checkout a bundle was built from
+type Source struct {
+	// URI locates the source: its git remote, or the directory outside of git
+	URI string
+	// Commit is the git commit checked out, if any
+	Commit string
+	// Prefix is the directory built, relative to the root of the checkout
+	Prefix string
+	// Modified is set when tracked files of the checkout have uncommitted changes
+	Modified bool
+}
+
+// DetectSource describes the git checkout holding dir, or dir itself when it is not part
+// of one or git is missing
+func DetectSource(dir string) *Source {
+	commit, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			abs = dir
+		}
+		return &Source{URI: "file://" + filepath.ToSlash(abs)}
+	}
+	s := &Source{Commit: commit}
+	s.Prefix, _ = git(dir, "rev-parse", "--show-prefix")
+	// untracked files are left out, as builds write their outputs into the checkout
+	if status, err := git(dir, "status", "--porcelain", "--untracked-files=no"); err == nil && status != "" {
+		s.Modified = true
+	}
+	if remote, err := git(dir, "config", "--get", "remote.origin.url"); err == nil && remote != "" {
+		s.URI = "git+" + redactURL(remote)
+	} else if top, err := git(dir, "rev-parse", "--show-toplevel"); err == nil {
+		s.URI = "git+file://" + filepath.ToSlash(top)
+	}
+	return s
+}
+
+// redactURL drops the credentials a remote URL may embed
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return u.String()
+}
+
+func git(dir string, args ...string) (string, error) {
+	out, err := exec.Command(GitCommand, append([]string{"-C", dir}, args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,194 @@
+// Package build turns a bundle project into a bundle: the images described by the
+// project's duffle.toml are built with Docker or kaniko, and a bundle referring to them is generated.
+package build
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/manifest"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+// Options control how a project is built
+type Options struct {
+	// Out receives the output of the builds
+	Out io.Writer
+	// Cache remembers the inputs of previous builds; images whose inputs did not change
+	// since they were last built are not built again
+	Cache *Cache
+	// Force builds every image, even unchanged ones
+	Force bool
+	// Registry, when set, resolves the digests of images pushed to their registry that
+	// the builder does not know, so that they are recorded in the bundle
+	Registry *registry.Client
+	// Push pushes every image to its registry once built, so that the bundle records
+	// their digests
+	Push bool
+	// Record, when set, receives a description of how each image was built
+	Record *Record
+}
+
+// Record describes the images of a build, for build provenance
+type Record struct {
+	Images []ImageRecord
+}
+
+// ImageRecord describes how an image of the bundle was built
+type ImageRecord struct {
+	// Name is the name of the image in the manifest
+	Name string
+	// Ref is the reference the image was tagged as
+	Ref string
+	// Digest is the registry digest of the image, if known
+	Digest string
+	// Builder identifies the builder and where it ran
+	Builder string
+	// Inputs is the digest of the build context, the Dockerfile and the build options
+	Inputs string
+	// Reused is set when the image was not built again, being unchanged since its last build
+	Reused bool
+}
+
+func (r *Record) add(img ImageRecord) {
+	if r != nil {
+		r.Images = append(r.Images, img)
+	}
+}
+
+// Build builds the images of the project in dir, described by m, and returns the bundle
+// referring to them: invocation images, and components as the bundle's images. Images are
+// tagged with the bundle's version. The bundle's parameters and credentials are those of
+// the manifest, along with those declared by annotations in the invocation images' files.
+func Build(dir string, m *manifest.Manifest, opts Options) (*bundle.Bundle, error) {
+	b := &bundle.Bundle{
+		SchemaVersion: bundle.SchemaVersion,
+		Name:          m.Name,
+		Version:       m.Version,
+		Description:   m.Description,
+	}
+	decls := newDeclarations()
+	e := newEngine(m.Build)
+	for _, name := range m.InvocationImageNames() {
+		img := m.InvocationImages[name]
+		if err := decls.scanImage(img.Context(dir, name), img); err != nil {
+			return nil, fmt.Errorf("cannot read the declarations of %s: %v", name, err)
+		}
+		ref, digest, err := buildImage(e, dir, m, name, img, opts)
+		if err != nil {
+			return nil, err
+		}
+		b.InvocationImages = append(b.InvocationImages, bundle.InvocationImage{
+			ImageType: "docker",
+			Image:     ref,
+			Digest:    digest,
+			OS:        img.OS,
+			Arch:      img.Arch,
+		})
+	}
+	b.Parameters, b.Credentials = decls.merge(m.Parameters, m.Credentials)
+	for _, name := range m.ComponentNames() {
+		img := m.Components[name]
+		ref, digest, err := buildImage(e, dir, m, name, img, opts)
+		if err != nil {
+			return nil, err
+		}
+		b.Images = append(b.Images, bundle.Image{
+			Name:      name,
+			URI:       ref,
+			ImageType: "docker",
+			Digest:    digest,
+			Refs:      img.Refs,
+		})
+	}
+	return b, nil
+}
+
+// buildImage builds the image called name with e, unless it is unchanged since its last
+// build, and returns the reference it was tagged as and its registry digest, if known.
+// Digests unknown to e are resolved with opts.Registry.
+func buildImage(e engine, dir string, m *manifest.Manifest, name string, img *manifest.Image, opts Options) (string, string, error) {
+	if opts.Push && !m.Pushable(img) {
+		return "", "", fmt.Errorf("cannot push %s: neither the manifest nor the image sets a registry", name)
+	}
+	rec, err := buildOrSkip(e, dir, m, name, img, opts)
+	if err != nil {
+		return "", "", err
+	}
+	if opts.Push {
+		fmt.Fprintf(opts.Out, "Pushing %s\n", rec.Ref)
+		if rec.Digest, err = e.push(rec.Ref, rec.Digest); err != nil {
+			return "", "", fmt.Errorf("cannot push %s: %v", name, err)
+		}
+	}
+	// images tagged without a registry are local to the daemon and were never pushed
+	if rec.Digest == "" && opts.Registry != nil && m.Pushable(img) {
+		rec.Digest, err = e.resolve(opts.Registry, rec.Ref)
+		if err != nil {
+			fmt.Fprintf(opts.Out, "Cannot resolve the digest of %s, leaving it out of the bundle: %v\n", rec.Ref, err)
+		}
+	}
+	opts.Record.add(rec)
+	return rec.Ref, rec.Digest, nil
+}
+
+func buildOrSkip(e engine, dir string, m *manifest.Manifest, name string, img *manifest.Image, opts Options) (ImageRecord, error) {
+	if len(img.Secrets) > 0 && !m.Build.BuildKit && m.Build.Kaniko == nil {
+		return ImageRecord{}, fmt.Errorf("image %s uses build secrets, which require BuildKit", name)
+	}
+	context := img.Context(dir, name)
+	ref := m.Repository(name, img) + ":" + Tag(m.Version)
+	bo := docker.BuildOptions{
+		Context:  context,
+		Tag:      ref,
+		Args:     img.Args,
+		Target:   img.Target,
+		Labels:   img.Labels,
+		BuildKit: m.Build.BuildKit,
+		Secrets:  map[string]string{},
+	}
+	if img.UsesDockerfile() {
+		bo.Dockerfile = img.DockerfilePath(context)
+	}
+	for id, src := range img.Secrets {
+		bo.Secrets[id] = filepath.Join(dir, filepath.FromSlash(src))
+	}
+	if img.OS != "" && img.Arch != "" {
+		bo.Platform = img.OS + "/" + img.Arch
+	}
+
+	hash, err := inputsHash(bo)
+	if err != nil {
+		return ImageRecord{}, fmt.Errorf("cannot read the build context of %s: %v", name, err)
+	}
+	b, err := newBuilder(img, e)
+	if err != nil {
+		return ImageRecord{}, fmt.Errorf("cannot build %s: %v", name, err)
+	}
+	rec := ImageRecord{Name: name, Ref: ref, Builder: b.Name(), Inputs: hash}
+	if c, ok := opts.Cache.get(name); !opts.Force && ok && c.Hash == hash && c.Ref == ref && c.Engine == b.Name() {
+		if digest, ok := e.lookup(ref, c.Digest); ok {
+			fmt.Fprintf(opts.Out, "Skipping %s: unchanged since it was built as %s\n", name, ref)
+			rec.Digest, rec.Reused = digest, true
+			return rec, nil
+		}
+	}
+	fmt.Fprintf(opts.Out, "Building %s from %s\n", ref, context)
+	if rec.Digest, err = b.Build(bo, opts.Out); err != nil {
+		return ImageRecord{}, fmt.Errorf("cannot build %s: %v", name, err)
+	}
+	if err := opts.Cache.put(name, cacheEntry{Hash: hash, Ref: ref, Engine: b.Name(), Digest: rec.Digest}); err != nil {
+		return ImageRecord{}, err
+	}
+	return rec, nil
+}
+
+// Tag returns the image tag for a bundle version. Build metadata is kept, with the plus
+// sign replaced by an underscore since tags cannot contain it.
+func Tag(version string) string {
+	return strings.Replace(version, "+", "_", -1)
+}
@@ -0,0 +1,140 @@
+package build
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/manifest"
+)
+
+// Pack is the buildpacks CLI used by the buildpacks builder
+var Pack = "pack"
+
+// Ko is the ko executable used by the ko builder
+var Ko = "ko"
+
+// defaultBuilderImage is the buildpacks builder used when an image names none
+const defaultBuilderImage = "heroku/buildpacks:18"
+
+// Builder produces the image of a project from its sources
+type Builder interface {
+	// Name identifies the builder and how it is configured, so that images are built again
+	// when it changes
+	Name() string
+	// Build builds the image described by opts from opts.Context, tags it as opts.Tag, and
+	// returns its registry digest, if known
+	Build(opts docker.BuildOptions, out io.Writer) (string, error)
+}
+
+// newBuilder returns the builder selected by img, building Dockerfiles with e
+func newBuilder(img *manifest.Image, e engine) (Builder, error) {
+	switch img.Builder {
+	case "", manifest.BuilderDockerfile:
+		return dockerfileBuilder{e}, nil
+	}
+	if _, ok := e.(dockerEngine); !ok {
+		return nil, fmt.Errorf("the %s builder needs a Docker daemon, and cannot be used with kaniko", img.Builder)
+	}
+	switch img.Builder {
+	case manifest.BuilderBuildpacks:
+		image := img.BuilderImage
+		if image == "" {
+			image = defaultBuilderImage
+		}
+		return buildpacksBuilder{image: image}, nil
+	case manifest.BuilderKo:
+		pkg := img.Package
+		if pkg == "" {
+			pkg = "."
+		}
+		return koBuilder{pkg: pkg}, nil
+	}
+	return nil, fmt.Errorf("unknown builder %q", img.Builder)
+}
+
+// dockerfileBuilder builds images from Dockerfiles with an engine
+type dockerfileBuilder struct {
+	e engine
+}
+
+func (b dockerfileBuilder) Name() string {
+	return b.e.name()
+}
+
+func (b dockerfileBuilder) Build(opts docker.BuildOptions, out io.Writer) (string, error) {
+	return b.e.build(opts, out)
+}
+
+// buildpacksBuilder builds images with Cloud Native Buildpacks, detecting how to build the
+// sources, into the Docker daemon. Build arguments are passed to the buildpacks as
+// environment variables.
+type buildpacksBuilder struct {
+	image string
+}
+
+func (b buildpacksBuilder) Name() string {
+	return "buildpacks " + b.image + " " + docker.Host
+}
+
+func (b buildpacksBuilder) Build(opts docker.BuildOptions, out io.Writer) (string, error) {
+	args := []string{"build", opts.Tag, "--path", opts.Context, "--builder", b.image}
+	for _, k := range sortedKeys(opts.Args) {
+		args = append(args, "--env", k+"="+opts.Args[k])
+	}
+	cmd := daemonCommand(Pack, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("pack: %v", err)
+	}
+	return digestOf(opts.Tag), nil
+}
+
+// koBuilder builds Go programs into images with ko, without a Dockerfile, into the Docker
+// daemon
+type koBuilder struct {
+	pkg string
+}
+
+func (b koBuilder) Name() string {
+	return "ko " + b.pkg + " " + docker.Host
+}
+
+func (b koBuilder) Build(opts docker.BuildOptions, out io.Writer) (string, error) {
+	args := []string{"publish", "--local", b.pkg}
+	if opts.Platform != "" {
+		args = append(args, "--platform", opts.Platform)
+	}
+	var stdout bytes.Buffer
+	cmd := daemonCommand(Ko, args...)
+	cmd.Dir = opts.Context
+	cmd.Stdout = &stdout
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ko: %v", err)
+	}
+	// ko prints the reference of the image it built last
+	lines := strings.Fields(stdout.String())
+	if len(lines) == 0 {
+		return "", errors.New("ko did not report the image it built")
+	}
+	if err := docker.Tag(lines[len(lines)-1], opts.Tag); err != nil {
+		return "", err
+	}
+	return digestOf(opts.Tag), nil
+}
+
+// daemonCommand prepares a command using the Docker daemon duffle is configured with
+func daemonCommand(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	if docker.Host != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_HOST="+docker.Host)
+	}
+	return cmd
+}
@@ -0,0 +1,192 @@
+package build
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/docker"
+)
+
+// Cache remembers the content hashes of the build contexts images were last built from,
+// so that images whose inputs did not change are not built again. A nil Cache remembers
+// nothing.
+type Cache struct {
+	path string
+}
+
+// cacheEntry records the last build of an image
+type cacheEntry struct {
+	// Hash covers the build context, the Dockerfile and the build options
+	Hash string `json:"hash"`
+	// Ref is the reference the image was tagged as
+	Ref string `json:"ref"`
+	// Engine identifies where the image was built
+	Engine string `json:"engine"`
+	// Digest is the registry digest of the image, if known
+	Digest string `json:"digest,omitempty"`
+}
+
+// NewCache returns the cache of the project in dir, stored in the directory cacheDir
+func NewCache(cacheDir, dir string) (*Cache, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return &Cache{path: filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".json")}, nil
+}
+
+func (c *Cache) load() map[string]cacheEntry {
+	entries := map[string]cacheEntry{}
+	if c == nil {
+		return entries
+	}
+	if data, err := ioutil.ReadFile(c.path); err == nil {
+		json.Unmarshal(data, &entries)
+	}
+	return entries
+}
+
+// get returns the record of the last build of the image called name
+func (c *Cache) get(name string) (cacheEntry, bool) {
+	e, ok := c.load()[name]
+	return e, ok
+}
+
+func (c *Cache) put(name string, e cacheEntry) error {
+	if c == nil {
+		return nil
+	}
+	entries := c.load()
+	entries[name] = e
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(c.path, data, 0644)
+}
+
+// inputsHash returns a digest of everything the build described by opts depends on: the
+// files of the build context that .dockerignore does not exclude, the Dockerfile if there
+// is one, and the build options themselves
+func inputsHash(opts docker.BuildOptions) (string, error) {
+	h := sha256.New()
+	o, err := json.Marshal(opts)
+	if err != nil {
+		return "", err
+	}
+	h.Write(o)
+
+	if opts.Dockerfile != "" {
+		if err := copyFile(h, opts.Dockerfile); err != nil {
+			return "", err
+		}
+	}
+
+	ignore, err := readDockerignore(opts.Context)
+	if err != nil {
+		return "", err
+	}
+	err = filepath.Walk(opts.Context, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(opts.Context, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if ignored(rel, ignore) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fmt.Fprintf(h, "%s %o\n", rel, info.Mode())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(h, target)
+		case info.Mode().IsRegular():
+			return copyFile(h, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFile writes the content of the file at path to w
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// readDockerignore returns the patterns of the .dockerignore file of a build context
+func readDockerignore(context string) ([]string, error) {
+	f, err := os.Open(filepath.Join(context, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, s.Err()
+}
+
+// ignored reports whether the path rel, relative to the build context, is excluded by
+// the .dockerignore patterns. As with docker, the last matching pattern wins, and
+// patterns starting with ! include paths again.
+func ignored(rel string, patterns []string) bool {
+	excluded := false
+	for _, p := range patterns {
+		include := strings.HasPrefix(p, "!")
+		p = strings.Trim(filepath.ToSlash(strings.TrimPrefix(p, "!")), "/")
+		if matchesPath(p, rel) {
+			excluded = !include
+		}
+	}
+	return excluded
+}
+
+// matchesPath reports whether pattern matches rel or one of its parent directories
+func matchesPath(pattern, rel string) bool {
+	for p := rel; p != "."; p = filepath.ToSlash(filepath.Dir(p)) {
+		if ok, _ := filepath.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package build
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/manifest"
+)
+
+// defaultDeclarations is the file of an invocation image's build context scanned for
+// declarations when the manifest names none: the run script of the bundle
+const defaultDeclarations = "app/run"
+
+// annotationPrefix starts the comments declaring parameters and credentials, such as
+//
+//	# duffle:parameter port type=int default=8080 description="port to listen on"
+//	# duffle:credential kubeconfig path=/root/.kube/config
+const annotationPrefix = "duffle:"
+
+// declarations collects the parameters and credentials declared by annotations in the
+// files of the invocation images' build contexts
+type declarations struct {
+	parameters  map[string]bundle.ParameterDefinition
+	credentials map[string]bundle.CredentialLocation
+	// origins records where each declaration was found, for error messages
+	origins map[string]string
+}
+
+func newDeclarations() *declarations {
+	return &declarations{
+		parameters:  map[string]bundle.ParameterDefinition{},
+		credentials: map[string]bundle.CredentialLocation{},
+		origins:     map[string]string{},
+	}
+}
+
+// scanImage reads the declarations of the invocation image img, built from context
+func (d *declarations) scanImage(context string, img *manifest.Image) error {
+	files := img.Declarations
+	if len(files) == 0 {
+		if _, err := os.Stat(filepath.Join(context, filepath.FromSlash(defaultDeclarations))); err != nil {
+			return nil
+		}
+		files = []string{defaultDeclarations}
+	}
+	for _, f := range files {
+		if err := d.scanFile(filepath.Join(context, filepath.FromSlash(f))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *declarations) scanFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		for _, c := range []string{"#", "//", "--", ";"} {
+			if strings.HasPrefix(line, c) {
+				line = strings.TrimSpace(strings.TrimLeft(line, c))
+				break
+			}
+		}
+		if !strings.HasPrefix(line, annotationPrefix) {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d", path, n)
+		if err := d.parse(strings.TrimPrefix(line, annotationPrefix), where); err != nil {
+			return fmt.Errorf("%s: %v", where, err)
+		}
+	}
+	return s.Err()
+}
+
+// merge returns the parameters and credentials declared by the manifest, completed with
+// the annotated declarations. The manifest's definitions take precedence.
+func (d *declarations) merge(params map[string]bundle.ParameterDefinition, creds map[string]bundle.CredentialLocation) (map[string]bundle.ParameterDefinition, map[string]bundle.CredentialLocation) {
+	if len(d.parameters) > 0 {
+		merged := map[string]bundle.ParameterDefinition{}
+		for name, p := range d.parameters {
+			merged[name] = p
+		}
+		for name, p := range params {
+			merged[name] = p
+		}
+		params = merged
+	}
+	if len(d.credentials) > 0 {
+		merged := map[string]bundle.CredentialLocation{}
+		for name, c := range d.credentials {
+			merged[name] = c
+		}
+		for name, c := range creds {
+			merged[name] = c
+		}
+		creds = merged
+	}
+	return params, creds
+}
+
+// parse reads an annotation of the form KIND NAME KEY=VALUE...
+func (d *declarations) parse(annotation, where string) error {
+	fields, err := splitFields(annotation)
+	if err != nil {
+		return err
+	}
+	if len(fields) < 2 {
+		return fmt.Errorf("expected %sparameter or %scredential followed by a name", annotationPrefix, annotationPrefix)
+	}
+	kind, name := fields[0], fields[1]
+	attrs := map[string]string{}
+	for _, f := range fields[2:] {
+		parts := strings.SplitN(f, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("malformed attribute %q, expected KEY=VALUE", f)
+		}
+		attrs[parts[0]] = parts[1]
+	}
+
+	switch kind {
+	case "parameter":
+		p, err := parameterFromAttrs(attrs)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %v", name, err)
+		}
+		if prev, ok := d.parameters[name]; ok && !reflect.DeepEqual(prev, p) {
+			return fmt.Errorf("parameter %s is already declared differently at %s", name, d.origins["parameter "+name])
+		}
+		d.parameters[name] = p
+	case "credential":
+		c := bundle.CredentialLocation{Path: attrs["path"], EnvironmentVariable: attrs["env"]}
+		delete(attrs, "path")
+		delete(attrs, "env")
+		for k := range attrs {
+			return fmt.Errorf("credential %s: unknown attribute %s", name, k)
+		}
+		if c.Path == "" && c.EnvironmentVariable == "" {
+			return fmt.Errorf("credential %s: expected path= or env=", name)
+		}
+		if prev, ok := d.credentials[name]; ok && prev != c {
+			return fmt.Errorf("credential %s is already declared differently at %s", name, d.origins["credential "+name])
+		}
+		d.credentials[name] = c
+	default:
+		return fmt.Errorf("unknown annotation %s%s", annotationPrefix, kind)
+	}
+	d.origins[kind+" "+name] = where
+	return nil
+}
+
+func parameterFromAttrs(attrs map[string]string) (bundle.ParameterDefinition, error) {
+	p := bundle.ParameterDefinition{DataType: "string"}
+	for k, v := range attrs {
+		switch k {
+		case "type":
+			p.DataType = v
+		case "default", "allowed":
+		case "applyTo":
+			p.ApplyTo = strings.Split(v, ",")
+		case "description":
+			p.Metadata = &bundle.ParameterMetadata{Description: v}
+		default:
+			return p, fmt.Errorf("unknown attribute %s", k)
+		}
+	}
+	if v, ok := attrs["default"]; ok {
+		value, err := p.ConvertValue(v)
+		if err != nil {
+			return p, fmt.Errorf("default: %v", err)
+		}
+		p.DefaultValue = value
+	}
+	if v, ok := attrs["allowed"]; ok {
+		for _, a := range strings.Split(v, ",") {
+			value, err := p.ConvertValue(a)
+			if err != nil {
+				return p, fmt.Errorf("allowed: %v", err)
+			}
+			p.AllowedValues = append(p.AllowedValues, value)
+		}
+	}
+	if p.DefaultValue != nil {
+		if err := p.ValidateValue(p.DefaultValue); err != nil {
+			return p, fmt.Errorf("default: %v", err)
+		}
+	}
+	return p, nil
+}
+
+// splitFields splits s at spaces, except within double-quoted strings, which are unquoted
+func splitFields(s string) ([]string, error) {
+	var (
+		fields []string
+		field  strings.Builder
+		quoted bool
+		start  int
+	)
+	flush := func() {
+		if field.Len() > 0 {
+			fields = append(fields, field.String())
+			field.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' && !quoted:
+			quoted, start = true, i
+		case c == '\\' && quoted && i+1 < len(s):
+			i++
+		case c == '"' && quoted:
+			v, err := strconv.Unquote(s[start : i+1])
+			if err != nil {
+				return nil, fmt.Errorf("malformed string %s", s[start:i+1])
+			}
+			field.WriteString(v)
+			quoted = false
+		case !quoted && unicode.IsSpace(rune(c)):
+			flush()
+		case !quoted:
+			field.WriteByte(c)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated string %s", s[start:])
+	}
+	flush()
+	return fields, nil
+}
@@ -0,0 +1,81 @@
+package build
+
+import (
+	"io"
+
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/manifest"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+// engine runs image builds
+type engine interface {
+	// name identifies where images are built, so that changing it builds them again
+	name() string
+	// build builds the image described by opts and returns its registry digest, if known
+	build(opts docker.BuildOptions, out io.Writer) (string, error)
+	// lookup reports whether the image built as ref is still available, and returns its
+	// registry digest if known. cached is the digest recorded when it was built.
+	lookup(ref, cached string) (string, bool)
+	// push pushes the image built as ref to its registry, unless the engine already did,
+	// and returns its registry digest. digest is the digest known so far, if any.
+	push(ref, digest string) (string, error)
+	// resolve returns the digest of the image built as ref in its registry, if it was
+	// pushed there
+	resolve(c *registry.Client, ref string) (string, error)
+}
+
+// newEngine returns the engine selected by the build configuration: kaniko, or a local or
+// remote Docker daemon
+func newEngine(c manifest.BuildConfig) engine {
+	if c.Kaniko != nil {
+		return kanikoEngine{config: *c.Kaniko}
+	}
+	if c.Host != "" {
+		docker.Host = c.Host
+	}
+	return dockerEngine{host: c.Host}
+}
+
+// dockerEngine builds images with a Docker daemon, where they are kept
+type dockerEngine struct {
+	host string
+}
+
+func (e dockerEngine) name() string {
+	if e.host == "" {
+		return "docker"
+	}
+	return "docker " + e.host
+}
+
+func (dockerEngine) build(opts docker.BuildOptions, out io.Writer) (string, error) {
+	if err := docker.Build(opts, out); err != nil {
+		return "", err
+	}
+	return digestOf(opts.Tag), nil
+}
+
+func (dockerEngine) push(ref, digest string) (string, error) {
+	if err := docker.Push(ref); err != nil {
+		return "", err
+	}
+	return docker.Digest(ref)
+}
+
+func (dockerEngine) lookup(ref, cached string) (string, bool) {
+	if !docker.ImageExists(ref) {
+		return "", false
+	}
+	return digestOf(ref), true
+}
+
+// digestOf returns the registry digest of the image built as ref, which is only known when
+// an identical image was pushed before
+func digestOf(ref string) string {
+	d, err := docker.Digest(ref)
+	if err != nil {
+		return ""
+	}
+	return d
+}
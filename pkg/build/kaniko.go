@@ -0,0 +1,228 @@
+package build
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/manifest"
+)
+
+// Kubectl is the kubectl executable used to run kaniko in a cluster
+var Kubectl = "kubectl"
+
+const (
+	defaultKanikoExecutor = "/kaniko/executor"
+	defaultKanikoImage    = "gcr.io/kaniko-project/executor:latest"
+)
+
+// kanikoEngine builds images with kaniko, without a Docker daemon. Images are pushed to
+// their registry as they are built.
+type kanikoEngine struct {
+	config manifest.KanikoConfig
+}
+
+func (k kanikoEngine) name() string {
+	if k.config.Namespace == "" {
+		return "kaniko"
+	}
+	return "kaniko " + k.config.Namespace
+}
+
+func (k kanikoEngine) build(opts docker.BuildOptions, out io.Writer) (string, error) {
+	if len(opts.Secrets) > 0 {
+		return "", errors.New("kaniko does not support build secrets")
+	}
+	if k.config.Namespace != "" {
+		return "", k.buildInCluster(opts, out)
+	}
+
+	context, err := filepath.Abs(opts.Context)
+	if err != nil {
+		return "", err
+	}
+	dockerfile, err := filepath.Abs(opts.Dockerfile)
+	if err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile("", "duffle-digest-")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	executor := k.config.Executor
+	if executor == "" {
+		executor = defaultKanikoExecutor
+	}
+	cmd := exec.Command(executor, append(kanikoArgs(opts, "dir://"+context, dockerfile), "--digest-file", f.Name())...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("kaniko: %v", err)
+	}
+	d, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(d)), nil
+}
+
+// buildInCluster runs kaniko as a pod, streaming the build context to it
+func (k kanikoEngine) buildInCluster(opts docker.BuildOptions, out io.Writer) error {
+	dockerfile, err := filepath.Rel(opts.Context, opts.Dockerfile)
+	if err != nil || strings.HasPrefix(dockerfile, "..") {
+		return fmt.Errorf("%s must be within the build context to build in a cluster", opts.Dockerfile)
+	}
+	image := k.config.Image
+	if image == "" {
+		image = defaultKanikoImage
+	}
+	pod := "duffle-build-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	container := map[string]interface{}{
+		"name":      pod,
+		"image":     image,
+		"stdin":     true,
+		"stdinOnce": true,
+		"args":      kanikoArgs(opts, "tar://stdin", filepath.ToSlash(dockerfile)),
+	}
+	spec := map[string]interface{}{"containers": []interface{}{container}}
+	if k.config.Secret != "" {
+		container["volumeMounts"] = []interface{}{
+			map[string]interface{}{"name": "docker-config", "mountPath": "/kaniko/.docker"},
+		}
+		spec["volumes"] = []interface{}{
+			map[string]interface{}{
+				"name": "docker-config",
+				"secret": map[string]interface{}{
+					"secretName": k.config.Secret,
+					"items":      []interface{}{map[string]interface{}{"key": ".dockerconfigjson", "path": "config.json"}},
+				},
+			},
+		}
+	}
+	overrides, err := json.Marshal(map[string]interface{}{"apiVersion": "v1", "spec": spec})
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarContext(opts.Context, filepath.ToSlash(dockerfile), pw))
+	}()
+	cmd := exec.Command(Kubectl, "run", pod, "--namespace", k.config.Namespace, "--image", image,
+		"--restart=Never", "--rm", "-i", "--overrides", string(overrides))
+	cmd.Stdin = pr
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err = cmd.Run()
+	pr.Close()
+	if err != nil {
+		return fmt.Errorf("kaniko in namespace %s: %v", k.config.Namespace, err)
+	}
+	return nil
+}
+
+// push has nothing to do, since kaniko pushes images as it builds them
+func (kanikoEngine) push(ref, digest string) (string, error) {
+	return digest, nil
+}
+
+// lookup trusts that images kaniko pushed are still in their registry
+func (kanikoEngine) lookup(ref, cached string) (string, bool) {
+	return cached, true
+}
+
+func kanikoArgs(opts docker.BuildOptions, context, dockerfile string) []string {
+	args := []string{"--context", context, "--dockerfile", dockerfile, "--destination", opts.Tag}
+	if opts.Target != "" {
+		args = append(args, "--target", opts.Target)
+	}
+	if opts.Platform != "" {
+		args = append(args, "--custom-platform", opts.Platform)
+	}
+	for _, k := range sortedKeys(opts.Args) {
+		args = append(args, "--build-arg", k+"="+opts.Args[k])
+	}
+	for _, k := range sortedKeys(opts.Labels) {
+		args = append(args, "--label", k+"="+opts.Labels[k])
+	}
+	return args
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tarContext writes the files of the directory dir that .dockerignore does not exclude
+// to w as a gzipped tarball. The Dockerfile, at the slash-separated path dockerfile within
+// dir, is written even when excluded, as Docker does.
+func tarContext(dir, dockerfile string, w io.Writer) error {
+	ignore, err := readDockerignore(dir)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		keep := rel == dockerfile || strings.HasPrefix(dockerfile, rel+"/")
+		if !keep && ignored(rel, ignore) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(tw, path)
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,54 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+// registryDigest returns the digest of the manifest ref is tagged with in its registry,
+// provided the manifest is that of the image whose config has the digest config. Any
+// config is accepted when config is empty. No digest is returned when the tag is missing
+// or holds another image, since the registry's copy then does not match what was built.
+func registryDigest(c *registry.Client, ref, config string) (string, error) {
+	r, err := registry.ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	if ok, err := c.ManifestExists(r); !ok || err != nil {
+		return "", err
+	}
+	data, mediaType, d, err := c.GetManifest(r)
+	if err != nil {
+		return "", err
+	}
+	if config == "" {
+		return d, nil
+	}
+	if mediaType != registry.MediaTypeDockerManifest && mediaType != registry.MediaTypeOCIManifest {
+		return "", nil
+	}
+	var m registry.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return "", fmt.Errorf("invalid manifest for %s: %v", ref, err)
+	}
+	if m.Config.Digest != config {
+		return "", nil
+	}
+	return d, nil
+}
+
+func (dockerEngine) resolve(c *registry.Client, ref string) (string, error) {
+	id, err := docker.ImageID(ref)
+	if err != nil {
+		return "", err
+	}
+	return registryDigest(c, ref, id)
+}
+
+// resolve trusts the registry's copy, which kaniko pushed as it built the image
+func (kanikoEngine) resolve(c *registry.Client, ref string) (string, error) {
+	return registryDigest(c, ref, "")
+}
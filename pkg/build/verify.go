@@ -0,0 +1,60 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+// VerifyImages checks that every image b refers to can be found, either in the local
+// Docker daemon or in its registry, by digest when the bundle records one. The error
+// lists every image that cannot be found.
+func VerifyImages(b *bundle.Bundle, c *registry.Client) error {
+	var missing []string
+	check := func(what, ref, digest string) {
+		if err := findImage(c, ref, digest); err != nil {
+			missing = append(missing, fmt.Sprintf("  %s %s: %v", what, ref, err))
+		}
+	}
+	for _, img := range b.InvocationImages {
+		check("invocation image", img.Image, img.Digest)
+	}
+	for _, img := range b.Images {
+		check("image "+img.Name, img.URI, img.Digest)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the bundle refers to images that cannot be found:\n%s", strings.Join(missing, "\n"))
+	}
+	return nil
+}
+
+func findImage(c *registry.Client, ref, digest string) error {
+	local := ref
+	if digest != "" {
+		local = docker.WithDigest(ref, digest)
+	}
+	if docker.ImageExists(local) {
+		return nil
+	}
+	// references without a registry, such as nginx:1.15, name images on Docker Hub
+	r, err := registry.ParseReference(ref)
+	if err != nil {
+		return err
+	}
+	if digest != "" {
+		r = r.WithDigest(digest)
+	}
+	ok, err := c.ManifestExists(r)
+	switch {
+	case err != nil:
+		return err
+	case !ok && digest != "":
+		return fmt.Errorf("digest %s not found in the registry or the Docker daemon", digest)
+	case !ok:
+		return fmt.Errorf("not found in the registry or the Docker daemon")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Watch polls the files of the project in dir every interval and calls f each time they
+// change, once they have stopped changing. Paths relative to dir for which skip returns
+// true are not watched. Watch only returns when the project cannot be read.
+func Watch(dir string, interval time.Duration, skip func(rel string) bool, f func()) error {
+	last, err := snapshot(dir, skip)
+	if err != nil {
+		return err
+	}
+	for {
+		time.Sleep(interval)
+		current, err := snapshot(dir, skip)
+		if err != nil {
+			return err
+		}
+		if equalSnapshots(last, current) {
+			continue
+		}
+		// let editors and tools finish writing before building
+		for {
+			time.Sleep(interval)
+			settled, err := snapshot(dir, skip)
+			if err != nil {
+				return err
+			}
+			if equalSnapshots(current, settled) {
+				break
+			}
+			current = settled
+		}
+		f()
+		last = current
+	}
+}
+
+type fileState struct {
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func snapshot(dir string, skip func(string) bool) (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && (info.Name() == ".git" || skip(filepath.ToSlash(rel))) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		files[rel] = fileState{size: info.Size(), mode: info.Mode(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+func equalSnapshots(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, s := range a {
+		if t, ok := b[path]; !ok || s.size != t.size || s.mode != t.mode || !s.modTime.Equal(t.modTime) {
+			return false
+		}
+	}
+	return true
+}
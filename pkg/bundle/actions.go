@@ -0,0 +1,20 @@
+package bundle
+
+// Action describes a custom (non-core) action supported by the invocation image
+type Action struct {
+	// Modifies indicates whether the action changes any resources managed by the installation
+	Modifies    bool   `json:"modifies"`
+	Description string `json:"description,omitempty"`
+}
+
+// coreActions are the actions every invocation image must implement
+var coreActions = map[string]bool{
+	"install":   true,
+	"upgrade":   true,
+	"uninstall": true,
+}
+
+// IsCoreAction reports whether name is one of the actions defined by the CNAB specification
+func IsCoreAction(name string) bool {
+	return coreActions[name]
+}
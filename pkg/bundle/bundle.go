@@ -0,0 +1,229 @@
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/deis/duffle/pkg/docker"
+)
+
+// Bundle is a CNAB metadata document
+type Bundle struct {
+	SchemaVersion    string                         `json:"schemaVersion"`
+	Name             string                         `json:"name"`
+	Version          string                         `json:"version"`
+	Description      string                         `json:"description,omitempty"`
+	InvocationImages []InvocationImage              `json:"invocationImages"`
+	Images           []Image                        `json:"images,omitempty"`
+	Parameters       map[string]ParameterDefinition `json:"parameters,omitempty"`
+	Credentials      map[string]CredentialLocation  `json:"credentials,omitempty"`
+	Outputs          map[string]OutputDefinition    `json:"outputs,omitempty"`
+	Dependencies     []Dependency                   `json:"dependencies,omitempty"`
+	Actions          map[string]Action              `json:"actions,omitempty"`
+	// Custom holds tool-specific extension metadata, keyed by extension name.
+	// Duffle does not interpret these values but preserves them.
+	Custom map[string]interface{} `json:"custom,omitempty"`
+}
+
+// InvocationImage contains the image type and location for the installation of a bundle
+type InvocationImage struct {
+	ImageType string `json:"imageType"`
+	Image     string `json:"image"`
+	Digest    string `json:"digest,omitempty"`
+	// OS and Arch describe the platform the image is built for, using GOOS/GOARCH values
+	OS   string `json:"os,omitempty"`
+	Arch string `json:"arch,omitempty"`
+}
+
+// RunsOn reports whether the image can run on the given platform.
+//
+// Unset fields, on either side, match anything.
+func (i InvocationImage) RunsOn(os, arch string) bool {
+	return (i.OS == "" || os == "" || i.OS == os) && (i.Arch == "" || arch == "" || i.Arch == arch)
+}
+
+// Platform returns the image's platform as os/arch, with "*" standing in for unset fields
+func (i InvocationImage) Platform() string {
+	os, arch := i.OS, i.Arch
+	if os == "" {
+		os = "*"
+	}
+	if arch == "" {
+		arch = "*"
+	}
+	return os + "/" + arch
+}
+
+// Ref returns the reference used to run the image, preferring the pinned digest when one is set
+func (i InvocationImage) Ref() string {
+	if i.Digest == "" {
+		return i.Image
+	}
+	return docker.WithDigest(i.Image, i.Digest)
+}
+
+// ImageSelector describes the constraints used to choose an invocation image
+type ImageSelector struct {
+	// Types lists the image types the driver can run; any type is accepted when empty
+	Types []string
+	// OS and Arch describe the platform the image will run on; any platform is accepted when empty
+	OS   string
+	Arch string
+	// Preferred, when set, names the exact invocation image to use
+	Preferred string
+}
+
+// SelectInvocationImage picks the invocation image to run.
+//
+// If a preferred image is named, it is returned as long as it can run on the selector's
+// platform. Otherwise the first image of a supported type built for the platform is chosen.
+func (b *Bundle) SelectInvocationImage(s ImageSelector) (InvocationImage, error) {
+	if len(b.InvocationImages) == 0 {
+		return InvocationImage{}, errors.New("bundle does not declare any invocation images")
+	}
+	if s.Preferred != "" {
+		for _, img := range b.InvocationImages {
+			if img.Image != s.Preferred {
+				continue
+			}
+			if !img.RunsOn(s.OS, s.Arch) {
+				return InvocationImage{}, fmt.Errorf("invocation image %s is built for %s, not %s/%s", img.Image, img.Platform(), s.OS, s.Arch)
+			}
+			return img, nil
+		}
+		return InvocationImage{}, fmt.Errorf("bundle does not declare invocation image %q", s.Preferred)
+	}
+	for _, img := range b.InvocationImages {
+		if img.RunsOn(s.OS, s.Arch) && supportsType(s.Types, img.ImageType) {
+			return img, nil
+		}
+	}
+	if s.OS != "" || s.Arch != "" {
+		return InvocationImage{}, fmt.Errorf("none of the bundle's invocation images can run on %s/%s with a driver supporting %s", s.OS, s.Arch, strings.Join(s.Types, ", "))
+	}
+	return InvocationImage{}, fmt.Errorf("none of the bundle's invocation images are of a supported type (%s)", strings.Join(s.Types, ", "))
+}
+
+func supportsType(types []string, t string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, supported := range types {
+		if supported == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Image describes a container image in the bundle
+type Image struct {
+	Name      string `json:"name"`
+	URI       string `json:"uri"`
+	ImageType string `json:"imageType,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+	// Refs locate the references to the image in the invocation image's files
+	Refs []LocationRef `json:"refs,omitempty"`
+}
+
+// LocationRef points to a reference to an image in a file of the invocation image, so
+// that tools relocating the image know what to rewrite
+type LocationRef struct {
+	// Path is the path of the file within the invocation image
+	Path string `json:"path"`
+	// Field is the path of the field holding the reference within the file
+	Field string `json:"field"`
+}
+
+// Ref returns the reference used to fetch the image, preferring the pinned digest when one is set
+func (i Image) Ref() string {
+	if i.Digest == "" {
+		return i.URI
+	}
+	return docker.WithDigest(i.URI, i.Digest)
+}
+
+// ParameterDefinition defines a single parameter for a CNAB bundle
+type ParameterDefinition struct {
+	DataType      string             `json:"type"`
+	DefaultValue  interface{}        `json:"defaultValue,omitempty"`
+	AllowedValues []interface{}      `json:"allowedValues,omitempty"`
+	Metadata      *ParameterMetadata `json:"metadata,omitempty"`
+	// ApplyTo lists the actions the parameter is used by; it applies to every action when empty
+	ApplyTo []string `json:"applyTo,omitempty"`
+}
+
+// ParameterMetadata contains metadata for a parameter definition
+type ParameterMetadata struct {
+	Description string `json:"description,omitempty"`
+}
+
+// CredentialLocation describes where a credential is injected into the invocation image
+type CredentialLocation struct {
+	Path                string `json:"path,omitempty"`
+	EnvironmentVariable string `json:"env,omitempty"`
+}
+
+// ParseReader reads a bundle document from r
+func ParseReader(r io.Reader) (*Bundle, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(data)
+}
+
+// Unmarshal decodes a bundle document, upgrading documents written for older schema versions
+func Unmarshal(data []byte) (*Bundle, error) {
+	converted, err := migrate(data)
+	if err != nil {
+		if _, ok := err.(ErrUnsupportedSchema); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot parse bundle: %v", describeJSONError(data, err))
+	}
+	b := &Bundle{}
+	if err := json.Unmarshal(converted, b); err != nil {
+		return nil, fmt.Errorf("cannot parse bundle: %v", describeJSONError(converted, err))
+	}
+	return b, nil
+}
+
+// Load reads the bundle document at path
+func Load(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseReader(f)
+}
+
+// WriteFile serializes the bundle and writes it to dest
+func (b *Bundle) WriteFile(dest string, mode os.FileMode) error {
+	if b.SchemaVersion == "" {
+		b.SchemaVersion = SchemaVersion
+	}
+	data, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, data, mode)
+}
+
+// ImageRefs returns the invocation images followed by every component image referenced by the bundle
+func (b *Bundle) ImageRefs() []string {
+	var refs []string
+	for _, img := range b.InvocationImages {
+		refs = append(refs, img.Ref())
+	}
+	for _, img := range b.Images {
+		refs = append(refs, img.Ref())
+	}
+	return refs
+}
@@ -0,0 +1,33 @@
+package bundle
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver"
+)
+
+// Dependency declares that a bundle requires another bundle to be installed first
+type Dependency struct {
+	Name string `json:"name"`
+	// Version is a semantic version range, such as "^1.2" or ">=2.0.0, <3"
+	Version string `json:"version,omitempty"`
+}
+
+// SatisfiedBy reports whether a bundle with the given name and version fulfils the dependency
+func (d Dependency) SatisfiedBy(name, version string) (bool, error) {
+	if d.Name != name {
+		return false, nil
+	}
+	if d.Version == "" {
+		return true, nil
+	}
+	c, err := semver.NewConstraint(d.Version)
+	if err != nil {
+		return false, fmt.Errorf("dependency %s has an invalid version range %q: %v", d.Name, d.Version, err)
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false, nil
+	}
+	return c.Check(v), nil
+}
@@ -0,0 +1,118 @@
+package bundle
+
+// Merge overlays an organization's customizations onto an upstream bundle, returning a new bundle.
+//
+// Non-empty metadata fields in the overlay replace those of the base. Parameters are merged
+// by name: the overlay may add parameters or replace the type, default, allowed values,
+// metadata and actions of existing ones. Images are merged by name, replacing the URI,
+// type, digest and location refs that the overlay sets. If the overlay declares invocation images, they
+// replace those of the base. Credentials, outputs, actions and custom extensions are merged
+// by key, with the overlay taking precedence. The base and overlay are not modified.
+func Merge(base, overlay *Bundle) *Bundle {
+	b := *base
+
+	if overlay.Name != "" {
+		b.Name = overlay.Name
+	}
+	if overlay.Version != "" {
+		b.Version = overlay.Version
+	}
+	if overlay.Description != "" {
+		b.Description = overlay.Description
+	}
+
+	b.InvocationImages = append([]InvocationImage(nil), base.InvocationImages...)
+	if len(overlay.InvocationImages) > 0 {
+		b.InvocationImages = append([]InvocationImage(nil), overlay.InvocationImages...)
+	}
+
+	b.Images = append([]Image(nil), base.Images...)
+	for _, o := range overlay.Images {
+		b.Images = mergeImage(b.Images, o)
+	}
+
+	b.Parameters = map[string]ParameterDefinition{}
+	for name, p := range base.Parameters {
+		b.Parameters[name] = p
+	}
+	for name, o := range overlay.Parameters {
+		b.Parameters[name] = mergeParameter(b.Parameters[name], o)
+	}
+
+	b.Credentials = map[string]CredentialLocation{}
+	for name, c := range base.Credentials {
+		b.Credentials[name] = c
+	}
+	for name, c := range overlay.Credentials {
+		b.Credentials[name] = c
+	}
+
+	b.Outputs = map[string]OutputDefinition{}
+	for name, o := range base.Outputs {
+		b.Outputs[name] = o
+	}
+	for name, o := range overlay.Outputs {
+		b.Outputs[name] = o
+	}
+
+	b.Actions = map[string]Action{}
+	for name, a := range base.Actions {
+		b.Actions[name] = a
+	}
+	for name, a := range overlay.Actions {
+		b.Actions[name] = a
+	}
+
+	b.Custom = map[string]interface{}{}
+	for name, c := range base.Custom {
+		b.Custom[name] = c
+	}
+	for name, c := range overlay.Custom {
+		b.Custom[name] = c
+	}
+
+	return &b
+}
+
+func mergeImage(images []Image, o Image) []Image {
+	for i, img := range images {
+		if img.Name != o.Name {
+			continue
+		}
+		if o.URI != "" {
+			images[i].URI = o.URI
+			// a new URI invalidates any digest pinned for the old one
+			images[i].Digest = ""
+		}
+		if o.ImageType != "" {
+			images[i].ImageType = o.ImageType
+		}
+		if o.Digest != "" {
+			images[i].Digest = o.Digest
+		}
+		if len(o.Refs) > 0 {
+			images[i].Refs = o.Refs
+		}
+		return images
+	}
+	return append(images, o)
+}
+
+func mergeParameter(p, o ParameterDefinition) ParameterDefinition {
+	if o.DataType != "" {
+		p.DataType = o.DataType
+	}
+	if o.DefaultValue != nil {
+		p.DefaultValue = o.DefaultValue
+	}
+	if len(o.AllowedValues) > 0 {
+		p.AllowedValues = o.AllowedValues
+	}
+	if o.Metadata != nil {
+		p.Metadata = o.Metadata
+	}
+	if len(o.ApplyTo) > 0 {
+		p.ApplyTo = o.ApplyTo
+	}
+	return p
+}
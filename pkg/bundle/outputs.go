@@ -0,0 +1,35 @@
+package bundle
+
+// OutputDefinition describes a value produced by the invocation image
+type OutputDefinition struct {
+	DataType    string   `json:"type"`
+	Path        string   `json:"path"`
+	ApplyTo     []string `json:"applyTo,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// AppliesTo reports whether the output is produced by the given action.
+//
+// An output that does not list any actions is produced by every action.
+func (o OutputDefinition) AppliesTo(action string) bool {
+	if len(o.ApplyTo) == 0 {
+		return true
+	}
+	for _, a := range o.ApplyTo {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// OutputsFor returns the outputs the bundle declares for the given action
+func (b *Bundle) OutputsFor(action string) map[string]OutputDefinition {
+	outputs := map[string]OutputDefinition{}
+	for name, o := range b.Outputs {
+		if o.AppliesTo(action) {
+			outputs[name] = o
+		}
+	}
+	return outputs
+}
@@ -0,0 +1,52 @@
+package bundle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AppliesTo reports whether the parameter is used by the given action.
+//
+// A parameter that does not list any actions applies to every action.
+func (p ParameterDefinition) AppliesTo(action string) bool {
+	if len(p.ApplyTo) == 0 {
+		return true
+	}
+	for _, a := range p.ApplyTo {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// ConvertValue converts a string into the data type of the parameter definition
+func (p ParameterDefinition) ConvertValue(val string) (interface{}, error) {
+	switch p.DataType {
+	case "string":
+		return val, nil
+	case "int":
+		return strconv.Atoi(val)
+	case "bool":
+		return strconv.ParseBool(val)
+	default:
+		return nil, fmt.Errorf("invalid parameter type %q", p.DataType)
+	}
+}
+
+// ValidateValue checks that value satisfies the parameter definition
+func (p ParameterDefinition) ValidateValue(value interface{}) error {
+	if !p.matchesType(value) {
+		return fmt.Errorf("value %v is not of type %s", value, p.DataType)
+	}
+	if len(p.AllowedValues) > 0 && !p.allows(value) {
+		return fmt.Errorf("value %v is not one of the allowed values", value)
+	}
+	return nil
+}
+
+// EnvironmentVariable returns the variable a parameter's value is injected into
+func EnvironmentVariable(name string) string {
+	return "CNAB_P_" + strings.ToUpper(name)
+}
@@ -0,0 +1,40 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// RelocationMappingPath is where the relocation mapping is made available inside the invocation image
+const RelocationMappingPath = "/cnab/app/relocation-mapping.json"
+
+// RelocationMap maps original image references to their relocated counterparts
+type RelocationMap map[string]string
+
+// LoadRelocationMap reads a relocation mapping file
+func LoadRelocationMap(path string) (RelocationMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := RelocationMap{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse relocation mapping %s: %v", path, err)
+	}
+	return m, nil
+}
+
+// Relocate rewrites the bundle's invocation and component image references according to m
+func (b *Bundle) Relocate(m RelocationMap) {
+	for i, img := range b.InvocationImages {
+		if r, ok := m[img.Image]; ok {
+			b.InvocationImages[i].Image = r
+		}
+	}
+	for i, img := range b.Images {
+		if r, ok := m[img.URI]; ok {
+			b.Images[i].URI = r
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersion is the bundle document schema written by this version of duffle
+const SchemaVersion = "v1"
+
+// legacySchemaVersion is assumed for documents that predate the schemaVersion field
+const legacySchemaVersion = "v0"
+
+// converter upgrades a raw bundle document by one major schema version
+type converter func(doc map[string]interface{}) error
+
+// converters are keyed by the major version they upgrade from
+var converters = map[int]converter{
+	0: convertV0,
+}
+
+// ErrUnsupportedSchema indicates a document written for a newer schema than duffle understands
+type ErrUnsupportedSchema struct {
+	Version string
+}
+
+func (e ErrUnsupportedSchema) Error() string {
+	return fmt.Sprintf("bundle schema version %s is not supported (this duffle supports up to %s); upgrade duffle to read this bundle", e.Version, SchemaVersion)
+}
+
+// majorVersion extracts the major component of a schema version such as "v1" or "v1.2"
+func majorVersion(v string) (int, error) {
+	major := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 2)[0]
+	n, err := strconv.Atoi(major)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid schema version %q", v)
+	}
+	return n, nil
+}
+
+// migrate upgrades a raw bundle document to the current schema version
+func migrate(data []byte) ([]byte, error) {
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	version := legacySchemaVersion
+	if v, ok := doc["schemaVersion"].(string); ok {
+		version = v
+	}
+	from, err := majorVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	current, _ := majorVersion(SchemaVersion)
+	if from > current {
+		return nil, ErrUnsupportedSchema{Version: version}
+	}
+	if from == current {
+		return data, nil
+	}
+
+	for v := from; v < current; v++ {
+		if err := converters[v](doc); err != nil {
+			return nil, fmt.Errorf("cannot upgrade bundle from schema v%d: %v", v, err)
+		}
+	}
+	doc["schemaVersion"] = SchemaVersion
+	return json.Marshal(doc)
+}
+
+// convertV0 replaces the single invocationImage of v0 documents with an invocationImages list
+func convertV0(doc map[string]interface{}) error {
+	img, ok := doc["invocationImage"]
+	if !ok {
+		return nil
+	}
+	delete(doc, "invocationImage")
+	if _, ok := doc["invocationImages"]; !ok {
+		doc["invocationImages"] = []interface{}{img}
+	}
+	return nil
+}
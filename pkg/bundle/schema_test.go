@@ -0,0 +1,112 @@
+package bundle
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+		err  string
+	}{
+		{
+			"v0 without schemaVersion",
+			`{"name":"foo","invocationImage":{"imageType":"docker","image":"foo:1"}}`,
+			`{"name":"foo","schemaVersion":"v1","invocationImages":[{"imageType":"docker","image":"foo:1"}]}`,
+			"",
+		},
+		{
+			"explicit v0",
+			`{"schemaVersion":"v0","name":"foo","invocationImage":{"imageType":"docker","image":"foo:1"}}`,
+			`{"name":"foo","schemaVersion":"v1","invocationImages":[{"imageType":"docker","image":"foo:1"}]}`,
+			"",
+		},
+		{
+			"v0 with both image fields keeps the list",
+			`{"name":"foo","invocationImage":{"image":"old"},"invocationImages":[{"image":"new"}]}`,
+			`{"name":"foo","schemaVersion":"v1","invocationImages":[{"image":"new"}]}`,
+			"",
+		},
+		{
+			"v0 without an invocation image",
+			`{"name":"foo"}`,
+			`{"name":"foo","schemaVersion":"v1"}`,
+			"",
+		},
+		{
+			"v1 is unchanged",
+			`{"schemaVersion":"v1","name":"foo","invocationImage":{"image":"kept"}}`,
+			`{"schemaVersion":"v1","name":"foo","invocationImage":{"image":"kept"}}`,
+			"",
+		},
+		{"minor version of v1", `{"schemaVersion":"v1.2","name":"foo"}`, `{"schemaVersion":"v1.2","name":"foo"}`, ""},
+		{"newer major version", `{"schemaVersion":"v2","name":"foo"}`, "", "bundle schema version v2 is not supported"},
+		{"invalid version", `{"schemaVersion":"latest"}`, "", `invalid schema version "latest"`},
+		{"negative version", `{"schemaVersion":"v-1"}`, "", `invalid schema version "v-1"`},
+		{"not an object", `[]`, "", "cannot unmarshal array"},
+	}
+	for _, tt := range tests {
+		got, err := migrate([]byte(tt.doc))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var gotDoc, wantDoc interface{}
+		if err := json.Unmarshal(got, &gotDoc); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if err := json.Unmarshal([]byte(tt.want), &wantDoc); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotDoc, wantDoc) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMigrateUnsupportedSchema(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"schemaVersion":"v3"}`))
+	if e, ok := err.(ErrUnsupportedSchema); !ok || e.Version != "v3" {
+		t.Errorf("got error %#v, want ErrUnsupportedSchema for v3", err)
+	}
+	_, err = UnmarshalStrict([]byte(`{"schemaVersion":"v3"}`))
+	if _, ok := err.(ErrUnsupportedSchema); !ok {
+		t.Errorf("strict: got error %#v, want ErrUnsupportedSchema", err)
+	}
+}
+
+func TestMajorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+		ok      bool
+	}{
+		{"v0", 0, true},
+		{"v1", 1, true},
+		{"v1.2", 1, true},
+		{"1", 1, true},
+		{"v10.0.1", 10, true},
+		{"", 0, false},
+		{"v", 0, false},
+		{"vx", 0, false},
+		{"v-1", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := majorVersion(tt.version)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("majorVersion(%q) = %d, %v; want %d, ok %v", tt.version, got, err, tt.want, tt.ok)
+		}
+	}
+}
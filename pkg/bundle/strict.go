@@ -0,0 +1,88 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// bundleV0 holds a v0 bundle document, which has a single invocation image
+type bundleV0 struct {
+	Bundle
+	InvocationImage *InvocationImage `json:"invocationImage,omitempty"`
+}
+
+// UnmarshalStrict decodes a bundle document, rejecting fields the bundle format does not define.
+//
+// Syntax and type errors report the line and column at which they occurred in data. Documents
+// of earlier schema versions are checked as they are written, against the fields of their
+// version, before they are upgraded.
+func UnmarshalStrict(data []byte) (*Bundle, error) {
+	converted, err := migrate(data)
+	if err != nil {
+		if _, ok := err.(ErrUnsupportedSchema); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("cannot parse bundle: %v", describeJSONError(data, err))
+	}
+
+	b := &Bundle{}
+	var doc interface{} = b
+	if legacy(data) {
+		doc = &bundleV0{}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(doc); err != nil {
+		return nil, fmt.Errorf("cannot parse bundle: %v", describeJSONError(data, err))
+	}
+	if dec.More() {
+		return nil, errors.New("cannot parse bundle: unexpected data after the bundle document")
+	}
+	if doc != b {
+		if err := json.Unmarshal(converted, b); err != nil {
+			return nil, fmt.Errorf("cannot parse bundle: %v", err)
+		}
+	}
+	return b, nil
+}
+
+// legacy reports whether the bundle document data has schema version v0, which documents
+// without a schemaVersion have
+func legacy(data []byte) bool {
+	var doc struct {
+		SchemaVersion string `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil || doc.SchemaVersion == "" {
+		return true
+	}
+	major, err := majorVersion(doc.SchemaVersion)
+	return err == nil && major == 0
+}
+
+// describeJSONError adds the line and column to errors that carry an offset into data
+func describeJSONError(data []byte, err error) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return err
+	}
+	line, col := position(data, offset)
+	return fmt.Errorf("line %d, column %d: %v", line, col, err)
+}
+
+// position converts a byte offset into data to a 1-based line and column
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
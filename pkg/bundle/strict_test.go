@@ -0,0 +1,122 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalStrict(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		images []string
+		err    string
+	}{
+		{
+			"v1",
+			`{"schemaVersion":"v1","name":"foo","version":"0.1.0","invocationImages":[{"imageType":"docker","image":"foo:1"}]}`,
+			[]string{"foo:1"},
+			"",
+		},
+		{
+			"v0 is upgraded",
+			`{"name":"foo","version":"0.1.0","invocationImage":{"imageType":"docker","image":"foo:1"}}`,
+			[]string{"foo:1"},
+			"",
+		},
+		{
+			"unknown field",
+			`{"schemaVersion":"v1","name":"foo","colour":"blue"}`,
+			nil,
+			`unknown field "colour"`,
+		},
+		{
+			"unknown field of v0",
+			`{"name":"foo","invocationImage":{"image":"foo:1","colour":"blue"}}`,
+			nil,
+			`unknown field "colour"`,
+		},
+		{
+			"v0 field in a v1 document",
+			`{"schemaVersion":"v1","name":"foo","invocationImage":{"image":"foo:1"}}`,
+			nil,
+			`unknown field "invocationImage"`,
+		},
+		{
+			"trailing data",
+			`{"schemaVersion":"v1","name":"foo"} {}`,
+			nil,
+			"line 1, column 38: invalid character '{' after top-level value",
+		},
+		{
+			"syntax error",
+			"{\n  \"schemaVersion\": \"v1\",\n  \"name\": \"foo\",,\n}",
+			nil,
+			"line 3, column 18",
+		},
+		{
+			"type error",
+			"{\n  \"schemaVersion\": \"v1\",\n  \"name\": 42\n}",
+			nil,
+			"line 3, column 13",
+		},
+		{
+			// the error points into the v0 document, not into the upgraded one, in which
+			// the image has moved
+			"type error in v0",
+			"{\n  \"name\": \"foo\",\n  \"invocationImage\": {\n    \"image\": 1\n  }\n}",
+			nil,
+			"line 4, column 15",
+		},
+		{
+			"type error after the image of v0",
+			"{\n  \"invocationImage\": {\"image\": \"foo:1\"},\n  \"version\": true\n}",
+			nil,
+			"line 3, column 18",
+		},
+	}
+	for _, tt := range tests {
+		b, err := UnmarshalStrict([]byte(tt.doc))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b.SchemaVersion != SchemaVersion {
+			t.Errorf("%s: got schema version %q, want %q", tt.name, b.SchemaVersion, SchemaVersion)
+		}
+		var images []string
+		for _, i := range b.InvocationImages {
+			images = append(images, i.Image)
+		}
+		if strings.Join(images, " ") != strings.Join(tt.images, " ") {
+			t.Errorf("%s: got invocation images %q, want %q", tt.name, images, tt.images)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	data := []byte("ab\ncd\n\nef")
+	tests := []struct {
+		offset    int64
+		line, col int
+	}{
+		{0, 1, 1},
+		{1, 1, 2},
+		{3, 2, 1},
+		{5, 2, 3},
+		{6, 3, 1},
+		{7, 4, 1},
+		{100, 4, 3},
+	}
+	for _, tt := range tests {
+		if line, col := position(data, tt.offset); line != tt.line || col != tt.col {
+			t.Errorf("position(%d) = %d:%d, want %d:%d", tt.offset, line, col, tt.line, tt.col)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package bundle
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/deis/duffle/pkg/docker"
+)
+
+// Severity classifies a validation finding
+type Severity string
+
+const (
+	// SeverityError marks a finding that makes the bundle unusable
+	SeverityError Severity = "error"
+	// SeverityWarning marks a finding that is suspicious but not fatal
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a single problem discovered while validating a bundle
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Field    string   `json:"field"`
+	Message  string   `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Field, f.Message)
+}
+
+// Findings is the result of validating a bundle
+type Findings []Finding
+
+// HasErrors reports whether any finding has error severity
+func (fs Findings) HasErrors() bool {
+	for _, f := range fs {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// semverRegexp matches a Semantic Versioning 2.0.0 version string
+var semverRegexp = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// digestRegexp matches a content digest such as sha256:<hex>
+var digestRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-fA-F0-9]{32,}$`)
+
+// Validate checks a bundle for missing required fields and inconsistent definitions
+func Validate(b *Bundle) Findings {
+	v := &validator{}
+
+	if b.Name == "" {
+		v.errorf("name", "name is required")
+	}
+	if b.Version == "" {
+		v.errorf("version", "version is required")
+	} else if !semverRegexp.MatchString(b.Version) {
+		v.errorf("version", "%q is not a valid semantic version", b.Version)
+	}
+
+	if len(b.InvocationImages) == 0 {
+		v.errorf("invocationImages", "at least one invocation image is required")
+	}
+	for i, img := range b.InvocationImages {
+		field := fmt.Sprintf("invocationImages[%d]", i)
+		v.image(field+".image", img.Image)
+		v.digest(field+".digest", img.Digest)
+	}
+	for i, img := range b.Images {
+		field := fmt.Sprintf("images[%d]", i)
+		if img.Name == "" {
+			v.warnf(field+".name", "image has no name")
+		}
+		v.image(field+".uri", img.URI)
+		v.digest(field+".digest", img.Digest)
+	}
+
+	for _, name := range sortedKeys(b.Parameters) {
+		v.parameter("parameters."+name, b.Parameters[name])
+	}
+	for _, name := range sortedKeys(b.Credentials) {
+		c := b.Credentials[name]
+		if c.Path == "" && c.EnvironmentVariable == "" {
+			v.errorf("credentials."+name, "credential must declare a path or an environment variable")
+		}
+	}
+	for _, name := range sortedKeys(b.Outputs) {
+		if b.Outputs[name].Path == "" {
+			v.errorf("outputs."+name+".path", "output must declare a path")
+		}
+	}
+
+	for _, name := range sortedKeys(b.Actions) {
+		if IsCoreAction(name) {
+			v.errorf("actions."+name, "%s is a core action and cannot be redefined", name)
+		}
+	}
+
+	for i, d := range b.Dependencies {
+		field := fmt.Sprintf("dependencies[%d]", i)
+		if d.Name == "" {
+			v.errorf(field+".name", "dependency name is required")
+		}
+		if d.Version != "" {
+			if _, err := semver.NewConstraint(d.Version); err != nil {
+				v.errorf(field+".version", "%q is not a valid version range", d.Version)
+			}
+		}
+	}
+
+	return v.findings
+}
+
+type validator struct {
+	findings Findings
+}
+
+func (v *validator) errorf(field, format string, args ...interface{}) {
+	v.findings = append(v.findings, Finding{SeverityError, field, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(field, format string, args ...interface{}) {
+	v.findings = append(v.findings, Finding{SeverityWarning, field, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) image(field, ref string) {
+	if ref == "" {
+		v.errorf(field, "image reference is required")
+	} else if !docker.ValidReference(ref) {
+		v.errorf(field, "%q is not a valid image reference", ref)
+	}
+}
+
+func (v *validator) digest(field, digest string) {
+	if digest != "" && !digestRegexp.MatchString(digest) {
+		v.errorf(field, "%q is not a valid digest", digest)
+	}
+}
+
+func (v *validator) parameter(field string, p ParameterDefinition) {
+	if !validDataType(p.DataType) {
+		v.errorf(field+".type", "unknown type %q", p.DataType)
+		return
+	}
+	if p.DefaultValue != nil && !p.matchesType(p.DefaultValue) {
+		v.errorf(field+".defaultValue", "default value %v is not of type %s", p.DefaultValue, p.DataType)
+	}
+	for _, allowed := range p.AllowedValues {
+		if !p.matchesType(allowed) {
+			v.errorf(field+".allowedValues", "allowed value %v is not of type %s", allowed, p.DataType)
+		}
+	}
+	if p.DefaultValue != nil && len(p.AllowedValues) > 0 && !p.allows(p.DefaultValue) {
+		v.errorf(field+".defaultValue", "default value %v is not one of the allowed values", p.DefaultValue)
+	}
+}
+
+func validDataType(t string) bool {
+	switch t {
+	case "string", "int", "bool":
+		return true
+	}
+	return false
+}
+
+func (p ParameterDefinition) matchesType(value interface{}) bool {
+	switch p.DataType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "bool":
+		_, ok := value.(bool)
+		return ok
+	case "int":
+		switch n := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return n == float64(int64(n))
+		}
+	}
+	return false
+}
+
+func (p ParameterDefinition) allows(value interface{}) bool {
+	for _, allowed := range p.AllowedValues {
+		if normalize(allowed) == normalize(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize maps the numeric types produced by JSON decoding and by ConvertValue onto int64
+func normalize(value interface{}) interface{} {
+	switch n := value.(type) {
+	case int:
+		return int64(n)
+	case float64:
+		if n == float64(int64(n)) {
+			return int64(n)
+		}
+	}
+	return value
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]ParameterDefinition:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]CredentialLocation:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]Action:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]OutputDefinition:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
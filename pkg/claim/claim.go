@@ -0,0 +1,87 @@
+package claim
+
+import (
+	"time"
+
+	"github.com/deis/duffle/pkg/bundle"
+)
+
+// Status constants define the CNAB status fields on a Result.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Claim is an installation claim receipt.
+//
+// Claims represent information about a particular installation, and
+// provide the necessary data to upgrade, uninstall, and downgrade
+// a CNAB package.
+type Claim struct {
+	Name       string                 `json:"name"`
+	Created    time.Time              `json:"created"`
+	Modified   time.Time              `json:"modified"`
+	Bundle     *bundle.Bundle         `json:"bundle"`
+	Result     Result                 `json:"result"`
+	Parameters map[string]interface{} `json:"parameters"`
+	// Dependencies maps each bundle dependency to the installation that satisfies it
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	// Source records the repository or registry the bundle was resolved through, if any
+	Source *Source `json:"source,omitempty"`
+}
+
+// Source records where an installation's bundle was resolved from
+type Source struct {
+	// Repository is the name of the repository the bundle was found in; it is empty for
+	// bundles loaded directly from a registry
+	Repository string `json:"repository,omitempty"`
+	// IndexDigest is the digest of the repository index the bundle was resolved with.
+	// It is empty for registry-backed repositories, which have no index.
+	IndexDigest string `json:"indexDigest,omitempty"`
+	// URL is where the bundle document was loaded from
+	URL string `json:"url"`
+	// Digest pins the bundle: the digest of the bundle document, or of the artifact
+	// manifest for bundles stored in registries
+	Digest string `json:"digest,omitempty"`
+	// Constraint is the version constraint the bundle was requested with, if any
+	Constraint string `json:"constraint,omitempty"`
+	// Version is the exact version the request resolved to
+	Version string `json:"version"`
+	// Deprecated records that the repository had deprecated the version when it was resolved
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+// Result tracks the result of a Duffle operation on a CNAB installation
+type Result struct {
+	Message string `json:"message"`
+	Action  string `json:"action"`
+	Status  string `json:"status"`
+}
+
+// New creates a new Claim initialized for an installation operation.
+func New(name string) *Claim {
+	now := time.Now()
+	return &Claim{
+		Name:     name,
+		Created:  now,
+		Modified: now,
+		Result: Result{
+			Action: "unknown",
+			Status: "unknown",
+		},
+		Parameters: map[string]interface{}{},
+	}
+}
+
+// Update records the outcome of running action against the installation
+func (c *Claim) Update(action string, err error) {
+	c.Modified = time.Now()
+	c.Result.Action = action
+	if err != nil {
+		c.Result.Status = StatusFailure
+		c.Result.Message = err.Error()
+		return
+	}
+	c.Result.Status = StatusSuccess
+	c.Result.Message = ""
+}
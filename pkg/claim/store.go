@@ -0,0 +1,90 @@
+package claim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+)
+
+// ErrClaimNotFound indicates that no claim exists for an installation
+type ErrClaimNotFound struct {
+	Name string
+}
+
+func (e ErrClaimNotFound) Error() string {
+	return fmt.Sprintf("installation %q not found", e.Name)
+}
+
+// Store persists claims as JSON documents in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore returns a Store rooted at dir
+func NewStore(dir string) Store {
+	return Store{dir: dir}
+}
+
+func (s Store) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// Store saves a claim, replacing any previous claim for the same installation
+func (s Store) Store(c *Claim) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(s.path(c.Name), data, 0644)
+}
+
+// Read returns the claim for the named installation
+func (s Store) Read(name string) (*Claim, error) {
+	data, err := ioutil.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrClaimNotFound{Name: name}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Claim{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("cannot parse claim for %s: %v", name, err)
+	}
+	return c, nil
+}
+
+// List returns the names of all stored installations
+func (s Store) List() ([]string, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	return names, nil
+}
+
+// Delete removes the claim for the named installation
+func (s Store) Delete(name string) error {
+	err := os.Remove(s.path(name))
+	if os.IsNotExist(err) {
+		return ErrClaimNotFound{Name: name}
+	}
+	return err
+}
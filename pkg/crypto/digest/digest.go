@@ -0,0 +1,57 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Algorithm is the digest algorithm used throughout duffle
+const Algorithm = "sha256"
+
+// OfBuffer returns the digest of data, prefixed with the algorithm (e.g. "sha256:<hex>")
+func OfBuffer(data []byte) string {
+	sum := sha256.Sum256(data)
+	return Algorithm + ":" + hex.EncodeToString(sum[:])
+}
+
+// OfReader returns the digest of everything read from r
+func OfReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return Algorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// OfFile returns the digest of the file at path
+func OfFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return OfReader(f)
+}
+
+// Hex returns the hex-encoded part of a digest, without its algorithm prefix
+func Hex(d string) string {
+	return strings.TrimPrefix(d, Algorithm+":")
+}
+
+// Validate checks that d is a digest as duffle computes them: the algorithm prefix followed
+// by the 64 lower-case hex digits of a SHA-256 sum. Digests read from untrusted documents
+// must be validated before they name files.
+func Validate(d string) error {
+	h := strings.TrimPrefix(d, Algorithm+":")
+	if h == d || len(h) != sha256.Size*2 || strings.ToLower(h) != h {
+		return fmt.Errorf("invalid digest %q: expected %s:<64 hex digits>", d, Algorithm)
+	}
+	if _, err := hex.DecodeString(h); err != nil {
+		return fmt.Errorf("invalid digest %q: expected %s:<64 hex digits>", d, Algorithm)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Package docker wraps the docker CLI for the handful of image operations duffle needs.
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Command is the docker executable invoked by this package
+var Command = "docker"
+
+// Host is the address of the Docker daemon to use, in the format of DOCKER_HOST. The
+// daemon configured in the environment is used when it is empty.
+var Host string
+
+func command(args ...string) *exec.Cmd {
+	cmd := exec.Command(Command, args...)
+	if Host != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_HOST="+Host)
+	}
+	return cmd
+}
+
+func run(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := command(args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("docker %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// ImageExists reports whether ref is present in the local daemon
+func ImageExists(ref string) bool {
+	_, err := run("image", "inspect", ref)
+	return err == nil
+}
+
+// Pull fetches ref from its registry into the local daemon
+func Pull(ref string) error {
+	_, err := run("pull", ref)
+	return err
+}
+
+// EnsureImage pulls ref unless it is already present in the local daemon
+func EnsureImage(ref string) error {
+	if ImageExists(ref) {
+		return nil
+	}
+	return Pull(ref)
+}
+
+// Save writes ref as an image tarball to dest
+func Save(ref, dest string) error {
+	_, err := run("save", "-o", dest, ref)
+	return err
+}
+
+// Load imports the image tarball at src into the local daemon, returning the references it contained.
+//
+// Images saved by digest carry no tag, in which case their image ID is returned instead.
+func Load(src string) ([]string, error) {
+	out, err := run("load", "-i", src)
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	for _, line := range strings.Split(out, "\n") {
+		for _, prefix := range []string{"Loaded image: ", "Loaded image ID: "} {
+			if ref := strings.TrimPrefix(line, prefix); ref != line {
+				refs = append(refs, strings.TrimSpace(ref))
+			}
+		}
+	}
+	return refs, nil
+}
+
+// BuildOptions describes an image build
+type BuildOptions struct {
+	// Context is the directory sent to the daemon as the build context
+	Context string
+	// Dockerfile is the path of the Dockerfile; it defaults to Dockerfile within Context
+	Dockerfile string
+	// Tag is the reference the built image is tagged as
+	Tag string
+	// Platform, as os/arch, selects the platform to build for; the daemon's platform is used when empty
+	Platform string
+	// Args are the build arguments passed to the Dockerfile
+	Args map[string]string
+	// Target is the stage of a multi-stage Dockerfile to build
+	Target string
+	// Labels are added to the built image
+	Labels map[string]string
+	// BuildKit builds the image with BuildKit
+	BuildKit bool
+	// Secrets maps the IDs of build secrets to the files holding them. They require BuildKit.
+	Secrets map[string]string
+}
+
+// Build builds an image, streaming the output of the build to w
+func Build(opts BuildOptions, w io.Writer) error {
+	args := []string{"build", "-t", opts.Tag}
+	if opts.Dockerfile != "" {
+		args = append(args, "-f", opts.Dockerfile)
+	}
+	if opts.Platform != "" {
+		args = append(args, "--platform", opts.Platform)
+	}
+	if opts.Target != "" {
+		args = append(args, "--target", opts.Target)
+	}
+	for _, k := range sortedKeys(opts.Args) {
+		args = append(args, "--build-arg", k+"="+opts.Args[k])
+	}
+	for _, k := range sortedKeys(opts.Labels) {
+		args = append(args, "--label", k+"="+opts.Labels[k])
+	}
+	for _, id := range sortedKeys(opts.Secrets) {
+		args = append(args, "--secret", fmt.Sprintf("id=%s,src=%s", id, opts.Secrets[id]))
+	}
+	cmd := command(append(args, opts.Context)...)
+	if opts.BuildKit {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, "DOCKER_BUILDKIT=1")
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker build: %v", err)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Tag creates target as an alias of the local image source
+func Tag(source, target string) error {
+	_, err := run("tag", source, target)
+	return err
+}
+
+// Push uploads ref from the local daemon to its registry
+func Push(ref string) error {
+	_, err := run("push", ref)
+	return err
+}
+
+// Digest returns the registry digest of ref, which must have been pushed or pulled by the local daemon
+func Digest(ref string) (string, error) {
+	out, err := run("image", "inspect", "--format", "{{json .RepoDigests}}", ref)
+	if err != nil {
+		return "", err
+	}
+	var digests []string
+	if err := json.Unmarshal([]byte(out), &digests); err != nil {
+		return "", err
+	}
+	repo := WithDigest(ref, "")
+	for _, d := range digests {
+		if i := strings.IndexRune(d, '@'); i != -1 && d[:i+1] == repo {
+			return d[i+1:], nil
+		}
+	}
+	return "", fmt.Errorf("no registry digest found for %s", ref)
+}
+
+// ImageID returns the ID of the local image ref, which is the digest of its config
+func ImageID(ref string) (string, error) {
+	return run("image", "inspect", "--format", "{{.Id}}", ref)
+}
+
+// ServerPlatform returns the operating system and architecture of the Docker daemon
+func ServerPlatform() (string, string, error) {
+	out, err := run("version", "--format", "{{.Server.Os}}/{{.Server.Arch}}")
+	if err != nil {
+		return "", "", err
+	}
+	return splitPlatform(out)
+}
+
+// ImagePlatform returns the operating system and architecture ref was built for
+func ImagePlatform(ref string) (string, string, error) {
+	out, err := run("image", "inspect", "--format", "{{.Os}}/{{.Architecture}}", ref)
+	if err != nil {
+		return "", "", err
+	}
+	return splitPlatform(out)
+}
+
+func splitPlatform(s string) (string, string, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("unexpected platform %q", s)
+	}
+	return parts[0], parts[1], nil
+}
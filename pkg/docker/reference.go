@@ -0,0 +1,55 @@
+package docker
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SplitDomain separates the registry domain from the rest of an image reference.
+//
+// References without an explicit domain (e.g. "alpine:3.7") return an empty domain.
+func SplitDomain(ref string) (domain, remainder string) {
+	i := strings.IndexRune(ref, '/')
+	if i == -1 {
+		return "", ref
+	}
+	first := ref[:i]
+	if first != "localhost" && !strings.ContainsAny(first, ".:") {
+		return "", ref
+	}
+	return first, ref[i+1:]
+}
+
+// Relocate rewrites ref so that it points at the same repository within registry
+func Relocate(ref, registry string) string {
+	_, remainder := SplitDomain(ref)
+	return strings.TrimSuffix(registry, "/") + "/" + remainder
+}
+
+var referenceRegexp = func() *regexp.Regexp {
+	const (
+		label     = `[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?`
+		domain    = label + `(?:\.` + label + `)*(?::[0-9]+)?`
+		component = `[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*`
+		name      = `(?:` + domain + `/)?` + component + `(?:/` + component + `)*`
+		tag       = `[\w][\w.-]{0,127}`
+		digest    = `[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}`
+	)
+	return regexp.MustCompile(`^` + name + `(?::` + tag + `)?(?:@` + digest + `)?$`)
+}()
+
+// ValidReference reports whether ref is a syntactically valid image reference
+func ValidReference(ref string) bool {
+	return referenceRegexp.MatchString(ref)
+}
+
+// WithDigest returns ref pinned to digest, dropping any tag or digest ref already carried
+func WithDigest(ref, digest string) string {
+	if i := strings.IndexRune(ref, '@'); i != -1 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref + "@" + digest
+}
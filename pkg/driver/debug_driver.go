@@ -0,0 +1,26 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DebugDriver prints the information passed to a driver
+//
+// It does not ever run the image.
+type DebugDriver struct{}
+
+// Run executes the operation on the Debug driver
+func (d *DebugDriver) Run(op *Operation) error {
+	data, err := json.MarshalIndent(op, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(output(op), string(data))
+	return nil
+}
+
+// Handles always returns true, effectively claiming to work for any image type
+func (d *DebugDriver) Handles(dt string) bool {
+	return true
+}
@@ -0,0 +1,127 @@
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/deis/duffle/pkg/docker"
+)
+
+// DockerDriver is capable of running Docker invocation images using the docker CLI.
+type DockerDriver struct {
+	config map[string]string
+}
+
+// Config returns the Docker driver configuration options
+func (d *DockerDriver) Config() map[string]string {
+	return map[string]string{
+		"SKIP_DIGEST_CHECK": "run images even when their pinned digest does not match the registry",
+	}
+}
+
+// SetConfig sets Docker driver configuration
+func (d *DockerDriver) SetConfig(settings map[string]string) {
+	d.config = settings
+}
+
+// Platform returns the platform of the Docker host
+func (d *DockerDriver) Platform() (string, string, error) {
+	return docker.ServerPlatform()
+}
+
+// Run executes the Docker driver
+func (d *DockerDriver) Run(op *Operation) error {
+	image := op.Image
+	if op.Digest != "" {
+		if d.config["SKIP_DIGEST_CHECK"] != "true" {
+			if err := checkDigest(op.Image, op.Digest); err != nil {
+				return err
+			}
+		}
+		image = docker.WithDigest(op.Image, op.Digest)
+	}
+	if err := d.checkPlatform(image); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir("", "duffle-files-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	args := []string{"run", "--rm"}
+
+	var keys []string
+	for k := range op.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", k, op.Environment[k]))
+	}
+
+	i := 0
+	for dest, content := range op.Files {
+		src := filepath.Join(tmp, fmt.Sprintf("file-%d", i))
+		i++
+		if err := ioutil.WriteFile(src, []byte(content), 0644); err != nil {
+			return err
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", src, dest))
+	}
+
+	args = append(args, image)
+
+	cmd := exec.Command(docker.Command, args...)
+	cmd.Stdout = output(op)
+	cmd.Stderr = output(op)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("invocation image %s failed: %v", image, err)
+	}
+	return nil
+}
+
+// Handles returns true when the image type is Docker or OCI
+func (d *DockerDriver) Handles(imagetype string) bool {
+	return imagetype == "docker" || imagetype == "oci"
+}
+
+// checkPlatform verifies that image was built for the Docker host's platform, so that a
+// mismatch is reported clearly rather than as an exec format error from inside the container
+func (d *DockerDriver) checkPlatform(image string) error {
+	if err := docker.EnsureImage(image); err != nil {
+		return err
+	}
+	hostOS, hostArch, err := d.Platform()
+	if err != nil {
+		return err
+	}
+	imageOS, imageArch, err := docker.ImagePlatform(image)
+	if err != nil {
+		return err
+	}
+	if imageOS != hostOS || imageArch != hostArch {
+		return fmt.Errorf("invocation image %s is built for %s/%s but the Docker host is %s/%s", image, imageOS, imageArch, hostOS, hostArch)
+	}
+	return nil
+}
+
+// checkDigest resolves image in its registry and verifies that it still has the pinned digest
+func checkDigest(image, pinned string) error {
+	if err := docker.Pull(image); err != nil {
+		return err
+	}
+	actual, err := docker.Digest(image)
+	if err != nil {
+		return err
+	}
+	if actual != pinned {
+		return fmt.Errorf("invocation image %s resolves to %s but the bundle pins %s; refusing to run (use --skip-digest-check to override)", image, actual, pinned)
+	}
+	return nil
+}
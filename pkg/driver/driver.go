@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Operation describes the data passed into the driver to run an operation
+type Operation struct {
+	// Installation is the name of this installation
+	Installation string `json:"installation_name"`
+	// Action is the action to be performed
+	Action string `json:"action"`
+	// Image is the invocation image
+	Image string `json:"image"`
+	// Digest is the digest the invocation image is pinned to, if any
+	Digest string `json:"digest,omitempty"`
+	// ImageType is the type of image
+	ImageType string `json:"image_type"`
+	// Environment contains environment variables that should be injected into the invocation image
+	Environment map[string]string `json:"environment"`
+	// Files contains files that should be injected into the invocation image, keyed by destination path
+	Files map[string]string `json:"files"`
+	// Out is the writer the invocation image's output is copied to
+	Out io.Writer `json:"-"`
+}
+
+// Driver is capable of running a invocation image
+type Driver interface {
+	// Run executes the operation inside of the invocation image
+	Run(*Operation) error
+	// Handles receives an ImageType* and answers whether this driver supports that type
+	Handles(string) bool
+}
+
+// Configurable drivers can explain their configuration, and have it explicitly set
+type Configurable interface {
+	// Config returns a map of configuration names and values that can be set via environment variable
+	Config() map[string]string
+	// SetConfig allows setting configuration, where name corresponds to the key in Config, and value is
+	// the value to be set.
+	SetConfig(map[string]string)
+}
+
+// Platformer is implemented by drivers that run images on a known platform
+type Platformer interface {
+	// Platform returns the operating system and architecture images are run on
+	Platform() (os, arch string, err error)
+}
+
+// ImageTypes lists the image types a driver knows how to run
+var ImageTypes = []string{"docker", "oci"}
+
+// Lookup takes a driver name and tries to resolve the most pertinent driver.
+func Lookup(name string) (Driver, error) {
+	switch name {
+	case "docker":
+		return &DockerDriver{}, nil
+	case "debug":
+		return &DebugDriver{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported driver: %s", name)
+	}
+}
+
+// SupportedTypes filters ImageTypes down to those d can run
+func SupportedTypes(d Driver) []string {
+	var types []string
+	for _, t := range ImageTypes {
+		if d.Handles(t) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func output(op *Operation) io.Writer {
+	if op.Out != nil {
+		return op.Out
+	}
+	return os.Stdout
+}
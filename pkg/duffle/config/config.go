@@ -0,0 +1,341 @@
+// Package config holds the user's defaults for duffle commands, kept in config.yaml in
+// duffle home, so that they need not be repeated as flags on every command.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/printer"
+)
+
+// Config holds the defaults of duffle commands. Flags given on the command line take
+// precedence over them.
+type Config struct {
+	// Driver is the driver bundles are run with
+	Driver string `json:"driver,omitempty"`
+	// Repository is the repository bundles given as NAME[:VERSION] are looked up in when
+	// the local store does not hold them
+	Repository string `json:"repository,omitempty"`
+	// Output is the output format of results: table, json or yaml
+	Output string `json:"output,omitempty"`
+	// Claims configures where the claims of installations are stored
+	Claims Claims `json:"claims,omitempty"`
+	// Registry configures how registries are accessed
+	Registry Registry `json:"registry,omitempty"`
+	// Credentials is the file holding saved registry credentials; registries.json in
+	// duffle home when empty
+	Credentials string `json:"credentials,omitempty"`
+	// Telemetry configures the reporting of anonymous usage
+	Telemetry Telemetry `json:"telemetry,omitempty"`
+	// ReleaseKeys are the fingerprints of the keys allowed to sign the releases 'duffle
+	// self-update' installs; the keys built into duffle when empty
+	ReleaseKeys []string `json:"releaseKeys,omitempty"`
+	// CurrentContext names the context in use, whose defaults take precedence
+	CurrentContext string `json:"currentContext,omitempty"`
+	// Contexts maps names to sets of defaults, such as those of an environment
+	Contexts map[string]*Context `json:"contexts,omitempty"`
+}
+
+// Telemetry configures the reporting of anonymous usage
+type Telemetry struct {
+	// Mode is on to report usage, and off, the default, not to
+	Mode string `json:"mode,omitempty"`
+	// Endpoint is the URL reports are sent to, such as a self-hosted collector; the
+	// endpoint of the duffle project when empty
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Telemetry modes
+const (
+	TelemetryOn  = "on"
+	TelemetryOff = "off"
+)
+
+// Context is a named set of defaults, such as those of a dev, staging or prod environment.
+// The values set in the current context take precedence over the other defaults.
+type Context struct {
+	// Driver is the driver bundles are run with
+	Driver string `json:"driver,omitempty"`
+	// Repository is the default repository
+	Repository string `json:"repository,omitempty"`
+	// Claims configures where the claims of installations are stored
+	Claims Claims `json:"claims,omitempty"`
+	// Credentials is the file holding saved registry credentials
+	Credentials string `json:"credentials,omitempty"`
+}
+
+// Claims configures the claim store
+type Claims struct {
+	// Backend is the kind of claim store; filesystem, the only one, when empty
+	Backend string `json:"backend,omitempty"`
+	// Path is the directory of the filesystem claim store; claims in duffle home when empty
+	Path string `json:"path,omitempty"`
+}
+
+// Registry configures how registries are accessed
+type Registry struct {
+	// ChunkSize is the size, in bytes, of the chunks large blobs are uploaded in
+	ChunkSize int64 `json:"chunkSize,omitempty"`
+	// PlainHTTP lists the registries, as HOST[:PORT], accessed over plain HTTP rather
+	// than HTTPS, besides those on localhost
+	PlainHTTP []string `json:"plainHTTP,omitempty"`
+}
+
+// FilesystemBackend is the claim store keeping each claim in a JSON file of a directory
+const FilesystemBackend = "filesystem"
+
+// Load reads the configuration at path. The configuration is empty when the file does not
+// exist.
+func Load(path string) (*Config, error) {
+	c := &Config{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// WriteFile saves the configuration to path
+func (c *Config) WriteFile(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// Validate checks the values of the configuration
+func (c *Config) Validate() error {
+	if c.Output != "" {
+		if _, err := printer.ParseFormat(c.Output); err != nil {
+			return err
+		}
+	}
+	switch c.Claims.Backend {
+	case "", FilesystemBackend:
+	default:
+		return fmt.Errorf("unknown claim store backend %q: expected %s", c.Claims.Backend, FilesystemBackend)
+	}
+	if c.Registry.ChunkSize < 0 {
+		return fmt.Errorf("invalid registry chunk size %d", c.Registry.ChunkSize)
+	}
+	switch c.Telemetry.Mode {
+	case "", TelemetryOn, TelemetryOff:
+	default:
+		return fmt.Errorf("invalid telemetry mode %q: expected %s or %s", c.Telemetry.Mode, TelemetryOn, TelemetryOff)
+	}
+	if e := c.Telemetry.Endpoint; e != "" {
+		if u, err := url.Parse(e); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid telemetry endpoint %q: expected an http(s) URL", e)
+		}
+	}
+	for _, fp := range c.ReleaseKeys {
+		if !validFingerprint(fp) {
+			return fmt.Errorf("invalid release key %q: expected the fingerprint of a key, as 40 hexadecimal digits", fp)
+		}
+	}
+	for name, ctx := range c.Contexts {
+		if !ValidContextName(name) {
+			return fmt.Errorf("invalid context name %q", name)
+		}
+		if ctx == nil {
+			return fmt.Errorf("context %s is empty", name)
+		}
+		switch ctx.Claims.Backend {
+		case "", FilesystemBackend:
+		default:
+			return fmt.Errorf("context %s: unknown claim store backend %q: expected %s", name, ctx.Claims.Backend, FilesystemBackend)
+		}
+	}
+	if c.CurrentContext != "" && c.Contexts[c.CurrentContext] == nil {
+		return fmt.Errorf("current context %s does not exist", c.CurrentContext)
+	}
+	return nil
+}
+
+// ValidContextName reports whether name may name a context: letters, digits, '.', '-' and
+// '_'
+func ValidContextName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validFingerprint reports whether fp is the fingerprint of an OpenPGP key, as 40
+// hexadecimal digits
+func validFingerprint(fp string) bool {
+	if len(fp) != 40 {
+		return false
+	}
+	for _, c := range fp {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Current returns the defaults in effect: those of the configuration, overridden by the
+// values set in the current context
+func (c *Config) Current() *Config {
+	cur := *c
+	ctx := c.Contexts[c.CurrentContext]
+	if ctx == nil {
+		return &cur
+	}
+	if ctx.Driver != "" {
+		cur.Driver = ctx.Driver
+	}
+	if ctx.Repository != "" {
+		cur.Repository = ctx.Repository
+	}
+	if ctx.Claims.Backend != "" {
+		cur.Claims.Backend = ctx.Claims.Backend
+	}
+	if ctx.Claims.Path != "" {
+		cur.Claims.Path = ctx.Claims.Path
+	}
+	if ctx.Credentials != "" {
+		cur.Credentials = ctx.Credentials
+	}
+	return &cur
+}
+
+// setting is a key of the configuration, as used with Get and Set
+type setting struct {
+	get func(c *Config) string
+	set func(c *Config, value string) error
+}
+
+func stringSetting(field func(c *Config) *string) setting {
+	return setting{
+		get: func(c *Config) string { return *field(c) },
+		set: func(c *Config, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+// listSetting is a setting holding a comma-separated list
+func listSetting(field func(c *Config) *[]string) setting {
+	return setting{
+		get: func(c *Config) string { return strings.Join(*field(c), ",") },
+		set: func(c *Config, value string) error {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			*field(c) = items
+			return nil
+		},
+	}
+}
+
+var settings = map[string]setting{
+	"driver":             stringSetting(func(c *Config) *string { return &c.Driver }),
+	"repository":         stringSetting(func(c *Config) *string { return &c.Repository }),
+	"output":             stringSetting(func(c *Config) *string { return &c.Output }),
+	"claims.backend":     stringSetting(func(c *Config) *string { return &c.Claims.Backend }),
+	"claims.path":        stringSetting(func(c *Config) *string { return &c.Claims.Path }),
+	"credentials":        stringSetting(func(c *Config) *string { return &c.Credentials }),
+	"telemetry":          stringSetting(func(c *Config) *string { return &c.Telemetry.Mode }),
+	"telemetry.endpoint": stringSetting(func(c *Config) *string { return &c.Telemetry.Endpoint }),
+	"registry.chunkSize": {
+		get: func(c *Config) string {
+			if c.Registry.ChunkSize == 0 {
+				return ""
+			}
+			return strconv.FormatInt(c.Registry.ChunkSize, 10)
+		},
+		set: func(c *Config, value string) error {
+			if value == "" {
+				c.Registry.ChunkSize = 0
+				return nil
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid chunk size %q: expected a number of bytes", value)
+			}
+			c.Registry.ChunkSize = n
+			return nil
+		},
+	},
+	"registry.plainHTTP": listSetting(func(c *Config) *[]string { return &c.Registry.PlainHTTP }),
+	"releaseKeys":        listSetting(func(c *Config) *[]string { return &c.ReleaseKeys }),
+}
+
+// Keys lists the keys of the configuration, sorted
+func Keys() []string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the value of key, such as driver or registry.chunkSize, as a string. Lists
+// are comma-separated. Unset keys have an empty value.
+func (c *Config) Get(key string) (string, error) {
+	s, ok := settings[key]
+	if !ok {
+		return "", unknownKey(key)
+	}
+	return s.get(c), nil
+}
+
+// Set sets key to value, as Get returns it; an empty value unsets the key
+func (c *Config) Set(key, value string) error {
+	s, ok := settings[key]
+	if !ok {
+		return unknownKey(key)
+	}
+	updated := *c
+	if err := s.set(&updated, value); err != nil {
+		return err
+	}
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+	*c = updated
+	return nil
+}
+
+func unknownKey(key string) error {
+	return fmt.Errorf("unknown configuration key %q (known keys: %s)", key, strings.Join(Keys(), ", "))
+}
@@ -0,0 +1,177 @@
+package home
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Home describes the location of a CLI configuration.
+//
+// This helper builds paths relative to a Duffle Home directory. The home returned by
+// XDGHome follows the XDG base directory specification instead: it holds the data of
+// duffle, while its configuration and cache are kept in the XDG configuration and cache
+// directories.
+type Home string
+
+// DefaultHome returns the value of $DUFFLE_HOME. When it is unset, the home is ~/.duffle if
+// that directory exists, and the one returned by XDGHome otherwise.
+func DefaultHome() string {
+	if h := os.Getenv("DUFFLE_HOME"); h != "" {
+		return h
+	}
+	legacy := filepath.Join(homeDir(), ".duffle")
+	if fi, err := os.Stat(legacy); err == nil && fi.IsDir() {
+		return legacy
+	}
+	return XDGHome()
+}
+
+// XDGHome returns the data directory of duffle in the XDG layout, $XDG_DATA_HOME/duffle.
+func XDGHome() string {
+	return filepath.Join(xdgDir("XDG_DATA_HOME", ".local", "share"), "duffle")
+}
+
+// IsXDG reports whether the home follows the XDG layout, splitting its configuration and
+// cache out of the home directory.
+func (h Home) IsXDG() bool {
+	return filepath.Clean(h.String()) == XDGHome()
+}
+
+// String returns Home as a string.
+//
+// Implements fmt.Stringer.
+func (h Home) String() string {
+	return string(h)
+}
+
+// Path returns Home with elements appended.
+func (h Home) Path(elem ...string) string {
+	p := []string{h.String()}
+	p = append(p, elem...)
+	return filepath.Join(p...)
+}
+
+// ConfigPath returns a path in the configuration directory of the home: the home itself,
+// or $XDG_CONFIG_HOME/duffle in the XDG layout.
+func (h Home) ConfigPath(elem ...string) string {
+	if h.IsXDG() {
+		return filepath.Join(append([]string{xdgDir("XDG_CONFIG_HOME", ".config"), "duffle"}, elem...)...)
+	}
+	return h.Path(elem...)
+}
+
+// CachePath returns a path in the cache directory of the home: cache in the home, or
+// $XDG_CACHE_HOME/duffle in the XDG layout.
+func (h Home) CachePath(elem ...string) string {
+	if h.IsXDG() {
+		return filepath.Join(append([]string{xdgDir("XDG_CACHE_HOME", ".cache"), "duffle"}, elem...)...)
+	}
+	return h.Path(append([]string{"cache"}, elem...)...)
+}
+
+// Bundles returns the path to the local bundle store.
+func (h Home) Bundles() string {
+	return h.Path("bundles")
+}
+
+// BundleIndex returns the path to the index of the local bundle store.
+func (h Home) BundleIndex() string {
+	return h.Path("bundles", "index.json")
+}
+
+// Claims returns the path to the claim store.
+func (h Home) Claims() string {
+	return h.Path("claims")
+}
+
+// Plugins returns the path to the directory holding installed plugins.
+func (h Home) Plugins() string {
+	return h.Path("plugins")
+}
+
+// Lock returns the path to the lock file serializing the duffle processes modifying the home.
+func (h Home) Lock() string {
+	return h.Path("duffle.lock")
+}
+
+// Cache returns the path to the directory holding downloaded content.
+func (h Home) Cache() string {
+	return h.CachePath()
+}
+
+// SecretKeyring returns the path to the keyring holding signing keys.
+func (h Home) SecretKeyring() string {
+	return h.Path("secret.ring")
+}
+
+// PublicKeyring returns the path to the keyring holding trusted public keys.
+func (h Home) PublicKeyring() string {
+	return h.Path("public.ring")
+}
+
+// TrustPolicy returns the path to the file restricting which keys may sign bundles from
+// each repository or registry.
+func (h Home) TrustPolicy() string {
+	return h.ConfigPath("trust-policy.json")
+}
+
+// Repositories returns the path to the file listing configured bundle repositories.
+func (h Home) Repositories() string {
+	return h.ConfigPath("repositories.json")
+}
+
+// Registries returns the path to the file holding registry credentials and settings.
+func (h Home) Registries() string {
+	return h.ConfigPath("registries.json")
+}
+
+// UploadSessions returns the path to the file remembering unfinished registry uploads.
+func (h Home) UploadSessions() string {
+	return h.CachePath("uploads.json")
+}
+
+// Config returns the path to the file holding the defaults of duffle commands.
+func (h Home) Config() string {
+	return h.ConfigPath("config.yaml")
+}
+
+// BuildConfig returns the path to the file holding build settings shared by every project.
+func (h Home) BuildConfig() string {
+	return h.ConfigPath("build.toml")
+}
+
+// TemplateRepositories returns the path to the file listing git repositories of project templates.
+func (h Home) TemplateRepositories() string {
+	return h.ConfigPath("templates.json")
+}
+
+// TemplateCache returns the path to the directory holding fetched project templates.
+func (h Home) TemplateCache() string {
+	return h.CachePath("templates")
+}
+
+// BuildCache returns the path to the directory remembering the inputs of image builds.
+func (h Home) BuildCache() string {
+	return h.CachePath("builds")
+}
+
+// RepositoryCache returns the path to the directory holding cached repository indexes.
+func (h Home) RepositoryCache() string {
+	return h.CachePath("repositories")
+}
+
+// xdgDir returns the value of the XDG variable env, or the directory elem in the user's
+// home directory when it is unset or not absolute, as the specification requires
+func xdgDir(env string, elem ...string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(append([]string{homeDir()}, elem...)...)
+}
+
+func homeDir() string {
+	if dir, err := os.UserHomeDir(); err == nil {
+		return dir
+	}
+	return os.Getenv("HOME")
+}
@@ -0,0 +1,38 @@
+package home
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+)
+
+// VersionFile returns the path to the file recording the version of the layout of the home.
+func (h Home) VersionFile() string {
+	return h.Path("version")
+}
+
+// Version returns the version of the layout of the home. Homes set up before the layout was
+// versioned have no version file, and are at version 0.
+func (h Home) Version() (int, error) {
+	data, err := ioutil.ReadFile(h.VersionFile())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid layout version in %s: %q", h.VersionFile(), strings.TrimSpace(string(data)))
+	}
+	return v, nil
+}
+
+// SetVersion records v as the version of the layout of the home.
+func (h Home) SetVersion(v int) error {
+	return atomicfile.WriteFile(h.VersionFile(), []byte(strconv.Itoa(v)+"\n"), 0644)
+}
@@ -0,0 +1,130 @@
+package loader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+)
+
+// Cache stores fetched bundle documents by the digest of their content
+type Cache struct {
+	dir string
+}
+
+// CacheEntry describes a cached bundle document
+type CacheEntry struct {
+	Digest   string    `json:"digest"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// NewCache returns a cache stored in dir
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// path returns the path of the entry with digest d, which must be a valid digest so that
+// it cannot name files outside the cache
+func (c *Cache) path(d string) (string, error) {
+	if err := digest.Validate(d); err != nil {
+		return "", err
+	}
+	return filepath.Join(c.dir, strings.Replace(d, ":", "-", 1)), nil
+}
+
+// Get returns the cached document with digest d, if present and intact
+func (c *Cache) Get(d string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	path, err := c.path(d)
+	if err != nil {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if digest.OfBuffer(data) != d {
+		// a corrupt entry is treated as missing and overwritten by the next Put
+		return nil, false
+	}
+	return data, true
+}
+
+// Put stores data in the cache, returning its digest
+func (c *Cache) Put(data []byte) (string, error) {
+	d := digest.OfBuffer(data)
+	if c == nil {
+		return d, nil
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	path, err := c.path(d)
+	if err != nil {
+		return "", err
+	}
+	return d, os.Rename(tmp.Name(), path)
+}
+
+// List returns every cache entry, most recently modified first
+func (c *Cache) List() ([]CacheEntry, error) {
+	files, err := ioutil.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return []CacheEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := []CacheEntry{}
+	for _, f := range files {
+		d := strings.Replace(f.Name(), "-", ":", 1)
+		if f.IsDir() || digest.Validate(d) != nil {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Digest:   d,
+			Size:     f.Size(),
+			Modified: f.ModTime(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Modified.After(entries[j].Modified) })
+	return entries, nil
+}
+
+// Remove deletes the entry with digest d
+func (c *Cache) Remove(d string) error {
+	path, err := c.path(d)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s is not cached", d)
+	}
+	return err
+}
+
+// Purge deletes every entry
+func (c *Cache) Purge() error {
+	return os.RemoveAll(c.dir)
+}
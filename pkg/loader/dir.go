@@ -0,0 +1,166 @@
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// bundleFiles are the names a bundle document may have within a bundle directory, in order of preference
+var bundleFiles = []string{"bundle.json", "bundle.yaml", "bundle.yml", "bundle.cnab"}
+
+// Handle is a bundle loaded from a directory, together with its auxiliary files
+type Handle struct {
+	// Bundle is the parsed bundle document
+	Bundle *bundle.Bundle
+	// Dir is the directory the bundle was loaded from
+	Dir string
+	// Readme holds the contents of README.md, if present
+	Readme string
+	// Parameters holds parameter value templates from parameters.yaml or parameters.json, if present
+	Parameters map[string]interface{}
+	// OutputsSchema holds the raw JSON schema from outputs.schema.json, if present
+	OutputsSchema json.RawMessage
+	// Signers identify the keys whose signature on the bundle document verified, if it
+	// was signed
+	Signers []*signature.KeyInfo
+	// Document holds the bundle document exactly as it was pulled, for bundles loaded from
+	// registries
+	Document []byte
+}
+
+// DirLoader loads unpacked bundles from a directory.
+//
+// The directory holds the bundle document (bundle.json, bundle.yaml or a signed bundle.cnab)
+// alongside optional auxiliary files: README.md, parameters.yaml (or parameters.json) with
+// parameter value templates, and outputs.schema.json describing the bundle's outputs.
+type DirLoader struct {
+	// Options control how the bundle document is parsed
+	Options Options
+	// Keyring is the public keyring used to verify signed documents; see SignedLoader
+	Keyring string
+}
+
+// IsDir reports whether source is a local directory
+func IsDir(source string) bool {
+	fi, err := os.Stat(source)
+	return err == nil && fi.IsDir()
+}
+
+// Load a bundle from a directory
+func (l *DirLoader) Load(dir string) (*bundle.Bundle, error) {
+	h, err := l.LoadHandle(dir)
+	if err != nil {
+		return nil, err
+	}
+	return h.Bundle, nil
+}
+
+// LoadSigned loads a bundle from a directory, returning the verified signers if it was signed
+func (l *DirLoader) LoadSigned(dir string) (*bundle.Bundle, []*signature.KeyInfo, error) {
+	h, err := l.LoadHandle(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h.Bundle, h.Signers, nil
+}
+
+// LoadData loads a bundle document from raw data
+func (l *DirLoader) LoadData(data []byte) (*bundle.Bundle, error) {
+	parser, err := l.Options.parser()
+	if err != nil {
+		return nil, err
+	}
+	if parser == nil {
+		parser = detect(data, "", l.Options.Strict)
+	}
+	return parser.LoadData(data)
+}
+
+// LoadHandle loads the bundle in dir along with its auxiliary files
+func (l *DirLoader) LoadHandle(dir string) (*Handle, error) {
+	h, err := loadHandle(os.DirFS(dir), dir, l.Options, l.Keyring)
+	if err != nil {
+		return nil, err
+	}
+	h.Dir = dir
+	return h, nil
+}
+
+// loadHandle loads the bundle at the root of fsys along with its auxiliary files.
+// name describes fsys in error messages.
+func loadHandle(fsys fs.FS, name string, opts Options, keyring string) (*Handle, error) {
+	h := &Handle{}
+
+	doc, err := findBundleFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.ReadFile(fsys, doc)
+	if err != nil {
+		return nil, err
+	}
+	h.Bundle, h.Signers, err = opts.loadData(data, doc, keyring)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path.Join(name, doc), err)
+	}
+
+	if readme, err := readOptional(fsys, "README.md"); err != nil {
+		return nil, err
+	} else if readme != nil {
+		h.Readme = string(readme)
+	}
+
+	for _, file := range []string{"parameters.yaml", "parameters.json"} {
+		values, err := readOptional(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			continue
+		}
+		if err := yaml.Unmarshal(values, &h.Parameters); err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %v", file, err)
+		}
+		break
+	}
+
+	schema, err := readOptional(fsys, "outputs.schema.json")
+	if err != nil {
+		return nil, err
+	}
+	if schema != nil {
+		if !json.Valid(schema) {
+			return nil, fmt.Errorf("outputs.schema.json is not valid JSON")
+		}
+		h.OutputsSchema = schema
+	}
+
+	return h, nil
+}
+
+func findBundleFile(fsys fs.FS, name string) (string, error) {
+	for _, file := range bundleFiles {
+		if _, err := fs.Stat(fsys, file); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("no bundle document found in %s", name)
+}
+
+// readOptional reads a file, returning nil data if it does not exist
+func readOptional(fsys fs.FS, file string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
@@ -0,0 +1,111 @@
+package loader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+)
+
+// urlEntry records the cached copy of a document fetched from a URL, with the validators
+// the server sent for it
+type urlEntry struct {
+	URL          string `json:"url"`
+	Digest       string `json:"digest"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+func (c *Cache) urlPath(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return filepath.Join(c.dir, "urls", hex.EncodeToString(sum[:])+".json")
+}
+
+// Fetch downloads the document at u, using http.DefaultClient when client is nil.
+//
+// When a copy from an earlier fetch is cached, the request is made conditional on it having
+// changed (If-None-Match / If-Modified-Since), and the cached copy is returned if the server
+// answers 304 Not Modified. A nil cache always downloads the document.
+func (c *Cache) Fetch(client *http.Client, u string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	cached, entry := c.lookupURL(u)
+	if cached != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s: %s", u, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if c != nil && (etag != "" || modified != "") {
+		if err := c.storeURL(u, data, etag, modified); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// lookupURL returns the cached copy of the document at u along with its validators
+func (c *Cache) lookupURL(u string) ([]byte, urlEntry) {
+	var entry urlEntry
+	if c == nil {
+		return nil, entry
+	}
+	data, err := ioutil.ReadFile(c.urlPath(u))
+	if err != nil {
+		return nil, entry
+	}
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != u {
+		return nil, entry
+	}
+	doc, ok := c.Get(entry.Digest)
+	if !ok {
+		return nil, entry
+	}
+	return doc, entry
+}
+
+// storeURL caches data as the document at u, recording its validators
+func (c *Cache) storeURL(u string, data []byte, etag, modified string) error {
+	d, err := c.Put(data)
+	if err != nil {
+		return err
+	}
+	entry, err := json.Marshal(urlEntry{URL: u, Digest: d, ETag: etag, LastModified: modified})
+	if err != nil {
+		return err
+	}
+	p := c.urlPath(u)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(p, entry, 0644)
+}
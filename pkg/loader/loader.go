@@ -0,0 +1,203 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// Loader provides an interface for loading a bundle
+type Loader interface {
+	// Load a bundle from a local file
+	Load(source string) (*bundle.Bundle, error)
+	// LoadData loads a bundle from raw data
+	LoadData(data []byte) (*bundle.Bundle, error)
+}
+
+// VerifyingLoader is implemented by loaders that can report who signed the bundle they loaded
+type VerifyingLoader interface {
+	// LoadSigned loads a bundle, returning the keys whose signature verified, or none if
+	// the bundle was not signed
+	LoadSigned(source string) (*bundle.Bundle, []*signature.KeyInfo, error)
+}
+
+// Formats lists the document formats that can be selected explicitly
+var Formats = []string{"json", "yaml", "signed"}
+
+// Options control how a bundle document is parsed
+type Options struct {
+	// Format forces the document format (one of Formats); it is detected when empty
+	Format string
+	// Strict rejects fields the bundle format does not define
+	Strict bool
+	// Keys verify signed documents in place of a keyring file when set. Signed documents
+	// loaded from readers and file systems require them.
+	Keys *signature.KeyRing
+	// Cache stores the documents fetched from URLs and registries; nothing is cached when
+	// it is nil
+	Cache *Cache
+	// Insecure loads signed documents whose signature cannot be verified, without
+	// reporting a signer
+	Insecure bool
+}
+
+// parser returns the loader for the forced format, or nil if the format is to be detected
+func (o Options) parser() (Loader, error) {
+	switch o.Format {
+	case "":
+		return nil, nil
+	case "json":
+		return &JSONLoader{Strict: o.Strict}, nil
+	case "yaml":
+		return &YAMLLoader{Strict: o.Strict}, nil
+	case "signed":
+		return &SignedLoader{Strict: o.Strict, Keys: o.Keys, Insecure: o.Insecure}, nil
+	default:
+		return nil, fmt.Errorf("unknown bundle format %q (expected one of %s)", o.Format, strings.Join(Formats, ", "))
+	}
+}
+
+// New returns the loader able to read the bundle at source, detecting its format.
+//
+// Sources may be local files or bundle directories, http(s) URLs, registry references
+// or "-" for standard input.
+func New(source string) (Loader, error) {
+	return NewWithOptions(source, Options{})
+}
+
+// NewWithOptions returns the loader able to read the bundle at source, parsing it as opts describe
+func NewWithOptions(source string, opts Options) (Loader, error) {
+	parser, err := opts.parser()
+	if err != nil {
+		return nil, err
+	}
+	if source == StdinSource {
+		return &StdinLoader{Parser: parser, Keys: opts.Keys, Strict: opts.Strict, Insecure: opts.Insecure}, nil
+	}
+	if IsURL(source) {
+		return &URLLoader{Cache: opts.Cache, Parser: parser, Keys: opts.Keys, Strict: opts.Strict, Insecure: opts.Insecure}, nil
+	}
+	if IsDir(source) {
+		return &DirLoader{Options: opts}, nil
+	}
+	if IsRegistryReference(source) {
+		if opts.Format != "" && opts.Format != "json" {
+			return nil, fmt.Errorf("bundles in registries are always stored as json, not %s", opts.Format)
+		}
+		return &OCILoader{Cache: opts.Cache, Strict: opts.Strict}, nil
+	}
+	if parser != nil {
+		return parser, nil
+	}
+	if isSignedFile(source) {
+		return &SignedLoader{Strict: opts.Strict, Keys: opts.Keys, Insecure: opts.Insecure}, nil
+	}
+	return forPath(source, opts.Strict), nil
+}
+
+// loadData parses a document fetched from name, returning the verified signers if it was signed.
+// keyring is the keyring file used to verify signed documents when no Keys are given.
+func (o Options) loadData(data []byte, name, keyring string) (*bundle.Bundle, []*signature.KeyInfo, error) {
+	parser, err := o.parser()
+	if err != nil {
+		return nil, nil, err
+	}
+	if parser == nil {
+		parser = detect(data, name, o.Strict)
+	}
+	if sl, ok := parser.(*SignedLoader); ok {
+		sl.Keyring = keyring
+		sl.Keys = o.Keys
+		sl.Insecure = o.Insecure
+		return sl.LoadSignedData(data)
+	}
+	b, err := parser.LoadData(data)
+	return b, nil, err
+}
+
+// isSignedFile reports whether the local file at path holds a clearsigned document
+func isSignedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 64)
+	n, _ := io.ReadFull(f, head)
+	return signature.IsClearsigned(head[:n])
+}
+
+// HomeCache returns the bundle cache in the given duffle home
+func HomeCache(h home.Home) *Cache {
+	return NewCache(filepath.Join(h.Cache(), "bundles"))
+}
+
+// forPath picks a loader for a document based on its file extension
+func forPath(path string, strict bool) Loader {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return &YAMLLoader{Strict: strict}
+	default:
+		return &JSONLoader{Strict: strict}
+	}
+}
+
+// detect picks a loader for a document fetched from name, based on its content
+func detect(data []byte, name string, strict bool) Loader {
+	if signature.IsClearsigned(data) {
+		return &SignedLoader{Strict: strict}
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return &YAMLLoader{Strict: strict}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return &JSONLoader{Strict: strict}
+	}
+	return &YAMLLoader{Strict: strict}
+}
+
+// unmarshal decodes a bundle.json document, strictly if requested
+func unmarshal(data []byte, strict bool) (*bundle.Bundle, error) {
+	if strict {
+		return bundle.UnmarshalStrict(data)
+	}
+	return bundle.Unmarshal(data)
+}
+
+// Load reads the bundle at source using the loader suited to it
+func Load(source string) (*bundle.Bundle, error) {
+	l, err := New(source)
+	if err != nil {
+		return nil, err
+	}
+	return l.Load(source)
+}
+
+// JSONLoader loads canonical bundle.json documents
+type JSONLoader struct {
+	// Strict rejects fields the bundle format does not define
+	Strict bool
+}
+
+// Load a bundle from a local file
+func (l *JSONLoader) Load(source string) (*bundle.Bundle, error) {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	return l.LoadData(data)
+}
+
+// LoadData loads a bundle from raw data
+func (l *JSONLoader) LoadData(data []byte) (*bundle.Bundle, error) {
+	return unmarshal(data, l.Strict)
+}
@@ -0,0 +1,80 @@
+package loader
+
+import (
+	"os"
+	"strings"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/registry"
+)
+
+// OCIPrefix explicitly marks a source as a registry reference
+const OCIPrefix = "oci://"
+
+// OCILoader loads bundles stored as artifacts in an OCI registry
+type OCILoader struct {
+	// Client is the registry client; when nil, the registry is accessed with the
+	// credentials of the docker CLI, if any
+	Client *registry.Client
+	// Cache holds previously fetched documents, keyed by the digest recorded in the manifest
+	Cache *Cache
+	// Strict rejects fields the bundle format does not define
+	Strict bool
+}
+
+// IsRegistryReference reports whether source names a bundle in a registry.
+//
+// Sources prefixed with oci:// always do. Otherwise, a source that is not a local file
+// and parses as an image reference with an explicit registry domain is treated as one.
+func IsRegistryReference(source string) bool {
+	if strings.HasPrefix(source, OCIPrefix) {
+		return true
+	}
+	if _, err := os.Stat(source); err == nil {
+		return false
+	}
+	domain, _ := docker.SplitDomain(source)
+	return domain != "" && docker.ValidReference(source)
+}
+
+// Load fetches the bundle at the registry reference source
+func (l *OCILoader) Load(source string) (*bundle.Bundle, error) {
+	data, _, err := l.Pull(source)
+	if err != nil {
+		return nil, err
+	}
+	return l.LoadData(data)
+}
+
+// Pull fetches the raw bundle document at the registry reference source, along with its manifest digest
+func (l *OCILoader) Pull(source string) ([]byte, string, error) {
+	ref, err := registry.ParseReference(strings.TrimPrefix(source, OCIPrefix))
+	if err != nil {
+		return nil, "", err
+	}
+	client := l.Client
+	if client == nil {
+		client = registry.NewClient(registry.DockerCredentials())
+	}
+	m, manifestDigest, err := client.BundleManifest(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	if data, ok := l.Cache.Get(m.Config.Digest); ok {
+		return data, manifestDigest, nil
+	}
+	data, err := client.FetchVerifiedBlob(ref, m.Config.Digest)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := l.Cache.Put(data); err != nil {
+		return nil, "", err
+	}
+	return data, manifestDigest, nil
+}
+
+// LoadData loads a bundle from raw data
+func (l *OCILoader) LoadData(data []byte) (*bundle.Bundle, error) {
+	return unmarshal(data, l.Strict)
+}
@@ -0,0 +1,46 @@
+package loader
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// LoadReader reads a bundle document from r, parsing it as opts describe.
+//
+// The format is detected from the content unless opts forces one. Signed documents are
+// verified against opts.Keys, and rejected when it is unset unless opts.Insecure is set.
+// Nothing but r is read.
+func LoadReader(r io.Reader, opts Options) (*bundle.Bundle, error) {
+	b, _, err := LoadSignedReader(r, opts)
+	return b, err
+}
+
+// LoadSignedReader reads a bundle document from r, returning the verified signers if it was signed
+func LoadSignedReader(r io.Reader, opts Options) (*bundle.Bundle, []*signature.KeyInfo, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return opts.loadData(data, "", "")
+}
+
+// LoadFS loads an unpacked bundle from dir within fsys, along with its auxiliary files.
+//
+// The layout of dir is the one DirLoader reads, and signed documents are verified as
+// LoadReader does; nothing outside fsys is read. The returned handle's Dir is dir.
+func LoadFS(fsys fs.FS, dir string, opts Options) (*Handle, error) {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	h, err := loadHandle(sub, dir, opts, "")
+	if err != nil {
+		return nil, err
+	}
+	h.Dir = dir
+	return h, nil
+}
@@ -0,0 +1,84 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// SignedLoader loads clearsigned bundle documents, verifying their signature
+type SignedLoader struct {
+	// Keyring is the path of the public keyring holding trusted keys. Documents cannot be
+	// verified when neither Keyring nor Keys is set.
+	Keyring string
+	// Keys are the trusted keys; they take precedence over Keyring when set
+	Keys *signature.KeyRing
+	// Strict rejects fields the bundle format does not define
+	Strict bool
+	// Insecure loads documents whose signature cannot be verified instead of rejecting
+	// them; no signer is reported for them
+	Insecure bool
+}
+
+// Load a signed bundle from a local file
+func (l *SignedLoader) Load(source string) (*bundle.Bundle, error) {
+	b, _, err := l.LoadSigned(source)
+	return b, err
+}
+
+// LoadData loads a signed bundle from raw data
+func (l *SignedLoader) LoadData(data []byte) (*bundle.Bundle, error) {
+	b, _, err := l.LoadSignedData(data)
+	return b, err
+}
+
+// LoadSigned loads a signed bundle from a local file, returning the verified signers
+func (l *SignedLoader) LoadSigned(source string) (*bundle.Bundle, []*signature.KeyInfo, error) {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l.LoadSignedData(data)
+}
+
+// LoadSignedData verifies and loads a signed bundle from raw data, returning the verified
+// signers. Documents may carry signatures by several keys; those by keys missing from the
+// keyring are ignored.
+func (l *SignedLoader) LoadSignedData(data []byte) (*bundle.Bundle, []*signature.KeyInfo, error) {
+	kr, err := l.keyRing()
+	if err != nil && !l.Insecure {
+		return nil, nil, err
+	}
+	if kr == nil {
+		kr = signature.NewKeyRing()
+	}
+	signers, body, err := signature.NewVerifier(kr).VerifyAll(data)
+	if err != nil {
+		if !l.Insecure {
+			return nil, nil, fmt.Errorf("bundle signature is not valid: %v", err)
+		}
+		if body, err = signature.Plaintext(data); err != nil {
+			return nil, nil, err
+		}
+		b, err := unmarshal(body, l.Strict)
+		return b, nil, err
+	}
+	b, err := unmarshal(body, l.Strict)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, signature.Infos(signers), nil
+}
+
+func (l *SignedLoader) keyRing() (*signature.KeyRing, error) {
+	if l.Keys != nil {
+		return l.Keys, nil
+	}
+	if l.Keyring == "" {
+		return nil, errors.New("no trusted keys are given to verify signed bundles with")
+	}
+	return signature.LoadKeyRing(l.Keyring)
+}
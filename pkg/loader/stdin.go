@@ -0,0 +1,73 @@
+package loader
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// StdinSource is the source name that reads a bundle from standard input
+const StdinSource = "-"
+
+// StdinLoader loads a bundle document from standard input.
+//
+// Unless a parser is given, the format is detected from the content: clearsigned documents
+// are verified like SignedLoader does, documents starting with '{' are read as JSON, and
+// anything else as YAML.
+type StdinLoader struct {
+	// In is read instead of os.Stdin when set
+	In io.Reader
+	// Keyring is the public keyring used to verify signed documents; see SignedLoader
+	Keyring string
+	// Keys verify signed documents in place of Keyring when set
+	Keys *signature.KeyRing
+	// Parser parses the document; the format is detected when nil
+	Parser Loader
+	// Strict rejects fields the bundle format does not define when the format is detected
+	Strict bool
+	// Insecure loads signed documents whose signature cannot be verified; see SignedLoader
+	Insecure bool
+}
+
+// Load reads a bundle from standard input; source is ignored
+func (l *StdinLoader) Load(source string) (*bundle.Bundle, error) {
+	b, _, err := l.LoadSigned(source)
+	return b, err
+}
+
+// LoadSigned reads a bundle from standard input, returning the verified signers if it was signed
+func (l *StdinLoader) LoadSigned(source string) (*bundle.Bundle, []*signature.KeyInfo, error) {
+	in := l.In
+	if in == nil {
+		in = os.Stdin
+	}
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sl, ok := l.parser(data).(*SignedLoader); ok {
+		sl.Keyring = l.Keyring
+		if sl.Keys == nil {
+			sl.Keys = l.Keys
+		}
+		sl.Insecure = sl.Insecure || l.Insecure
+		return sl.LoadSignedData(data)
+	}
+	b, err := l.LoadData(data)
+	return b, nil, err
+}
+
+// LoadData loads a bundle from raw data
+func (l *StdinLoader) LoadData(data []byte) (*bundle.Bundle, error) {
+	return l.parser(data).LoadData(data)
+}
+
+func (l *StdinLoader) parser(data []byte) Loader {
+	if l.Parser != nil {
+		return l.Parser
+	}
+	return detect(data, "", l.Strict)
+}
@@ -0,0 +1,118 @@
+package loader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/crypto/digest"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// URLLoader loads a bundle document over HTTP(S).
+//
+// A "#sha256=<hex>" fragment on the URL pins the expected checksum of the document.
+type URLLoader struct {
+	// Client is the HTTP client used to fetch bundles; http.DefaultClient is used when nil
+	Client *http.Client
+	// Cache holds previously fetched documents. Checksum-pinned URLs are served from it
+	// directly; other documents are revalidated with the server before being reused.
+	Cache *Cache
+	// Parser parses the fetched document; the format is detected when nil
+	Parser Loader
+	// Strict rejects fields the bundle format does not define when the format is detected
+	Strict bool
+	// Keyring is the public keyring used to verify signed documents; see SignedLoader
+	Keyring string
+	// Keys verify signed documents in place of Keyring when set
+	Keys *signature.KeyRing
+	// Insecure loads signed documents whose signature cannot be verified; see SignedLoader
+	Insecure bool
+}
+
+// IsURL reports whether source refers to a remote bundle
+func IsURL(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
+
+// Load fetches and parses the bundle at the URL source
+func (l *URLLoader) Load(source string) (*bundle.Bundle, error) {
+	b, _, err := l.LoadSigned(source)
+	return b, err
+}
+
+// LoadSigned fetches and parses the bundle at the URL source, returning the verified
+// signers if it was signed
+func (l *URLLoader) LoadSigned(source string) (*bundle.Bundle, []*signature.KeyInfo, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	checksum, err := parseChecksum(u.Fragment)
+	if err != nil {
+		return nil, nil, err
+	}
+	u.Fragment = ""
+
+	data, ok := l.Cache.Get(digest.Algorithm + ":" + checksum)
+	if checksum == "" || !ok {
+		if data, err = l.fetch(u.String()); err != nil {
+			return nil, nil, err
+		}
+		if checksum != "" {
+			sum := sha256.Sum256(data)
+			if actual := hex.EncodeToString(sum[:]); actual != checksum {
+				return nil, nil, fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", u, checksum, actual)
+			}
+			if _, err := l.Cache.Put(data); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	parser := l.Parser
+	if parser == nil {
+		parser = detect(data, u.Path, l.Strict)
+	}
+	if sl, ok := parser.(*SignedLoader); ok {
+		sl.Keyring = l.Keyring
+		if sl.Keys == nil {
+			sl.Keys = l.Keys
+		}
+		sl.Insecure = sl.Insecure || l.Insecure
+		return sl.LoadSignedData(data)
+	}
+	b, err := parser.LoadData(data)
+	return b, nil, err
+}
+
+// LoadData loads a bundle from raw data
+func (l *URLLoader) LoadData(data []byte) (*bundle.Bundle, error) {
+	if l.Parser != nil {
+		return l.Parser.LoadData(data)
+	}
+	return detect(data, "", l.Strict).LoadData(data)
+}
+
+func (l *URLLoader) fetch(u string) ([]byte, error) {
+	return l.Cache.Fetch(l.Client, u)
+}
+
+// parseChecksum extracts the hex digest from a "sha256=<hex>" URL fragment
+func parseChecksum(fragment string) (string, error) {
+	if fragment == "" {
+		return "", nil
+	}
+	parts := strings.SplitN(fragment, "=", 2)
+	if len(parts) != 2 || parts[0] != "sha256" {
+		return "", fmt.Errorf("unsupported checksum %q, expected sha256=<hex>", fragment)
+	}
+	sum := strings.ToLower(parts[1])
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid sha256 checksum %q", parts[1])
+	}
+	return sum, nil
+}
@@ -0,0 +1,37 @@
+package loader
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/deis/duffle/pkg/bundle"
+)
+
+// YAMLLoader loads bundle manifests authored in YAML.
+//
+// The YAML document uses the same field names as bundle.json and is converted to
+// canonical JSON before being parsed.
+type YAMLLoader struct {
+	// Strict rejects fields the bundle format does not define
+	Strict bool
+}
+
+// Load a bundle from a local file
+func (l *YAMLLoader) Load(source string) (*bundle.Bundle, error) {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	return l.LoadData(data)
+}
+
+// LoadData loads a bundle from raw data
+func (l *YAMLLoader) LoadData(data []byte) (*bundle.Bundle, error) {
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse bundle manifest: %v", err)
+	}
+	return unmarshal(j, l.Strict)
+}
@@ -0,0 +1,121 @@
+// Package lock provides advisory file locks, which serialize the duffle processes
+// modifying the same files. Locks are released when their process exits, even when it
+// crashes, so that they never need to be removed by hand.
+package lock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pollInterval is how often a held lock is tried again while waiting for it
+const pollInterval = 100 * time.Millisecond
+
+// Lock is an advisory lock on a file
+type Lock struct {
+	path string
+	f    *os.File
+}
+
+// HeldError is returned when another process holds a lock
+type HeldError struct {
+	// Path is the lock file
+	Path string
+	// Holder describes the process holding the lock, as it recorded itself in the lock
+	// file; it is empty when unknown
+	Holder string
+}
+
+func (e *HeldError) Error() string {
+	if e.Holder == "" {
+		return fmt.Sprintf("%s is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%s is locked by another process (%s)", e.Path, e.Holder)
+}
+
+// New returns the lock of the file at path, which is created when it is first locked
+func New(path string) *Lock {
+	return &Lock{path: path}
+}
+
+// Path returns the lock file
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// TryLock takes the lock if no other process holds it, recording owner, which describes
+// the process, in the lock file. It reports whether the lock was taken.
+func (l *Lock) TryLock(owner string) (bool, error) {
+	if l.f != nil {
+		return true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return false, err
+	}
+	ok, err := tryLock(f)
+	if err != nil || !ok {
+		f.Close()
+		return false, err
+	}
+	l.f = f
+	info := fmt.Sprintf("pid %d: %s\n", os.Getpid(), owner)
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(info), 0)
+	}
+	return true, nil
+}
+
+// Lock takes the lock, waiting up to timeout for the process holding it to release it.
+// waiting is called with the holder of the lock once, when Lock starts waiting. A
+// *HeldError is returned when the lock is still held after timeout.
+func (l *Lock) Lock(owner string, timeout time.Duration, waiting func(holder string)) error {
+	deadline := time.Now().Add(timeout)
+	for first := true; ; first = false {
+		ok, err := l.TryLock(owner)
+		if err != nil {
+			return fmt.Errorf("cannot lock %s: %v", l.path, err)
+		}
+		if ok {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return &HeldError{Path: l.path, Holder: l.Holder()}
+		}
+		if first && waiting != nil {
+			waiting(l.Holder())
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Holder describes the process holding the lock, as it recorded itself in the lock file
+func (l *Lock) Holder() string {
+	data, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Unlock releases the lock, if it is held
+func (l *Lock) Unlock() error {
+	if l.f == nil {
+		return nil
+	}
+	f := l.f
+	l.f = nil
+	f.Truncate(0)
+	err := unlock(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
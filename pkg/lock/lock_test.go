@@ -0,0 +1,172 @@
+package lock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempLockPath(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "duffle-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "sub", "repositories.lock"), func() { os.RemoveAll(dir) }
+}
+
+func TestTryLock(t *testing.T) {
+	path, cleanup := tempLockPath(t)
+	defer cleanup()
+	a, b := New(path), New(path)
+
+	if ok, err := a.TryLock("a"); !ok || err != nil {
+		t.Fatalf("TryLock = %v, %v; want the lock taken", ok, err)
+	}
+	if ok, err := a.TryLock("a"); !ok || err != nil {
+		t.Errorf("TryLock of a held lock = %v, %v; want it still held", ok, err)
+	}
+	if ok, err := b.TryLock("b"); ok || err != nil {
+		t.Errorf("TryLock of a lock held elsewhere = %v, %v; want false", ok, err)
+	}
+	if h := b.Holder(); h != fmt.Sprintf("pid %d: a", os.Getpid()) {
+		t.Errorf("holder %q, want a", h)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Errorf("second Unlock: %v", err)
+	}
+	if h := b.Holder(); h != "" {
+		t.Errorf("released lock held by %q", h)
+	}
+	if ok, err := b.TryLock("b"); !ok || err != nil {
+		t.Errorf("TryLock of a released lock = %v, %v; want the lock taken", ok, err)
+	}
+	b.Unlock()
+}
+
+func TestLockTimeout(t *testing.T) {
+	path, cleanup := tempLockPath(t)
+	defer cleanup()
+	a, b := New(path), New(path)
+	if err := a.Lock("a", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Unlock()
+
+	var holders []string
+	start := time.Now()
+	err := b.Lock("b", 3*pollInterval, func(holder string) { holders = append(holders, holder) })
+	if d := time.Since(start); d < 3*pollInterval {
+		t.Errorf("gave up after %v, before the timeout", d)
+	}
+	held, ok := err.(*HeldError)
+	if !ok {
+		t.Fatalf("got error %v, want a *HeldError", err)
+	}
+	want := fmt.Sprintf("pid %d: a", os.Getpid())
+	if held.Path != path || held.Holder != want {
+		t.Errorf("got %+v, want the lock at %s held by %q", held, path, want)
+	}
+	if !strings.Contains(err.Error(), "is locked by another process ("+want+")") {
+		t.Errorf("unexpected error %q", err)
+	}
+	if len(holders) != 1 || holders[0] != want {
+		t.Errorf("waiting called with %q, want once with %q", holders, want)
+	}
+
+	// without a timeout, the lock is tried once
+	holders = nil
+	if err := b.Lock("b", 0, func(holder string) { holders = append(holders, holder) }); err == nil {
+		t.Error("took a held lock")
+	}
+	if len(holders) != 0 {
+		t.Errorf("waiting called with %q without a timeout", holders)
+	}
+}
+
+func TestLockWaits(t *testing.T) {
+	path, cleanup := tempLockPath(t)
+	defer cleanup()
+	a, b := New(path), New(path)
+	if err := a.Lock("a", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(2 * pollInterval)
+		a.Unlock()
+	}()
+	waited := false
+	if err := b.Lock("b", time.Minute, func(string) { waited = true }); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Unlock()
+	if !waited {
+		t.Error("took the lock without waiting for it to be released")
+	}
+	if h := b.Holder(); h != fmt.Sprintf("pid %d: b", os.Getpid()) {
+		t.Errorf("holder %q, want b", h)
+	}
+}
+
+// TestHelperProcess takes the lock named by DUFFLE_TEST_LOCK and exits without releasing
+// it; it is run by TestLockReleasedOnExit
+func TestHelperProcess(t *testing.T) {
+	path := os.Getenv("DUFFLE_TEST_LOCK")
+	if path == "" {
+		return
+	}
+	if ok, err := New(path).TryLock("helper"); !ok || err != nil {
+		fmt.Fprintf(os.Stderr, "cannot lock %s: %v, %v\n", path, ok, err)
+		os.Exit(1)
+	}
+	fmt.Println("locked")
+	// wait for the test to see the lock held, then exit while holding it
+	ioutil.ReadAll(os.Stdin)
+	os.Exit(0)
+}
+
+func TestLockReleasedOnExit(t *testing.T) {
+	path, cleanup := tempLockPath(t)
+	defer cleanup()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "DUFFLE_TEST_LOCK="+path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len("locked"))
+	if _, err := stdout.Read(buf); err != nil || string(buf) != "locked" {
+		t.Fatalf("helper process: %q, %v", buf, err)
+	}
+
+	l := New(path)
+	if ok, err := l.TryLock("test"); ok || err != nil {
+		t.Errorf("TryLock of a lock held by another process = %v, %v; want false", ok, err)
+	}
+	if h := l.Holder(); !strings.HasSuffix(h, ": helper") || h == fmt.Sprintf("pid %d: helper", os.Getpid()) {
+		t.Errorf("holder %q, want the helper process", h)
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Lock("test", time.Second, nil); err != nil {
+		t.Errorf("lock of an exited process not released: %v", err)
+	}
+	l.Unlock()
+}
@@ -0,0 +1,364 @@
+// Package manifest reads duffle.toml, the file describing how a bundle project is built.
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/deis/duffle/pkg/bundle"
+)
+
+// FileName is the name of the manifest within a bundle project
+const FileName = "duffle.toml"
+
+// Manifest describes a bundle project: the bundle's metadata and the images to build for it
+type Manifest struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	// Registry prefixes the repositories built images are tagged in, such as example.com/org
+	Registry string `json:"registry,omitempty"`
+	// Build configures how images are built
+	Build BuildConfig `json:"build,omitempty"`
+	// InvocationImages describes the invocation images to build, keyed by name
+	InvocationImages map[string]*Image `json:"invocationImages"`
+	// Components describes the other images of the bundle, such as the images of the
+	// application the bundle installs, keyed by name
+	Components  map[string]*Image                     `json:"components,omitempty"`
+	Parameters  map[string]bundle.ParameterDefinition `json:"parameters,omitempty"`
+	Credentials map[string]bundle.CredentialLocation  `json:"credentials,omitempty"`
+}
+
+// BuildConfig configures how the images of a project are built
+type BuildConfig struct {
+	// BuildKit builds images with BuildKit, which runs independent build stages in
+	// parallel and supports build secrets and cache mounts
+	BuildKit bool `json:"buildkit,omitempty"`
+	// Host is the address of a remote Docker daemon to build with, in the format of
+	// DOCKER_HOST, such as ssh://user@builder or tcp://builder:2376
+	Host string `json:"host,omitempty"`
+	// Kaniko builds images with kaniko instead of Docker, without a daemon
+	Kaniko *KanikoConfig `json:"kaniko,omitempty"`
+}
+
+// KanikoConfig configures daemonless builds with kaniko. Images built with kaniko are
+// pushed to their registry as they are built.
+type KanikoConfig struct {
+	// Executor is the path of the kaniko executor, for builds running where duffle runs,
+	// such as in a CI job using the kaniko image; it defaults to /kaniko/executor
+	Executor string `json:"executor,omitempty"`
+	// Namespace, when set, runs each build as a pod of this Kubernetes namespace, in the
+	// cluster of kubectl's current context
+	Namespace string `json:"namespace,omitempty"`
+	// Image is the kaniko executor image run in the cluster
+	Image string `json:"image,omitempty"`
+	// Secret names the docker-registry secret holding the credentials pods push images with
+	Secret string `json:"secret,omitempty"`
+}
+
+// LoadBuildConfig reads build settings shared by every project from the file at path,
+// which holds the keys of the [build] table of duffle.toml. No settings are returned
+// when the file does not exist.
+func LoadBuildConfig(path string) (BuildConfig, error) {
+	var c BuildConfig
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	doc, err := decodeTOML(data)
+	if err != nil {
+		return c, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	if err := decodeStrict(doc, &c); err != nil {
+		return c, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Merge returns the settings of c, completed with the settings of defaults where c has
+// none. Where to build, on a Docker host or with kaniko, is taken from defaults only when
+// c names neither.
+func (c BuildConfig) Merge(defaults BuildConfig) BuildConfig {
+	if !c.BuildKit {
+		c.BuildKit = defaults.BuildKit
+	}
+	if c.Host == "" && c.Kaniko == nil {
+		c.Host = defaults.Host
+		c.Kaniko = defaults.Kaniko
+	}
+	return c
+}
+
+// Image describes how an image of the project is built
+type Image struct {
+	// Path is the build context, relative to the project directory; it defaults to the
+	// name of the image
+	Path string `json:"path,omitempty"`
+	// Dockerfile is the path of the Dockerfile, relative to the build context; it
+	// defaults to Dockerfile
+	Dockerfile string `json:"dockerfile,omitempty"`
+	// Repository is where the image is tagged, such as example.com/org/app; it defaults
+	// to REGISTRY/BUNDLE-IMAGE, using the image's registry and the names of the bundle
+	// and the image
+	Repository string `json:"repository,omitempty"`
+	// Registry overrides the manifest's registry for this image
+	Registry string `json:"registry,omitempty"`
+	// OS and Arch describe the platform an invocation image is built for, using
+	// GOOS/GOARCH values
+	OS   string `json:"os,omitempty"`
+	Arch string `json:"arch,omitempty"`
+	// Args are the build arguments passed to the Dockerfile
+	Args map[string]string `json:"args,omitempty"`
+	// Target is the build stage to build in a multi-stage Dockerfile; the last stage is
+	// built when empty
+	Target string `json:"target,omitempty"`
+	// Labels are added to the built image
+	Labels map[string]string `json:"labels,omitempty"`
+	// Secrets maps the IDs of build secrets, which RUN --mount=type=secret instructions
+	// read, to files relative to the project directory. They require BuildKit.
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// Refs locate the references to a component in the invocation image's files
+	Refs []bundle.LocationRef `json:"refs,omitempty"`
+	// Builder selects how the image is built: from a Dockerfile (dockerfile, the
+	// default), with Cloud Native Buildpacks (buildpacks), or from a Go program (ko)
+	Builder string `json:"builder,omitempty"`
+	// BuilderImage is the buildpacks builder image, such as heroku/buildpacks:18
+	BuilderImage string `json:"builderImage,omitempty"`
+	// Package is the Go package ko builds, relative to the build context; it defaults to
+	// the build context itself
+	Package string `json:"package,omitempty"`
+	// Declarations lists the files of an invocation image's build context scanned for
+	// parameter and credential annotations; it defaults to app/run
+	Declarations []string `json:"declarations,omitempty"`
+}
+
+// Builders selectable by images
+const (
+	BuilderDockerfile = "dockerfile"
+	BuilderBuildpacks = "buildpacks"
+	BuilderKo         = "ko"
+)
+
+// UsesDockerfile reports whether img is built from a Dockerfile
+func (img *Image) UsesDockerfile() bool {
+	return img.Builder == "" || img.Builder == BuilderDockerfile
+}
+
+// validate checks that the settings of the image called name suit its builder
+func (img *Image) validate(name string) error {
+	var unsupported []string
+	switch img.Builder {
+	case "", BuilderDockerfile:
+		if img.BuilderImage != "" {
+			unsupported = append(unsupported, "builderImage")
+		}
+		if img.Package != "" {
+			unsupported = append(unsupported, "package")
+		}
+	case BuilderBuildpacks, BuilderKo:
+		if img.Dockerfile != "" {
+			unsupported = append(unsupported, "dockerfile")
+		}
+		if img.Target != "" {
+			unsupported = append(unsupported, "target")
+		}
+		if len(img.Secrets) > 0 {
+			unsupported = append(unsupported, "secrets")
+		}
+		if len(img.Labels) > 0 {
+			unsupported = append(unsupported, "labels")
+		}
+		if img.Builder == BuilderBuildpacks && img.Package != "" {
+			unsupported = append(unsupported, "package")
+		}
+		if img.Builder == BuilderKo && img.BuilderImage != "" {
+			unsupported = append(unsupported, "builderImage")
+		}
+	default:
+		return fmt.Errorf("image %s: unknown builder %q (expected %s, %s or %s)", name, img.Builder, BuilderDockerfile, BuilderBuildpacks, BuilderKo)
+	}
+	if len(unsupported) > 0 {
+		builder := img.Builder
+		if builder == "" {
+			builder = BuilderDockerfile
+		}
+		return fmt.Errorf("image %s: the %s builder does not support %s", name, builder, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// Load reads the manifest of the project in dir
+func Load(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a manifest. Keys the manifest does not define are rejected.
+func Parse(data []byte) (*Manifest, error) {
+	doc, err := decodeTOML(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", FileName, err)
+	}
+	m := &Manifest{}
+	if err := decodeStrict(doc, m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", FileName, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", FileName, err)
+	}
+	return m, nil
+}
+
+// decodeStrict decodes a TOML document into v, rejecting keys v does not define
+func decodeStrict(doc map[string]interface{}, v interface{}) error {
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if f := strings.TrimPrefix(err.Error(), "json: unknown field "); f != err.Error() {
+			return fmt.Errorf("unknown key %s", f)
+		}
+		return err
+	}
+	return nil
+}
+
+// Validate checks that the manifest names a bundle and an invocation image
+func (m *Manifest) Validate() error {
+	if m.Name == "" {
+		return errors.New("name is required")
+	}
+	if m.Version == "" {
+		return errors.New("version is required")
+	}
+	if _, err := semver.NewVersion(m.Version); err != nil {
+		return fmt.Errorf("version %q is not a semantic version", m.Version)
+	}
+	if len(m.InvocationImages) == 0 {
+		return errors.New("at least one invocation image is required")
+	}
+	for _, name := range m.InvocationImageNames() {
+		if err := m.InvocationImages[name].validate(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range m.ComponentNames() {
+		if err := m.Components[name].validate(name); err != nil {
+			return err
+		}
+	}
+	for name, img := range m.Components {
+		if _, ok := m.InvocationImages[name]; ok {
+			return fmt.Errorf("%s is both an invocation image and a component", name)
+		}
+		if len(img.Declarations) > 0 {
+			return fmt.Errorf("component %s declares declarations, which only invocation images have", name)
+		}
+	}
+	return nil
+}
+
+// InvocationImageNames returns the names of the invocation images, sorted
+func (m *Manifest) InvocationImageNames() []string {
+	return sortedNames(m.InvocationImages)
+}
+
+// ComponentNames returns the names of the components, sorted
+func (m *Manifest) ComponentNames() []string {
+	return sortedNames(m.Components)
+}
+
+// images returns every image of the project, invocation images and components alike
+func (m *Manifest) images() []*Image {
+	var images []*Image
+	for _, img := range m.InvocationImages {
+		images = append(images, img)
+	}
+	for _, img := range m.Components {
+		images = append(images, img)
+	}
+	return images
+}
+
+// SetBuildArgs sets build arguments on every image, overriding the values declared in
+// the manifest
+func (m *Manifest) SetBuildArgs(args map[string]string) {
+	for _, img := range m.images() {
+		if img.Args == nil {
+			img.Args = map[string]string{}
+		}
+		for k, v := range args {
+			img.Args[k] = v
+		}
+	}
+}
+
+// Repository returns the repository the image called name is tagged in
+func (m *Manifest) Repository(name string, img *Image) string {
+	if img.Repository != "" {
+		return img.Repository
+	}
+	repo := m.Name + "-" + name
+	if r := m.RegistryOf(img); r != "" {
+		repo = r + "/" + repo
+	}
+	return repo
+}
+
+// RegistryOf returns the registry prefixing the default repository of img: its own
+// registry, or the manifest's
+func (m *Manifest) RegistryOf(img *Image) string {
+	if img.Registry != "" {
+		return img.Registry
+	}
+	return m.Registry
+}
+
+// Pushable reports whether img is tagged in a registry, rather than only in the local
+// Docker daemon
+func (m *Manifest) Pushable(img *Image) bool {
+	return img.Repository != "" || m.RegistryOf(img) != ""
+}
+
+// Context returns the build context of the image called name, within the project in dir
+func (img *Image) Context(dir, name string) string {
+	if img.Path != "" {
+		return filepath.Join(dir, filepath.FromSlash(img.Path))
+	}
+	return filepath.Join(dir, name)
+}
+
+// DockerfilePath returns the Dockerfile of the image, given its build context
+func (img *Image) DockerfilePath(context string) string {
+	if img.Dockerfile != "" {
+		return filepath.Join(context, filepath.FromSlash(img.Dockerfile))
+	}
+	return filepath.Join(context, "Dockerfile")
+}
+
+func sortedNames(images map[string]*Image) []string {
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,12 @@
+package manifest
+
+import "github.com/BurntSushi/toml"
+
+// decodeTOML parses a TOML document into nested maps
+func decodeTOML(data []byte) (map[string]interface{}, error) {
+	doc := map[string]interface{}{}
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
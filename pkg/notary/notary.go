@@ -0,0 +1,93 @@
+// Package notary wraps the notary CLI to sign and verify content with Docker Content Trust.
+package notary
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+)
+
+// Command is the notary executable invoked by this package
+var Command = "notary"
+
+// Client signs and looks up targets in the trust data held by a Notary server
+type Client struct {
+	// Server is the URL of the Notary server
+	Server string
+	// TrustDir is the directory holding cached trust data and signing keys; the notary
+	// default is used when empty
+	TrustDir string
+}
+
+func (c Client) run(args ...string) (string, error) {
+	global := []string{"-s", c.Server}
+	if c.TrustDir != "" {
+		global = append(global, "-d", c.TrustDir)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(Command, append(global, args...)...)
+	// signing may prompt for the passphrases of the keys
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("notary %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Sign records that tag of the trusted collection gun is the content with digest d and
+// the given size, and publishes the change to the server. The collection is initialized
+// first if the server has no trust data for it yet.
+func (c Client) Sign(gun, tag, d string, size int64) error {
+	hex := strings.TrimPrefix(d, digest.Algorithm+":")
+	if hex == d {
+		return fmt.Errorf("unsupported digest %q", d)
+	}
+	if _, err := c.run("lookup", gun, tag); err != nil && strings.Contains(err.Error(), "does not have trust data") {
+		if _, err := c.run("init", gun, "--publish"); err != nil {
+			return err
+		}
+	}
+	_, err := c.run("addhash", gun, tag, strconv.FormatInt(size, 10), "--sha256", hex, "--publish")
+	return err
+}
+
+// Lookup returns the digest and size tag of the trusted collection gun is signed for
+func (c Client) Lookup(gun, tag string) (string, int64, error) {
+	out, err := c.run("lookup", gun, tag)
+	if err != nil {
+		return "", 0, err
+	}
+	// the target is printed as: NAME SHA256 SIZE
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return "", 0, fmt.Errorf("unexpected output of notary lookup: %q", out)
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("unexpected output of notary lookup: %q", out)
+	}
+	return digest.Algorithm + ":" + fields[1], size, nil
+}
+
+// Verify checks that tag of the trusted collection gun is signed for the content with digest d
+func (c Client) Verify(gun, tag, d string) error {
+	signed, _, err := c.Lookup(gun, tag)
+	if err != nil {
+		return fmt.Errorf("no trust data for %s:%s: %v", gun, tag, err)
+	}
+	if signed != d {
+		return fmt.Errorf("%s:%s is signed for %s, but the registry served %s", gun, tag, signed, d)
+	}
+	return nil
+}
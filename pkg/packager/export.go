@@ -0,0 +1,76 @@
+package packager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/progress"
+)
+
+// Exporter packages a bundle and every image it references into a single compressed archive
+type Exporter struct {
+	// Source is the path to the bundle document being exported
+	Source string
+	// Destination is the path of the archive to write
+	Destination string
+	// Progress receives the progress of pulling, saving and archiving images
+	Progress progress.Reporter
+}
+
+// Export saves each image referenced by the bundle and writes the archive.
+//
+// Image tarballs are stored in a content-addressed layout, so that layers shared by
+// several images are only stored once.
+func (ex *Exporter) Export() error {
+	b, err := loader.Load(ex.Source)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir("", "duffle-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := b.WriteFile(filepath.Join(tmp, "bundle.json"), 0644); err != nil {
+		return err
+	}
+	artifacts := filepath.Join(tmp, artifactsDir)
+	if err := os.Mkdir(artifacts, 0755); err != nil {
+		return err
+	}
+
+	for _, ref := range b.ImageRefs() {
+		if !docker.ImageExists(ref) {
+			event := progress.Event{ID: ref, Action: "pull"}
+			ex.Progress.Start(event)
+			if err := docker.Pull(ref); err != nil {
+				return fmt.Errorf("cannot fetch image %s: %v", ref, err)
+			}
+			ex.Progress.Finish(event)
+		}
+		event := progress.Event{ID: ref, Action: "save"}
+		ex.Progress.Start(event)
+		saved := filepath.Join(tmp, artifactName(ref))
+		if err := docker.Save(ref, saved); err != nil {
+			return fmt.Errorf("cannot save image %s: %v", ref, err)
+		}
+		if info, err := os.Stat(saved); err == nil {
+			event.Total = info.Size()
+		}
+		if err := storeImage(saved, artifacts, ref); err != nil {
+			return err
+		}
+		if err := os.Remove(saved); err != nil {
+			return err
+		}
+		ex.Progress.Finish(event)
+	}
+
+	return tarGz(tmp, ex.Destination, ex.Progress)
+}
@@ -0,0 +1,107 @@
+package packager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/progress"
+)
+
+// Importer unpacks an archive produced by Exporter and makes its images available locally
+type Importer struct {
+	// Source is the path to the archive being imported
+	Source string
+	// TargetRegistry, when set, is the registry every image is pushed to.
+	// Image references in the bundle are rewritten to point at it.
+	TargetRegistry string
+	// Progress receives the progress of extracting, loading and pushing images
+	Progress progress.Reporter
+}
+
+// Import loads the archived images into the local daemon, pushing them to the
+// target registry if one is configured, and returns the (possibly rewritten) bundle
+func (im *Importer) Import() (*bundle.Bundle, error) {
+	tmp, err := ioutil.TempDir("", "duffle-import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := untarGz(im.Source, tmp, im.Progress); err != nil {
+		return nil, fmt.Errorf("cannot unpack %s: %v", im.Source, err)
+	}
+	b, err := bundle.Load(filepath.Join(tmp, "bundle.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	// loaded maps each bundle reference to the name the daemon knows the loaded image by
+	loaded := map[string]string{}
+	for _, ref := range b.ImageRefs() {
+		event := progress.Event{ID: ref, Action: "load"}
+		im.Progress.Start(event)
+		refs, err := loadImage(tmp, ref)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load image %s: %v", ref, err)
+		}
+		im.Progress.Finish(event)
+		loaded[ref] = ref
+		if len(refs) == 1 {
+			loaded[ref] = refs[0]
+		}
+	}
+
+	if im.TargetRegistry != "" {
+		for i, img := range b.InvocationImages {
+			target := docker.Relocate(img.Image, im.TargetRegistry)
+			digest, err := im.pushAs(loaded[img.Ref()], target)
+			if err != nil {
+				return nil, err
+			}
+			b.InvocationImages[i].Image, b.InvocationImages[i].Digest = target, digest
+		}
+		for i, img := range b.Images {
+			target := docker.Relocate(img.URI, im.TargetRegistry)
+			digest, err := im.pushAs(loaded[img.Ref()], target)
+			if err != nil {
+				return nil, err
+			}
+			b.Images[i].URI, b.Images[i].Digest = target, digest
+		}
+	}
+	return b, nil
+}
+
+// pushAs pushes the local image ref to target and returns the digest the registry assigned it
+func (im *Importer) pushAs(ref, target string) (string, error) {
+	if err := docker.Tag(ref, target); err != nil {
+		return "", err
+	}
+	event := progress.Event{ID: target, Action: "push"}
+	im.Progress.Start(event)
+	if err := docker.Push(target); err != nil {
+		return "", fmt.Errorf("cannot push image %s: %v", target, err)
+	}
+	im.Progress.Finish(event)
+	return docker.Digest(target)
+}
+
+// loadImage loads the image tarball of ref from the archive unpacked in dir into the local
+// daemon. Archives written before images were stored in the content-addressed layout hold
+// the tarball itself.
+func loadImage(dir, ref string) ([]string, error) {
+	artifacts := filepath.Join(dir, artifactsDir)
+	if _, err := os.Stat(filepath.Join(artifacts, entriesName(ref))); os.IsNotExist(err) {
+		return docker.Load(filepath.Join(artifacts, artifactName(ref)))
+	}
+	tarball := filepath.Join(dir, artifactName(ref))
+	if err := restoreImage(artifacts, ref, tarball); err != nil {
+		return nil, err
+	}
+	defer os.Remove(tarball)
+	return docker.Load(tarball)
+}
@@ -0,0 +1,175 @@
+package packager
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+)
+
+// blobsDir is the directory within the artifacts directory holding the files of every image
+// tarball, stored once per content digest. Images sharing base layers thus share their
+// layer files in the archive.
+const blobsDir = "blobs"
+
+// tarEntry describes a member of an image tarball stored in the content-addressed layout
+type tarEntry struct {
+	Name     string    `json:"name"`
+	Type     byte      `json:"type"`
+	Mode     int64     `json:"mode"`
+	Linkname string    `json:"linkname,omitempty"`
+	ModTime  time.Time `json:"modTime"`
+	UID      int       `json:"uid,omitempty"`
+	GID      int       `json:"gid,omitempty"`
+	Uname    string    `json:"uname,omitempty"`
+	Gname    string    `json:"gname,omitempty"`
+	// Digest identifies the content of regular files in the blobs directory
+	Digest string `json:"digest,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// entriesName maps an image reference to the name of the file listing its tarball's entries
+func entriesName(ref string) string {
+	return strings.TrimSuffix(artifactName(ref), ".tar") + ".json"
+}
+
+// storeImage splits the image tarball src, saved for ref, into the content-addressed layout
+// of dir: each regular file is written to the blobs directory unless a file with the same
+// content is already there, and the tarball's entries are listed next to it.
+func storeImage(src, dir, ref string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	blobs := filepath.Join(dir, blobsDir, digest.Algorithm)
+	if err := os.MkdirAll(blobs, 0755); err != nil {
+		return err
+	}
+
+	var entries []tarEntry
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read image tarball of %s: %v", ref, err)
+		}
+		e := tarEntry{
+			Name:     hdr.Name,
+			Type:     hdr.Typeflag,
+			Mode:     hdr.Mode,
+			Linkname: hdr.Linkname,
+			ModTime:  hdr.ModTime,
+			UID:      hdr.Uid,
+			GID:      hdr.Gid,
+			Uname:    hdr.Uname,
+			Gname:    hdr.Gname,
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if e.Digest, err = storeBlob(blobs, tr); err != nil {
+				return err
+			}
+			e.Size = hdr.Size
+		}
+		entries = append(entries, e)
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, entriesName(ref)), data, 0644)
+}
+
+// storeBlob writes the content of r to the blobs directory under its digest, and returns the digest
+func storeBlob(blobs string, r io.Reader) (string, error) {
+	tmp, err := ioutil.TempFile(blobs, ".blob-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	d, err := digest.OfReader(io.TeeReader(r, tmp))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(blobs, digest.Hex(d))
+	if _, err := os.Stat(dest); err == nil {
+		return d, nil
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", err
+	}
+	return d, os.Rename(tmp.Name(), dest)
+}
+
+// restoreImage rebuilds the image tarball of ref from the content-addressed layout of dir,
+// writing it to dest
+func restoreImage(dir, ref, dest string) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, entriesName(ref)))
+	if err != nil {
+		return err
+	}
+	var entries []tarEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("cannot parse %s: %v", entriesName(ref), err)
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	tw := tar.NewWriter(out)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.Name,
+			Typeflag: e.Type,
+			Mode:     e.Mode,
+			Linkname: e.Linkname,
+			Size:     e.Size,
+			ModTime:  e.ModTime,
+			Uid:      e.UID,
+			Gid:      e.GID,
+			Uname:    e.Uname,
+			Gname:    e.Gname,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if e.Type != tar.TypeReg {
+			continue
+		}
+		if err := digest.Validate(e.Digest); err != nil {
+			return fmt.Errorf("cannot restore %s of %s: %v", e.Name, ref, err)
+		}
+		if err := copyBlob(tw, filepath.Join(dir, blobsDir, digest.Algorithm, digest.Hex(e.Digest))); err != nil {
+			return fmt.Errorf("cannot restore %s of %s: %v", e.Name, ref, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func copyBlob(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,285 @@
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// GitCommand is the git executable invoked to install plugins from git repositories
+var GitCommand = "git"
+
+// gitPrefix marks plugin sources that are git URLs, as in git::https://host/duffle-foo.git?ref=v1
+const gitPrefix = "git::"
+
+// InstallOptions configures Install
+type InstallOptions struct {
+	// Name is the command the plugin adds; it is derived from the source when empty
+	Name string
+	// Force replaces an installed plugin of the same name
+	Force bool
+	// Client downloads plugins from http(s) URLs; http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Install installs the plugin at source into dir. Sources are:
+//
+//   - an https URL or a local path to an executable, or to a .tar.gz or .tgz archive
+//     holding the executable duffle-NAME at its root along with the files it needs. URLs
+//     may pin the content with a '#sha256=<hex>' fragment, which plain http URLs must.
+//     On Windows, executables keep their .exe, .bat or .cmd extension.
+//   - a git URL, such as git::https://example.com/org/duffle-foo.git?ref=v1, whose
+//     repository holds the executable duffle-NAME at its root
+//
+// Unless given, NAME is the base name of the source without the duffle- prefix and
+// archive or .git extensions.
+func Install(dir, source string, opts InstallOptions) (*Plugin, error) {
+	name := opts.Name
+	if name == "" {
+		name = NameOf(source)
+	}
+	if !ValidName(name) {
+		return nil, fmt.Errorf("cannot derive a plugin name from %s; give one", source)
+	}
+	ext := ""
+	if runtime.GOOS == "windows" && !strings.HasPrefix(source, gitPrefix) && !isArchive(source) {
+		if ext = filepath.Ext(sourcePath(source)); !isWindowsExecutable(ext) {
+			return nil, fmt.Errorf("cannot install plugin %s: %s is not an .exe, .bat or .cmd file", name, source)
+		}
+	}
+	installed, err := findInstalled(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range installed {
+		if p.Name == name && !opts.Force {
+			return nil, fmt.Errorf("plugin %s is already installed at %s; pass --force to replace it", name, p.Path)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// plugins are prepared in a temporary directory of dir, then moved into place
+	tmp, err := ioutil.TempDir(dir, ".install-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	staged := filepath.Join(tmp, name)
+
+	if strings.HasPrefix(source, gitPrefix) {
+		if err := clone(strings.TrimPrefix(source, gitPrefix), staged); err != nil {
+			return nil, fmt.Errorf("cannot install plugin %s: %v", name, err)
+		}
+	} else {
+		data, err := fetch(opts.Client, source)
+		if err != nil {
+			return nil, fmt.Errorf("cannot install plugin %s: %v", name, err)
+		}
+		if isArchive(source) {
+			err = extract(data, staged)
+		} else {
+			staged = filepath.Join(tmp, Prefix+name+ext)
+			err = ioutil.WriteFile(staged, data, 0755)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot install plugin %s: %v", name, err)
+		}
+	}
+
+	if fi, err := os.Stat(staged); err == nil && fi.IsDir() && executable(staged, Prefix+name) == nil {
+		return nil, fmt.Errorf("cannot install plugin %s: %s has no executable %s at its root", name, source, Prefix+name)
+	}
+	if err := Remove(dir, name); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := os.Rename(staged, filepath.Join(dir, filepath.Base(staged))); err != nil {
+		return nil, err
+	}
+	return Lookup(dir, name)
+}
+
+// Remove removes the plugin name installed into dir. The error satisfies os.IsNotExist when
+// no such plugin is installed.
+func Remove(dir, name string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("invalid plugin name %q", name)
+	}
+	paths := []string{filepath.Join(dir, Prefix+name), filepath.Join(dir, name)}
+	if runtime.GOOS == "windows" {
+		for _, ext := range windowsExts {
+			paths = append(paths, filepath.Join(dir, Prefix+name+ext))
+		}
+	}
+	removed := false
+	for _, p := range paths {
+		if _, err := os.Lstat(p); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+		removed = true
+	}
+	if !removed {
+		return &os.PathError{Op: "remove", Path: filepath.Join(dir, Prefix+name), Err: os.ErrNotExist}
+	}
+	return nil
+}
+
+// NameOf derives the name of the plugin at source from its base name, without the duffle-
+// prefix and archive or .git extensions
+func NameOf(source string) string {
+	base := strings.ToLower(path.Base(filepath.ToSlash(sourcePath(strings.TrimPrefix(source, gitPrefix)))))
+	for _, ext := range []string{".tar.gz", ".tgz", ".git", ".exe"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return strings.TrimPrefix(base, Prefix)
+}
+
+// sourcePath returns the path of the URL source, or source itself when it is a local path
+func sourcePath(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" {
+		return u.Path
+	}
+	return source
+}
+
+// isArchive reports whether source names a gzipped tarball
+func isArchive(source string) bool {
+	source = strings.ToLower(sourcePath(source))
+	return strings.HasSuffix(source, ".tar.gz") || strings.HasSuffix(source, ".tgz")
+}
+
+// fetch reads the local file or downloads the http(s) URL source, checking the checksum
+// its '#sha256=' fragment pins. Plain http URLs must pin one.
+func fetch(client *http.Client, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return ioutil.ReadFile(source)
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	var want string
+	if u.Fragment != "" {
+		if !strings.HasPrefix(u.Fragment, "sha256=") {
+			return nil, fmt.Errorf("unsupported fragment %q: expected sha256=<hex>", u.Fragment)
+		}
+		want = strings.ToLower(strings.TrimPrefix(u.Fragment, "sha256="))
+		u.Fragment = ""
+	}
+	if u.Scheme == "http" && want == "" {
+		return nil, fmt.Errorf("%s is not downloaded over https: use https, or pin its checksum with '#sha256=<hex>'", u)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s: %s", u, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if want != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != want {
+			return nil, fmt.Errorf("checksum mismatch for %s: got sha256:%s, expected sha256:%s", u, got, want)
+		}
+	}
+	return data, nil
+}
+
+// extract unpacks the gzipped tarball data into the directory dest
+func extract(data []byte, dest string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, filepath.FromSlash(hdr.Name))
+		if target != dest && !strings.HasPrefix(target, dest+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal path in archive: %s", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// clone checks out the git repository at raw, with an optional ?ref= branch or tag, into dest
+func clone(raw, dest string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("invalid git URL %q", raw)
+	}
+	if u.Scheme == "http" {
+		return fmt.Errorf("%s is not cloned over https: use https", raw)
+	}
+	ref := u.Query().Get("ref")
+	u.RawQuery = ""
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, "--", u.String(), dest)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(GitCommand, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("git clone: %s", msg)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Package plugin finds, installs and runs duffle plugins: executables named duffle-NAME,
+// which extend duffle with the command NAME.
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Prefix starts the file names of plugin executables
+const Prefix = "duffle-"
+
+// Plugin is an executable extending duffle with a command
+type Plugin struct {
+	// Name is the command the plugin adds to duffle
+	Name string `json:"name"`
+	// Path is the location of the executable
+	Path string `json:"path"`
+	// Installed is set for plugins installed into the plugins directory of duffle home,
+	// rather than found on $PATH
+	Installed bool `json:"installed"`
+}
+
+// Find returns the plugins installed into dir and those found on $PATH, sorted by name.
+// Installed plugins take precedence over those on $PATH, and the directories of $PATH over
+// the ones after them.
+func Find(dir string) ([]*Plugin, error) {
+	found := map[string]*Plugin{}
+	installed, err := findInstalled(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range installed {
+		found[p.Name] = p
+	}
+	for _, d := range filepath.SplitList(os.Getenv("PATH")) {
+		if d == "" {
+			continue
+		}
+		for _, p := range findIn(d) {
+			if _, ok := found[p.Name]; !ok {
+				found[p.Name] = p
+			}
+		}
+	}
+
+	plugins := make([]*Plugin, 0, len(found))
+	for _, p := range found {
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
+
+// Lookup returns the plugin adding the command name, installed into dir or found on $PATH
+func Lookup(dir, name string) (*Plugin, error) {
+	if !ValidName(name) {
+		return nil, fmt.Errorf("invalid plugin name %q", name)
+	}
+	installed, err := findInstalled(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range installed {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	path, err := exec.LookPath(Prefix + name)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s not found", name)
+	}
+	return &Plugin{Name: name, Path: path}, nil
+}
+
+// ValidName reports whether name may name a plugin: letters, digits, '-' and '_', not
+// starting with '-'
+func ValidName(name string) bool {
+	if name == "" || name[0] == '-' {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Command returns the command running the plugin with args. The environment of duffle
+// is passed on, with env added to it.
+func (p *Plugin) Command(args []string, env ...string) *exec.Cmd {
+	cmd := exec.Command(p.Path, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// findInstalled returns the plugins installed into dir: executables dir/duffle-NAME, and
+// dir/NAME/duffle-NAME for plugins installed with other files
+func findInstalled(dir string) ([]*Plugin, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var plugins []*Plugin
+	for _, fi := range files {
+		var p *Plugin
+		if fi.IsDir() {
+			p = executable(filepath.Join(dir, fi.Name()), Prefix+fi.Name())
+		} else {
+			p = executable(dir, fi.Name())
+		}
+		if p != nil {
+			p.Installed = true
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins, nil
+}
+
+// findIn returns the plugins in the directory dir of $PATH
+func findIn(dir string) []*Plugin {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var plugins []*Plugin
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasPrefix(fi.Name(), Prefix) {
+			continue
+		}
+		if p := executable(dir, fi.Name()); p != nil {
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins
+}
+
+// executable returns the plugin of the file file in dir, or nil if it is not an
+// executable named like a plugin
+func executable(dir, file string) *Plugin {
+	name := strings.TrimPrefix(file, Prefix)
+	if runtime.GOOS == "windows" {
+		if !isWindowsExecutable(name) {
+			return nil
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if name == file || !ValidName(name) {
+		return nil
+	}
+	path := filepath.Join(dir, file)
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0111 == 0 {
+		return nil
+	}
+	return &Plugin{Name: name, Path: path}
+}
+
+// windowsExts are the extensions of the files Windows runs
+var windowsExts = []string{".exe", ".bat", ".cmd"}
+
+// isWindowsExecutable reports whether Windows runs the file file, by its extension
+func isWindowsExecutable(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	for _, e := range windowsExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
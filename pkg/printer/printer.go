@@ -0,0 +1,86 @@
+// Package printer prints the results of commands in the output format the user chose: as
+// a table for people, or as JSON or YAML for programs.
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+)
+
+// Output formats
+const (
+	Table = "table"
+	JSON  = "json"
+	YAML  = "yaml"
+)
+
+// Formats lists the output formats
+var Formats = []string{Table, JSON, YAML}
+
+// ParseFormat returns the output format named format, ignoring case. "text" names the
+// table format, as it did in configurations written by earlier versions of duffle.
+func ParseFormat(format string) (string, error) {
+	switch f := strings.ToLower(format); f {
+	case Table, JSON, YAML:
+		return f, nil
+	case "text":
+		return Table, nil
+	}
+	return "", fmt.Errorf("unknown output format %q: expected %s", format, strings.Join(Formats, ", "))
+}
+
+// Printer prints results in an output format
+type Printer struct {
+	w      io.Writer
+	format string
+}
+
+// New returns a printer writing to w in format
+func New(w io.Writer, format string) (*Printer, error) {
+	f, err := ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	return &Printer{w: w, format: f}, nil
+}
+
+// Format returns the output format of p
+func (p *Printer) Format() string {
+	return p.format
+}
+
+// IsTable reports whether p prints for people rather than programs
+func (p *Printer) IsTable() bool {
+	return p.format == Table
+}
+
+// Print prints v as JSON or YAML, or calls table to print it for people. Output table
+// writes to w is aligned on tabs, like text/tabwriter aligns it.
+func (p *Printer) Print(v interface{}, table func(w io.Writer) error) error {
+	switch p.format {
+	case JSON:
+		data, err := json.MarshalIndent(v, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.w, string(data))
+		return err
+	case YAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = p.w.Write(data)
+		return err
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	if err := table(tw); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
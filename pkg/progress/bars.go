@@ -0,0 +1,86 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// barWidth is the number of characters of a progress bar
+	barWidth = 30
+	// redrawInterval limits how often a progress bar is redrawn
+	redrawInterval = 100 * time.Millisecond
+)
+
+// Bars returns a reporter drawing a progress bar on the terminal w for each transfer.
+// Transfers are expected to run one after the other: each bar is redrawn in place until
+// its transfer is done.
+func Bars(w io.Writer) Reporter {
+	var (
+		current string
+		drawn   time.Time
+	)
+	return func(e Event) {
+		if e.ID != current {
+			if current != "" {
+				fmt.Fprintln(w)
+			}
+			current, drawn = e.ID, time.Time{}
+		}
+		if !e.Done && time.Since(drawn) < redrawInterval {
+			return
+		}
+		drawn = time.Now()
+		fmt.Fprintf(w, "\r%-8s %-24s %s", e.Action, shortID(e.ID), bar(e))
+		if e.Done {
+			fmt.Fprintln(w)
+			current = ""
+		}
+	}
+}
+
+// bar renders the progress of e
+func bar(e Event) string {
+	if e.Total <= 0 {
+		if e.Done {
+			return fmt.Sprintf("done %-30s", HumanSize(e.Current))
+		}
+		return fmt.Sprintf("%-35s", HumanSize(e.Current))
+	}
+	filled := int(e.Current * barWidth / e.Total)
+	if filled > barWidth {
+		filled = barWidth
+	}
+	b := strings.Repeat("=", filled)
+	if filled < barWidth {
+		b += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return fmt.Sprintf("[%s] %s/%s", b, HumanSize(e.Current), HumanSize(e.Total))
+}
+
+// shortID abbreviates digests the way docker does, and long references to their end
+func shortID(id string) string {
+	if i := strings.Index(id, ":"); i != -1 && strings.HasPrefix(id, "sha256:") && len(id) > i+13 {
+		return id[i+1 : i+13]
+	}
+	if len(id) > 24 {
+		return "..." + id[len(id)-21:]
+	}
+	return id
+}
+
+// HumanSize formats a number of bytes with a binary unit
+func HumanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
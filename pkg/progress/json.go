@@ -0,0 +1,36 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// DefaultInterval is how often JSON reporters emit an event for an ongoing transfer
+const DefaultInterval = 5 * time.Second
+
+// jsonEvent is an event as written by JSON reporters
+type jsonEvent struct {
+	Time string `json:"time"`
+	Event
+}
+
+// JSON returns a reporter writing events to w as JSON objects, one per line. For each
+// transfer, the first and last events are written, and ongoing progress at most once
+// per interval.
+func JSON(w io.Writer, interval time.Duration) Reporter {
+	enc := json.NewEncoder(w)
+	written := map[string]time.Time{}
+	return func(e Event) {
+		last, started := written[e.ID]
+		if started && !e.Done && time.Since(last) < interval {
+			return
+		}
+		now := time.Now()
+		written[e.ID] = now
+		if e.Done {
+			delete(written, e.ID)
+		}
+		enc.Encode(jsonEvent{Time: now.UTC().Format(time.RFC3339), Event: e})
+	}
+}
@@ -0,0 +1,90 @@
+// Package progress reports the progress of long-running transfers: as progress bars on
+// terminals, and as periodic JSON events otherwise.
+package progress
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Event describes the progress of a transfer
+type Event struct {
+	// ID identifies the transfer, such as the digest of a layer or an image reference
+	ID string `json:"id"`
+	// Action is what is being done, such as "push", "pull", "save" or "load"
+	Action string `json:"action"`
+	// Current is the number of bytes transferred so far
+	Current int64 `json:"current"`
+	// Total is the size of the transfer, or zero when it is unknown
+	Total int64 `json:"total,omitempty"`
+	// Done marks the end of the transfer
+	Done bool `json:"done,omitempty"`
+}
+
+// Reporter receives progress events. A nil Reporter discards them.
+type Reporter func(Event)
+
+// Report sends e to the reporter
+func (r Reporter) Report(e Event) {
+	if r != nil {
+		r(e)
+	}
+}
+
+// Start reports that the transfer e begins, or resumes from e.Current
+func (r Reporter) Start(e Event) {
+	e.Done = false
+	r.Report(e)
+}
+
+// Finish reports that the transfer e is complete
+func (r Reporter) Finish(e Event) {
+	if e.Total > 0 {
+		e.Current = e.Total
+	}
+	e.Done = true
+	r.Report(e)
+}
+
+// New returns a reporter writing to w: progress bars if w is a terminal, and JSON
+// events otherwise
+func New(w io.Writer) Reporter {
+	if f, ok := w.(*os.File); ok && terminal.IsTerminal(int(f.Fd())) {
+		return Bars(w)
+	}
+	return JSON(w, DefaultInterval)
+}
+
+// NewReader returns a reader reporting the bytes read from rd as the progress of e, on top
+// of the e.Current bytes already transferred. The transfer is reported done once Total
+// bytes, or all of rd, have been read.
+func NewReader(rd io.Reader, r Reporter, e Event) io.Reader {
+	if r == nil {
+		return rd
+	}
+	r.Start(e)
+	return &reader{Reader: rd, report: r, event: e}
+}
+
+type reader struct {
+	io.Reader
+	report Reporter
+	event  Event
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if r.event.Done {
+		return n, err
+	}
+	r.event.Current += int64(n)
+	if err == io.EOF || (r.event.Total > 0 && r.event.Current >= r.event.Total) {
+		r.event.Done = true
+	}
+	if n > 0 || r.event.Done {
+		r.report(r.event)
+	}
+	return n, err
+}
@@ -0,0 +1,190 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/deis/duffle/pkg/bundle"
+)
+
+// Annotations and values of the cnab-to-oci representation of bundles, which stores a bundle
+// as an image index referencing its bundle.json and its images
+const (
+	AnnotationArtifactType   = "org.opencontainers.artifactType"
+	AnnotationTitle          = "org.opencontainers.image.title"
+	AnnotationVersion        = "org.opencontainers.image.version"
+	AnnotationDescription    = "org.opencontainers.image.description"
+	AnnotationRuntimeVersion = "io.cnab.runtime_version"
+	AnnotationManifestType   = "io.cnab.manifest.type"
+	AnnotationComponentName  = "io.cnab.component.name"
+
+	// ArtifactTypeBundle is the artifact type of bundle indexes
+	ArtifactTypeBundle = "application/vnd.cnab.manifest.v1"
+	// ManifestTypeConfig marks the manifest holding bundle.json as its config blob
+	ManifestTypeConfig = "config"
+	// ManifestTypeInvocation marks the manifests of invocation images
+	ManifestTypeInvocation = "invocation"
+	// ManifestTypeComponent marks the manifests of component images
+	ManifestTypeComponent = "component"
+)
+
+// BundleManifest fetches the manifest holding the bundle artifact at r, returning it along
+// with the digest of the artifact.
+//
+// Bundles stored in the cnab-to-oci representation are image indexes; the manifest they
+// mark as the bundle's config is returned, and the digest is that of the index. Bundles
+// stored as a single manifest, as older duffle versions pushed them, are read too.
+func (c *Client) BundleManifest(r Reference) (*Manifest, string, error) {
+	data, mediaType, artifactDigest, err := c.GetManifest(r)
+	if err != nil {
+		return nil, "", err
+	}
+	if isIndex(mediaType) {
+		index := &Index{}
+		if err := json.Unmarshal(data, index); err != nil {
+			return nil, "", fmt.Errorf("cannot parse manifest list for %s: %v", r, err)
+		}
+		config, err := index.bundleConfig()
+		if err != nil {
+			return nil, "", fmt.Errorf("%s is not a bundle: %v", r, err)
+		}
+		if data, _, _, err = c.GetManifest(r.WithDigest(config.Digest)); err != nil {
+			return nil, "", err
+		}
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, "", fmt.Errorf("cannot parse manifest for %s: %v", r, err)
+	}
+	if m.Config.MediaType != MediaTypeBundleConfig {
+		return nil, "", fmt.Errorf("%s is not a bundle (config media type %q)", r, m.Config.MediaType)
+	}
+	return m, artifactDigest, nil
+}
+
+// bundleConfig returns the descriptor of the index's bundle config manifest
+func (i *Index) bundleConfig() (Descriptor, error) {
+	if t := i.Annotations[AnnotationArtifactType]; t != "" && t != ArtifactTypeBundle {
+		return Descriptor{}, fmt.Errorf("artifact type is %q", t)
+	}
+	for _, m := range i.Manifests {
+		if m.Annotations[AnnotationManifestType] == ManifestTypeConfig {
+			return m, nil
+		}
+	}
+	return Descriptor{}, fmt.Errorf("no manifest is marked as the bundle's config")
+}
+
+// PullBundle fetches the bundle document stored as an artifact at r.
+//
+// It returns the raw bundle.json and the digest of the artifact.
+func (c *Client) PullBundle(r Reference) ([]byte, string, error) {
+	m, artifactDigest, err := c.BundleManifest(r)
+	if err != nil {
+		return nil, "", err
+	}
+	b, err := c.FetchVerifiedBlob(r, m.Config.Digest)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, artifactDigest, nil
+}
+
+// PushBundle stores the bundle document data as an artifact at r, in the cnab-to-oci
+// representation, and returns the digest of the artifact.
+//
+// data becomes the config blob of an OCI manifest without layers, which is referenced by an
+// image index tagged with the tag of r. The index also references the bundle's invocation
+// and component images that are stored by digest in the repository of r, so that the
+// registry keeps them as long as the bundle.
+func (c *Client) PushBundle(r Reference, data []byte) (string, error) {
+	var b bundle.Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return "", fmt.Errorf("cannot parse bundle: %v", err)
+	}
+	config, err := c.PushBlob(r, MediaTypeBundleConfig, data)
+	if err != nil {
+		return "", err
+	}
+	m, err := json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifest,
+		Config:        config,
+		Layers:        []Descriptor{},
+	})
+	if err != nil {
+		return "", err
+	}
+	md, err := c.PutManifest(r.WithTag(""), MediaTypeOCIManifest, m)
+	if err != nil {
+		return "", err
+	}
+
+	index := Index{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIIndex,
+		Manifests: []Descriptor{{
+			MediaType:   MediaTypeOCIManifest,
+			Digest:      md,
+			Size:        int64(len(m)),
+			Annotations: map[string]string{AnnotationManifestType: ManifestTypeConfig},
+		}},
+		Annotations: map[string]string{
+			AnnotationArtifactType:   ArtifactTypeBundle,
+			AnnotationRuntimeVersion: b.SchemaVersion,
+			AnnotationTitle:          b.Name,
+			AnnotationVersion:        b.Version,
+		},
+	}
+	if b.Description != "" {
+		index.Annotations[AnnotationDescription] = b.Description
+	}
+	for _, img := range b.InvocationImages {
+		desc, err := c.localImage(r, img.Image, img.Digest)
+		if err != nil {
+			return "", err
+		}
+		if desc != nil {
+			desc.Annotations = map[string]string{AnnotationManifestType: ManifestTypeInvocation}
+			index.Manifests = append(index.Manifests, *desc)
+		}
+	}
+	for _, img := range b.Images {
+		desc, err := c.localImage(r, img.URI, img.Digest)
+		if err != nil {
+			return "", err
+		}
+		if desc != nil {
+			desc.Annotations = map[string]string{
+				AnnotationManifestType:  ManifestTypeComponent,
+				AnnotationComponentName: img.Name,
+			}
+			index.Manifests = append(index.Manifests, *desc)
+		}
+	}
+	data, err = json.Marshal(index)
+	if err != nil {
+		return "", err
+	}
+	return c.PutManifest(r, MediaTypeOCIIndex, data)
+}
+
+// localImage returns the descriptor of the image ref, if it is stored by digest in the
+// repository of r, and nil otherwise
+func (c *Client) localImage(r Reference, ref, d string) (*Descriptor, error) {
+	img, err := ParseReference(ref)
+	if err != nil || img.Registry != r.Registry || img.Repository != r.Repository {
+		return nil, nil
+	}
+	if img.Digest == "" {
+		img.Digest = d
+	}
+	if img.Digest == "" {
+		return nil, nil
+	}
+	data, mediaType, md, err := c.GetManifest(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{MediaType: mediaType, Digest: md, Size: int64(len(data))}, nil
+}
@@ -0,0 +1,254 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/deis/duffle/pkg/progress"
+)
+
+// IdentityTokenUsername is the username credentials carry when their password is an
+// identity token, which is exchanged for registry tokens as an OAuth2 refresh token
+const IdentityTokenUsername = "<token>"
+
+// Credentials returns the username and password to use for a registry.
+//
+// Empty values mean the registry is accessed anonymously.
+type Credentials func(registry string) (username, password string, err error)
+
+// Client talks to registries implementing the Docker Registry HTTP API V2
+type Client struct {
+	// HTTP is the underlying HTTP client; http.DefaultClient is used when nil
+	HTTP *http.Client
+	// Credentials supplies registry credentials; requests are anonymous when nil
+	Credentials Credentials
+	// Progress receives the progress of blob transfers; it is not reported when nil
+	Progress progress.Reporter
+	// ChunkSize is the size of the chunks large blobs are uploaded in; DefaultChunkSize when zero
+	ChunkSize int64
+	// Sessions remembers unfinished uploads so that they can be resumed; when nil,
+	// interrupted uploads restart from the beginning
+	Sessions *UploadSessions
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewClient returns a Client using the given credentials
+func NewClient(creds Credentials) *Client {
+	return &Client{Credentials: creds}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
+
+// PlainHTTPRegistries lists the registries, as HOST[:PORT], that speak plain HTTP rather
+// than HTTPS. Registries on localhost are always assumed to.
+var PlainHTTPRegistries []string
+
+// scheme returns the URL scheme for a registry host; local registries are assumed to speak plain HTTP
+func scheme(host string) string {
+	h := host
+	if i := strings.LastIndex(h, ":"); i != -1 {
+		h = h[:i]
+	}
+	if h == "localhost" || h == "127.0.0.1" {
+		return "http"
+	}
+	for _, r := range PlainHTTPRegistries {
+		if strings.EqualFold(r, host) || strings.EqualFold(r, h) {
+			return "http"
+		}
+	}
+	return "https"
+}
+
+func (c *Client) url(r Reference, format string, args ...interface{}) string {
+	return fmt.Sprintf("%s://%s/v2/%s/", scheme(r.Host()), r.Host(), r.Repository) + fmt.Sprintf(format, args...)
+}
+
+// Do sends a request for the repository of r, authenticating if the registry asks for it.
+//
+// The request body, if any, must be replayable, since the request may be retried after authenticating.
+func (c *Client) Do(r Reference, req *http.Request, body []byte) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		if body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		c.authorize(r, req)
+		return c.httpClient().Do(req)
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if err := c.authenticate(r, challenge); err != nil {
+		return nil, err
+	}
+	return send()
+}
+
+func (c *Client) authorize(r Reference, req *http.Request) {
+	c.mu.Lock()
+	token := c.tokens[r.Host()+"/"+r.Repository]
+	c.mu.Unlock()
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+}
+
+// authenticate answers a WWW-Authenticate challenge, caching the resulting Authorization header
+func (c *Client) authenticate(r Reference, challenge string) error {
+	var user, pass string
+	if c.Credentials != nil {
+		var err error
+		if user, pass, err = c.Credentials(r.Registry); err != nil {
+			return err
+		}
+	}
+
+	scheme, params := parseChallenge(challenge)
+	var header string
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if user == "" || user == IdentityTokenUsername {
+			return fmt.Errorf("%s requires authentication", r.Registry)
+		}
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.SetBasicAuth(user, pass)
+		header = req.Header.Get("Authorization")
+	case "bearer":
+		token, err := c.fetchToken(params, user, pass)
+		if err != nil {
+			return err
+		}
+		header = "Bearer " + token
+	default:
+		return fmt.Errorf("unsupported authentication scheme %q from %s", scheme, r.Registry)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == nil {
+		c.tokens = map[string]string{}
+	}
+	c.tokens[r.Host()+"/"+r.Repository] = header
+	return nil
+}
+
+func (c *Client) fetchToken(params map[string]string, user, pass string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+
+	var req *http.Request
+	if user == IdentityTokenUsername {
+		// identity tokens are exchanged with the OAuth2 refresh token grant
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {pass},
+			"client_id":     {"duffle"},
+			"service":       {params["service"]},
+			"scope":         {params["scope"]},
+		}
+		req, err = http.NewRequest("POST", realm.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		q := realm.Query()
+		if s := params["service"]; s != "" {
+			q.Set("service", s)
+		}
+		if s := params["scope"]; s != "" {
+			q.Set("scope", s)
+		}
+		realm.RawQuery = q.Encode()
+		if req, err = http.NewRequest("GET", realm.String(), nil); err != nil {
+			return "", err
+		}
+		if user != "" {
+			req.SetBasicAuth(user, pass)
+		}
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot obtain registry token from %s: %s", realm.Host, resp.Status)
+	}
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.Token != "" {
+		return t.Token, nil
+	}
+	return t.AccessToken, nil
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and parameters
+func parseChallenge(h string) (string, map[string]string) {
+	params := map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(h), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+	rest := parts[1]
+	for rest != "" {
+		eq := strings.IndexRune(rest, '=')
+		if eq == -1 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexRune(rest[1:], '"')
+			if end == -1 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.IndexRune(rest, ','); comma != -1 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return parts[0], params
+}
+
+// errorFromResponse builds an error describing an unexpected registry response
+func errorFromResponse(resp *http.Response, what string) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		return fmt.Errorf("%s: %s", what, resp.Status)
+	}
+	return fmt.Errorf("%s: %s: %s", what, resp.Status, msg)
+}
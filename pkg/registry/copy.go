@@ -0,0 +1,126 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/deis/duffle/pkg/docker"
+	"github.com/deis/duffle/pkg/progress"
+)
+
+// CopyImage copies the image at src into the repository of dst and returns the digest of its
+// manifest, which is unchanged by the copy. The image is tagged with the tag of dst if it
+// has one.
+//
+// Manifest lists are copied along with the manifest of every platform. Blobs already in
+// dst are skipped, and blobs within the same registry are mounted rather than transferred.
+func (c *Client) CopyImage(src, dst Reference) (string, error) {
+	data, mediaType, d, err := c.GetManifest(src)
+	if err != nil {
+		return "", err
+	}
+	if isIndex(mediaType) {
+		var index Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return "", fmt.Errorf("cannot parse manifest list %s: %v", src, err)
+		}
+		for _, m := range index.Manifests {
+			if _, err := c.CopyImage(src.WithDigest(m.Digest), dst.WithTag("").WithDigest(m.Digest)); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return "", fmt.Errorf("cannot parse manifest %s: %v", src, err)
+		}
+		for _, desc := range append([]Descriptor{m.Config}, m.Layers...) {
+			if err := c.copyBlob(src, dst, desc); err != nil {
+				return "", err
+			}
+		}
+	}
+	target := dst
+	if target.Tag == "" {
+		target.Digest = d
+	}
+	if _, err := c.PutManifest(target, mediaType, data); err != nil {
+		return "", err
+	}
+	return d, nil
+}
+
+// copyBlob copies the blob desc from the repository of src to that of dst, unless dst already has it
+func (c *Client) copyBlob(src, dst Reference, desc Descriptor) error {
+	event := progress.Event{ID: desc.Digest, Action: "exists", Total: desc.Size}
+	exists, err := c.BlobExists(dst, desc.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		c.Progress.Finish(event)
+		return nil
+	}
+	var params url.Values
+	if src.Registry == dst.Registry {
+		params = url.Values{"mount": {desc.Digest}, "from": {src.Repository}}
+	}
+	open := func(offset int64) (io.ReadCloser, error) {
+		return c.fetchBlobFrom(src, desc.Digest, offset)
+	}
+	mounted, err := c.sendBlob(dst, desc, params, open, "copy")
+	if err == nil && mounted {
+		event.Action = "mounted"
+		c.Progress.Finish(event)
+	}
+	return err
+}
+
+// Relocate copies the image ref into the registry namespace prefix, such as
+// example.com/mirror, and returns the reference of the copy, pinned by digest.
+//
+// The copy keeps the repository path and tag of the original within the namespace:
+// docker.io/library/alpine:3.8 relocated to example.com/mirror becomes
+// example.com/mirror/library/alpine:3.8.
+func (c *Client) Relocate(ref, prefix string) (string, error) {
+	src, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	prefix = strings.Trim(prefix, "/")
+	ns, err := ParseReference(prefix)
+	if domain, _ := docker.SplitDomain(prefix); err != nil || domain == "" || ns.Object() != defaultTag {
+		return "", fmt.Errorf("invalid repository prefix %q: expected <registry>/<namespace>", prefix)
+	}
+	dst := Reference{Registry: ns.Registry, Repository: ns.Repository + "/" + src.Repository, Tag: src.Tag}
+	d, err := c.CopyImage(src, dst)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s@%s", dst.Registry, dst.Repository, d), nil
+}
+
+// Tag points the tag of dst at the manifest of src and returns the manifest's digest.
+//
+// Within a repository only the manifest is sent again, since the registry already holds
+// everything it references; tagging into another repository copies the image as CopyImage
+// does.
+func (c *Client) Tag(src, dst Reference) (string, error) {
+	if dst.Tag == "" || dst.Digest != "" {
+		return "", fmt.Errorf("cannot tag %s: a tag is required", dst)
+	}
+	if src.Registry != dst.Registry || src.Repository != dst.Repository {
+		return c.CopyImage(src, dst)
+	}
+	data, mediaType, d, err := c.GetManifest(src)
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.PutManifest(dst, mediaType, data); err != nil {
+		return "", err
+	}
+	return d, nil
+}
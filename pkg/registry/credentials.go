@@ -0,0 +1,174 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+)
+
+// RegistryFile holds the registry settings saved in duffle home: the credentials saved with
+// 'duffle registry login', and the content trust settings of 'duffle registry trust'
+type RegistryFile struct {
+	// Auths holds the credentials stored in the file, keyed by registry
+	Auths map[string]Auth `json:"auths,omitempty"`
+	// CredentialHelpers names, by registry, the docker credential helper holding the
+	// registry's credentials, so that they need not be stored in the file
+	CredentialHelpers map[string]string `json:"credHelpers,omitempty"`
+	// Trust holds, by registry, where the trust data of its bundles is kept. Bundles
+	// pushed to these registries are signed, and bundles pulled from them verified.
+	Trust map[string]Trust `json:"trust,omitempty"`
+}
+
+// Trust configures content trust for a registry
+type Trust struct {
+	// Server is the URL of the Notary server holding the registry's trust data
+	Server string `json:"server"`
+	// TrustDir is the directory holding cached trust data and signing keys; the notary
+	// client's default is used when empty
+	TrustDir string `json:"trustDir,omitempty"`
+}
+
+// TrustFor returns the content trust settings of registry, or nil if content trust is
+// not enabled for it
+func (f *RegistryFile) TrustFor(registry string) *Trust {
+	t, ok := f.Trust[NormalizeRegistry(registry)]
+	if !ok {
+		return nil
+	}
+	return &t
+}
+
+// Auth is a username and password for a registry
+type Auth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoadRegistryFile reads the registries file at path. A missing file holds no settings.
+func LoadRegistryFile(path string) (*RegistryFile, error) {
+	f := &RegistryFile{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// WriteFile saves the registries file to path, readable only by its owner, since it may
+// hold credentials
+func (f *RegistryFile) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// Login records the credentials of registry, replacing any previous ones. When helper is
+// set, the credentials are handed to that docker credential helper and only its name is
+// recorded.
+func (f *RegistryFile) Login(registry, username, password, helper string) error {
+	registry = NormalizeRegistry(registry)
+	if _, err := f.Logout(registry); err != nil {
+		return err
+	}
+	if helper != "" {
+		if err := HelperStore(helper, dockerServer(registry), username, password); err != nil {
+			return err
+		}
+		if f.CredentialHelpers == nil {
+			f.CredentialHelpers = map[string]string{}
+		}
+		f.CredentialHelpers[registry] = helper
+		return nil
+	}
+	if f.Auths == nil {
+		f.Auths = map[string]Auth{}
+	}
+	f.Auths[registry] = Auth{Username: username, Password: password}
+	return nil
+}
+
+// Logout forgets the credentials of registry, erasing them from the credential helper
+// holding them, and reports whether there were any
+func (f *RegistryFile) Logout(registry string) (bool, error) {
+	registry = NormalizeRegistry(registry)
+	if helper, ok := f.CredentialHelpers[registry]; ok {
+		if err := HelperErase(helper, dockerServer(registry)); err != nil {
+			return false, err
+		}
+		delete(f.CredentialHelpers, registry)
+		return true, nil
+	}
+	if _, ok := f.Auths[registry]; ok {
+		delete(f.Auths, registry)
+		return true, nil
+	}
+	return false, nil
+}
+
+// Credentials returns the credentials recorded for each registry. Registries without
+// recorded credentials fall back to those of the docker CLI (see DockerCredentials).
+func (f *RegistryFile) Credentials() Credentials {
+	return func(registry string) (string, string, error) {
+		registry = NormalizeRegistry(registry)
+		if helper, ok := f.CredentialHelpers[registry]; ok {
+			return HelperGet(helper, dockerServer(registry))
+		}
+		if a, ok := f.Auths[registry]; ok {
+			return a.Username, a.Password, nil
+		}
+		return DockerCredentials()(registry)
+	}
+}
+
+// NormalizeRegistry returns the name credentials of a registry are recorded under: its
+// host, as it appears in references, without any URL scheme or path. Docker Hub is
+// recorded as DefaultRegistry.
+func NormalizeRegistry(registry string) string {
+	registry = strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	if i := strings.IndexRune(registry, '/'); i != -1 {
+		registry = registry[:i]
+	}
+	switch registry {
+	case "index.docker.io", defaultRegistryHost:
+		return DefaultRegistry
+	}
+	return registry
+}
+
+// Ping checks that registry accepts the client's credentials by requesting its API root
+func (c *Client) Ping(registry string) error {
+	r := Reference{Registry: NormalizeRegistry(registry)}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s://%s/v2/", scheme(r.Host()), r.Host()), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errorFromResponse(resp, fmt.Sprintf("cannot log in to %s", r.Registry))
+	}
+	return nil
+}
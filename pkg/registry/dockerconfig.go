@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dockerHubServer is the key docker configurations record Docker Hub under
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// dockerConfig is the part of the docker CLI configuration describing registry credentials
+type dockerConfig struct {
+	Auths             map[string]dockerAuth `json:"auths,omitempty"`
+	CredentialsStore  string                `json:"credsStore,omitempty"`
+	CredentialHelpers map[string]string     `json:"credHelpers,omitempty"`
+}
+
+// dockerAuth holds the credentials docker stores in its configuration for a registry
+type dockerAuth struct {
+	// Auth is the base64 encoding of username:password
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// decode returns the username and password of a, or IdentityTokenUsername and the identity token
+func (a dockerAuth) decode() (string, string, error) {
+	if a.IdentityToken != "" {
+		return IdentityTokenUsername, a.IdentityToken, nil
+	}
+	if a.Auth == "" {
+		return a.Username, a.Password, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(a.Auth)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid credentials in %s: %v", dockerConfigPath(), err)
+	}
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid credentials in %s", dockerConfigPath())
+	}
+	return parts[0], parts[1], nil
+}
+
+// dockerConfigPath returns the path of the docker CLI configuration, honoring $DOCKER_CONFIG
+func dockerConfigPath() string {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// loadDockerConfig reads the docker CLI configuration. A missing or unreadable
+// configuration is treated as empty, since docker need not be installed.
+func loadDockerConfig() *dockerConfig {
+	c := &dockerConfig{}
+	data, err := ioutil.ReadFile(dockerConfigPath())
+	if err != nil {
+		return c
+	}
+	json.Unmarshal(data, c)
+	return c
+}
+
+// dockerServer returns the key the docker configuration records registry under
+func dockerServer(registry string) string {
+	if registry == DefaultRegistry {
+		return dockerHubServer
+	}
+	return registry
+}
+
+// DockerCredentialHelper returns the credential helper the docker configuration uses for
+// registry: the helper configured for the registry, or else the default credential store.
+// It returns an empty string when docker keeps the registry's credentials in its
+// configuration file, or has no configuration.
+func DockerCredentialHelper(registry string) string {
+	c := loadDockerConfig()
+	if h, ok := c.CredentialHelpers[dockerServer(NormalizeRegistry(registry))]; ok {
+		return h
+	}
+	return c.CredentialsStore
+}
+
+// DockerCredentials returns the credentials the docker CLI uses for each registry, as
+// 'docker login' saved them: from the credential helper configured for the registry,
+// from the docker configuration file itself, or from the default credential store, in
+// that order. Registries docker has no credentials for are accessed anonymously.
+func DockerCredentials() Credentials {
+	return loadDockerConfig().credentials
+}
+
+func (c *dockerConfig) credentials(registry string) (string, string, error) {
+	registry = NormalizeRegistry(registry)
+	server := dockerServer(registry)
+	if h, ok := c.CredentialHelpers[server]; ok {
+		return HelperGet(h, server)
+	}
+	for key, a := range c.Auths {
+		if NormalizeRegistry(key) != registry {
+			continue
+		}
+		// with a credential store, entries of the file only record which registries
+		// the store has credentials for
+		if user, pass, err := a.decode(); err != nil || user != "" {
+			return user, pass, err
+		}
+	}
+	if c.CredentialsStore != "" {
+		return HelperGet(c.CredentialsStore, server)
+	}
+	return "", "", nil
+}
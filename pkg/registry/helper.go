@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// helperCredentials is the payload exchanged with a docker credential helper
+type helperCredentials struct {
+	ServerURL string `json:",omitempty"`
+	Username  string
+	Secret    string
+}
+
+// HelperGet asks the docker credential helper docker-credential-<helper> for the
+// credentials of serverURL. Empty credentials are returned if the helper has none.
+func HelperGet(helper, serverURL string) (username, secret string, err error) {
+	out, err := runHelper(helper, "get", strings.NewReader(serverURL))
+	if err != nil {
+		if isNotFound(err) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	var creds helperCredentials
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return "", "", fmt.Errorf("cannot parse output of credential helper %s: %v", helper, err)
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+// HelperStore saves credentials for serverURL with the docker credential helper
+// docker-credential-<helper>
+func HelperStore(helper, serverURL, username, secret string) error {
+	data, err := json.Marshal(helperCredentials{ServerURL: serverURL, Username: username, Secret: secret})
+	if err != nil {
+		return err
+	}
+	_, err = runHelper(helper, "store", bytes.NewReader(data))
+	return err
+}
+
+// HelperErase removes the credentials of serverURL from the docker credential helper
+// docker-credential-<helper>. Credentials the helper does not have are not an error.
+func HelperErase(helper, serverURL string) error {
+	_, err := runHelper(helper, "erase", strings.NewReader(serverURL))
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// helperError is the failure of a credential helper command, carrying its message
+type helperError struct {
+	helper  string
+	message string
+	err     error
+}
+
+func (e *helperError) Error() string {
+	return fmt.Sprintf("credential helper %s failed: %v: %s", e.helper, e.err, e.message)
+}
+
+// isNotFound reports whether err is a credential helper saying it has no credentials
+func isNotFound(err error) bool {
+	he, ok := err.(*helperError)
+	return ok && strings.Contains(he.message, "credentials not found")
+}
+
+// runHelper runs command of the credential helper with the given input and returns its output.
+// Helpers report some failures on standard output, so it is included in the error's message.
+func runHelper(helper, command string, stdin io.Reader) ([]byte, error) {
+	cmd := exec.Command("docker-credential-"+helper, command)
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String() + "\n" + stdout.String())
+		return nil, &helperError{helper: helper, message: msg, err: err}
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,146 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+	"github.com/deis/duffle/pkg/progress"
+)
+
+// Media types used by duffle when storing bundles in registries
+const (
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	// MediaTypeBundleConfig is the config blob of a bundle artifact; it holds bundle.json
+	MediaTypeBundleConfig = "application/vnd.cnab.config.v1+json"
+)
+
+// acceptedManifests lists the manifest media types duffle can read
+var acceptedManifests = []string{MediaTypeOCIManifest, MediaTypeDockerManifest, MediaTypeOCIIndex, MediaTypeDockerManifestList}
+
+// Descriptor describes content stored in a registry
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is an OCI image manifest
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Index is an OCI image index or Docker manifest list, referencing one manifest per platform
+type Index struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// isIndex reports whether mediaType is that of an image index or manifest list
+func isIndex(mediaType string) bool {
+	return mediaType == MediaTypeOCIIndex || mediaType == MediaTypeDockerManifestList
+}
+
+// GetManifest fetches the manifest r points at, returning its content, media type and digest
+func (c *Client) GetManifest(r Reference) ([]byte, string, string, error) {
+	req, err := http.NewRequest("GET", c.url(r, "manifests/%s", r.Object()), nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	req.Header.Set("Accept", strings.Join(acceptedManifests, ", "))
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", errorFromResponse(resp, fmt.Sprintf("cannot fetch manifest %s", r))
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", err
+	}
+	d := digest.OfBuffer(data)
+	if r.Digest != "" && d != r.Digest {
+		return nil, "", "", fmt.Errorf("manifest digest mismatch for %s: got %s", r, d)
+	}
+	mediaType := strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	if mediaType == "" || mediaType == "application/json" {
+		var m struct {
+			MediaType string `json:"mediaType"`
+		}
+		if err := json.Unmarshal(data, &m); err == nil && m.MediaType != "" {
+			mediaType = m.MediaType
+		}
+	}
+	return data, mediaType, d, nil
+}
+
+// ManifestExists reports whether the repository of r holds the manifest r points at
+func (c *Client) ManifestExists(r Reference) (bool, error) {
+	req, err := http.NewRequest("HEAD", c.url(r, "manifests/%s", r.Object()), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", strings.Join(acceptedManifests, ", "))
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, errorFromResponse(resp, fmt.Sprintf("cannot check manifest %s", r))
+}
+
+// FetchBlob returns the blob with the given digest from the repository of r
+func (c *Client) FetchBlob(r Reference, d string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", c.url(r, "blobs/%s", d), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, errorFromResponse(resp, fmt.Sprintf("cannot fetch blob %s", d))
+	}
+	return resp.Body, nil
+}
+
+// FetchVerifiedBlob reads a blob into memory and checks it against its digest
+func (c *Client) FetchVerifiedBlob(r Reference, d string) ([]byte, error) {
+	rc, err := c.FetchBlob(r, d)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(progress.NewReader(rc, c.Progress, progress.Event{ID: d, Action: "pull"}))
+	if err != nil {
+		return nil, err
+	}
+	if actual := digest.OfBuffer(data); actual != d {
+		return nil, fmt.Errorf("blob digest mismatch: expected %s, got %s", d, actual)
+	}
+	return data, nil
+}
@@ -0,0 +1,133 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+)
+
+// BlobExists reports whether the repository of r holds the blob with digest d
+func (c *Client) BlobExists(r Reference, d string) (bool, error) {
+	req, err := http.NewRequest("HEAD", c.url(r, "blobs/%s", d), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, errorFromResponse(resp, fmt.Sprintf("cannot check blob %s", d))
+}
+
+// PushBlob uploads data as a blob to the repository of r, unless it is already there, and
+// returns its descriptor
+func (c *Client) PushBlob(r Reference, mediaType string, data []byte) (Descriptor, error) {
+	desc := Descriptor{MediaType: mediaType, Digest: digest.OfBuffer(data), Size: int64(len(data))}
+	exists, err := c.BlobExists(r, desc.Digest)
+	if err != nil || exists {
+		return desc, err
+	}
+	open := func(offset int64) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data[offset:])), nil
+	}
+	_, err = c.sendBlob(r, desc, nil, open, "push")
+	return desc, err
+}
+
+// startUpload opens a blob upload session in the repository of r and returns its URL.
+//
+// The query parameters of params are added to the request; they may ask the registry to
+// mount an existing blob instead, in which case the returned URL is empty.
+func (c *Client) startUpload(r Reference, params url.Values) (string, error) {
+	u := c.url(r, "blobs/uploads/")
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return "", nil
+	case http.StatusAccepted:
+		loc, err := req.URL.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			return "", fmt.Errorf("invalid upload location %q: %v", resp.Header.Get("Location"), err)
+		}
+		return loc.String(), nil
+	}
+	return "", errorFromResponse(resp, fmt.Sprintf("cannot start upload to %s/%s", r.Registry, r.Repository))
+}
+
+// finishUpload completes the upload session at location with the rest of the blob's
+// content, streamed from body with the given size; body is nil when all of the content was
+// sent in chunks. Streamed content cannot be sent again, so the repository must already
+// have authorized the upload session.
+func (c *Client) finishUpload(r Reference, location, d string, body io.Reader, size int64) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("digest", d)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequest("PUT", u.String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if body != nil {
+		req.ContentLength = size
+	}
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return errorFromResponse(resp, fmt.Sprintf("cannot upload blob %s", d))
+	}
+	return nil
+}
+
+// PutManifest uploads a manifest of the given media type to r, tagging it with the tag of
+// r if it has one, and returns the manifest's digest
+func (c *Client) PutManifest(r Reference, mediaType string, data []byte) (string, error) {
+	d := digest.OfBuffer(data)
+	object := r.Tag
+	if object == "" {
+		object = d
+	}
+	req, err := http.NewRequest("PUT", c.url(r, "manifests/%s", object), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mediaType)
+	resp, err := c.Do(r, req, data)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", errorFromResponse(resp, fmt.Sprintf("cannot push manifest %s", r))
+	}
+	return d, nil
+}
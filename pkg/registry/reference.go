@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deis/duffle/pkg/docker"
+)
+
+const (
+	// DefaultRegistry is the registry used for references that do not name one
+	DefaultRegistry = "docker.io"
+	// defaultRegistryHost is the API endpoint of DefaultRegistry
+	defaultRegistryHost = "registry-1.docker.io"
+	defaultTag          = "latest"
+)
+
+// Reference identifies a repository in a registry and, optionally, a tag or digest within it
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses an image-style reference such as "example.com/org/bundle:1.0.0"
+func ParseReference(ref string) (Reference, error) {
+	if !docker.ValidReference(ref) {
+		return Reference{}, fmt.Errorf("invalid reference %q", ref)
+	}
+	r := Reference{}
+	domain, remainder := docker.SplitDomain(ref)
+	if i := strings.IndexRune(remainder, '@'); i != -1 {
+		r.Digest = remainder[i+1:]
+		remainder = remainder[:i]
+	}
+	if i := strings.LastIndex(remainder, ":"); i != -1 {
+		r.Tag = remainder[i+1:]
+		remainder = remainder[:i]
+	}
+	r.Registry = domain
+	if r.Registry == "" {
+		r.Registry = DefaultRegistry
+	}
+	r.Repository = remainder
+	if r.Registry == DefaultRegistry && !strings.ContainsRune(r.Repository, '/') {
+		r.Repository = "library/" + r.Repository
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = defaultTag
+	}
+	return r, nil
+}
+
+// Object returns the digest if set, otherwise the tag
+func (r Reference) Object() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// Host returns the host serving the registry API
+func (r Reference) Host() string {
+	if r.Registry == DefaultRegistry {
+		return defaultRegistryHost
+	}
+	return r.Registry
+}
+
+// WithTag returns a copy of the reference pointing at tag
+func (r Reference) WithTag(tag string) Reference {
+	r.Tag, r.Digest = tag, ""
+	return r
+}
+
+// WithDigest returns a copy of the reference pointing at digest
+func (r Reference) WithDigest(digest string) Reference {
+	r.Digest = digest
+	return r
+}
+
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ListTags returns every tag in the repository of r
+func (c *Client) ListTags(r Reference) ([]string, error) {
+	var tags []string
+	err := c.list(r, c.url(r, "tags/list"), func(data []byte) error {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		tags = append(tags, page.Tags...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list tags of %s/%s: %v", r.Registry, r.Repository, err)
+	}
+	return tags, nil
+}
+
+// Catalog returns every repository served by the registry of r.
+//
+// Many registries restrict or disable the catalog API, in which case an error is returned.
+func (c *Client) Catalog(r Reference) ([]string, error) {
+	r.Repository = ""
+	var repos []string
+	u := fmt.Sprintf("%s://%s/v2/_catalog", scheme(r.Host()), r.Host())
+	err := c.list(r, u, func(data []byte) error {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		repos = append(repos, page.Repositories...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list repositories of %s: %v", r.Registry, err)
+	}
+	return repos, nil
+}
+
+// list fetches each page of a paginated listing starting at u, following Link headers
+func (c *Client) list(r Reference, u string, page func([]byte) error) error {
+	for u != "" {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.Do(r, req, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return errorFromResponse(resp, "listing failed")
+		}
+		var data json.RawMessage
+		err = json.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if err := page(data); err != nil {
+			return err
+		}
+		next, err := nextLink(req.URL, resp.Header.Get("Link"))
+		if err != nil {
+			return err
+		}
+		u = next
+	}
+	return nil
+}
+
+// nextLink returns the absolute URL of a `<url>; rel="next"` Link header, if any
+func nextLink(base *url.URL, link string) (string, error) {
+	if link == "" || !strings.Contains(link, `rel="next"`) {
+		return "", nil
+	}
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start == -1 || end < start {
+		return "", fmt.Errorf("invalid Link header %q", link)
+	}
+	next, err := base.Parse(link[start+1 : end])
+	if err != nil {
+		return "", err
+	}
+	return next.String(), nil
+}
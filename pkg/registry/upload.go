@@ -0,0 +1,290 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/progress"
+)
+
+const (
+	// DefaultChunkSize is the size of the chunks blobs are uploaded in, unless the client
+	// configures it. Smaller blobs are uploaded in a single request.
+	DefaultChunkSize = 8 << 20
+	// maxChunkFailures is how many failed chunks an upload survives before giving up
+	maxChunkFailures = 5
+)
+
+// opener returns the content of a blob from the given offset
+type opener func(offset int64) (io.ReadCloser, error)
+
+// sendBlob uploads the blob desc to the repository of r, reading it with open, and reports
+// whether the registry mounted it instead. params are added to the request opening the
+// upload session, and may ask the registry to mount an existing blob.
+//
+// An upload of the same blob left unfinished by an earlier failure is resumed when the
+// client remembers upload sessions and the registry still holds it.
+func (c *Client) sendBlob(r Reference, desc Descriptor, params url.Values, open opener, action string) (bool, error) {
+	key := sessionKey(r, desc.Digest)
+	var (
+		location string
+		offset   int64
+	)
+	if loc := c.Sessions.get(key); loc != "" {
+		if o, err := c.uploadStatus(r, loc); err == nil {
+			location, offset = loc, o
+		} else {
+			c.Sessions.remove(key)
+		}
+	}
+	if location == "" {
+		var err error
+		if location, err = c.startUpload(r, params); err != nil || location == "" {
+			return err == nil, err
+		}
+	}
+
+	event := progress.Event{ID: desc.Digest, Action: action, Total: desc.Size}
+	if offset == 0 && desc.Size <= c.chunkSize() {
+		rc, err := open(0)
+		if err != nil {
+			return false, err
+		}
+		defer rc.Close()
+		return false, c.finishUpload(r, location, desc.Digest, progress.NewReader(rc, c.Progress, event), desc.Size)
+	}
+	return false, c.uploadChunks(r, location, offset, desc, open, event)
+}
+
+// uploadChunks sends the blob desc from offset onwards to the upload session at location,
+// one chunk per request, and completes the upload. After a failed chunk, the upload
+// resumes from the last chunk the registry acknowledged.
+func (c *Client) uploadChunks(r Reference, location string, offset int64, desc Descriptor, open opener, event progress.Event) error {
+	key := sessionKey(r, desc.Digest)
+	if err := c.Sessions.put(key, location); err != nil {
+		return err
+	}
+	for failures := 0; offset < desc.Size; {
+		err := func() error {
+			rc, err := open(offset)
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			event.Current = offset
+			body := progress.NewReader(rc, c.Progress, event)
+			for offset < desc.Size {
+				n := c.chunkSize()
+				if rest := desc.Size - offset; rest < n {
+					n = rest
+				}
+				next, received, err := c.uploadChunk(r, location, io.LimitReader(body, n), offset, n)
+				if err != nil {
+					return err
+				}
+				location, offset = next, received
+				if err := c.Sessions.put(key, location); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+		if err == nil {
+			break
+		}
+		// the session is kept, so that a later run may resume the upload
+		if failures++; failures > maxChunkFailures {
+			return err
+		}
+		time.Sleep(time.Duration(failures) * time.Second)
+		o, serr := c.uploadStatus(r, location)
+		if serr != nil {
+			return err
+		}
+		offset = o
+	}
+	if err := c.finishUpload(r, location, desc.Digest, nil, 0); err != nil {
+		return err
+	}
+	c.Sessions.remove(key)
+	return nil
+}
+
+// uploadChunk sends n bytes of body, starting at offset of the blob, to the upload session
+// at location. It returns the location of the session for the next chunk, and the offset
+// the registry has received the blob up to.
+func (c *Client) uploadChunk(r Reference, location string, body io.Reader, offset, n int64) (string, int64, error) {
+	req, err := http.NewRequest("PATCH", location, body)
+	if err != nil {
+		return "", 0, err
+	}
+	req.ContentLength = n
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+n-1))
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return "", 0, errorFromResponse(resp, fmt.Sprintf("cannot upload chunk %d-%d", offset, offset+n-1))
+	}
+	next, err := req.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid upload location %q: %v", resp.Header.Get("Location"), err)
+	}
+	received, err := parseUploadRange(resp.Header.Get("Range"))
+	if err != nil {
+		received = offset + n
+	}
+	return next.String(), received, nil
+}
+
+// uploadStatus returns how much of the blob the upload session at location has received
+func (c *Client) uploadStatus(r Reference, location string) (int64, error) {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return 0, errorFromResponse(resp, "cannot check upload status")
+	}
+	return parseUploadRange(resp.Header.Get("Range"))
+}
+
+// parseUploadRange parses the Range header of upload responses, 0-<last byte received>,
+// into the number of bytes received. Registries report empty uploads as 0-0.
+func parseUploadRange(h string) (int64, error) {
+	parts := strings.SplitN(strings.TrimPrefix(h, "bytes="), "-", 2)
+	if len(parts) != 2 || parts[0] != "0" {
+		return 0, fmt.Errorf("invalid upload range %q", h)
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid upload range %q", h)
+	}
+	if end == 0 {
+		return 0, nil
+	}
+	return end + 1, nil
+}
+
+func (c *Client) chunkSize() int64 {
+	if c.ChunkSize > 0 {
+		return c.ChunkSize
+	}
+	return DefaultChunkSize
+}
+
+// fetchBlobFrom returns the blob with digest d from the repository of r, starting at offset
+func (c *Client) fetchBlobFrom(r Reference, d string, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", c.url(r, "blobs/%s", d), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := c.Do(r, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// the registry ignored the range
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+	return nil, errorFromResponse(resp, fmt.Sprintf("cannot fetch blob %s", d))
+}
+
+// UploadSessions remembers the blob uploads in progress in a file, so that uploads
+// interrupted by a failure can be resumed by a later run. A nil UploadSessions remembers
+// nothing.
+type UploadSessions struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewUploadSessions returns the upload sessions remembered in the file at path
+func NewUploadSessions(path string) *UploadSessions {
+	return &UploadSessions{path: path}
+}
+
+func sessionKey(r Reference, d string) string {
+	return r.Registry + "/" + r.Repository + "@" + d
+}
+
+func (s *UploadSessions) load() map[string]string {
+	sessions := map[string]string{}
+	if data, err := ioutil.ReadFile(s.path); err == nil {
+		json.Unmarshal(data, &sessions)
+	}
+	return sessions
+}
+
+func (s *UploadSessions) save(sessions map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(s.path, data, 0600)
+}
+
+func (s *UploadSessions) get(key string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()[key]
+}
+
+func (s *UploadSessions) put(key, location string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := s.load()
+	sessions[key] = location
+	return s.save(sessions)
+}
+
+func (s *UploadSessions) remove(key string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := s.load()
+	if _, ok := sessions[key]; ok {
+		delete(sessions, key)
+		s.save(sessions)
+	}
+}
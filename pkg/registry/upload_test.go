@@ -0,0 +1,288 @@
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+)
+
+func TestParseUploadRange(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+		ok     bool
+	}{
+		{"0-0", 0, true},
+		{"0-9", 10, true},
+		{"bytes=0-9", 10, true},
+		{"0-1048575", 1 << 20, true},
+		{"", 0, false},
+		{"0", 0, false},
+		{"5-9", 0, false},
+		{"0-x", 0, false},
+		{"0-9-10", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseUploadRange(tt.header)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseUploadRange(%q) = %d, %v; want %d, ok %v", tt.header, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// uploadRegistry is a registry accepting chunked blob uploads to a single repository. It
+// can be told to fail chunks and upload status requests.
+type uploadRegistry struct {
+	mu sync.Mutex
+	// uploads holds the content received by each open upload session
+	uploads map[string][]byte
+	// blobs holds the completed blobs by digest
+	blobs map[string][]byte
+	// started and received count the sessions opened and the bytes received in chunks
+	started, received int
+	// patches counts the chunks sent; the chunks numbered in failPatches fail
+	patches     int
+	failPatches map[int]bool
+	// failStatus fails upload status requests
+	failStatus bool
+}
+
+func newUploadRegistry() *uploadRegistry {
+	return &uploadRegistry{uploads: map[string][]byte{}, blobs: map[string][]byte{}, failPatches: map[int]bool{}}
+}
+
+func (u *uploadRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	const prefix = "/v2/test/blobs/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, prefix)
+	if r.Method == "HEAD" {
+		if _, ok := u.blobs[p]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		return
+	}
+	if r.Method == "POST" && p == "uploads/" {
+		u.started++
+		id := strconv.Itoa(u.started)
+		u.uploads[id] = nil
+		w.Header().Set("Location", prefix+"uploads/"+id)
+		w.Header().Set("Range", "0-0")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	id := strings.TrimPrefix(p, "uploads/")
+	data, ok := u.uploads[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		if u.failStatus {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		u.writeRange(w, id)
+		w.WriteHeader(http.StatusNoContent)
+	case "PATCH":
+		u.patches++
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil || u.failPatches[u.patches] {
+			http.Error(w, "chunk failed", http.StatusInternalServerError)
+			return
+		}
+		if want := fmt.Sprintf("%d-%d", len(data), len(data)+len(body)-1); r.Header.Get("Content-Range") != want {
+			http.Error(w, "unexpected range "+r.Header.Get("Content-Range"), http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		u.uploads[id] = append(data, body...)
+		u.received += len(body)
+		u.writeRange(w, id)
+		w.Header().Set("Location", prefix+"uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case "PUT":
+		body, _ := ioutil.ReadAll(r.Body)
+		data = append(data, body...)
+		d := r.URL.Query().Get("digest")
+		if digest.OfBuffer(data) != d {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		delete(u.uploads, id)
+		u.blobs[d] = data
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (u *uploadRegistry) writeRange(w http.ResponseWriter, id string) {
+	end := len(u.uploads[id]) - 1
+	if end < 0 {
+		end = 0
+	}
+	w.Header().Set("Range", fmt.Sprintf("0-%d", end))
+}
+
+// testUploadClient returns a client uploading to the registry served by s in chunks of 4
+// bytes, remembering its sessions in dir
+func testUploadClient(t *testing.T, s *httptest.Server, dir string) (*Client, Reference) {
+	t.Helper()
+	r, err := ParseReference(strings.TrimPrefix(s.URL, "http://") + "/test:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(nil)
+	c.ChunkSize = 4
+	c.Sessions = NewUploadSessions(filepath.Join(dir, "uploads.json"))
+	return c, r
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "duffle-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPushBlobInChunks(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	reg := newUploadRegistry()
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	c, r := testUploadClient(t, s, dir)
+
+	data := []byte("0123456789")
+	desc, err := c.PushBlob(r, "application/octet-stream", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(reg.blobs[desc.Digest]); got != string(data) {
+		t.Errorf("registry holds %q, want %q", got, data)
+	}
+	if reg.patches != 3 {
+		t.Errorf("blob sent in %d chunks, want 3", reg.patches)
+	}
+	if loc := c.Sessions.get(sessionKey(r, desc.Digest)); loc != "" {
+		t.Errorf("finished upload still remembered at %s", loc)
+	}
+
+	// small blobs are sent in a single request
+	small := []byte("abc")
+	if _, err := c.PushBlob(r, "application/octet-stream", small); err != nil {
+		t.Fatal(err)
+	}
+	if reg.patches != 3 {
+		t.Errorf("small blob sent in %d chunks, want none", reg.patches-3)
+	}
+	if got := string(reg.blobs[digest.OfBuffer(small)]); got != string(small) {
+		t.Errorf("registry holds %q, want %q", got, small)
+	}
+}
+
+func TestPushBlobResumesFailedChunk(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	reg := newUploadRegistry()
+	reg.failPatches[2] = true
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	c, r := testUploadClient(t, s, dir)
+
+	data := []byte("0123456789")
+	desc, err := c.PushBlob(r, "application/octet-stream", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(reg.blobs[desc.Digest]); got != string(data) {
+		t.Errorf("registry holds %q, want %q", got, data)
+	}
+	if reg.started != 1 {
+		t.Errorf("%d upload sessions opened, want 1", reg.started)
+	}
+	if reg.received != len(data) {
+		t.Errorf("registry received %d bytes in chunks, want %d: acknowledged chunks were sent again", reg.received, len(data))
+	}
+}
+
+func TestPushBlobResumesSession(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	reg := newUploadRegistry()
+	reg.failPatches[2] = true
+	reg.failStatus = true
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	c, r := testUploadClient(t, s, dir)
+
+	data := []byte("0123456789")
+	if _, err := c.PushBlob(r, "application/octet-stream", data); err == nil {
+		t.Fatal("upload succeeded, although its status could not be checked after a failed chunk")
+	}
+	key := sessionKey(r, digest.OfBuffer(data))
+	if c.Sessions.get(key) == "" {
+		t.Fatal("interrupted upload is not remembered")
+	}
+
+	// a later run, with sessions read from the same file, resumes the upload
+	reg.failStatus = false
+	c, r = testUploadClient(t, s, dir)
+	desc, err := c.PushBlob(r, "application/octet-stream", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(reg.blobs[desc.Digest]); got != string(data) {
+		t.Errorf("registry holds %q, want %q", got, data)
+	}
+	if reg.started != 1 {
+		t.Errorf("%d upload sessions opened, want the first one resumed", reg.started)
+	}
+	if reg.received != len(data) {
+		t.Errorf("registry received %d bytes in chunks, want %d", reg.received, len(data))
+	}
+	if c.Sessions.get(key) != "" {
+		t.Error("finished upload is still remembered")
+	}
+}
+
+func TestPushBlobForgetsExpiredSession(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	reg := newUploadRegistry()
+	s := httptest.NewServer(reg)
+	defer s.Close()
+	c, r := testUploadClient(t, s, dir)
+
+	data := []byte("0123456789")
+	key := sessionKey(r, digest.OfBuffer(data))
+	if err := c.Sessions.put(key, s.URL+"/v2/test/blobs/uploads/expired"); err != nil {
+		t.Fatal(err)
+	}
+	desc, err := c.PushBlob(r, "application/octet-stream", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(reg.blobs[desc.Digest]); got != string(data) {
+		t.Errorf("registry holds %q, want %q", got, data)
+	}
+	if reg.started != 1 {
+		t.Errorf("%d upload sessions opened, want 1", reg.started)
+	}
+}
@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/deis/duffle/pkg/registry"
+)
+
+// DirectProxy is the proxy setting that connects to a repository without any proxy
+const DirectProxy = "direct"
+
+// Client returns an HTTP client that authenticates requests to the hosts of the repository
+// and its mirrors with the repository's credentials, using its TLS settings. Requests to
+// other hosts are sent anonymously.
+//
+// Requests failing with a network error or a temporary server error are retried with
+// exponential backoff, and each attempt is bounded by the repository's timeout.
+func (r *Repository) Client() (*http.Client, error) {
+	user, pass, token, err := r.credentials()
+	if err != nil {
+		return nil, err
+	}
+	base, err := r.retryingTransport()
+	if err != nil {
+		return nil, err
+	}
+	if user == "" && token == "" {
+		return &http.Client{Transport: base}, nil
+	}
+	hosts := map[string]bool{}
+	for _, base := range r.bases() {
+		u, err := url.Parse(base)
+		if err != nil {
+			return nil, err
+		}
+		hosts[u.Host] = true
+	}
+	return &http.Client{
+		Transport: &authTransport{
+			hosts:    hosts,
+			username: user,
+			password: pass,
+			token:    token,
+			base:     base,
+		},
+	}, nil
+}
+
+// transport returns the round tripper applying the repository's TLS and proxy settings.
+//
+// Unless the repository overrides it, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY environment variables.
+func (r *Repository) transport() (http.RoundTripper, error) {
+	if r.CAFile == "" && r.CertFile == "" && r.KeyFile == "" && !r.InsecureSkipTLSVerify && r.Proxy == "" {
+		return http.DefaultTransport, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: r.InsecureSkipTLSVerify}
+	if r.CAFile != "" {
+		pem, err := ioutil.ReadFile(r.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", r.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if r.CertFile != "" || r.KeyFile != "" {
+		if r.CertFile == "" || r.KeyFile == "" {
+			return nil, fmt.Errorf("a client certificate requires both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = config
+	switch r.Proxy {
+	case "":
+	case DirectProxy:
+		t.Proxy = nil
+	default:
+		u, err := url.Parse(r.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", r.Proxy, err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	return t, nil
+}
+
+// proxyEnv returns environment variables applying the repository's proxy override to
+// programs run on its behalf, such as git
+func (r *Repository) proxyEnv() []string {
+	switch r.Proxy {
+	case "":
+		return nil
+	case DirectProxy:
+		return []string{"NO_PROXY=*", "no_proxy=*"}
+	default:
+		var env []string
+		for _, v := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			env = append(env, v+"="+r.Proxy)
+		}
+		return append(env, "NO_PROXY=", "no_proxy=")
+	}
+}
+
+// credentials returns the repository's basic auth credentials or bearer token
+func (r *Repository) credentials() (user, pass, token string, err error) {
+	if r.CredentialHelper == "" {
+		return r.Username, r.Password, r.Token, nil
+	}
+	user, secret, err := registry.HelperGet(r.CredentialHelper, r.URL)
+	if err != nil {
+		return "", "", "", err
+	}
+	if user == registry.IdentityTokenUsername {
+		return "", "", secret, nil
+	}
+	return user, secret, "", nil
+}
+
+// authTransport adds credentials to requests for a set of hosts
+type authTransport struct {
+	hosts    map[string]bool
+	username string
+	password string
+	token    string
+	base     http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.hosts[req.URL.Host] {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	} else {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,268 @@
+package repo
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+	"github.com/deis/duffle/pkg/loader"
+)
+
+// IndexPath is the location of the index relative to a repository's URL
+const IndexPath = "index.json"
+
+// YAMLIndexPath is the location of an index maintained in YAML, which is used when a
+// repository has no index.json
+const YAMLIndexPath = "index.yaml"
+
+// FetchIndex downloads the repository's index. The repository's own client is used when client is nil.
+//
+// The repository URL is tried first, then each mirror in turn until one serves a valid index.
+// Copies of the index kept in cache are revalidated with the server rather than downloaded again.
+func (r *Repository) FetchIndex(client *http.Client, cache *loader.Cache) (*IndexFile, error) {
+	if client == nil {
+		var err error
+		if client, err = r.Client(); err != nil {
+			return nil, err
+		}
+	}
+	var errs []string
+	for _, base := range r.bases() {
+		i, err := r.fetchIndexFrom(client, cache, base)
+		if err == nil {
+			return i, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("cannot fetch index of repository %s: %s", r.Name, strings.Join(errs, "; "))
+}
+
+// fetchIndexFrom downloads and verifies the index served at base, falling back to
+// index.yaml when base serves no index.json
+func (r *Repository) fetchIndexFrom(client *http.Client, cache *loader.Cache, base string) (*IndexFile, error) {
+	var (
+		path = IndexPath
+		data []byte
+	)
+	u, err := resolve(base, path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = cache.Fetch(client, u)
+	if err != nil {
+		yu, yerr := resolve(base, YAMLIndexPath)
+		if yerr != nil {
+			return nil, err
+		}
+		var ydata []byte
+		if ydata, yerr = cache.Fetch(client, yu); yerr != nil {
+			return nil, err
+		}
+		path, data = YAMLIndexPath, ydata
+	}
+	if r.Verify {
+		su, err := resolve(base, signaturePath(path))
+		if err != nil {
+			return nil, err
+		}
+		sig, err := cache.Fetch(client, su)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch index signature: %v", err)
+		}
+		if err := r.verifyIndex(data, sig); err != nil {
+			return nil, err
+		}
+	}
+	return LoadIndex(data)
+}
+
+// BundleURLs returns the absolute URLs of a bundle version served by the repository, from
+// the repository URL first, then from each mirror.
+//
+// When the index records the bundle's digest, it is appended as a '#sha256=' fragment so
+// that the downloaded document is verified. Bundles of git-backed repositories are
+// located by their path in the local checkout.
+func (r *Repository) BundleURLs(v *BundleVersion) ([]string, error) {
+	if len(v.URLs) == 0 {
+		return nil, fmt.Errorf("bundle %s %s has no download URL", v.Name, v.Version)
+	}
+	ref := v.URLs[0]
+	if filepath.IsAbs(ref) {
+		return []string{ref}, nil
+	}
+	var urls []string
+	for _, base := range r.bases() {
+		u := ref
+		// absolute URLs into the repository are served by its mirrors at the same path
+		if prefix := strings.TrimSuffix(r.URL, "/") + "/"; strings.HasPrefix(ref, prefix) {
+			u = strings.TrimPrefix(ref, prefix)
+		}
+		u, err := resolve(base, u)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(v.Digest, digest.Algorithm+":") {
+			u += "#" + digest.Algorithm + "=" + digest.Hex(v.Digest)
+		}
+		if len(urls) == 0 || urls[len(urls)-1] != u {
+			urls = append(urls, u)
+		}
+	}
+	return urls, nil
+}
+
+// httpCache returns the cache of documents fetched from repositories within dir
+func (r *Repository) httpCache(dir string) *loader.Cache {
+	return loader.NewCache(filepath.Join(dir, "http"))
+}
+
+// bases returns the repository URL followed by its mirrors
+func (r *Repository) bases() []string {
+	return append([]string{r.URL}, r.Mirrors...)
+}
+
+// resolve returns ref resolved against the base URL of a repository
+func resolve(base, ref string) (string, error) {
+	b, err := url.Parse(strings.TrimSuffix(base, "/") + "/")
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(u).String(), nil
+}
+
+// CachePath returns where the repository's index is cached within dir
+func (r *Repository) CachePath(dir string) string {
+	return filepath.Join(dir, r.Name+"-index.json")
+}
+
+// Update fetches the repository's index and caches it in dir.
+//
+// Git-backed repositories are checked out within dir as well.
+func (r *Repository) Update(client *http.Client, dir string) (*IndexFile, error) {
+	var (
+		i   *IndexFile
+		err error
+	)
+	switch {
+	case IsGitURL(r.URL):
+		i, err = r.updateGit(dir)
+	case IsOCIURL(r.URL):
+		i, err = r.updateOCI()
+	default:
+		i, err = r.FetchIndex(client, r.httpCache(dir))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return i, i.WriteFile(r.CachePath(dir))
+}
+
+// Ping checks that the repository can be reached, without updating its cached index: the
+// index of HTTP repositories is fetched and verified, the ref of git repositories is
+// looked up, and registries are asked to accept the repository's credentials.
+func (r *Repository) Ping() error {
+	switch {
+	case IsGitURL(r.URL):
+		src, err := parseGitURL(r.URL)
+		if err != nil {
+			return err
+		}
+		ref := src.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		return git("", r.proxyEnv(), "ls-remote", "--exit-code", "--", src.Remote, ref)
+	case IsOCIURL(r.URL):
+		ns, err := r.namespace()
+		if err != nil {
+			return err
+		}
+		c, err := r.RegistryClient()
+		if err != nil {
+			return err
+		}
+		return c.Ping(ns.Registry)
+	default:
+		_, err := r.FetchIndex(nil, nil)
+		return err
+	}
+}
+
+// Find returns the newest version of the named bundle satisfying the version constraint,
+// looking it up in the index cached in dir. The version may also be a digest, as
+// sha256:<hex>, pinning the exact bundle document.
+//
+// Registry-backed repositories are queried for the bundle's tags instead, so that the
+// bundles of registries without a catalog can be found too. Their digests pin the bundle's
+// artifact manifest, and the version is only known once the bundle is loaded.
+func (r *Repository) Find(dir, name, version string) (*BundleVersion, error) {
+	pinned := strings.HasPrefix(version, digest.Algorithm+":")
+	if IsOCIURL(r.URL) && pinned {
+		ns, err := r.namespace()
+		if err != nil {
+			return nil, err
+		}
+		ref := ns.WithTag("").WithDigest(version)
+		ref.Repository = ns.Repository + "/" + name
+		return &BundleVersion{Name: name, URLs: []string{OCIPrefix + ref.String()}}, nil
+	}
+	if IsOCIURL(r.URL) {
+		c, err := r.RegistryClient()
+		if err != nil {
+			return nil, err
+		}
+		versions, err := r.versionsOCI(c, name)
+		if err != nil {
+			return nil, err
+		}
+		i := NewIndexFile()
+		i.Entries[name] = versions
+		return i.Get(name, version)
+	}
+	i, err := r.Index(nil, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.FetchShard(i, name, dir); err != nil {
+		return nil, err
+	}
+	if pinned {
+		return i.GetDigest(name, version)
+	}
+	return i.Get(name, version)
+}
+
+// Index returns the repository's index cached in dir, fetching and caching it if it is not cached yet
+func (r *Repository) Index(client *http.Client, dir string) (*IndexFile, error) {
+	i, err := LoadIndexFile(r.CachePath(dir))
+	if os.IsNotExist(err) {
+		return r.Update(client, dir)
+	}
+	return i, err
+}
+
+// IndexDigest returns the digest of the repository's index cached in dir, identifying the
+// index that bundles were resolved with. Registry-backed repositories have no index, so
+// their digest is empty.
+func (r *Repository) IndexDigest(dir string) (string, error) {
+	if IsOCIURL(r.URL) {
+		return "", nil
+	}
+	return digest.OfFile(r.CachePath(dir))
+}
+
+// ClearCache removes the repository's cached index and checkout from dir
+func (r *Repository) ClearCache(dir string) error {
+	if err := os.Remove(r.CachePath(dir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.RemoveAll(r.checkoutDir(dir))
+}
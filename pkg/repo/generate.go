@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/crypto/digest"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// bundleFiles are the file names recognized as bundle documents when scanning a directory
+var bundleFiles = map[string]bool{
+	"bundle.json": true,
+	"bundle.yaml": true,
+	"bundle.yml":  true,
+	"bundle.cnab": true,
+}
+
+// GenerateFromDirectory builds an index of the bundle documents found anywhere under dir.
+//
+// Bundle URLs in the index are relative to dir, or prefixed with baseURL when it is set.
+func GenerateFromDirectory(dir, baseURL string) (*IndexFile, error) {
+	return MergeDirectory(nil, dir, baseURL)
+}
+
+// MergeDirectory updates an existing index of dir with the bundle documents found under it.
+//
+// Bundle files not modified since the existing index was generated keep their entries
+// without being read again. Entries keep the creation time they were first indexed with,
+// even when their file changed since, and stay deprecated if they were. Entries for files that were removed from dir are
+// dropped, while entries pointing outside dir are kept as they are.
+func MergeDirectory(existing *IndexFile, dir, baseURL string) (*IndexFile, error) {
+	i := NewIndexFile()
+	previous := map[string]*BundleVersion{}
+	if existing != nil {
+		i.PublicKeys = existing.PublicKeys
+		for _, versions := range existing.Entries {
+			for _, v := range versions {
+				if len(v.URLs) > 0 {
+					previous[v.URLs[0]] = v
+				}
+			}
+		}
+	}
+	seen := map[string]bool{}
+
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if fi.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !bundleFiles[fi.Name()] {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		u := bundleURL(baseURL, filepath.ToSlash(rel))
+		seen[u] = true
+
+		prev := previous[u]
+		if prev != nil && !fi.ModTime().After(existing.Generated) {
+			i.Entries[prev.Name] = append(i.Entries[prev.Name], prev)
+			return nil
+		}
+		b, err := readBundle(path)
+		if err != nil {
+			return err
+		}
+		d, err := digest.OfFile(path)
+		if err != nil {
+			return err
+		}
+		v := &BundleVersion{
+			Name:        b.Name,
+			Version:     b.Version,
+			Description: b.Description,
+			URLs:        []string{u},
+			Digest:      d,
+			Created:     fi.ModTime().UTC().Truncate(time.Second),
+		}
+		if prev != nil {
+			v.Created, v.Deprecated = prev.Created, prev.Deprecated
+		}
+		i.Entries[b.Name] = append(i.Entries[b.Name], v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for u, v := range previous {
+		if seen[u] || inDirectory(u, baseURL) {
+			continue
+		}
+		i.Entries[v.Name] = append(i.Entries[v.Name], v)
+	}
+	i.SortEntries()
+	return i, nil
+}
+
+// readBundle parses the bundle document at path. Signed documents are not verified: their
+// signatures are checked by the clients that install them.
+func readBundle(path string) (*bundle.Bundle, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if signature.IsClearsigned(data) {
+		if data, err = signature.Plaintext(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	b, err := loader.LoadReader(bytes.NewReader(data), loader.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return b, nil
+}
+
+// bundleURL returns the index URL of the bundle file at rel
+func bundleURL(baseURL, rel string) string {
+	if baseURL == "" {
+		return rel
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + rel
+}
+
+// inDirectory reports whether an index URL points at a file within the indexed directory
+func inDirectory(u, baseURL string) bool {
+	if baseURL != "" {
+		return strings.HasPrefix(u, strings.TrimSuffix(baseURL, "/")+"/")
+	}
+	return !strings.Contains(u, "://") && !filepath.IsAbs(u)
+}
@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitPrefix marks repository URLs served from a git repository
+const GitPrefix = "git::"
+
+// GitCommand is the git executable invoked to fetch git-backed repositories
+var GitCommand = "git"
+
+// gitSource is a parsed git repository URL
+type gitSource struct {
+	// Remote is the URL git clones from
+	Remote string
+	// Path is the directory within the git repository holding the bundles
+	Path string
+	// Ref is the branch, tag or commit to check out; the remote's HEAD when empty
+	Ref string
+}
+
+// IsGitURL reports whether u refers to a git-backed repository
+func IsGitURL(u string) bool {
+	return strings.HasPrefix(u, GitPrefix)
+}
+
+// parseGitURL parses URLs of the form git::https://host/org/repo.git//path?ref=v1
+func parseGitURL(raw string) (*gitSource, error) {
+	u, err := url.Parse(strings.TrimPrefix(raw, GitPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid git repository URL %q: %v", raw, err)
+	}
+	if u.Scheme == "" || (u.Host == "" && u.Scheme != "file") {
+		return nil, fmt.Errorf("invalid git repository URL %q: expected git::<scheme>://<host>/<path>", raw)
+	}
+	s := &gitSource{Ref: u.Query().Get("ref")}
+	// git would take such a ref for an option, such as --upload-pack running a command
+	if strings.HasPrefix(s.Ref, "-") {
+		return nil, fmt.Errorf("invalid git repository URL %q: ref may not start with '-'", raw)
+	}
+	u.RawQuery = ""
+	if i := strings.Index(u.Path, "//"); i != -1 {
+		s.Path = strings.Trim(u.Path[i+2:], "/")
+		u.Path = u.Path[:i]
+	}
+	if strings.Contains(s.Path, "..") {
+		return nil, fmt.Errorf("invalid git repository URL %q: path may not leave the repository", raw)
+	}
+	u.RawPath = ""
+	s.Remote = u.String()
+	return s, nil
+}
+
+// checkoutDir returns where the repository is checked out within the cache dir
+func (r *Repository) checkoutDir(dir string) string {
+	return filepath.Join(dir, "git", r.Name)
+}
+
+// updateGit fetches the configured ref of a git-backed repository into the cache dir and
+// indexes its bundles. The checkout's index.json is used when present; otherwise the
+// checkout is scanned for bundle documents, unless the index must be verified.
+//
+// Bundle URLs in the returned index are absolute paths within the checkout.
+func (r *Repository) updateGit(dir string) (*IndexFile, error) {
+	src, err := parseGitURL(r.URL)
+	if err != nil {
+		return nil, err
+	}
+	co := r.checkoutDir(dir)
+	if _, err := os.Stat(filepath.Join(co, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(co, 0755); err != nil {
+			return nil, err
+		}
+		if err := git(co, r.proxyEnv(), "init", "--quiet"); err != nil {
+			return nil, err
+		}
+		if err := git(co, r.proxyEnv(), "remote", "add", "--", "origin", src.Remote); err != nil {
+			return nil, err
+		}
+	}
+	ref := src.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := git(co, r.proxyEnv(), "fetch", "--quiet", "--depth", "1", "--", "origin", ref); err != nil {
+		return nil, err
+	}
+	if err := git(co, r.proxyEnv(), "checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return nil, err
+	}
+
+	root := filepath.Join(co, filepath.FromSlash(src.Path))
+	var i *IndexFile
+	path := FindIndexFile(root)
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err) && r.Verify:
+		return nil, fmt.Errorf("repository %s has no signed index", r.Name)
+	case os.IsNotExist(err):
+		i, err = GenerateFromDirectory(root, "")
+	case err == nil && r.Verify:
+		sig, err := ioutil.ReadFile(signaturePath(path))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read index signature of repository %s: %v", r.Name, err)
+		}
+		if err := r.verifyIndex(data, sig); err != nil {
+			return nil, err
+		}
+		fallthrough
+	case err == nil:
+		if i, err = LoadIndex(data); err == nil {
+			err = loadLocalShards(i, root)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, versions := range i.Entries {
+		for _, v := range versions {
+			for n, u := range v.URLs {
+				if !strings.Contains(u, "://") && !filepath.IsAbs(u) {
+					v.URLs[n] = filepath.Join(root, filepath.FromSlash(u))
+				}
+			}
+		}
+	}
+	return i, nil
+}
+
+// git runs a git command in dir, with env added to the environment
+func git(dir string, env []string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(GitCommand, args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return nil
+}
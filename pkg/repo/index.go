@@ -0,0 +1,242 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/ghodss/yaml"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// APIVersion is the version of the index format written by this package
+const APIVersion = "v1"
+
+// IndexFile describes the bundles a repository serves
+type IndexFile struct {
+	APIVersion string `json:"apiVersion"`
+	// Generated is when the index was written
+	Generated time.Time `json:"generated"`
+	// Entries maps bundle names to their available versions
+	Entries map[string]BundleVersions `json:"entries"`
+	// Shards maps the names of bundles whose versions are listed in a separate document
+	// to the location of that document; such bundles have no entry in Entries
+	Shards map[string]Shard `json:"shards,omitempty"`
+	// PublicKeys holds armored public keys of the repository's signers
+	PublicKeys []string `json:"publicKeys,omitempty"`
+}
+
+// BundleVersion describes one version of a bundle in a repository
+type BundleVersion struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	// URLs locate the bundle document, absolute or relative to the repository URL
+	URLs []string `json:"urls"`
+	// Digest is the digest of the bundle document, as sha256:<hex>
+	Digest  string    `json:"digest,omitempty"`
+	Created time.Time `json:"created"`
+	// Deprecated marks versions that should no longer be installed. They are hidden from
+	// searches by default but can still be installed.
+	Deprecated bool `json:"deprecated,omitempty"`
+	// Origin records where a bundle of the local store was pulled or imported from. It is
+	// not set in repository indexes.
+	Origin *Origin `json:"origin,omitempty"`
+}
+
+// Origin is the source a bundle of the local store was stored from, so that the trust
+// policy for that source still applies once the bundle is stored and signed locally
+type Origin struct {
+	// Repository names the repository the bundle was resolved through, if any
+	Repository string `json:"repository,omitempty"`
+	// URL locates the bundle at its source: a registry reference, URL or file path
+	URL string `json:"url"`
+	// Signers are the fingerprints of the keys whose signatures were verified when the
+	// bundle was fetched
+	Signers []string `json:"signers,omitempty"`
+}
+
+// BundleVersions is a list of versions of a bundle, sorted from newest to oldest
+type BundleVersions []*BundleVersion
+
+func (v BundleVersions) Len() int      { return len(v) }
+func (v BundleVersions) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v BundleVersions) Less(i, j int) bool {
+	a, err := semver.NewVersion(v[i].Version)
+	if err != nil {
+		return false
+	}
+	b, err := semver.NewVersion(v[j].Version)
+	if err != nil {
+		return true
+	}
+	return a.GreaterThan(b)
+}
+
+// NewIndexFile returns an empty index
+func NewIndexFile() *IndexFile {
+	return &IndexFile{
+		APIVersion: APIVersion,
+		Generated:  time.Now().UTC(),
+		Entries:    map[string]BundleVersions{},
+	}
+}
+
+// LoadIndex parses an index document, written in JSON or YAML
+func LoadIndex(data []byte) (*IndexFile, error) {
+	unmarshal := yaml.Unmarshal
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		unmarshal = json.Unmarshal
+	}
+	i := &IndexFile{}
+	if err := unmarshal(data, i); err != nil {
+		return nil, fmt.Errorf("cannot parse repository index: %v", err)
+	}
+	if i.APIVersion == "" {
+		return nil, fmt.Errorf("repository index has no apiVersion")
+	}
+	if i.Entries == nil {
+		i.Entries = map[string]BundleVersions{}
+	}
+	i.SortEntries()
+	return i, nil
+}
+
+// SortEntries sorts the versions of every bundle from newest to oldest
+func (i *IndexFile) SortEntries() {
+	for _, versions := range i.Entries {
+		sort.Stable(versions)
+	}
+}
+
+// Get returns the newest version of the named bundle satisfying the version constraint.
+//
+// An empty constraint matches any version.
+func (i *IndexFile) Get(name, version string) (*BundleVersion, error) {
+	versions, err := i.GetAll(name, version)
+	if err != nil {
+		return nil, err
+	}
+	return versions[0], nil
+}
+
+// GetAll returns every version of the named bundle satisfying the version constraint,
+// newest first. At least one version is returned unless there is an error.
+//
+// An empty constraint matches any version.
+func (i *IndexFile) GetAll(name, version string) (BundleVersions, error) {
+	versions, ok := i.Entries[name]
+	if !ok || len(versions) == 0 {
+		return nil, fmt.Errorf("no bundle named %q in the repository", name)
+	}
+	if version == "" {
+		return versions, nil
+	}
+	c, err := semver.NewConstraint(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %v", version, err)
+	}
+	var matches BundleVersions
+	for _, v := range versions {
+		sv, err := semver.NewVersion(v.Version)
+		if err != nil {
+			continue
+		}
+		if c.Check(sv) {
+			matches = append(matches, v)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no version of %s satisfies %s", name, version)
+	}
+	return matches, nil
+}
+
+// GetDigest returns the version of the named bundle whose document has the digest d
+func (i *IndexFile) GetDigest(name, d string) (*BundleVersion, error) {
+	versions, ok := i.Entries[name]
+	if !ok || len(versions) == 0 {
+		return nil, fmt.Errorf("no bundle named %q in the repository", name)
+	}
+	for _, v := range versions {
+		if v.Digest == d {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("no version of %s has digest %s", name, d)
+}
+
+// LoadIndexFile reads an index from a local file
+func LoadIndexFile(path string) (*IndexFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadIndex(data)
+}
+
+// WriteFile saves the index to path, as YAML if path has a .yaml or .yml extension and as
+// JSON otherwise. The file is replaced atomically, so that readers never see a partially
+// written index.
+func (i *IndexFile) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var (
+		data []byte
+		err  error
+	)
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(i)
+	default:
+		data, err = json.MarshalIndent(i, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// FindIndexFile returns the path of the index of the repository directory dir: its
+// index.json, or its index.yaml when it has no index.json
+func FindIndexFile(dir string) string {
+	path := filepath.Join(dir, IndexPath)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(filepath.Join(dir, YAMLIndexPath)); err == nil {
+			return filepath.Join(dir, YAMLIndexPath)
+		}
+	}
+	return path
+}
+
+// WriteDir saves the index in the repository directory dir as index.yaml when yamlFormat is
+// set, or as index.json otherwise, and returns the path written. The index is signed by
+// signer unless it is nil.
+//
+// The index in the other format and stale signatures are removed, since they would
+// otherwise shadow or contradict the new index.
+func (i *IndexFile) WriteDir(dir string, yamlFormat bool, signer *signature.Signer) (string, error) {
+	path, other := filepath.Join(dir, IndexPath), filepath.Join(dir, YAMLIndexPath)
+	if yamlFormat {
+		path, other = other, path
+	}
+	for _, stale := range []string{other, signaturePath(other), signaturePath(path)} {
+		if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	if signer != nil {
+		return path, i.WriteSignedFile(path, signer)
+	}
+	return path, i.WriteFile(path)
+}
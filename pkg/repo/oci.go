@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/deis/duffle/pkg/registry"
+)
+
+// OCIPrefix marks repository URLs naming a namespace in an OCI registry
+const OCIPrefix = "oci://"
+
+// IsOCIURL reports whether u refers to a registry-backed repository
+func IsOCIURL(u string) bool {
+	return strings.HasPrefix(u, OCIPrefix)
+}
+
+// namespace parses the registry and namespace of a registry-backed repository
+func (r *Repository) namespace() (registry.Reference, error) {
+	ns := strings.Trim(strings.TrimPrefix(r.URL, OCIPrefix), "/")
+	ref, err := registry.ParseReference(ns)
+	if err != nil || !strings.Contains(ns, "/") || ref.Tag != "latest" || ref.Digest != "" {
+		return registry.Reference{}, fmt.Errorf("invalid repository URL %q: expected oci://<registry>/<namespace>", r.URL)
+	}
+	ref.Tag = ""
+	return ref, nil
+}
+
+// RegistryClient returns a registry client using the repository's credentials and TLS
+// settings, retrying failed requests like Client. Repositories configured without
+// credentials use those of the docker CLI, so that registries docker is logged in to,
+// or has a credential helper for, need no further setup.
+func (r *Repository) RegistryClient() (*registry.Client, error) {
+	user, pass, _, err := r.credentials()
+	if err != nil {
+		return nil, err
+	}
+	t, err := r.retryingTransport()
+	if err != nil {
+		return nil, err
+	}
+	creds := registry.DockerCredentials()
+	if user != "" {
+		creds = func(string) (string, string, error) { return user, pass, nil }
+	}
+	c := registry.NewClient(creds)
+	c.HTTP = &http.Client{Transport: t}
+	return c, nil
+}
+
+// versionsOCI lists the bundle versions of name in a registry-backed repository: every
+// semver tag of the registry repository <namespace>/<name> is a version.
+func (r *Repository) versionsOCI(c *registry.Client, name string) (BundleVersions, error) {
+	ns, err := r.namespace()
+	if err != nil {
+		return nil, err
+	}
+	ref := ns
+	ref.Repository = ns.Repository + "/" + name
+	tags, err := c.ListTags(ref)
+	if err != nil {
+		return nil, err
+	}
+	var versions BundleVersions
+	for _, tag := range tags {
+		if _, err := semver.NewVersion(tag); err != nil {
+			continue
+		}
+		versions = append(versions, &BundleVersion{
+			Name:    name,
+			Version: tag,
+			URLs:    []string{OCIPrefix + ref.WithTag(tag).String()},
+		})
+	}
+	sort.Stable(versions)
+	return versions, nil
+}
+
+// updateOCI indexes a registry-backed repository from the registry catalog. Registries that
+// do not expose their catalog yield an empty index; their bundles are still found by name.
+func (r *Repository) updateOCI() (*IndexFile, error) {
+	ns, err := r.namespace()
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.RegistryClient()
+	if err != nil {
+		return nil, err
+	}
+	i := NewIndexFile()
+	repos, err := c.Catalog(ns)
+	if err != nil {
+		return i, nil
+	}
+	for _, repo := range repos {
+		if !strings.HasPrefix(repo, ns.Repository+"/") {
+			continue
+		}
+		name := strings.TrimPrefix(repo, ns.Repository+"/")
+		versions, err := r.versionsOCI(c, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) > 0 {
+			i.Entries[name] = versions
+		}
+	}
+	return i, nil
+}
@@ -0,0 +1,170 @@
+// Package repo reads the repositories configured in duffle home and the indexes they serve.
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+)
+
+// validName matches the names repositories may be registered under
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Repository is a named remote bundle repository
+type Repository struct {
+	// Name is the name the repository is referred to by
+	Name string `json:"name"`
+	// URL is the base URL of the repository, where index.json is served, or a git URL
+	// of the form git::https://host/org/repo.git//path?ref=v1, or a registry namespace
+	// of the form oci://registry/namespace
+	URL string `json:"url"`
+	// Mirrors are alternative base URLs serving the same content, tried in order when
+	// URL cannot be reached
+	Mirrors []string `json:"mirrors,omitempty"`
+	// Username and Password authenticate requests with HTTP basic auth
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Token authenticates requests as a bearer token
+	Token string `json:"token,omitempty"`
+	// CredentialHelper names a docker credential helper program supplying the credentials
+	// instead, so that they need not be stored in duffle home
+	CredentialHelper string `json:"credentialHelper,omitempty"`
+	// CAFile is a PEM bundle of certificate authorities trusted in addition to the system's
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile hold a PEM client certificate and key presented to the server
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// InsecureSkipTLSVerify disables verification of the server's certificate
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+	// Proxy is the URL of the proxy used to reach the repository, overriding the proxy
+	// environment variables; DirectProxy connects without a proxy
+	Proxy string `json:"proxy,omitempty"`
+	// Retries is how many times failed requests are retried; DefaultRetries when unset
+	Retries *int `json:"retries,omitempty"`
+	// Timeout bounds each attempt of a request, as a duration such as 1m; DefaultTimeout
+	// when unset
+	Timeout string `json:"timeout,omitempty"`
+	// Verify requires the index to carry a valid signature from a trusted key
+	Verify bool `json:"verify,omitempty"`
+	// Keyring holds the keys trusted to sign the index; the public keyring in duffle
+	// home is used when empty
+	Keyring string `json:"keyring,omitempty"`
+}
+
+// RepositoryFile lists the repositories configured in duffle home
+type RepositoryFile struct {
+	Repositories []*Repository `json:"repositories"`
+}
+
+// LoadRepositoryFile reads the repositories file at path. A missing file holds no repositories.
+func LoadRepositoryFile(path string) (*RepositoryFile, error) {
+	f := &RepositoryFile{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// WriteFile saves the repositories file to path. The file is only readable by its owner,
+// since it may hold credentials.
+func (f *RepositoryFile) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// Get returns the named repository, or nil if it is not configured
+func (f *RepositoryFile) Get(name string) *Repository {
+	for _, r := range f.Repositories {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Add registers a repository. Names must be unique.
+func (f *RepositoryFile) Add(r *Repository) error {
+	if !validName.MatchString(r.Name) {
+		return fmt.Errorf("invalid repository name %q", r.Name)
+	}
+	switch {
+	case IsGitURL(r.URL):
+		if _, err := parseGitURL(r.URL); err != nil {
+			return err
+		}
+	case IsOCIURL(r.URL):
+		if _, err := r.namespace(); err != nil {
+			return err
+		}
+		if r.Verify {
+			return fmt.Errorf("registry-backed repositories have no index to verify")
+		}
+	default:
+		for _, u := range r.bases() {
+			if !isHTTPURL(u) {
+				return fmt.Errorf("invalid repository URL %q: expected an http(s), git:: or oci:// URL", u)
+			}
+		}
+	}
+	if r.Proxy != "" && r.Proxy != DirectProxy {
+		u, err := url.Parse(r.Proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+			return fmt.Errorf("invalid proxy %q: expected an http(s) or socks5 URL, or %q", r.Proxy, DirectProxy)
+		}
+	}
+	if r.Retries != nil && *r.Retries < 0 {
+		return fmt.Errorf("invalid retry count %d", *r.Retries)
+	}
+	if r.Timeout != "" {
+		if d, err := time.ParseDuration(r.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q: expected a positive duration such as 30s", r.Timeout)
+		}
+	}
+	if len(r.Mirrors) > 0 && (IsGitURL(r.URL) || IsOCIURL(r.URL)) {
+		return fmt.Errorf("mirrors are only supported for http(s) repositories")
+	}
+	if f.Get(r.Name) != nil {
+		return fmt.Errorf("repository %q already exists", r.Name)
+	}
+	f.Repositories = append(f.Repositories, r)
+	return nil
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Remove unregisters the named repository, reporting whether it was configured
+func (f *RepositoryFile) Remove(name string) bool {
+	for i, r := range f.Repositories {
+		if r.Name == name {
+			f.Repositories = append(f.Repositories[:i], f.Repositories[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultRetries is how many times a failed request to a repository is retried, unless
+	// the repository configures it
+	DefaultRetries = 3
+	// DefaultTimeout bounds each attempt of a request to a repository, including reading
+	// the response, unless the repository configures it
+	DefaultTimeout = 30 * time.Second
+	// retryBackoff is the delay before the first retry; it doubles with each further retry
+	retryBackoff = 500 * time.Millisecond
+	// maxRetryBackoff caps the delay between retries
+	maxRetryBackoff = 10 * time.Second
+)
+
+// retryingTransport returns the repository's transport, retrying failed requests and
+// bounding each attempt as the repository configures
+func (r *Repository) retryingTransport() (http.RoundTripper, error) {
+	base, err := r.transport()
+	if err != nil {
+		return nil, err
+	}
+	t := &retryTransport{retries: DefaultRetries, timeout: DefaultTimeout, base: base}
+	if r.Retries != nil {
+		t.retries = *r.Retries
+	}
+	if r.Timeout != "" {
+		if t.timeout, err = time.ParseDuration(r.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %v", r.Timeout, err)
+		}
+	}
+	return t, nil
+}
+
+// retryTransport retries requests that fail with a network error or a temporary server
+// error, waiting an exponentially growing, jittered delay between attempts. Each attempt
+// is bounded by timeout.
+type retryTransport struct {
+	retries int
+	timeout time.Duration
+	base    http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req)
+		if attempt >= t.retries || !retryable(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		// wait between half and all of the backoff, so that clients failing together
+		// do not retry together
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// attempt sends req once, bounded by the transport's timeout
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retryable reports whether the outcome of req is worth retrying. Requests with a body
+// are only retried if the body can be sent again.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// cancelBody releases the context of a request once its response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
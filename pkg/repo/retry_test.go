@@ -0,0 +1,206 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is a transport answering requests with a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// replies returns a transport answering each attempt with the next of statuses, where 0
+// stands for a network error, and recording the body of each attempt
+func replies(statuses []int, bodies *[]string) http.RoundTripper {
+	var mu sync.Mutex
+	attempt := 0
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if req.Body != nil {
+			b, _ := ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			*bodies = append(*bodies, string(b))
+		} else {
+			*bodies = append(*bodies, "")
+		}
+		status := statuses[len(statuses)-1]
+		if attempt < len(statuses) {
+			status = statuses[attempt]
+		}
+		attempt++
+		if status == 0 {
+			return nil, errors.New("connection reset")
+		}
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  int
+		statuses []int
+		attempts int
+		status   int
+	}{
+		{"success", 3, []int{200}, 1, 200},
+		{"temporary error", 3, []int{503, 200}, 2, 200},
+		{"network error", 3, []int{0, 200}, 2, 200},
+		{"rate limited", 3, []int{429, 502, 200}, 3, 200},
+		{"retries exhausted", 1, []int{504}, 2, 504},
+		{"network error exhausted", 1, []int{0}, 2, 0},
+		{"no retries", 0, []int{503}, 1, 503},
+		{"client error", 3, []int{404}, 1, 404},
+		{"server error", 3, []int{500}, 1, 500},
+	}
+	for _, tt := range tests {
+		var bodies []string
+		rt := &retryTransport{retries: tt.retries, base: replies(tt.statuses, &bodies)}
+		req, _ := http.NewRequest("GET", "http://example.com/index.json", nil)
+		resp, err := rt.RoundTrip(req)
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+			resp.Body.Close()
+		}
+		if status != tt.status {
+			t.Errorf("%s: got status %d (error %v), want %d", tt.name, status, err, tt.status)
+		}
+		if len(bodies) != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, len(bodies), tt.attempts)
+		}
+	}
+}
+
+func TestRetryTransportBodies(t *testing.T) {
+	// bodies that can be read again are sent in full with every attempt
+	var bodies []string
+	rt := &retryTransport{retries: 2, base: replies([]int{503, 503, 200}, &bodies)}
+	req, _ := http.NewRequest("PUT", "http://example.com/bundle.json", strings.NewReader("bundle"))
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := strings.Join(bodies, ","); got != "bundle,bundle,bundle" {
+		t.Errorf("bodies sent: %s, want the whole body 3 times", got)
+	}
+
+	// others are only sent once
+	bodies = nil
+	rt = &retryTransport{retries: 2, base: replies([]int{503, 200}, &bodies)}
+	req, _ = http.NewRequest("PUT", "http://example.com/bundle.json", ioutil.NopCloser(strings.NewReader("bundle")))
+	resp, err = rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 503 || len(bodies) != 1 {
+		t.Errorf("non-replayable body: got status %d after %d attempts, want 503 after 1", resp.StatusCode, len(bodies))
+	}
+}
+
+func TestRetryTransportCanceled(t *testing.T) {
+	var bodies []string
+	rt := &retryTransport{retries: 3, base: replies([]int{503}, &bodies)}
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", "http://example.com/index.json", nil)
+	req = req.WithContext(ctx)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	if _, err := rt.RoundTrip(req); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > retryBackoff {
+		t.Errorf("canceled request returned after %v, not when canceled", d)
+	}
+	if len(bodies) != 1 {
+		t.Errorf("%d attempts, want 1", len(bodies))
+	}
+}
+
+func TestRetryTransportTimeout(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		first := attempts == 1
+		mu.Unlock()
+		if first {
+			// the first attempt hangs until it times out
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("index"))
+	}))
+	defer s.Close()
+
+	rt := &retryTransport{retries: 1, timeout: 100 * time.Millisecond, base: http.DefaultTransport}
+	resp, err := (&http.Client{Transport: rt}).Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// the timeout of the attempt applies to reading its response too, and is only released
+	// once the body is closed
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || string(body) != "index" {
+		t.Errorf("got body %q, %v; want %q", body, err, "index")
+	}
+	mu.Lock()
+	if attempts != 2 {
+		t.Errorf("%d attempts, want 2", attempts)
+	}
+	attempts = 0
+	mu.Unlock()
+
+	rt = &retryTransport{retries: 0, timeout: 100 * time.Millisecond, base: http.DefaultTransport}
+	if _, err := (&http.Client{Transport: rt}).Get(s.URL); err == nil {
+		t.Error("attempt outlasting the timeout succeeded")
+	}
+}
+
+func TestRetryingTransport(t *testing.T) {
+	five := 5
+	tests := []struct {
+		repo    Repository
+		retries int
+		timeout time.Duration
+		err     string
+	}{
+		{Repository{}, DefaultRetries, DefaultTimeout, ""},
+		{Repository{Retries: &five, Timeout: "1m"}, 5, time.Minute, ""},
+		{Repository{Timeout: "soon"}, 0, 0, `invalid timeout "soon"`},
+	}
+	for _, tt := range tests {
+		rt, err := tt.repo.retryingTransport()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%+v: got error %v, want one containing %q", tt.repo, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: %v", tt.repo, err)
+			continue
+		}
+		if r := rt.(*retryTransport); r.retries != tt.retries || r.timeout != tt.timeout {
+			t.Errorf("%+v: got %d retries and timeout %v, want %d and %v", tt.repo, r.retries, r.timeout, tt.retries, tt.timeout)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Matcher reports whether a bundle version matches a search
+type Matcher func(v *BundleVersion) bool
+
+// Search returns the bundles matching match, sorted by name.
+//
+// Only the newest version of each bundle is considered, unless allVersions is set, in which
+// case every matching version is returned, newest first.
+func (i *IndexFile) Search(match Matcher, allVersions bool) []*BundleVersion {
+	var results []*BundleVersion
+	for _, versions := range i.Entries {
+		if !allVersions && len(versions) > 0 {
+			versions = versions[:1]
+		}
+		for _, v := range versions {
+			if match(v) {
+				results = append(results, v)
+			}
+		}
+	}
+	sort.SliceStable(results, func(a, b int) bool { return results[a].Name < results[b].Name })
+	return results
+}
+
+// NotDeprecated restricts match to versions that are not deprecated
+func NotDeprecated(match Matcher) Matcher {
+	return func(v *BundleVersion) bool {
+		return !v.Deprecated && match(v)
+	}
+}
+
+// MatchTerms matches bundles where every term appears in the name, description or
+// keywords, ignoring case. Every bundle matches when no terms are given.
+func MatchTerms(terms []string) Matcher {
+	return func(v *BundleVersion) bool {
+		for _, term := range terms {
+			term = strings.ToLower(term)
+			if !anyField(v, func(f string) bool { return strings.Contains(strings.ToLower(f), term) }) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchRegexps matches bundles where every expression matches the name, description or
+// one of the keywords
+func MatchRegexps(exprs []*regexp.Regexp) Matcher {
+	return func(v *BundleVersion) bool {
+		for _, re := range exprs {
+			if !anyField(v, re.MatchString) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func anyField(v *BundleVersion, match func(string) bool) bool {
+	if match(v.Name) || match(v.Description) {
+		return true
+	}
+	for _, k := range v.Keywords {
+		if match(k) {
+			return true
+		}
+	}
+	return false
+}
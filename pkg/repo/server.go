@@ -0,0 +1,340 @@
+package repo
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/loader"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// TagsPrefix is the path under which a server exposes bundles by name and version, as
+// /repositories/NAME/tags/VERSION
+const TagsPrefix = "/repositories/"
+
+// maxUploadSize bounds the size of uploaded bundle documents
+const maxUploadSize = 10 << 20
+
+// Server serves a repository directory over HTTP: its index, shards and bundle files as
+// they are laid out in the directory, and each indexed bundle at
+// /repositories/NAME/tags/VERSION.
+//
+// When uploads are allowed, a bundle document PUT to /repositories/NAME/tags/VERSION is
+// stored as NAME/VERSION/bundle.cnab (or bundle.json if unsigned) in the directory, and
+// the index is updated to include it.
+type Server struct {
+	// Dir is the repository directory, as written by 'duffle repo generate'
+	Dir string
+	// Username and Password require HTTP basic auth for every request when set
+	Username string
+	Password string
+	// AllowUpload accepts bundles uploaded with PUT
+	AllowUpload bool
+	// Keyring, when set, restricts uploads to bundles signed by one of its keys
+	Keyring *signature.KeyRing
+	// Signer, when set, signs the index each time an upload updates it
+	Signer *signature.Signer
+
+	mu       sync.Mutex
+	index    *IndexFile
+	modified time.Time
+
+	// uploadMu serializes uploads, so that each one updates the index on top of the previous one
+	uploadMu sync.Mutex
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="duffle"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPut && s.AllowUpload && strings.HasPrefix(r.URL.Path, TagsPrefix):
+		s.serveUpload(w, r)
+	case r.Method != http.MethodGet && r.Method != http.MethodHead:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	case strings.HasPrefix(r.URL.Path, TagsPrefix):
+		s.serveTag(w, r)
+	default:
+		s.serveFile(w, r)
+	}
+}
+
+// serveFile serves a file from the repository directory. Files carry an ETag derived from
+// their modification time, since Last-Modified alone cannot tell apart versions of the
+// index written within the same second.
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	p := filepath.Join(s.Dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+	}
+	http.FileServer(http.Dir(s.Dir)).ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Username == "" {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(s.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(s.Password)) == 1
+}
+
+// TagPath returns the server path of a bundle version
+func TagPath(name, version string) string {
+	return TagsPrefix + name + "/tags/" + version
+}
+
+// parseTagPath splits /repositories/NAME/tags/VERSION into the bundle name and version
+func parseTagPath(p string) (string, string, bool) {
+	p = strings.TrimPrefix(p, TagsPrefix)
+	i := strings.LastIndex(p, "/tags/")
+	if i == -1 {
+		return "", "", false
+	}
+	return p[:i], p[i+len("/tags/"):], true
+}
+
+// serveTag serves the bundle document of /repositories/NAME/tags/VERSION
+func (s *Server) serveTag(w http.ResponseWriter, r *http.Request) {
+	name, version, ok := parseTagPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	index, err := s.loadIndex()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if file := s.localFile(index, name, version); file != "" {
+		if fi, err := os.Stat(file); err == nil {
+			w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+		}
+		http.ServeFile(w, r, file)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// localFile returns the file within the repository directory holding a bundle version,
+// or an empty string if the index does not list one
+func (s *Server) localFile(index *IndexFile, name, version string) string {
+	for _, v := range index.Entries[name] {
+		if v.Version == version && len(v.URLs) > 0 && inDirectory(v.URLs[0], "") {
+			return filepath.Join(s.Dir, filepath.FromSlash(path.Clean("/"+v.URLs[0])))
+		}
+	}
+	return ""
+}
+
+// loadIndex returns the directory's index, reloading it when the index file changed
+func (s *Server) loadIndex() (*IndexFile, error) {
+	fi, err := os.Stat(FindIndexFile(s.Dir))
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.index == nil || !fi.ModTime().Equal(s.modified) {
+		i, err := LoadIndexDir(s.Dir)
+		if err != nil {
+			return nil, err
+		}
+		s.index, s.modified = i, fi.ModTime()
+	}
+	return s.index, nil
+}
+
+// serveUpload stores the bundle document PUT to /repositories/NAME/tags/VERSION and adds it
+// to the index. Existing versions are only replaced when the force query parameter is set.
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request) {
+	name, version, ok := parseTagPath(r.URL.Path)
+	if !ok || !validBundleName(name) {
+		http.Error(w, "invalid bundle name", http.StatusBadRequest)
+		return
+	}
+	if _, err := semver.NewVersion(version); err != nil {
+		http.Error(w, fmt.Sprintf("invalid bundle version %q", version), http.StatusBadRequest)
+		return
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	b, err := s.checkUpload(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if b.Name != name || b.Version != version {
+		http.Error(w, fmt.Sprintf("bundle is %s %s, not %s %s", b.Name, b.Version, name, version), http.StatusBadRequest)
+		return
+	}
+
+	s.uploadMu.Lock()
+	defer s.uploadMu.Unlock()
+
+	file := "bundle.json"
+	if signature.IsClearsigned(data) {
+		file = "bundle.cnab"
+	}
+	dir := filepath.Join(s.Dir, filepath.FromSlash(name), version)
+	dest := filepath.Join(dir, file)
+	if index, err := s.loadIndex(); err == nil {
+		if existing := s.localFile(index, name, version); existing != "" {
+			if r.URL.Query().Get("force") != "true" {
+				http.Error(w, fmt.Sprintf("%s %s already exists", name, version), http.StatusConflict)
+				return
+			}
+			if filepath.Dir(existing) != dir {
+				http.Error(w, fmt.Sprintf("%s %s is served from %s and cannot be replaced", name, version, existing), http.StatusConflict)
+				return
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.store(dir, dest, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// checkUpload parses and validates an uploaded bundle document, verifying its signature
+// when the server only accepts signed bundles
+func (s *Server) checkUpload(data []byte) (*bundle.Bundle, error) {
+	var (
+		b   *bundle.Bundle
+		err error
+	)
+	if s.Keyring != nil {
+		b, err = loader.LoadReader(bytes.NewReader(data), loader.Options{Format: "signed", Keys: s.Keyring})
+	} else {
+		if signature.IsClearsigned(data) {
+			if data, err = signature.Plaintext(data); err != nil {
+				return nil, err
+			}
+		}
+		b, err = loader.LoadReader(bytes.NewReader(data), loader.Options{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range bundle.Validate(b) {
+		if f.Severity == bundle.SeverityError {
+			return nil, fmt.Errorf("invalid bundle: %s", f)
+		}
+	}
+	return b, nil
+}
+
+// store writes an uploaded bundle document to dest, replacing any other document of the
+// same bundle version in dir, and updates the index
+func (s *Server) store(dir, dest string, data []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name := range bundleFiles {
+		if p := filepath.Join(dir, name); p != dest {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	if err := atomicfile.WriteFile(dest, data, 0644); err != nil {
+		return err
+	}
+	return s.reindex()
+}
+
+// reindex merges the repository directory into its index, keeping the index sharded and in
+// YAML if it was
+func (s *Server) reindex() error {
+	path := FindIndexFile(s.Dir)
+	existing, err := LoadIndexFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	sharded := existing != nil && len(existing.Shards) > 0
+	if existing != nil {
+		if err := loadLocalShards(existing, s.Dir); err != nil {
+			return err
+		}
+	}
+	index, err := MergeDirectory(existing, s.Dir, "")
+	if err != nil {
+		return err
+	}
+	if sharded {
+		if err := index.WriteShards(s.Dir); err != nil {
+			return err
+		}
+	}
+	_, err = index.WriteDir(s.Dir, filepath.Base(path) == YAMLIndexPath, s.Signer)
+	return err
+}
+
+// validBundleName reports whether name can safely be used as a path within the repository
+func validBundleName(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if !validName.MatchString(part) || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// Push uploads a bundle document to the repository, which must be served by a Server that
+// allows uploads. An existing version is only replaced when force is set.
+func (r *Repository) Push(name, version string, data []byte, force bool) error {
+	if IsGitURL(r.URL) || IsOCIURL(r.URL) {
+		return fmt.Errorf("bundles can only be pushed to http(s) repositories")
+	}
+	u, err := resolve(r.URL, strings.TrimPrefix(TagPath(name, version), "/"))
+	if err != nil {
+		return err
+	}
+	if force {
+		u += "?force=true"
+	}
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	client, err := r.Client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("cannot push %s %s to repository %s: %s: %s", name, version, r.Name, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
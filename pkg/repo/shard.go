@@ -0,0 +1,154 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+	"github.com/deis/duffle/pkg/loader"
+)
+
+// ShardDir is the directory shards are written to, relative to the index
+const ShardDir = "index"
+
+// Shard locates the versions of one bundle in a sharded index.
+//
+// Very large repositories split their index so that clients only download the metadata
+// of the bundles they use: index.json then lists each bundle's shard instead of its
+// versions, and each shard is a JSON list of the bundle's versions.
+type Shard struct {
+	// URL locates the shard, relative to the repository URL
+	URL string `json:"url"`
+	// Digest is the digest of the shard document; it ties the shard to the (signed) index
+	Digest string `json:"digest"`
+}
+
+// Split moves the versions of every bundle into a separate shard, returning the shard
+// documents keyed by their path relative to the index
+func (i *IndexFile) Split() (map[string][]byte, error) {
+	if i.Shards == nil {
+		i.Shards = map[string]Shard{}
+	}
+	files := map[string][]byte{}
+	for name, versions := range i.Entries {
+		data, err := json.MarshalIndent(versions, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		p := path.Join(ShardDir, name+".json")
+		files[p] = data
+		i.Shards[name] = Shard{URL: p, Digest: digest.OfBuffer(data)}
+	}
+	i.Entries = map[string]BundleVersions{}
+	return files, nil
+}
+
+// LoadShard adds the versions in the shard document data to the named bundle's entry,
+// checking the document against the digest the index records for it
+func (i *IndexFile) LoadShard(name string, data []byte) error {
+	s, ok := i.Shards[name]
+	if !ok {
+		return fmt.Errorf("index has no shard for %s", name)
+	}
+	if d := digest.OfBuffer(data); d != s.Digest {
+		return fmt.Errorf("digest mismatch for shard %s: expected %s, got %s", s.URL, s.Digest, d)
+	}
+	var versions BundleVersions
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return fmt.Errorf("cannot parse shard %s: %v", s.URL, err)
+	}
+	sort.Stable(versions)
+	i.Entries[name] = versions
+	delete(i.Shards, name)
+	return nil
+}
+
+// LoadIndexDir reads the index of a repository directory, loading every shard of a
+// sharded index from the directory
+func LoadIndexDir(dir string) (*IndexFile, error) {
+	i, err := LoadIndexFile(FindIndexFile(dir))
+	if err != nil {
+		return nil, err
+	}
+	return i, loadLocalShards(i, dir)
+}
+
+func loadLocalShards(i *IndexFile, dir string) error {
+	for name, s := range i.Shards {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(s.URL)))
+		if err != nil {
+			return err
+		}
+		if err := i.LoadShard(name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchShard loads the named bundle's shard of the repository's index i, unless the bundle
+// is not sharded. Shards are cached in dir by digest, so they are only downloaded once.
+func (r *Repository) FetchShard(i *IndexFile, name, dir string) error {
+	s, ok := i.Shards[name]
+	if !ok {
+		return nil
+	}
+	cache := r.httpCache(dir)
+	if data, ok := cache.Get(s.Digest); ok {
+		return i.LoadShard(name, data)
+	}
+	client, err := r.Client()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, base := range r.bases() {
+		err := r.fetchShardFrom(client, cache, i, name, base)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return fmt.Errorf("cannot fetch index shard of %s from repository %s: %s", name, r.Name, strings.Join(errs, "; "))
+}
+
+func (r *Repository) fetchShardFrom(client *http.Client, cache *loader.Cache, i *IndexFile, name, base string) error {
+	u, err := resolve(base, i.Shards[name].URL)
+	if err != nil {
+		return err
+	}
+	data, err := cache.Fetch(client, u)
+	if err != nil {
+		return err
+	}
+	if err := i.LoadShard(name, data); err != nil {
+		return err
+	}
+	_, err = cache.Put(data)
+	return err
+}
+
+// WriteShards splits the index and writes its shards into the repository directory dir
+func (i *IndexFile) WriteShards(dir string) error {
+	files, err := i.Split()
+	if err != nil {
+		return err
+	}
+	for p, data := range files {
+		dest := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(dest, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
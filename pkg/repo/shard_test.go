@@ -0,0 +1,205 @@
+package repo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func testIndex() *IndexFile {
+	i := NewIndexFile()
+	i.Entries["foo"] = BundleVersions{
+		{Name: "foo", Version: "0.1.0", URLs: []string{"foo-0.1.0.json"}},
+		{Name: "foo", Version: "0.2.0", URLs: []string{"foo-0.2.0.json"}},
+	}
+	i.Entries["bar"] = BundleVersions{{Name: "bar", Version: "1.0.0", URLs: []string{"bar-1.0.0.json"}}}
+	return i
+}
+
+func TestSplit(t *testing.T) {
+	i := testIndex()
+	files, err := i.Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(i.Entries) != 0 {
+		t.Errorf("split index still has entries for %d bundles", len(i.Entries))
+	}
+	for _, name := range []string{"foo", "bar"} {
+		s, ok := i.Shards[name]
+		if !ok {
+			t.Errorf("no shard for %s", name)
+			continue
+		}
+		if want := ShardDir + "/" + name + ".json"; s.URL != want {
+			t.Errorf("shard of %s at %s, want %s", name, s.URL, want)
+		}
+		data, ok := files[s.URL]
+		if !ok {
+			t.Errorf("no shard document for %s", name)
+			continue
+		}
+		if err := i.LoadShard(name, data); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if len(i.Shards) != 0 {
+		t.Errorf("%d shards left after loading all of them", len(i.Shards))
+	}
+	// shards are loaded with their versions sorted from newest to oldest
+	if v, err := i.Get("foo", ""); err != nil || v.Version != "0.2.0" {
+		t.Errorf("Get(foo) = %v, %v; want version 0.2.0", v, err)
+	}
+}
+
+func TestLoadShard(t *testing.T) {
+	i := testIndex()
+	files, err := i.Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+	foo := files[i.Shards["foo"].URL]
+
+	tests := []struct {
+		name  string
+		shard string
+		data  []byte
+		err   string
+	}{
+		{"other bundle's shard", "foo", files[i.Shards["bar"].URL], "digest mismatch for shard index/foo.json"},
+		{"tampered shard", "foo", []byte(strings.Replace(string(foo), "0.2.0", "0.3.0", 1)), "digest mismatch"},
+		{"empty shard", "foo", nil, "digest mismatch"},
+		{"unknown bundle", "baz", foo, "index has no shard for baz"},
+	}
+	for _, tt := range tests {
+		err := i.LoadShard(tt.shard, tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+	if _, ok := i.Entries["foo"]; ok {
+		t.Error("entries were added from a shard that does not match its digest")
+	}
+}
+
+// indexServer serves the given documents by path, answering 404 to any other request and
+// recording the paths requested
+func indexServer(t *testing.T, docs map[string]string, requested *[]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requested != nil {
+			*requested = append(*requested, r.URL.Path)
+		}
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+}
+
+const (
+	jsonIndex = `{"apiVersion":"v1","entries":{"foo":[{"name":"foo","version":"0.1.0","urls":["foo.json"]}]}}`
+	yamlIndex = "apiVersion: v1\nentries:\n  bar:\n  - name: bar\n    version: 1.0.0\n    urls:\n    - bar.json\n"
+)
+
+func TestFetchIndex(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	var jsonRequests, yamlRequests []string
+	withJSON := indexServer(t, map[string]string{"/repo/index.json": jsonIndex, "/repo/index.yaml": yamlIndex}, &jsonRequests)
+	defer withJSON.Close()
+	withYAML := indexServer(t, map[string]string{"/repo/index.yaml": yamlIndex}, &yamlRequests)
+	defer withYAML.Close()
+	empty := indexServer(t, nil, nil)
+	defer empty.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		mirrors []string
+		bundle  string
+		err     string
+	}{
+		{"index.json", withJSON.URL + "/repo", nil, "foo", ""},
+		{"index.yaml without index.json", withYAML.URL + "/repo/", nil, "bar", ""},
+		{"mirror of an unavailable repository", down.URL, []string{withJSON.URL + "/repo"}, "foo", ""},
+		{"second mirror", down.URL, []string{empty.URL, withYAML.URL + "/repo"}, "bar", ""},
+		{"no index", empty.URL, nil, "", "cannot fetch " + empty.URL + "/index.json: 404 Not Found"},
+		{"no mirror serves an index", down.URL, []string{empty.URL}, "", "503 Service Unavailable; cannot fetch " + empty.URL + "/index.json: 404"},
+	}
+	for _, tt := range tests {
+		r := &Repository{Name: "test", URL: tt.url, Mirrors: tt.mirrors}
+		i, err := r.FetchIndex(http.DefaultClient, nil)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if _, err := i.Get(tt.bundle, ""); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+	for _, p := range jsonRequests {
+		if p != "/repo/index.json" {
+			t.Errorf("%s requested from a repository serving index.json", p)
+		}
+	}
+	if strings.Join(yamlRequests, " ") != "/repo/index.json /repo/index.yaml /repo/index.json /repo/index.yaml" {
+		t.Errorf("requests to a repository serving index.yaml: %v", yamlRequests)
+	}
+}
+
+func TestFetchShard(t *testing.T) {
+	i := testIndex()
+	files, err := i.Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]string{}
+	for p, data := range files {
+		docs["/"+p] = string(data)
+	}
+	mirror := indexServer(t, docs, nil)
+	defer mirror.Close()
+	empty := indexServer(t, nil, nil)
+	defer empty.Close()
+
+	noRetries := 0
+	r := &Repository{Name: "test", URL: empty.URL, Mirrors: []string{mirror.URL}, Retries: &noRetries}
+	dir, err := ioutil.TempDir("", "duffle-shard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := r.FetchShard(i, "foo", dir); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := i.Get("foo", ""); err != nil || v.Version != "0.2.0" {
+		t.Errorf("Get(foo) = %v, %v; want version 0.2.0", v, err)
+	}
+
+	// the shard is cached by digest, so it is loaded again without any server
+	mirror.Close()
+	i = testIndex()
+	if _, err := i.Split(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.FetchShard(i, "foo", dir); err != nil {
+		t.Fatalf("cached shard: %v", err)
+	}
+	if err := r.FetchShard(i, "bar", dir); err == nil || !strings.Contains(err.Error(), "cannot fetch index shard of bar from repository test") {
+		t.Errorf("uncached shard: got error %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/deis/duffle/pkg/duffle/home"
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// SignaturePath is the location of the index's detached signature relative to a repository's URL
+const SignaturePath = IndexPath + ".asc"
+
+// signaturePath returns the location of the detached signature of the index at path
+func signaturePath(path string) string {
+	return path + ".asc"
+}
+
+// verifyIndex checks the detached signature of the repository's index data against the
+// repository's trusted keys
+func (r *Repository) verifyIndex(data, sig []byte) error {
+	path := r.Keyring
+	if path == "" {
+		path = home.Home(home.DefaultHome()).PublicKeyring()
+	}
+	kr, err := signature.LoadKeyRing(path)
+	if err != nil {
+		return err
+	}
+	if _, err := signature.NewVerifier(kr).VerifyDetached(data, sig); err != nil {
+		return fmt.Errorf("index of repository %s is not signed by a trusted key: %v", r.Name, err)
+	}
+	return nil
+}
+
+// WriteSignedFile saves the index to path, along with a detached signature by signer in
+// path.asc. The signer's public key is added to the index's PublicKeys.
+func (i *IndexFile) WriteSignedFile(path string, signer *signature.Signer) error {
+	key, err := signer.PublicKey()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, k := range i.PublicKeys {
+		found = found || k == string(key)
+	}
+	if !found {
+		i.PublicKeys = append(i.PublicKeys, string(key))
+	}
+	if err := i.WriteFile(path); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.DetachSign(data)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(signaturePath(path), sig, 0644)
+}
@@ -0,0 +1,184 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/deis/duffle/pkg/crypto/digest"
+)
+
+// Problem is an inconsistency found while verifying a repository directory
+type Problem struct {
+	// Path locates the problem: an index entry as NAME@VERSION, or a file within the directory
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// VerifyDirectory checks that the index of the repository directory dir is consistent with
+// the files in it: every version must be valid semver, every bundle URL within dir must name
+// an existing file, and that file must match the recorded digest. baseURL is the base URL the
+// index was generated with, if any; URLs outside of dir are not checked.
+//
+// When dir has a repositories/NAME/tags/VERSION tree, as used to serve bundles by name and
+// version from static storage, each tag file must be the indexed bundle of that name and
+// version, and each indexed bundle must have a tag file.
+//
+// An error is returned when the index itself cannot be read.
+func VerifyDirectory(dir, baseURL string) ([]Problem, error) {
+	i, err := LoadIndexDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	v := &dirVerifier{dir: dir, baseURL: baseURL, digests: map[string]string{}}
+
+	var names []string
+	for name := range i.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		seen := map[string]bool{}
+		for _, e := range i.Entries[name] {
+			v.entry(name, e, seen)
+		}
+	}
+	if err := v.tags(i); err != nil {
+		return nil, err
+	}
+	return v.problems, nil
+}
+
+type dirVerifier struct {
+	dir      string
+	baseURL  string
+	problems []Problem
+	// digests caches the digests of the files read so far, by path
+	digests map[string]string
+}
+
+func (v *dirVerifier) problemf(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{path, fmt.Sprintf(format, args...)})
+}
+
+func (v *dirVerifier) entry(name string, e *BundleVersion, seen map[string]bool) {
+	p := name + "@" + e.Version
+	if e.Name != name {
+		v.problemf(p, "entry is listed under %q but names bundle %q", name, e.Name)
+	}
+	if sv, err := semver.NewVersion(e.Version); err != nil {
+		v.problemf(p, "%q is not a valid semantic version", e.Version)
+	} else if sv.String() != strings.TrimPrefix(e.Version, "v") {
+		v.problemf(p, "%q is not a full semantic version (expected %s)", e.Version, sv)
+	}
+	if seen[e.Version] {
+		v.problemf(p, "version is listed more than once")
+	}
+	seen[e.Version] = true
+	if len(e.URLs) == 0 {
+		v.problemf(p, "entry has no URLs")
+	}
+	for _, u := range e.URLs {
+		if !inDirectory(u, v.baseURL) {
+			continue
+		}
+		rel := u
+		if v.baseURL != "" {
+			rel = strings.TrimPrefix(u, strings.TrimSuffix(v.baseURL, "/")+"/")
+		}
+		path := filepath.Join(v.dir, filepath.FromSlash(rel))
+		d, err := v.digest(path)
+		switch {
+		case os.IsNotExist(err):
+			v.problemf(p, "%s does not exist", u)
+		case err != nil:
+			v.problemf(p, "cannot read %s: %v", u, err)
+		case e.Digest != "" && d != e.Digest:
+			v.problemf(p, "digest of %s is %s, but the index records %s", u, d, e.Digest)
+		}
+	}
+}
+
+// tags checks the repositories/NAME/tags/VERSION tree of the directory against the index
+func (v *dirVerifier) tags(i *IndexFile) error {
+	root := filepath.Join(v.dir, strings.Trim(TagsPrefix, "/"))
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+	found := map[string]bool{}
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(v.dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		name, version, ok := parseTagPath("/" + rel)
+		if !ok {
+			v.problemf(rel, "file is not a tag of the form %s", strings.TrimPrefix(TagPath("NAME", "VERSION"), "/"))
+			return nil
+		}
+		found[name+"@"+version] = true
+		e := findVersion(i, name, version)
+		if e == nil {
+			v.problemf(rel, "%s %s is not in the index", name, version)
+			return nil
+		}
+		d, err := v.digest(path)
+		if err != nil {
+			return err
+		}
+		if e.Digest != "" && d != e.Digest {
+			v.problemf(rel, "digest is %s, but the index records %s for %s %s", d, e.Digest, name, version)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var names []string
+	for name := range i.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, e := range i.Entries[name] {
+			if !found[name+"@"+e.Version] {
+				v.problemf(name+"@"+e.Version, "%s has no tag file", strings.TrimPrefix(TagPath(name, e.Version), "/"))
+			}
+		}
+	}
+	return nil
+}
+
+func (v *dirVerifier) digest(path string) (string, error) {
+	if d, ok := v.digests[path]; ok {
+		return d, nil
+	}
+	d, err := digest.OfFile(path)
+	if err != nil {
+		return "", err
+	}
+	v.digests[path] = d
+	return d, nil
+}
+
+// findVersion returns the index entry of the named bundle's version, or nil
+func findVersion(i *IndexFile, name, version string) *BundleVersion {
+	for _, e := range i.Entries[name] {
+		if e.Version == version {
+			return e
+		}
+	}
+	return nil
+}
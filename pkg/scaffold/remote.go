@@ -0,0 +1,204 @@
+package scaffold
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitCommand is the git executable invoked to fetch templates from git repositories
+var GitCommand = "git"
+
+// gitPrefix marks template sources that are git URLs, as in git::https://host/repo.git//path
+const gitPrefix = "git::"
+
+// Repositories maps the names of configured template repositories to their git URLs
+type Repositories map[string]string
+
+// LoadRepositories reads the template repositories configured in the file at path. No
+// repositories are returned when the file does not exist.
+func LoadRepositories(path string) (Repositories, error) {
+	repos := Repositories{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return repos, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &repos); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	return repos, nil
+}
+
+// Find returns the template source names:
+//
+//   - a built-in template, such as default
+//   - a directory holding a template
+//   - REPO/PATH, the directory PATH of the configured template repository REPO
+//   - a git URL, such as git::https://example.com/org/templates.git//terraform?ref=v1
+//   - a repository on a git host, such as github.com/org/templates/terraform, where the
+//     first two path elements name the repository and the rest the template's directory
+//
+// Git repositories are fetched into cacheDir.
+func Find(source string, repos Repositories, cacheDir string) (*Template, error) {
+	if t, err := Lookup(source); err == nil {
+		return t, nil
+	}
+	if fi, err := os.Stat(source); err == nil && fi.IsDir() {
+		return LoadDir(source)
+	}
+	remote, path, ref, err := parseSource(source, repos)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := fetch(remote, ref, cacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch template %s: %v", source, err)
+	}
+	t, err := LoadDir(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		return nil, err
+	}
+	t.Name = source
+	return t, nil
+}
+
+// parseSource returns the git remote, the directory within it and the ref a remote
+// template source names
+func parseSource(source string, repos Repositories) (remote, path, ref string, err error) {
+	if i := strings.Index(source, "/"); i != -1 {
+		if u, ok := repos[source[:i]]; ok {
+			query := ""
+			if j := strings.Index(u, "?"); j != -1 {
+				u, query = u[:j], u[j:]
+			}
+			source = strings.TrimSuffix(u, "/") + "//" + source[i+1:] + query
+			if !strings.HasPrefix(source, gitPrefix) && !strings.Contains(source, "://") {
+				source = gitPrefix + "https://" + source
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(source, gitPrefix), strings.Contains(source, "://"):
+		u, err := url.Parse(strings.TrimPrefix(source, gitPrefix))
+		if err != nil || u.Scheme == "" {
+			return "", "", "", fmt.Errorf("invalid template URL %q", source)
+		}
+		ref = u.Query().Get("ref")
+		// git would take such a ref for an option, such as --upload-pack running a command
+		if strings.HasPrefix(ref, "-") {
+			return "", "", "", fmt.Errorf("invalid template URL %q: ref may not start with '-'", source)
+		}
+		u.RawQuery = ""
+		if i := strings.Index(u.Path, "//"); i != -1 {
+			path = u.Path[i+2:]
+			u.Path = u.Path[:i]
+		}
+		u.RawPath = ""
+		remote = u.String()
+	default:
+		parts := strings.Split(source, "/")
+		if len(parts) < 3 || !strings.Contains(parts[0], ".") {
+			return "", "", "", fmt.Errorf("unknown template %q (available: %s, or a directory, REPO/PATH or git URL)", source, strings.Join(Names(), ", "))
+		}
+		remote = "https://" + strings.Join(parts[:3], "/")
+		if !strings.HasSuffix(remote, ".git") {
+			remote += ".git"
+		}
+		path = strings.Join(parts[3:], "/")
+	}
+	path = strings.Trim(path, "/")
+	if strings.Contains(path, "..") {
+		return "", "", "", fmt.Errorf("invalid template %q: path may not leave the repository", source)
+	}
+	return remote, path, ref, nil
+}
+
+// fetch checks out ref, or the remote's HEAD, of the git repository remote into a
+// directory of cacheDir and returns it
+func fetch(remote, ref, cacheDir string) (string, error) {
+	sum := sha256.Sum256([]byte(remote))
+	dir := filepath.Join(cacheDir, hex.EncodeToString(sum[:]))
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+		if err := git(dir, "init", "--quiet"); err != nil {
+			return "", err
+		}
+		if err := git(dir, "remote", "add", "--", "origin", remote); err != nil {
+			return "", err
+		}
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := git(dir, "fetch", "--quiet", "--depth", "1", "--", "origin", ref); err != nil {
+		return "", err
+	}
+	if err := git(dir, "checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// LoadDir returns the template made of the files in dir
+func LoadDir(dir string) (*Template, error) {
+	t := &Template{Name: filepath.Base(dir)}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		t.Files = append(t.Files, File{Path: filepath.ToSlash(rel), Mode: info.Mode().Perm(), Content: string(data)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read template %s: %v", dir, err)
+	}
+	if len(t.Files) == 0 {
+		return nil, fmt.Errorf("template %s has no files", dir)
+	}
+	return t, nil
+}
+
+func git(dir string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(GitCommand, args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return nil
+}
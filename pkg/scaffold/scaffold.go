@@ -0,0 +1,119 @@
+// Package scaffold generates new bundle projects from templates.
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// templateSuffix marks the files of a template rendered with the project's values. Other
+// files, such as Helm charts which use the same template syntax, are copied as they are.
+const templateSuffix = ".tmpl"
+
+// Template is a set of files making up a new bundle project
+type Template struct {
+	Name        string
+	Description string
+	Files       []File
+}
+
+// File is a file of a template
+type File struct {
+	// Path is the location of the file within the project, using slashes. It is rendered
+	// as a Go template. Files ending in .tmpl are rendered as well, and written without
+	// the suffix.
+	Path    string
+	Mode    os.FileMode
+	Content string
+}
+
+// Values are the values templates are rendered with
+type Values struct {
+	// Name is the name of the bundle
+	Name string
+}
+
+// Lookup returns the built-in template called name
+func Lookup(name string) (*Template, error) {
+	for _, t := range builtins {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the names of the built-in templates, sorted
+func Names() []string {
+	var names []string
+	for _, t := range builtins {
+		names = append(names, t.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Create writes the files of t into dir, rendered with v, and returns the paths written.
+// Nothing is written if any of the files already exists.
+func Create(dir string, t *Template, v Values) ([]string, error) {
+	type output struct {
+		path string
+		mode os.FileMode
+		data []byte
+	}
+	var outputs []output
+	for _, f := range t.Files {
+		p, err := render(f.Path, f.Path, v)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %v", t.Name, err)
+		}
+		data := []byte(f.Content)
+		if strings.HasSuffix(p, templateSuffix) {
+			p = strings.TrimSuffix(p, templateSuffix)
+			s, err := render(f.Path, f.Content, v)
+			if err != nil {
+				return nil, fmt.Errorf("template %s: %v", t.Name, err)
+			}
+			data = []byte(s)
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(p))
+		if _, err := os.Stat(dest); err == nil {
+			return nil, fmt.Errorf("%s already exists", dest)
+		}
+		mode := f.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		outputs = append(outputs, output{dest, mode, data})
+	}
+
+	var written []string
+	for _, o := range outputs {
+		if err := os.MkdirAll(filepath.Dir(o.path), 0755); err != nil {
+			return written, err
+		}
+		if err := ioutil.WriteFile(o.path, o.data, o.mode); err != nil {
+			return written, err
+		}
+		written = append(written, o.path)
+	}
+	return written, nil
+}
+
+func render(name, text string, v Values) (string, error) {
+	tpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,159 @@
+package scaffold
+
+var builtins = []*Template{
+	{
+		Name:        "default",
+		Description: "an invocation image running a shell script for each action",
+		Files: []File{
+			{Path: "duffle.toml.tmpl", Content: defaultManifest},
+			{Path: "README.md.tmpl", Content: readme},
+			{Path: "parameters.yaml", Content: defaultParameters},
+			{Path: "cnab/Dockerfile", Content: defaultDockerfile},
+			{Path: "cnab/app/run", Mode: 0755, Content: defaultRun},
+		},
+	},
+	{
+		Name:        "helm",
+		Description: "an invocation image installing a Helm chart into a Kubernetes cluster",
+		Files: []File{
+			{Path: "duffle.toml.tmpl", Content: helmManifest},
+			{Path: "README.md.tmpl", Content: readme},
+			{Path: "parameters.yaml", Content: helmParameters},
+			{Path: "cnab/Dockerfile", Content: helmDockerfile},
+			{Path: "cnab/app/run.tmpl", Mode: 0755, Content: helmRun},
+			{Path: "cnab/app/charts/{{.Name}}/Chart.yaml.tmpl", Content: helmChart},
+			{Path: "cnab/app/charts/{{.Name}}/values.yaml", Content: helmValues},
+			{Path: "cnab/app/charts/{{.Name}}/templates/configmap.yaml", Content: helmConfigMap},
+		},
+	},
+}
+
+const readme = `# {{.Name}}
+
+A CNAB bundle built with duffle.
+
+    duffle build
+    duffle install my-{{.Name}} .
+
+The bundle is described by duffle.toml. The invocation image is built from cnab/, and
+runs /cnab/app/run with the action to perform in $CNAB_ACTION. Parameter values are passed
+as $CNAB_P_<NAME>, and parameters.yaml holds the values used when installing this directory.
+`
+
+const defaultManifest = `name = "{{.Name}}"
+version = "0.1.0"
+description = "A short description of {{.Name}}"
+# registry prefixes the repositories built images are tagged in
+# registry = "example.com/org"
+
+[invocationImages.cnab]
+path = "cnab"
+
+[parameters.greeting]
+type = "string"
+defaultValue = "hello"
+metadata = { description = "what the bundle says" }
+`
+
+const defaultParameters = `# values of the bundle's parameters used when installing this directory
+greeting: hello
+`
+
+const defaultDockerfile = `FROM alpine:3.8
+
+COPY app /cnab/app
+
+CMD ["/cnab/app/run"]
+`
+
+const defaultRun = `#!/bin/sh
+set -e
+
+case "$CNAB_ACTION" in
+    install)
+        echo "$CNAB_P_GREETING from $CNAB_INSTALLATION_NAME: installing"
+        ;;
+    upgrade)
+        echo "$CNAB_P_GREETING from $CNAB_INSTALLATION_NAME: upgrading"
+        ;;
+    uninstall)
+        echo "$CNAB_P_GREETING from $CNAB_INSTALLATION_NAME: uninstalling"
+        ;;
+    *)
+        echo "unknown action $CNAB_ACTION" >&2
+        exit 1
+        ;;
+esac
+`
+
+const helmManifest = `name = "{{.Name}}"
+version = "0.1.0"
+description = "Installs the {{.Name}} chart"
+# registry prefixes the repositories built images are tagged in
+# registry = "example.com/org"
+
+[invocationImages.cnab]
+path = "cnab"
+
+[parameters.namespace]
+type = "string"
+defaultValue = "default"
+metadata = { description = "namespace to install the chart into" }
+
+[credentials.kubeconfig]
+path = "/root/.kube/config"
+`
+
+const helmParameters = `# values of the bundle's parameters used when installing this directory
+namespace: default
+`
+
+const helmDockerfile = `FROM alpine/helm:2.11.0
+
+COPY app /cnab/app
+
+ENTRYPOINT []
+CMD ["/cnab/app/run"]
+`
+
+const helmRun = `#!/bin/sh
+set -e
+
+chart=/cnab/app/charts/{{.Name}}
+
+case "$CNAB_ACTION" in
+    install)
+        helm init --client-only
+        helm install "$chart" --name "$CNAB_INSTALLATION_NAME" --namespace "$CNAB_P_NAMESPACE"
+        ;;
+    upgrade)
+        helm init --client-only
+        helm upgrade "$CNAB_INSTALLATION_NAME" "$chart"
+        ;;
+    uninstall)
+        helm init --client-only
+        helm delete --purge "$CNAB_INSTALLATION_NAME"
+        ;;
+    *)
+        echo "unknown action $CNAB_ACTION" >&2
+        exit 1
+        ;;
+esac
+`
+
+const helmChart = `apiVersion: v1
+name: {{.Name}}
+version: 0.1.0
+description: The {{.Name}} chart
+`
+
+const helmValues = `message: hello
+`
+
+const helmConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-config
+data:
+  message: {{ .Values.message | quote }}
+`
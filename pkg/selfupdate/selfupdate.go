@@ -0,0 +1,304 @@
+// Package selfupdate replaces the running duffle executable with the latest release of a
+// release channel.
+//
+// Every channel publishes a manifest, CHANNEL/latest.json under the release URL, naming the
+// latest version and the URL and SHA-256 checksum of its binary for each platform. The
+// manifest is signed, in CHANNEL/latest.json.asc, by one of the release keys, so that the
+// checksums it lists can be trusted.
+package selfupdate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/deis/duffle/pkg/signature"
+)
+
+// DefaultURL is where the release channels are published unless URLEnv names another place
+const DefaultURL = "https://releases.duffle.sh"
+
+// URLEnv names the environment variable overriding where the release channels are
+// published, such as a mirror
+const URLEnv = "DUFFLE_RELEASES_URL"
+
+// DefaultChannel is the channel of the stable releases
+const DefaultChannel = "stable"
+
+// ManifestFile is the manifest of a channel, relative to the URL of the channel
+const ManifestFile = "latest.json"
+
+// ReleaseKeys lists the fingerprints of the keys that sign release manifests, separated by
+// commas. It is set when duffle is built, with
+// -ldflags "-X github.com/deis/duffle/pkg/selfupdate.ReleaseKeys=FINGERPRINT,...".
+var ReleaseKeys = ""
+
+// DefaultReleaseKeys returns the fingerprints listed in ReleaseKeys
+func DefaultReleaseKeys() []string {
+	var keys []string
+	for _, fp := range strings.Split(ReleaseKeys, ",") {
+		if fp = strings.TrimSpace(fp); fp != "" {
+			keys = append(keys, fp)
+		}
+	}
+	return keys
+}
+
+// Release is the latest release of a channel, as its manifest describes it
+type Release struct {
+	// Version is the version of the release, such as v1.2.3
+	Version string `json:"version"`
+	// Binaries are the binaries of the release by platform, such as linux-amd64
+	Binaries map[string]Binary `json:"binaries"`
+	// Signer is the key that signed the manifest
+	Signer *openpgp.Entity `json:"-"`
+
+	// manifest is the URL the manifest was fetched from, which binary URLs are relative to
+	manifest *url.URL
+}
+
+// Binary is the executable of a release for a platform
+type Binary struct {
+	// URL locates the executable, absolute or relative to the manifest
+	URL string `json:"url"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the executable
+	SHA256 string `json:"sha256"`
+}
+
+// Updater fetches releases and verifies them
+type Updater struct {
+	// URL is where the release channels are published; the one URL returns when empty
+	URL string
+	// Keyring holds the release keys
+	Keyring *signature.KeyRing
+	// ReleaseKeys are the fingerprints of the keys of Keyring allowed to sign manifests;
+	// DefaultReleaseKeys when empty
+	ReleaseKeys []string
+	// Client downloads manifests and binaries; http.DefaultClient when nil
+	Client *http.Client
+}
+
+// URL returns where the release channels are published: the place named by the
+// environment, else DefaultURL
+func URL() string {
+	if u := os.Getenv(URLEnv); u != "" {
+		return u
+	}
+	return DefaultURL
+}
+
+// Platform returns the platform duffle runs on, as manifests name it, such as linux-amd64
+func Platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// ValidChannel reports whether name may name a channel: letters, digits, '.', '-' and '_',
+// not starting with '.'
+func ValidChannel(name string) bool {
+	if name == "" || name[0] == '.' {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ManifestURL returns the URL of the manifest of channel
+func (u *Updater) ManifestURL(channel string) string {
+	base := u.URL
+	if base == "" {
+		base = URL()
+	}
+	return strings.TrimSuffix(base, "/") + "/" + channel + "/" + ManifestFile
+}
+
+// Latest fetches the manifest of channel and verifies its signature
+func (u *Updater) Latest(channel string) (*Release, error) {
+	if !ValidChannel(channel) {
+		return nil, fmt.Errorf("invalid release channel %q", channel)
+	}
+	manifest := u.ManifestURL(channel)
+	loc, err := url.Parse(manifest)
+	if err != nil {
+		return nil, err
+	}
+	data, err := u.fetch(manifest)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := u.fetch(manifest + ".asc")
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch the signature of the manifest of channel %s: %v", channel, err)
+	}
+	keys := u.ReleaseKeys
+	if len(keys) == 0 {
+		keys = DefaultReleaseKeys()
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("this build of duffle has no release keys: set the fingerprints of the keys signing releases with 'duffle config set releaseKeys=FINGERPRINT'")
+	}
+	kr := u.Keyring
+	if kr == nil {
+		kr = signature.NewKeyRing()
+	}
+	signer, err := signature.NewVerifier(kr).VerifyDetached(data, sig)
+	if err != nil {
+		return nil, fmt.Errorf("manifest of channel %s is not signed by a trusted key: %v", channel, err)
+	}
+	if !isReleaseKey(keys, signer) {
+		return nil, fmt.Errorf("manifest of channel %s is signed by %s, which is not a release key (expected one of %s)", channel, signature.Info(signer), strings.Join(keys, ", "))
+	}
+	r := &Release{Signer: signer, manifest: loc}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("cannot parse the manifest of channel %s: %v", channel, err)
+	}
+	if r.Version == "" {
+		return nil, fmt.Errorf("manifest of channel %s names no version", channel)
+	}
+	return r, nil
+}
+
+// isReleaseKey reports whether the fingerprint of e is one of keys. Fingerprints must
+// match in full: key IDs, which can be forged, are not accepted.
+func isReleaseKey(keys []string, e *openpgp.Entity) bool {
+	fp := signature.Fingerprint(e)
+	for _, k := range keys {
+		if strings.ToUpper(strings.Replace(k, " ", "", -1)) == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// Binary returns the binary of the release for the platform duffle runs on
+func (r *Release) Binary() (*Binary, error) {
+	b, ok := r.Binaries[Platform()]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no binary for %s", r.Version, Platform())
+	}
+	if b.URL == "" || b.SHA256 == "" {
+		return nil, fmt.Errorf("release %s lists no URL or checksum for the binary for %s", r.Version, Platform())
+	}
+	if r.manifest != nil {
+		ref, err := url.Parse(b.URL)
+		if err != nil {
+			return nil, err
+		}
+		b.URL = r.manifest.ResolveReference(ref).String()
+	}
+	return &b, nil
+}
+
+// Download downloads the binary of r for the platform duffle runs on and checks its
+// checksum
+func (u *Updater) Download(r *Release) ([]byte, error) {
+	b, err := r.Binary()
+	if err != nil {
+		return nil, err
+	}
+	data, err := u.fetch(b.URL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got, want := hex.EncodeToString(sum[:]), strings.ToLower(b.SHA256); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: got sha256:%s, expected sha256:%s", b.URL, got, want)
+	}
+	return data, nil
+}
+
+// Newer reports whether the version latest is newer than current. Versions that are not
+// semantic versions, such as canary builds, are older than any release.
+func Newer(current, latest string) (bool, error) {
+	l, err := semver.NewVersion(latest)
+	if err != nil {
+		return false, fmt.Errorf("invalid release version %q: %v", latest, err)
+	}
+	c, err := semver.NewVersion(current)
+	if err != nil {
+		return true, nil
+	}
+	return l.GreaterThan(c), nil
+}
+
+// Executable returns the path of the running executable, with symbolic links resolved
+func Executable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// Replace atomically replaces the executable at path with data: data is written next to
+// it, then renamed over it, so that path holds either the old or the new executable.
+// Windows does not allow replacing a running executable, so there the old one is first
+// moved aside to path.old, which the next update removes.
+func Replace(path string, data []byte) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %v", dir, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()|0111); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			os.Rename(old, path)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// fetch downloads the http(s) URL raw
+func (u *Updater) fetch(raw string) ([]byte, error) {
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(raw)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s: %s", raw, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
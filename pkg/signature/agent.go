@@ -0,0 +1,200 @@
+package signature
+
+import (
+	"bufio"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AgentSocketEnv is the environment variable holding the path of the socket of a running
+// gpg-agent, which is then asked for the passphrases of encrypted secret keys. gpg-agent
+// prompts for them with its pinentry program, and caches them.
+//
+// Keys held by gpg-agent are signed with through the socket it names as well, or through
+// the agent's default socket when it is unset.
+const AgentSocketEnv = "DUFFLE_AGENT_SOCKET"
+
+// Agent talks to a gpg-agent over its Assuan socket
+type Agent struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// DialAgent connects to the gpg-agent listening on the Unix socket at path
+func DialAgent(path string) (*Agent, error) {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to gpg-agent: %v", err)
+	}
+	a := &Agent{conn: conn, r: bufio.NewReader(conn)}
+	// the agent greets its clients with an OK line
+	if _, _, err := a.response(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot connect to gpg-agent: %v", err)
+	}
+	// tell the agent where its pinentry program should prompt, as gpg does
+	for option, env := range map[string]string{"ttyname": "GPG_TTY", "ttytype": "TERM", "display": "DISPLAY"} {
+		if v := os.Getenv(env); v != "" {
+			if _, _, err := a.command("OPTION", option+"="+v); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+	}
+	return a, nil
+}
+
+// Close closes the connection to the agent
+func (a *Agent) Close() error {
+	return a.conn.Close()
+}
+
+// Passphrase asks the agent for the passphrase cached as cacheID, which the agent prompts
+// for with desc when it is not cached. After a failed attempt, errMsg tells why the
+// passphrase is asked for again.
+func (a *Agent) Passphrase(cacheID, errMsg, desc string) ([]byte, error) {
+	if errMsg == "" {
+		errMsg = "X"
+	}
+	line, _, err := a.command("GET_PASSPHRASE", cacheID, errMsg, "Passphrase:", desc)
+	if err != nil {
+		return nil, err
+	}
+	// without --data, the passphrase is returned hex-encoded on the OK line
+	return hex.DecodeString(strings.TrimSpace(strings.TrimPrefix(line, "OK")))
+}
+
+// ClearPassphrase removes the passphrase cached as cacheID, such as one that turned out to
+// be wrong
+func (a *Agent) ClearPassphrase(cacheID string) error {
+	_, _, err := a.command("CLEAR_PASSPHRASE", cacheID)
+	return err
+}
+
+// HaveKey reports whether the agent holds the secret key with the given keygrip
+func (a *Agent) HaveKey(keygrip string) bool {
+	_, _, err := a.command("HAVEKEY", keygrip)
+	return err == nil
+}
+
+// Sign signs digest, computed with hash, with the secret key with the given keygrip,
+// returning the signature as a canonical S-expression. The agent asks for the key's
+// passphrase with desc when it needs it.
+func (a *Agent) Sign(keygrip string, hash crypto.Hash, digest []byte, desc string) ([]byte, error) {
+	algo, ok := agentHashAlgos[hash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash function %v", hash)
+	}
+	if _, _, err := a.command("SIGKEY", keygrip); err != nil {
+		return nil, err
+	}
+	if _, _, err := a.command("SETKEYDESC", desc); err != nil {
+		return nil, err
+	}
+	// the digest is sent as is, in hex, rather than escaped
+	if _, _, err := a.command("SETHASH", algo, hex.EncodeToString(digest)); err != nil {
+		return nil, err
+	}
+	_, data, err := a.command("PKSIGN")
+	return data, err
+}
+
+// agentHashAlgos are the libgcrypt numbers of the hash functions the agent signs digests of
+var agentHashAlgos = map[crypto.Hash]string{
+	crypto.SHA1:   "2",
+	crypto.SHA256: "8",
+	crypto.SHA384: "9",
+	crypto.SHA512: "10",
+	crypto.SHA224: "11",
+}
+
+// command sends a command with its arguments, percent-escaped, and returns the OK line
+// ending the response and the data sent before it
+func (a *Agent) command(name string, args ...string) (string, []byte, error) {
+	line := name
+	for _, arg := range args {
+		line += " " + assuanEscape(arg)
+	}
+	if _, err := fmt.Fprintf(a.conn, "%s\n", line); err != nil {
+		return "", nil, err
+	}
+	return a.response()
+}
+
+// response reads the lines of a response up to the OK or ERR line ending it, returning
+// the OK line and the data of the D lines
+func (a *Agent) response() (string, []byte, error) {
+	var data []byte
+	for {
+		line, err := a.r.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "OK" || strings.HasPrefix(line, "OK "):
+			return line, data, nil
+		case strings.HasPrefix(line, "ERR "):
+			return "", nil, fmt.Errorf("gpg-agent: %s", agentError(line))
+		case strings.HasPrefix(line, "D "):
+			data = append(data, assuanUnescape(line[2:])...)
+		case strings.HasPrefix(line, "INQUIRE "):
+			// inquiries, such as the PINENTRY_LAUNCHED notice, are answered with no data
+			if _, err := fmt.Fprint(a.conn, "END\n"); err != nil {
+				return "", nil, err
+			}
+		}
+		// status (S) and comment (#) lines are ignored
+	}
+}
+
+// agentError returns the description of an ERR line, "ERR CODE DESCRIPTION"
+func agentError(line string) string {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 3 {
+		return line
+	}
+	if d, err := url.PathUnescape(parts[2]); err == nil {
+		return d
+	}
+	return parts[2]
+}
+
+// assuanUnescape decodes the data of a D line, where %, CR and LF are percent-escaped
+func assuanUnescape(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if c, err := hex.DecodeString(s[i+1 : i+3]); err == nil {
+				b = append(b, c[0])
+				i += 2
+				continue
+			}
+		}
+		b = append(b, s[i])
+	}
+	return b
+}
+
+// assuanEscape encodes an argument of an Assuan command, where spaces are written as +
+func assuanEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ':
+			b.WriteByte('+')
+		case c == '+' || c == '%' || c < 0x20:
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
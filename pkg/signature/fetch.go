@@ -0,0 +1,192 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// DefaultKeyserver is the keyserver keys are fetched from when no other is given
+const DefaultKeyserver = "hkps://keys.openpgp.org"
+
+// ErrKeyNotFound is returned when a key lookup finds no key
+var ErrKeyNotFound = errors.New("no key found")
+
+// maxFetchedKeys is the most bytes of keys read from a WKD or keyserver response
+const maxFetchedKeys = 1 << 20
+
+// WKDURLs returns the URLs of the Web Key Directory entries of the mail address email: the
+// one of the advanced method, on the openpgpkey subdomain, and the one of the direct method
+func WKDURLs(email string) (advanced, direct string, err error) {
+	i := strings.LastIndex(email, "@")
+	if i < 1 || i == len(email)-1 {
+		return "", "", fmt.Errorf("invalid mail address %q", email)
+	}
+	local, domain := email[:i], strings.ToLower(email[i+1:])
+	sum := sha1.Sum([]byte(strings.ToLower(local)))
+	hash := zbase32(sum[:])
+	query := "?l=" + url.QueryEscape(local)
+	advanced = fmt.Sprintf("https://openpgpkey.%s/.well-known/openpgpkey/%s/hu/%s%s", domain, domain, hash, query)
+	direct = fmt.Sprintf("https://%s/.well-known/openpgpkey/hu/%s%s", domain, hash, query)
+	return advanced, direct, nil
+}
+
+// FetchWKD looks up the keys of the mail address email in the Web Key Directory of its
+// domain, trying the advanced method before the direct one. Only the keys with a user ID
+// of that address are returned. client is http.DefaultClient when nil.
+func FetchWKD(client *http.Client, email string) (*KeyRing, error) {
+	advanced, direct, err := WKDURLs(email)
+	if err != nil {
+		return nil, err
+	}
+	var errs []string
+	for _, u := range []string{advanced, direct} {
+		data, err := fetchKeys(client, u)
+		if err == nil {
+			return readFetchedKeys(data, email)
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("WKD lookup of %s failed: %s", email, strings.Join(errs, "; "))
+}
+
+// FetchKeyserver looks up the keys matching query, a mail address, a fingerprint or a key
+// ID, on the HKP keyserver at server, such as hkps://keys.openpgp.org. Keys looked up by
+// mail address are only returned when they have a user ID of that address. client is
+// http.DefaultClient when nil.
+func FetchKeyserver(client *http.Client, server, query string) (*KeyRing, error) {
+	base, err := keyserverURL(server)
+	if err != nil {
+		return nil, err
+	}
+	search := query
+	if !strings.Contains(query, "@") {
+		search = "0x" + strings.ToUpper(strings.TrimPrefix(strings.Replace(query, " ", "", -1), "0x"))
+	}
+	u := base + "/pks/lookup?op=get&options=mr&search=" + url.QueryEscape(search)
+	data, err := fetchKeys(client, u)
+	if err != nil {
+		return nil, fmt.Errorf("keyserver lookup of %s failed: %v", query, err)
+	}
+	email := ""
+	if strings.Contains(query, "@") {
+		email = query
+	}
+	return readFetchedKeys(data, email)
+}
+
+// keyserverURL returns the base HTTP(S) URL of the keyserver at server, whose hkp and hkps
+// schemes stand for http on port 11371 and https
+func keyserverURL(server string) (string, error) {
+	if !strings.Contains(server, "://") {
+		server = "hkps://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("invalid keyserver %q: %v", server, err)
+	}
+	switch u.Scheme {
+	case "hkps":
+		u.Scheme = "https"
+	case "hkp":
+		u.Scheme = "http"
+		if u.Port() == "" {
+			u.Host += ":11371"
+		}
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("invalid keyserver %q: unsupported scheme %q", server, u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid keyserver %q", server)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+func fetchKeys(client *http.Client, u string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrKeyNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s: %s", u, resp.Status)
+	}
+	// key lists are small; cap what is read from servers
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxFetchedKeys+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFetchedKeys {
+		return nil, fmt.Errorf("cannot fetch %s: more than %d bytes of keys", u, maxFetchedKeys)
+	}
+	return data, nil
+}
+
+// readFetchedKeys parses fetched keys, keeping the public parts of those with a user ID of
+// the mail address email when it is not empty
+func readFetchedKeys(data []byte, email string) (*KeyRing, error) {
+	fetched, err := ReadKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	kr := NewKeyRing()
+	for _, e := range fetched.Entities() {
+		if email != "" && !hasAddress(e, email) {
+			continue
+		}
+		if err := kr.Add(e, false); err != nil {
+			return nil, err
+		}
+	}
+	if len(kr.Entities()) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return kr, nil
+}
+
+// hasAddress reports whether one of the user IDs of e has the mail address email
+func hasAddress(e *openpgp.Entity, email string) bool {
+	for _, ident := range e.Identities {
+		if ident.UserId != nil && strings.EqualFold(ident.UserId.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// zbase32 encodes data with the z-base-32 alphabet, as WKD hashes local parts
+func zbase32(data []byte) string {
+	const alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+	var (
+		b     strings.Builder
+		buf   uint
+		nbits uint
+	)
+	for _, c := range data {
+		buf = buf<<8 | uint(c)
+		nbits += 8
+		for nbits >= 5 {
+			nbits -= 5
+			b.WriteByte(alphabet[(buf>>nbits)&31])
+		}
+	}
+	if nbits > 0 {
+		b.WriteByte(alphabet[(buf<<(5-nbits))&31])
+	}
+	return b.String()
+}
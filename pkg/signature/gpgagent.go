@@ -0,0 +1,204 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// AgentKeyPrefix marks signer IDs naming a key held by gpg-agent, such as
+// gpg-agent:0x52904D8120B5649A, rather than a key of the secret keyring
+const AgentKeyPrefix = "gpg-agent:"
+
+var (
+	// GPGCommand is the gpg executable used to find the keygrips of keys held by gpg-agent
+	GPGCommand = "gpg"
+	// GPGConfCommand is the gpgconf executable used to find the socket of gpg-agent
+	GPGConfCommand = "gpgconf"
+)
+
+// IsAgentKey reports whether the signer ID id names a key held by gpg-agent
+func IsAgentKey(id string) bool {
+	return strings.HasPrefix(id, AgentKeyPrefix)
+}
+
+// AgentSocket returns the path of the socket of gpg-agent: the one in AgentSocketEnv, or
+// else the agent's default socket, as gpgconf reports it
+func AgentSocket() (string, error) {
+	if socket := os.Getenv(AgentSocketEnv); socket != "" {
+		return socket, nil
+	}
+	out, err := exec.Command(GPGConfCommand, "--list-dirs", "agent-socket").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot find the socket of gpg-agent: %s: %v", GPGConfCommand, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// NewAgentSigner returns a Signer for the OpenPGP key of kr named by id, a signer ID with
+// the AgentKeyPrefix, whose secret key is held by the gpg-agent listening on socket. The
+// agent signs with a signing subkey of the key when it holds one, and with the primary
+// key otherwise; kr only needs the key's public part.
+func NewAgentSigner(kr *KeyRing, id, socket string) (*Signer, error) {
+	e, err := kr.Key(strings.TrimPrefix(id, AgentKeyPrefix))
+	if err != nil {
+		return nil, err
+	}
+	grips, err := keygrips(Fingerprint(e))
+	if err != nil {
+		return nil, err
+	}
+	a, err := DialAgent(socket)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	device := func(pk *packet.PublicKey) *agentSigner {
+		grip := grips[fmt.Sprintf("%X", pk.Fingerprint[:])]
+		if grip == "" || !a.HaveKey(grip) {
+			return nil
+		}
+		return &agentSigner{socket: socket, grip: grip, pub: pk.PublicKey, desc: fmt.Sprintf("Sign with the duffle signing key %s", Info(e))}
+	}
+	for i, sk := range e.Subkeys {
+		if sk.Sig == nil || !sk.Sig.FlagsValid || !sk.Sig.FlagSign {
+			continue
+		}
+		if s := device(sk.PublicKey); s != nil {
+			return &Signer{entity: withSigningKey(e, sk.PublicKey, s, i)}, nil
+		}
+	}
+	if s := device(e.PrimaryKey); s != nil {
+		return &Signer{entity: withSigningKey(e, e.PrimaryKey, s, -1)}, nil
+	}
+	return nil, fmt.Errorf("gpg-agent holds no secret key for %s", Info(e))
+}
+
+// keygrips returns the keygrips of the primary key with the given fingerprint and of its
+// subkeys, by fingerprint, as listed by gpg
+func keygrips(fingerprint string) (map[string]string, error) {
+	out, err := exec.Command(GPGCommand, "--batch", "--with-colons", "--with-keygrip", "--list-keys", fingerprint).Output()
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the keygrip of %s: %s: %v; is the key in the gpg keyring?", fingerprint, GPGCommand, err)
+	}
+	grips := map[string]string{}
+	var fpr string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 10 {
+			continue
+		}
+		// each fpr record and the grp record following it belong to the preceding key
+		switch fields[0] {
+		case "pub", "sub":
+			fpr = ""
+		case "fpr":
+			fpr = strings.ToUpper(fields[9])
+		case "grp":
+			if fpr != "" {
+				grips[fpr] = fields[9]
+			}
+		}
+	}
+	return grips, nil
+}
+
+// agentSigner signs digests with a key held by gpg-agent
+type agentSigner struct {
+	socket string
+	grip   string
+	pub    crypto.PublicKey
+	desc   string
+}
+
+func (s *agentSigner) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign has the agent sign digest. The agent pads RSA signatures itself; ECDSA signatures
+// are returned ASN.1-encoded, as crypto.Signer requires.
+func (s *agentSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	a, err := DialAgent(s.socket)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	sexp, err := a.Sign(s.grip, opts.HashFunc(), digest, s.desc)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseSigVal(sexp)
+	if err != nil {
+		return nil, err
+	}
+	switch s.pub.(type) {
+	case *rsa.PublicKey:
+		if sig, ok := values["s"]; ok {
+			return sig, nil
+		}
+	case *ecdsa.PublicKey:
+		r, rok := values["r"]
+		ss, sok := values["s"]
+		if rok && sok {
+			return asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(r), new(big.Int).SetBytes(ss)})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", s.pub)
+	}
+	return nil, errors.New("gpg-agent returned a signature of another type of key")
+}
+
+// parseSigVal returns the values of the parameters of a signature returned by gpg-agent,
+// a canonical S-expression such as (7:sig-val(3:rsa(1:s256:...)))
+func parseSigVal(sexp []byte) (map[string][]byte, error) {
+	values := map[string][]byte{}
+	var (
+		// atoms holds the atoms read so far in each open list
+		atoms [][][]byte
+		data  = sexp
+	)
+	for len(data) > 0 {
+		switch data[0] {
+		case '(':
+			atoms = append(atoms, nil)
+			data = data[1:]
+		case ')':
+			if len(atoms) == 0 {
+				return nil, errors.New("malformed signature from gpg-agent")
+			}
+			if l := atoms[len(atoms)-1]; len(l) == 2 {
+				values[string(l[0])] = l[1]
+			}
+			atoms = atoms[:len(atoms)-1]
+			data = data[1:]
+		default:
+			i := bytes.IndexByte(data, ':')
+			if i < 1 || len(atoms) == 0 {
+				return nil, errors.New("malformed signature from gpg-agent")
+			}
+			n, err := strconv.Atoi(string(data[:i]))
+			if err != nil || n < 0 || len(data) < i+1+n {
+				return nil, errors.New("malformed signature from gpg-agent")
+			}
+			atoms[len(atoms)-1] = append(atoms[len(atoms)-1], data[i+1:i+1+n])
+			data = data[i+1+n:]
+		}
+	}
+	if len(atoms) != 0 || len(values) == 0 {
+		return nil, errors.New("malformed signature from gpg-agent")
+	}
+	return values, nil
+}
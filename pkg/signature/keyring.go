@@ -0,0 +1,381 @@
+package signature
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+)
+
+// KeyRing is a collection of OpenPGP keys
+type KeyRing struct {
+	entities openpgp.EntityList
+	// packets holds the serialized packets of each entity, as read, so that keys are
+	// written back exactly as they were, signatures and encrypted private keys included
+	packets [][]byte
+	// revoked holds the local revocations of keys, by fingerprint
+	revoked map[string]Revocation
+}
+
+// NewKeyRing returns an empty keyring
+func NewKeyRing() *KeyRing {
+	return &KeyRing{}
+}
+
+// LoadKeyRing reads a binary or ASCII-armored keyring from path, along with the
+// revocation list next to it
+func LoadKeyRing(path string) (*KeyRing, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	kr, err := ReadKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read keyring %s: %v", path, err)
+	}
+	list, err := LoadRevocations(RevocationListPath(path))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range list {
+		if kr.revoked == nil {
+			kr.revoked = map[string]Revocation{}
+		}
+		kr.revoked[r.Fingerprint] = r
+	}
+	return kr, nil
+}
+
+// ReadKeyRing reads a binary or ASCII-armored keyring from r
+func ReadKeyRing(r io.Reader) (*KeyRing, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if block, err := armor.Decode(bytes.NewReader(data)); err == nil {
+		if data, err = ioutil.ReadAll(block.Body); err != nil {
+			return nil, err
+		}
+	}
+	k := &KeyRing{}
+	for _, p := range splitEntities(data) {
+		e, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(p)))
+		if err != nil {
+			return nil, err
+		}
+		k.entities = append(k.entities, e)
+		k.packets = append(k.packets, p)
+	}
+	if len(k.entities) == 0 && len(bytes.TrimSpace(data)) > 0 {
+		return nil, errors.New("no keys found")
+	}
+	return k, nil
+}
+
+// splitEntities splits a binary keyring into the packets of each of its keys, which start
+// with a primary public or private key packet
+func splitEntities(data []byte) [][]byte {
+	var (
+		entities [][]byte
+		start    = -1
+	)
+	r := bytes.NewReader(data)
+	for {
+		offset := len(data) - r.Len()
+		p, err := packet.Read(r)
+		if err == io.EOF || len(data)-r.Len() == offset {
+			break
+		}
+		// packets that cannot be parsed are skipped, and left to ReadEntity to judge
+		primary := false
+		switch p := p.(type) {
+		case *packet.PublicKey:
+			primary = !p.IsSubkey
+		case *packet.PublicKeyV3:
+			primary = !p.IsSubkey
+		case *packet.PrivateKey:
+			primary = !p.IsSubkey
+		}
+		if primary {
+			if start != -1 {
+				entities = append(entities, data[start:offset])
+			}
+			start = offset
+		}
+	}
+	if start != -1 {
+		entities = append(entities, data[start:len(data)-r.Len()])
+	}
+	return entities
+}
+
+// Add adds e to the keyring, replacing any key with the same fingerprint. With private,
+// the private key is kept; it must not be encrypted.
+func (k *KeyRing) Add(e *openpgp.Entity, private bool) error {
+	var buf bytes.Buffer
+	var err error
+	if private {
+		err = e.SerializePrivate(&buf, nil)
+	} else {
+		err = e.Serialize(&buf)
+	}
+	if err != nil {
+		return err
+	}
+	k.put(e, buf.Bytes())
+	return nil
+}
+
+// Merge adds every key of o to the keyring, as they were read, replacing any key with the
+// same fingerprint
+func (k *KeyRing) Merge(o *KeyRing) {
+	for i, e := range o.entities {
+		k.put(e, o.packets[i])
+	}
+}
+
+// Private returns the keys of the keyring that include their private key
+func (k *KeyRing) Private() *KeyRing {
+	private := &KeyRing{}
+	for i, e := range k.entities {
+		if e.PrivateKey != nil {
+			private.put(e, k.packets[i])
+		}
+	}
+	return private
+}
+
+func (k *KeyRing) put(e *openpgp.Entity, packets []byte) {
+	for i, existing := range k.entities {
+		if Fingerprint(existing) == Fingerprint(e) {
+			k.entities[i], k.packets[i] = e, packets
+			return
+		}
+	}
+	k.entities = append(k.entities, e)
+	k.packets = append(k.packets, packets)
+}
+
+// FindExact returns the keys id names exactly: by fingerprint, long or short key ID, full
+// identity, or the email address of an identity. Unlike Find, it does not match parts of
+// fingerprints or identities, so that it can select keys to remove.
+func (k *KeyRing) FindExact(id string) []*openpgp.Entity {
+	var found []*openpgp.Entity
+	for _, e := range k.entities {
+		if id != "" && matchesKeyExactly(e, id) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Remove removes the keys id names exactly, as understood by FindExact, and returns them
+func (k *KeyRing) Remove(id string) []*openpgp.Entity {
+	var removed []*openpgp.Entity
+	if id == "" {
+		return nil
+	}
+	for i := 0; i < len(k.entities); i++ {
+		if matchesKeyExactly(k.entities[i], id) {
+			removed = append(removed, k.entities[i])
+			k.entities = append(k.entities[:i], k.entities[i+1:]...)
+			k.packets = append(k.packets[:i], k.packets[i+1:]...)
+			i--
+		}
+	}
+	return removed
+}
+
+// Export returns the keys matching id, every key when id is empty, as an ASCII-armored
+// keyring. With private, private keys are exported as they are stored; otherwise only the
+// public keys are.
+func (k *KeyRing) Export(id string, private bool) ([]byte, error) {
+	blockType := openpgp.PublicKeyType
+	if private {
+		blockType = openpgp.PrivateKeyType
+	}
+	buf := &bytes.Buffer{}
+	w, err := armor.Encode(buf, blockType, nil)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for i, e := range k.entities {
+		if id != "" && !matchesKey(e, id) {
+			continue
+		}
+		found = true
+		if private {
+			if e.PrivateKey == nil {
+				return nil, fmt.Errorf("key %s has no private key", Fingerprint(e))
+			}
+			_, err = w.Write(k.packets[i])
+		} else {
+			err = e.Serialize(w)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("key %q not found", id)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile writes the keyring to path as a binary keyring
+func (k *KeyRing) WriteFile(path string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, bytes.Join(k.packets, nil), mode)
+}
+
+// Generate creates a new signing key for the identity "name (comment) <email>". The key
+// expires after lifetime, unless it is zero.
+func Generate(name, comment, email string, lifetime time.Duration) (*openpgp.Entity, error) {
+	e, err := openpgp.NewEntity(name, comment, email, nil)
+	if err != nil || lifetime == 0 {
+		return e, err
+	}
+	secs := uint32(lifetime / time.Second)
+	for _, ident := range e.Identities {
+		ident.SelfSignature.KeyLifetimeSecs = &secs
+		if err := ident.SelfSignature.SignUserId(ident.UserId.Id, e.PrimaryKey, e.PrivateKey, nil); err != nil {
+			return nil, err
+		}
+	}
+	for _, sk := range e.Subkeys {
+		sk.Sig.KeyLifetimeSecs = &secs
+		if err := sk.Sig.SignKey(sk.PublicKey, e.PrivateKey, nil); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Entities returns every key in the keyring
+func (k *KeyRing) Entities() openpgp.EntityList {
+	return k.entities
+}
+
+// Find returns every key matching id, as understood by Key
+func (k *KeyRing) Find(id string) []*openpgp.Entity {
+	var found []*openpgp.Entity
+	for _, e := range k.entities {
+		if id != "" && matchesKey(e, id) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Key finds the key id names exactly, as understood by FindExact, failing when it names
+// several keys so that an ambiguous ID never selects a key to sign with. An empty id
+// returns the first key that can sign.
+func (k *KeyRing) Key(id string) (*openpgp.Entity, error) {
+	if id == "" {
+		for _, e := range k.entities {
+			if e.PrivateKey != nil {
+				return e, nil
+			}
+		}
+		return nil, fmt.Errorf("no signing key found")
+	}
+	found := k.FindExact(id)
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("key %q not found: pass its fingerprint, key ID, identity or email address", id)
+	case 1:
+		return found[0], nil
+	}
+	keys := make([]string, len(found))
+	for i, e := range found {
+		keys[i] = Info(e).String()
+	}
+	return nil, fmt.Errorf("%q names %d keys: %s; pass the fingerprint of the one to use", id, len(found), strings.Join(keys, ", "))
+}
+
+func matchesKey(e *openpgp.Entity, id string) bool {
+	fingerprint := Fingerprint(e)
+	if strings.HasSuffix(fingerprint, strings.ToUpper(strings.TrimPrefix(id, "0x"))) {
+		return true
+	}
+	for name := range e.Identities {
+		if strings.Contains(name, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesKeyExactly reports whether id is the fingerprint of e, its long or short key ID,
+// one of its identities, or the email address of one
+func matchesKeyExactly(e *openpgp.Entity, id string) bool {
+	hex := strings.ToUpper(strings.TrimPrefix(strings.Replace(id, " ", "", -1), "0x"))
+	switch len(hex) {
+	case 8, 16, 40:
+		if strings.HasSuffix(Fingerprint(e), hex) {
+			return true
+		}
+	}
+	for name, ident := range e.Identities {
+		if name == id || (ident.UserId != nil && ident.UserId.Email != "" && strings.EqualFold(ident.UserId.Email, id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fingerprint returns the upper-case hex fingerprint of the key
+func Fingerprint(e *openpgp.Entity) string {
+	return fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+}
+
+// Identity returns the primary identity of the key
+func Identity(e *openpgp.Entity) string {
+	for name, ident := range e.Identities {
+		if ident.SelfSignature != nil && ident.SelfSignature.IsPrimaryId != nil && *ident.SelfSignature.IsPrimaryId {
+			return name
+		}
+	}
+	for name := range e.Identities {
+		return name
+	}
+	return Fingerprint(e)
+}
+
+// KeyInfo identifies the key that produced a verified signature, or only the identity of
+// the signer for keyless signatures
+type KeyInfo struct {
+	Fingerprint string `json:"fingerprint"`
+	Identity    string `json:"identity"`
+}
+
+// Info describes a key
+func Info(e *openpgp.Entity) *KeyInfo {
+	return &KeyInfo{Fingerprint: Fingerprint(e), Identity: Identity(e)}
+}
+
+func (k KeyInfo) String() string {
+	// keyless signers are only known by identity
+	if k.Fingerprint == "" {
+		return k.Identity
+	}
+	return fmt.Sprintf("%s (%s)", k.Identity, k.Fingerprint)
+}
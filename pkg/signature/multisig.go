@@ -0,0 +1,245 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// signatureArmor starts the signature block of a clearsigned message
+const signatureArmor = "-----BEGIN PGP SIGNATURE-----"
+
+// Cosign adds a signature by s to the clearsigned message, keeping the signatures it
+// already carries, so that several keys can vouch for the same document
+func (s *Signer) Cosign(clearsigned []byte) ([]byte, error) {
+	return s.resign(clearsigned, nil)
+}
+
+// Resign replaces the signatures by the key old on the clearsigned message with one by s,
+// keeping the signatures by other keys. It does not verify the signatures it replaces.
+func (s *Signer) Resign(clearsigned []byte, old *openpgp.Entity) ([]byte, error) {
+	return s.resign(clearsigned, old)
+}
+
+// resign adds a signature by s to the clearsigned message, dropping those by the key old
+// unless it is nil
+func (s *Signer) resign(clearsigned []byte, old *openpgp.Entity) ([]byte, error) {
+	block, _ := clearsign.Decode(clearsigned)
+	if block == nil {
+		return nil, errors.New("no clearsigned message found")
+	}
+	sigs, err := ioutil.ReadAll(block.ArmoredSignature.Body)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := splitPackets(sigs)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		kept    [][]byte
+		removed bool
+		config  = &packet.Config{}
+	)
+	for i, p := range packets {
+		issuer, hash := signatureInfo(p)
+		// the Hash header of the message lists the hash of the first signature
+		if i == 0 && hash != 0 {
+			config.DefaultHash = hash
+		}
+		switch {
+		case old != nil && hasKeyID(old, issuer):
+			removed = true
+		case issuer == s.entity.PrivateKey.KeyId:
+			return nil, fmt.Errorf("already signed by %s", Info(s.entity))
+		default:
+			kept = append(kept, p)
+		}
+	}
+	if old != nil && !removed {
+		return nil, fmt.Errorf("not signed by %s", Info(old))
+	}
+
+	sig := &bytes.Buffer{}
+	if err := openpgp.DetachSignText(sig, s.entity, bytes.NewReader(block.Bytes), config); err != nil {
+		return nil, err
+	}
+	start := bytes.LastIndex(clearsigned, []byte(signatureArmor))
+	buf := bytes.NewBuffer(append([]byte{}, clearsigned[:start]...))
+	w, err := armor.Encode(buf, openpgp.SignatureType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(bytes.Join(kept, nil), sig.Bytes()...)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// VerifyAll checks every signature on a clearsigned message, returning the distinct keys
+// that made a valid one and the signed content. Signatures by keys missing from the
+// keyring are ignored, as are those by revoked or expired keys when another signature is
+// valid. It fails when no signature can be verified.
+func (v *Verifier) VerifyAll(clearsigned []byte) ([]*openpgp.Entity, []byte, error) {
+	block, _ := clearsign.Decode(clearsigned)
+	if block == nil {
+		return nil, nil, errors.New("no clearsigned message found")
+	}
+	sigs, err := ioutil.ReadAll(block.ArmoredSignature.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	packets, err := splitPackets(sigs)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		signers []*openpgp.Entity
+		seen    = map[string]bool{}
+	)
+	err = pgperrors.ErrUnknownIssuer
+	for _, p := range packets {
+		signer, cerr := v.check(block.Bytes, p)
+		if cerr != nil {
+			// report why a known key was rejected rather than the unknown ones
+			if err == pgperrors.ErrUnknownIssuer {
+				err = cerr
+			}
+			continue
+		}
+		if fp := Fingerprint(signer); !seen[fp] {
+			seen[fp] = true
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) == 0 {
+		return nil, nil, err
+	}
+	return signers, block.Plaintext, nil
+}
+
+// Infos describes the keys es
+func Infos(es []*openpgp.Entity) []*KeyInfo {
+	infos := make([]*KeyInfo, len(es))
+	for i, e := range es {
+		infos[i] = Info(e)
+	}
+	return infos
+}
+
+// hasKeyID reports whether id is the ID of the primary key of e or of one of its subkeys
+func hasKeyID(e *openpgp.Entity, id uint64) bool {
+	if e.PrimaryKey.KeyId == id {
+		return true
+	}
+	for _, sk := range e.Subkeys {
+		if sk.PublicKey.KeyId == id {
+			return true
+		}
+	}
+	return false
+}
+
+// signatureInfo returns the ID of the key that made the binary signature sig and its hash
+// function, or zero values when sig cannot be parsed
+func signatureInfo(sig []byte) (uint64, crypto.Hash) {
+	p, err := packet.NewReader(bytes.NewReader(sig)).Next()
+	if err != nil {
+		return 0, 0
+	}
+	switch s := p.(type) {
+	case *packet.Signature:
+		if s.IssuerKeyId != nil {
+			return *s.IssuerKeyId, s.Hash
+		}
+		return 0, s.Hash
+	case *packet.SignatureV3:
+		return s.IssuerKeyId, s.Hash
+	}
+	return 0, 0
+}
+
+// splitPackets splits a sequence of binary OpenPGP packets, such as the signatures of a
+// message, into the individual packets
+func splitPackets(data []byte) ([][]byte, error) {
+	var packets [][]byte
+	for len(data) > 0 {
+		n, err := packetLength(data)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, data[:n])
+		data = data[n:]
+	}
+	if len(packets) == 0 {
+		return nil, errors.New("the message carries no signature")
+	}
+	return packets, nil
+}
+
+// packetLength returns the length, header included, of the OpenPGP packet starting data
+func packetLength(data []byte) (int, error) {
+	truncated := errors.New("truncated signature packet")
+	if data[0]&0x80 == 0 {
+		return 0, errors.New("malformed signature packet")
+	}
+	var header, body int
+	if data[0]&0x40 != 0 {
+		// new format
+		if len(data) < 2 {
+			return 0, truncated
+		}
+		switch l := int(data[1]); {
+		case l < 192:
+			header, body = 2, l
+		case l < 224:
+			if len(data) < 3 {
+				return 0, truncated
+			}
+			header, body = 3, (l-192)<<8+int(data[2])+192
+		case l == 255:
+			if len(data) < 6 {
+				return 0, truncated
+			}
+			header, body = 6, int(binary.BigEndian.Uint32(data[2:6]))
+		default:
+			return 0, errors.New("signature packets with partial lengths are not supported")
+		}
+	} else {
+		// old format
+		switch data[0] & 3 {
+		case 0:
+			header = 2
+		case 1:
+			header = 3
+		case 2:
+			header = 5
+		default:
+			// indeterminate length: the packet runs to the end of the data
+			return len(data), nil
+		}
+		if len(data) < header {
+			return 0, truncated
+		}
+		for _, b := range data[1:header] {
+			body = body<<8 | int(b)
+		}
+	}
+	if len(data) < header+body {
+		return 0, truncated
+	}
+	return header + body, nil
+}
@@ -0,0 +1,205 @@
+package signature
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// newTestKey generates a small key for name, created at created and expiring after
+// lifetime unless it is zero
+func newTestKey(t *testing.T, name string, created time.Time, lifetime time.Duration) *openpgp.Entity {
+	t.Helper()
+	config := &packet.Config{RSABits: 1024, Time: func() time.Time { return created }}
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lifetime != 0 {
+		secs := uint32(lifetime / time.Second)
+		for _, ident := range e.Identities {
+			ident.SelfSignature.KeyLifetimeSecs = &secs
+			if err := ident.SelfSignature.SignUserId(ident.UserId.Id, e.PrimaryKey, e.PrivateKey, config); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return e
+}
+
+// sign clearsigns data by every key of es, in order
+func sign(t *testing.T, data []byte, es ...*openpgp.Entity) []byte {
+	t.Helper()
+	var msg []byte
+	for i, e := range es {
+		s, err := NewSigner(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			msg, err = s.Clearsign(data)
+		} else {
+			msg, err = s.Cosign(msg)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return msg
+}
+
+// repeatSignatures returns the clearsigned message with each of its signatures given twice,
+// which Cosign refuses to produce
+func repeatSignatures(t *testing.T, clearsigned []byte) []byte {
+	t.Helper()
+	block, _ := clearsign.Decode(clearsigned)
+	if block == nil {
+		t.Fatal("no clearsigned message found")
+	}
+	sigs, err := ioutil.ReadAll(block.ArmoredSignature.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := bytes.LastIndex(clearsigned, []byte(signatureArmor))
+	buf := bytes.NewBuffer(append([]byte{}, clearsigned[:start]...))
+	w, err := armor.Encode(buf, openpgp.SignatureType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(append(sigs, sigs...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+func TestVerifyAll(t *testing.T) {
+	now := time.Now()
+	a := newTestKey(t, "a", now.Add(-time.Hour), 0)
+	b := newTestKey(t, "b", now.Add(-time.Hour), 0)
+	c := newTestKey(t, "c", now.Add(-time.Hour), 0)
+	expired := newTestKey(t, "expired", now.Add(-48*time.Hour), 24*time.Hour)
+	revoked := newTestKey(t, "revoked", now.Add(-time.Hour), 0)
+
+	kr := NewKeyRing()
+	for _, e := range []*openpgp.Entity{a, b, c, expired, revoked} {
+		if err := kr.Add(e, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kr.revoked = map[string]Revocation{Fingerprint(revoked): {Fingerprint: Fingerprint(revoked), Date: now}}
+	v := NewVerifier(kr)
+	data := []byte(`{"name":"foo","version":"0.1.0"}` + "\n")
+	unknown := newTestKey(t, "unknown", now.Add(-time.Hour), 0)
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		signers []*openpgp.Entity
+		err     string
+	}{
+		{"one signature", sign(t, data, a), []*openpgp.Entity{a}, ""},
+		{"several signatures", sign(t, data, a, b, c), []*openpgp.Entity{a, b, c}, ""},
+		{"repeated signatures", repeatSignatures(t, sign(t, data, a, b)), []*openpgp.Entity{a, b}, ""},
+		{"unknown key ignored", sign(t, data, unknown, b), []*openpgp.Entity{b}, ""},
+		{"unknown key only", sign(t, data, unknown), nil, pgperrors.ErrUnknownIssuer.Error()},
+		{"expired key ignored", sign(t, data, expired, a), []*openpgp.Entity{a}, ""},
+		{"expired key only", sign(t, data, expired), nil, "expired on"},
+		{"expired key reported before unknown ones", sign(t, data, unknown, expired), nil, "expired on"},
+		{"revoked key ignored", sign(t, data, a, revoked), []*openpgp.Entity{a}, ""},
+		{"revoked key only", sign(t, data, revoked), nil, "was revoked on"},
+		{"not clearsigned", data, nil, "no clearsigned message found"},
+	}
+	for _, tt := range tests {
+		signers, body, err := v.VerifyAll(tt.msg)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(body, data) {
+			t.Errorf("%s: got content %q, want %q", tt.name, body, data)
+		}
+		if got, want := fingerprints(signers), fingerprints(tt.signers); got != want {
+			t.Errorf("%s: got signers %s, want %s", tt.name, got, want)
+		}
+	}
+
+	tampered := bytes.Replace(sign(t, data, a), []byte(`"0.1.0"`), []byte(`"0.2.0"`), 1)
+	if _, _, err := v.VerifyAll(tampered); err == nil {
+		t.Error("tampered message: got no error")
+	}
+}
+
+func TestVerifyAllThreshold(t *testing.T) {
+	now := time.Now()
+	a := newTestKey(t, "a", now.Add(-time.Hour), 0)
+	b := newTestKey(t, "b", now.Add(-time.Hour), 0)
+	c := newTestKey(t, "c", now.Add(-time.Hour), 0)
+	expired := newTestKey(t, "expired", now.Add(-48*time.Hour), 24*time.Hour)
+
+	kr := NewKeyRing()
+	for _, e := range []*openpgp.Entity{a, b, c, expired} {
+		if err := kr.Add(e, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v := NewVerifier(kr)
+	data := []byte(`{"name":"foo","version":"0.1.0"}` + "\n")
+	twoOf := func(es ...*openpgp.Entity) Rule {
+		r := Rule{Threshold: 2}
+		for _, e := range es {
+			r.Keys = append(r.Keys, Fingerprint(e))
+		}
+		return r
+	}
+
+	tests := []struct {
+		name string
+		msg  []byte
+		rule Rule
+		want bool
+	}{
+		{"2 of 3 signed by 2", sign(t, data, a, b), twoOf(a, b, c), true},
+		{"2 of 3 signed by 3", sign(t, data, c, a, b), twoOf(a, b, c), true},
+		{"2 of 3 signed by 1", sign(t, data, a), twoOf(a, b, c), false},
+		{"2 of 3 signed twice by 1", repeatSignatures(t, sign(t, data, a)), twoOf(a, b, c), false},
+		{"2 of 2 signed by 1 and an untrusted key", sign(t, data, a, c), twoOf(a, b), false},
+		{"2 of 2 signed by 1 and an expired key", sign(t, data, a, expired), twoOf(a, expired), false},
+	}
+	for _, tt := range tests {
+		signers, _, err := v.VerifyAll(tt.msg)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := tt.rule.Satisfied(Infos(signers)); got != tt.want {
+			t.Errorf("%s: Satisfied by %s = %v, want %v", tt.name, fingerprints(signers), got, tt.want)
+		}
+	}
+}
+
+// fingerprints lists the fingerprints of es, in order
+func fingerprints(es []*openpgp.Entity) string {
+	fps := make([]string, len(es))
+	for i, e := range es {
+		fps[i] = Fingerprint(e)
+	}
+	return strings.Join(fps, ", ")
+}
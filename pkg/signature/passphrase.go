@@ -0,0 +1,52 @@
+package signature
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// PassphraseEnv is the environment variable holding the passphrase of encrypted secret keys
+const PassphraseEnv = "DUFFLE_KEY_PASSPHRASE"
+
+// ErrPassphrase is returned when a passphrase does not decrypt a secret key
+var ErrPassphrase = errors.New("incorrect passphrase")
+
+// Encrypted reports whether the private key of e, or of one of its subkeys, is encrypted
+// with a passphrase
+func Encrypted(e *openpgp.Entity) bool {
+	for _, k := range privateKeys(e) {
+		if k.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+// Decrypt decrypts the private keys of e, and of its subkeys, with passphrase. Keys are
+// only decrypted in memory: the keyring they were read from keeps them encrypted.
+func Decrypt(e *openpgp.Entity, passphrase []byte) error {
+	for _, k := range privateKeys(e) {
+		if !k.Encrypted {
+			continue
+		}
+		if err := k.Decrypt(passphrase); err != nil {
+			return ErrPassphrase
+		}
+	}
+	return nil
+}
+
+func privateKeys(e *openpgp.Entity) []*packet.PrivateKey {
+	var keys []*packet.PrivateKey
+	if e.PrivateKey != nil {
+		keys = append(keys, e.PrivateKey)
+	}
+	for _, sk := range e.Subkeys {
+		if sk.PrivateKey != nil {
+			keys = append(keys, sk.PrivateKey)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,288 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// PKCS11Tool is the OpenSC pkcs11-tool executable used to talk to hardware tokens
+var PKCS11Tool = "pkcs11-tool"
+
+// PKCS11PINEnv is the environment variable holding the PIN of PKCS#11 tokens. When it is
+// unset and the URI carries no PIN, pkcs11-tool prompts for it.
+const PKCS11PINEnv = "DUFFLE_PKCS11_PIN"
+
+// pkcs11Scheme starts PKCS#11 URIs (RFC 7512)
+const pkcs11Scheme = "pkcs11:"
+
+// PKCS11Key locates a private key held by a PKCS#11 token, such as a smart card or a
+// YubiKey's PIV applet, so that release keys never have to exist on disk.
+type PKCS11Key struct {
+	// Module is the path of the PKCS#11 module driving the token
+	Module string
+	// ID is the hex-encoded ID of the key object
+	ID string
+	// Label is the label of the key object, used when ID is empty
+	Label string
+	// Token is the label of the token holding the key
+	Token string
+	// Slot is the ID of the slot holding the token
+	Slot string
+	// PIN unlocks the token; the PIN in PKCS11PINEnv is used when empty
+	PIN string
+}
+
+// IsPKCS11URI reports whether s is a PKCS#11 URI rather than a key ID
+func IsPKCS11URI(s string) bool {
+	return strings.HasPrefix(s, pkcs11Scheme)
+}
+
+// ParsePKCS11URI parses the PKCS#11 URI s, such as
+// pkcs11:id=%01;token=YubiKey?module-path=/usr/lib/libykcs11.so.
+// The id, object, token and slot-id path attributes and the module-path and pin-value
+// query attributes are understood.
+func ParsePKCS11URI(s string) (*PKCS11Key, error) {
+	if !IsPKCS11URI(s) {
+		return nil, fmt.Errorf("%q is not a PKCS#11 URI", s)
+	}
+	path, query := strings.TrimPrefix(s, pkcs11Scheme), ""
+	if i := strings.Index(path, "?"); i != -1 {
+		path, query = path[:i], path[i+1:]
+	}
+	k := &PKCS11Key{}
+	for _, attr := range strings.Split(path, ";") {
+		if attr == "" {
+			continue
+		}
+		name, value, err := pkcs11Attr(attr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PKCS#11 URI %q: %v", s, err)
+		}
+		switch name {
+		case "id":
+			k.ID = fmt.Sprintf("%x", value)
+		case "object":
+			k.Label = value
+		case "token":
+			k.Token = value
+		case "slot-id":
+			k.Slot = value
+		}
+	}
+	for _, attr := range strings.Split(query, "&") {
+		if attr == "" {
+			continue
+		}
+		name, value, err := pkcs11Attr(attr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PKCS#11 URI %q: %v", s, err)
+		}
+		switch name {
+		case "module-path":
+			k.Module = value
+		case "pin-value":
+			k.PIN = value
+		}
+	}
+	if k.Module == "" {
+		return nil, fmt.Errorf("PKCS#11 URI %q does not set module-path", s)
+	}
+	if k.ID == "" && k.Label == "" {
+		return nil, fmt.Errorf("PKCS#11 URI %q names no key: set id or object", s)
+	}
+	return k, nil
+}
+
+func pkcs11Attr(attr string) (string, string, error) {
+	parts := strings.SplitN(attr, "=", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("attribute %q has no value", attr)
+	}
+	value, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return "", "", err
+	}
+	return parts[0], value, nil
+}
+
+// args returns the pkcs11-tool arguments selecting the token and the key object
+func (k *PKCS11Key) args() []string {
+	args := []string{"--module", k.Module}
+	if k.Slot != "" {
+		args = append(args, "--slot", k.Slot)
+	}
+	if k.Token != "" {
+		args = append(args, "--token-label", k.Token)
+	}
+	if k.ID != "" {
+		args = append(args, "--id", k.ID)
+	} else {
+		args = append(args, "--label", k.Label)
+	}
+	return args
+}
+
+// run runs pkcs11-tool with args, and env added to its environment, returning what it
+// wrote to its output file
+func (k *PKCS11Key) run(env []string, args ...string) ([]byte, error) {
+	out, err := ioutil.TempFile("", "duffle-pkcs11-")
+	if err != nil {
+		return nil, err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	cmd := exec.Command(PKCS11Tool, append(append(k.args(), args...), "--output-file", out.Name())...)
+	cmd.Env = append(os.Environ(), env...)
+	// pkcs11-tool prompts for the PIN on the terminal when it is not given
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v", PKCS11Tool, err)
+	}
+	return ioutil.ReadFile(out.Name())
+}
+
+// Public reads the public key of the key object from the token
+func (k *PKCS11Key) Public() (crypto.PublicKey, error) {
+	der, err := k.run(nil, "--read-object", "--type", "pubkey")
+	if err != nil {
+		return nil, err
+	}
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return pub, nil
+	}
+	if pub, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return pub, nil
+	}
+	return nil, errors.New("the token returned a public key that is neither RSA nor ECDSA")
+}
+
+// NewPKCS11Signer returns a Signer for the OpenPGP key of kr whose key material is held by
+// the token. The key may be a primary key or a subkey; kr only needs its public part.
+func NewPKCS11Signer(kr *KeyRing, k *PKCS11Key) (*Signer, error) {
+	pub, err := k.Public()
+	if err != nil {
+		return nil, err
+	}
+	device := &pkcs11Signer{key: k, pub: pub}
+	for _, e := range kr.entities {
+		if samePublicKey(e.PrimaryKey, pub) {
+			return &Signer{entity: withSigningKey(e, e.PrimaryKey, device, -1)}, nil
+		}
+		for i, sk := range e.Subkeys {
+			if sk.Sig != nil && sk.Sig.FlagsValid && sk.Sig.FlagSign && samePublicKey(sk.PublicKey, pub) {
+				return &Signer{entity: withSigningKey(e, sk.PublicKey, device, i)}, nil
+			}
+		}
+	}
+	return nil, errors.New("no key in the public keyring matches the key on the token; import its OpenPGP public key first")
+}
+
+// withSigningKey returns a copy of e that signs with the key pk, held by signer. subkey is
+// the index of pk among e's subkeys, or -1 for the primary key; other subkeys are left out
+// so that pk is the one picked for signing.
+func withSigningKey(e *openpgp.Entity, pk *packet.PublicKey, signer crypto.Signer, subkey int) *openpgp.Entity {
+	priv := packet.NewSignerPrivateKey(pk.CreationTime, signer)
+	// keep the key's own algorithm, such as RSA sign-only, and thus its fingerprint
+	priv.PublicKey = *pk
+	c := *e
+	c.PrivateKey = priv
+	c.Subkeys = nil
+	if subkey >= 0 {
+		sk := e.Subkeys[subkey]
+		sk.PrivateKey = priv
+		c.Subkeys = []openpgp.Subkey{sk}
+	}
+	return &c
+}
+
+// samePublicKey reports whether the OpenPGP key pk has the key material pub
+func samePublicKey(pk *packet.PublicKey, pub crypto.PublicKey) bool {
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		k, ok := pk.PublicKey.(*rsa.PublicKey)
+		return ok && k.N.Cmp(p.N) == 0 && k.E == p.E
+	case *ecdsa.PublicKey:
+		k, ok := pk.PublicKey.(*ecdsa.PublicKey)
+		return ok && k.Curve == p.Curve && k.X.Cmp(p.X) == 0 && k.Y.Cmp(p.Y) == 0
+	}
+	return false
+}
+
+// pkcs11Signer signs digests with a key held by a PKCS#11 token
+type pkcs11Signer struct {
+	key *PKCS11Key
+	pub crypto.PublicKey
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign signs digest on the token. RSA signatures use PKCS #1 v1.5 padding; ECDSA
+// signatures are returned ASN.1-encoded, as crypto.Signer requires.
+func (s *pkcs11Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var mechanism string
+	switch s.pub.(type) {
+	case *rsa.PublicKey:
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash function %v", opts.HashFunc())
+		}
+		digest = append(append([]byte{}, prefix...), digest...)
+		mechanism = "RSA-PKCS"
+	default:
+		mechanism = "ECDSA"
+	}
+
+	in, err := ioutil.TempFile("", "duffle-pkcs11-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(in.Name())
+	_, err = in.Write(digest)
+	if cerr := in.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--sign", "--mechanism", mechanism, "--signature-format", "openssl", "--input-file", in.Name(), "--login"}
+	var env []string
+	pin := s.key.PIN
+	if pin == "" {
+		pin = os.Getenv(PKCS11PINEnv)
+	}
+	if pin != "" {
+		// the PIN is passed in the environment, which unlike the command line other users
+		// cannot read, and which pkcs11-tool reads PINs from when given env:VARIABLE
+		env = []string{PKCS11PINEnv + "=" + pin}
+		args = append(args, "--pin", "env:"+PKCS11PINEnv)
+	}
+	return s.key.run(env, args...)
+}
+
+// digestInfoPrefixes are the DER-encoded DigestInfo headers that precede a digest in an
+// RSA PKCS #1 v1.5 signature, since the RSA-PKCS mechanism does not add them
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA224: {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
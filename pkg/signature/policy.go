@@ -0,0 +1,161 @@
+package signature
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+	"github.com/deis/duffle/pkg/sigstore"
+)
+
+// Policy restricts which keys may sign bundles from a given source. Sources without an
+// entry accept bundles signed by any key in the public keyring.
+type Policy struct {
+	// Repositories maps the names of configured bundle repositories to the keys allowed
+	// to sign their bundles
+	Repositories map[string]Rule `json:"repositories,omitempty"`
+	// Registries maps registry and web server host names to the keys allowed to sign
+	// bundles loaded from them
+	Registries map[string]Rule `json:"registries,omitempty"`
+	// Identities are the identities trusted to sign bundles without keys, with sigstore
+	Identities []sigstore.Identity `json:"identities,omitempty"`
+}
+
+// LoadPolicy reads the trust policy at path. A missing file is an empty policy.
+func LoadPolicy(path string) (*Policy, error) {
+	p := &Policy{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	return p, nil
+}
+
+// WriteFile saves the policy to path
+func (p *Policy) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// Rule lists the IDs of the keys allowed to sign the bundles of a source, and how many of
+// them must have signed a bundle for it to be accepted
+type Rule struct {
+	Keys []string `json:"keys"`
+	// Threshold is the number of distinct keys that must sign; one when unset
+	Threshold int `json:"threshold,omitempty"`
+}
+
+// UnmarshalJSON reads a rule, or a plain list of key IDs for a rule requiring one of them
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var keys []string
+	if err := json.Unmarshal(data, &keys); err == nil {
+		*r = Rule{Keys: keys}
+		return nil
+	}
+	type rule Rule
+	return json.Unmarshal(data, (*rule)(r))
+}
+
+// MarshalJSON writes rules requiring a single signature as a plain list of key IDs, as
+// earlier versions of the policy did
+func (r Rule) MarshalJSON() ([]byte, error) {
+	if r.Threshold <= 1 {
+		return json.Marshal(r.Keys)
+	}
+	type rule Rule
+	return json.Marshal(rule(r))
+}
+
+// Required returns how many of the keys must sign
+func (r Rule) Required() int {
+	if r.Threshold < 1 {
+		return 1
+	}
+	return r.Threshold
+}
+
+// Approvals returns the signers whose keys the rule allows
+func (r Rule) Approvals(signers []*KeyInfo) []*KeyInfo {
+	var approvals []*KeyInfo
+	seen := map[string]bool{}
+	for _, s := range signers {
+		if Allows(r.Keys, s) && !seen[s.Fingerprint] {
+			seen[s.Fingerprint] = true
+			approvals = append(approvals, s)
+		}
+	}
+	return approvals
+}
+
+// Satisfied reports whether enough of the keys of the rule are among signers
+func (r Rule) Satisfied(signers []*KeyInfo) bool {
+	return len(r.Approvals(signers)) >= r.Required()
+}
+
+// Validate checks that the threshold can be met by the keys of the rule
+func (r Rule) Validate() error {
+	if r.Threshold < 0 {
+		return errors.New("the threshold cannot be negative")
+	}
+	if r.Required() > len(r.Keys) {
+		return fmt.Errorf("a threshold of %d needs at least %d trusted keys, but there are %d", r.Required(), r.Required(), len(r.Keys))
+	}
+	return nil
+}
+
+func (r Rule) String() string {
+	keys := strings.Join(r.Keys, ", ")
+	if r.Required() == 1 {
+		return keys
+	}
+	return fmt.Sprintf("%d of %s", r.Required(), keys)
+}
+
+// RuleFor returns the rule for bundles from the named repository, or from host when the
+// repository has no entry. Hosts are expected in lower case. It returns nil when any key
+// is allowed.
+func (p *Policy) RuleFor(repository, host string) *Rule {
+	if r, ok := p.Repositories[repository]; ok && repository != "" {
+		return &r
+	}
+	if r, ok := p.Registries[host]; ok && host != "" {
+		return &r
+	}
+	return nil
+}
+
+// Allows reports whether the key k is one of keys. Keys are matched by fingerprint or by
+// the long or short key ID ending it, as with KeyRing.FindExact; shorter parts of the
+// fingerprint match no key.
+func Allows(keys []string, k *KeyInfo) bool {
+	if k == nil {
+		return false
+	}
+	for _, id := range keys {
+		id = strings.ToUpper(strings.TrimPrefix(strings.Replace(id, " ", "", -1), "0x"))
+		switch len(id) {
+		case 8, 16, 40:
+			if strings.HasSuffix(k.Fingerprint, id) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package signature
+
+import "testing"
+
+const (
+	fingerprintA = "0123456789ABCDEF0123456789ABCDEF01234567"
+	// fingerprintB shares the short key ID of fingerprintA, but not its long key ID
+	fingerprintB = "FEDCBA9876543210FEDCBA987654321001234567"
+	// fingerprintC shares the long key ID of fingerprintA
+	fingerprintC = "AAAAAAAAAAAAAAAAAAAAAAAA89ABCDEF01234567"
+)
+
+func TestAllows(t *testing.T) {
+	a := &KeyInfo{Fingerprint: fingerprintA, Identity: "A <a@example.com>"}
+	tests := []struct {
+		name string
+		keys []string
+		key  *KeyInfo
+		want bool
+	}{
+		{"fingerprint", []string{fingerprintA}, a, true},
+		{"lower-case fingerprint", []string{"0123456789abcdef0123456789abcdef01234567"}, a, true},
+		{"spaced fingerprint", []string{"0123 4567 89AB CDEF 0123  4567 89AB CDEF 0123 4567"}, a, true},
+		{"long key ID", []string{"89ABCDEF01234567"}, a, true},
+		{"prefixed long key ID", []string{"0x89ABCDEF01234567"}, a, true},
+		{"short key ID", []string{"01234567"}, a, true},
+		{"one of several keys", []string{fingerprintB, fingerprintA}, a, true},
+		{"other fingerprint", []string{fingerprintB}, a, false},
+		{"long key ID of a colliding short key ID", []string{"7654321001234567"}, a, false},
+		{"fingerprint of a colliding long key ID", []string{fingerprintC}, a, false},
+		{"fingerprint suffix shorter than a short key ID", []string{"4567"}, a, false},
+		{"fingerprint suffix between key IDs", []string{"ABCDEF01234567"}, a, false},
+		{"fingerprint prefix", []string{"0123456789ABCDEF"}, a, false},
+		{"empty key", []string{""}, a, false},
+		{"no keys", nil, a, false},
+		{"no signer", []string{fingerprintA}, nil, false},
+		{"keyless signer", []string{fingerprintA}, &KeyInfo{Identity: "a@example.com"}, false},
+	}
+	for _, tt := range tests {
+		if got := Allows(tt.keys, tt.key); got != tt.want {
+			t.Errorf("%s: Allows(%q, %v) = %v, want %v", tt.name, tt.keys, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRuleFor(t *testing.T) {
+	repoRule := Rule{Keys: []string{fingerprintA}}
+	hostRule := Rule{Keys: []string{fingerprintB}, Threshold: 1}
+	p := &Policy{
+		Repositories: map[string]Rule{"stable": repoRule},
+		Registries:   map[string]Rule{"registry.example.com": hostRule},
+	}
+	tests := []struct {
+		name       string
+		repository string
+		host       string
+		want       *Rule
+	}{
+		{"repository", "stable", "", &repoRule},
+		{"repository before host", "stable", "registry.example.com", &repoRule},
+		{"host of a repository without a rule", "incubator", "registry.example.com", &hostRule},
+		{"host", "", "registry.example.com", &hostRule},
+		{"unknown host", "", "other.example.com", nil},
+		{"no source", "", "", nil},
+	}
+	for _, tt := range tests {
+		got := p.RuleFor(tt.repository, tt.host)
+		switch {
+		case got == nil && tt.want == nil:
+		case got == nil || tt.want == nil || got.String() != tt.want.String():
+			t.Errorf("%s: RuleFor(%q, %q) = %v, want %v", tt.name, tt.repository, tt.host, got, tt.want)
+		}
+	}
+
+	if got := (&Policy{}).RuleFor("stable", "registry.example.com"); got != nil {
+		t.Errorf("empty policy: RuleFor = %v, want nil", got)
+	}
+	p.Repositories[""] = repoRule
+	if got := p.RuleFor("", ""); got != nil {
+		t.Errorf("unnamed repository: RuleFor = %v, want nil", got)
+	}
+}
+
+func TestRuleSatisfied(t *testing.T) {
+	a := &KeyInfo{Fingerprint: fingerprintA, Identity: "A"}
+	b := &KeyInfo{Fingerprint: fingerprintB, Identity: "B"}
+	c := &KeyInfo{Fingerprint: fingerprintC, Identity: "C"}
+	d := &KeyInfo{Fingerprint: "0000000000000000000000000000000000000000", Identity: "D"}
+	tests := []struct {
+		name      string
+		rule      Rule
+		signers   []*KeyInfo
+		approvals int
+		want      bool
+	}{
+		{"one of one", Rule{Keys: []string{fingerprintA}}, []*KeyInfo{a}, 1, true},
+		{"unset threshold needs one", Rule{Keys: []string{fingerprintA, fingerprintB}}, []*KeyInfo{b}, 1, true},
+		{"untrusted signer", Rule{Keys: []string{fingerprintA}}, []*KeyInfo{d}, 0, false},
+		{"no signers", Rule{Keys: []string{fingerprintA}}, nil, 0, false},
+		{"2 of 3", Rule{Keys: []string{fingerprintA, fingerprintB, fingerprintC}, Threshold: 2}, []*KeyInfo{a, c}, 2, true},
+		{"1 short of 2 of 3", Rule{Keys: []string{fingerprintA, fingerprintB, fingerprintC}, Threshold: 2}, []*KeyInfo{b, d}, 1, false},
+		{"3 of 3", Rule{Keys: []string{fingerprintA, fingerprintB, fingerprintC}, Threshold: 3}, []*KeyInfo{c, b, a}, 3, true},
+		{"duplicate signer counted once", Rule{Keys: []string{fingerprintA, fingerprintB}, Threshold: 2}, []*KeyInfo{a, a}, 1, false},
+		{"duplicate signer described twice", Rule{Keys: []string{fingerprintA, fingerprintB}, Threshold: 2}, []*KeyInfo{a, {Fingerprint: fingerprintA}}, 1, false},
+		{"duplicates among enough signers", Rule{Keys: []string{fingerprintA, fingerprintB}, Threshold: 2}, []*KeyInfo{a, b, a, b}, 2, true},
+		{"key listed twice", Rule{Keys: []string{fingerprintA, "01234567"}, Threshold: 2}, []*KeyInfo{a}, 1, false},
+		{"colliding long key ID not trusted", Rule{Keys: []string{fingerprintA, fingerprintB}, Threshold: 2}, []*KeyInfo{a, c}, 1, false},
+		// a short key ID names every key ending in it, which is why trusted keys are
+		// recorded by fingerprint
+		{"colliding short key IDs", Rule{Keys: []string{"01234567", fingerprintC}, Threshold: 2}, []*KeyInfo{a, b}, 2, true},
+	}
+	for _, tt := range tests {
+		if got := len(tt.rule.Approvals(tt.signers)); got != tt.approvals {
+			t.Errorf("%s: %d approvals, want %d", tt.name, got, tt.approvals)
+		}
+		if got := tt.rule.Satisfied(tt.signers); got != tt.want {
+			t.Errorf("%s: Satisfied = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		ok   bool
+	}{
+		{Rule{Keys: []string{fingerprintA}}, true},
+		{Rule{Keys: []string{fingerprintA, fingerprintB}, Threshold: 2}, true},
+		{Rule{Keys: []string{fingerprintA}, Threshold: 2}, false},
+		{Rule{Keys: []string{fingerprintA}, Threshold: -1}, false},
+		{Rule{}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok %v", tt.rule, err, tt.ok)
+		}
+	}
+}
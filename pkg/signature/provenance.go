@@ -0,0 +1,60 @@
+package signature
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/deis/duffle/pkg/bundle"
+	"github.com/deis/duffle/pkg/crypto/digest"
+)
+
+// Provenance is the signed metadata recorded in a bundle's .prov file
+type Provenance struct {
+	Name    string            `json:"name"`
+	Version string            `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+// ProvenancePath returns the path of the provenance file for the bundle file at path
+func ProvenancePath(path string) string {
+	return path + ".prov"
+}
+
+// CreateProvenance returns a clearsigned provenance document for the bundle file named filename with contents data
+func (s *Signer) CreateProvenance(b *bundle.Bundle, filename string, data []byte) ([]byte, error) {
+	p := Provenance{
+		Name:    b.Name,
+		Version: b.Version,
+		Files:   map[string]string{filepath.Base(filename): digest.OfBuffer(data)},
+	}
+	body, err := yaml.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return s.Clearsign(body)
+}
+
+// VerifyProvenance checks the signature on a provenance document and that it records the
+// digest of data for the bundle file named filename. It returns the signers, of which
+// there are several when the provenance was cosigned.
+func (v *Verifier) VerifyProvenance(prov []byte, filename string, data []byte) ([]*openpgp.Entity, error) {
+	signers, body, err := v.VerifyAll(prov)
+	if err != nil {
+		return nil, fmt.Errorf("provenance signature is not valid: %v", err)
+	}
+	p := Provenance{}
+	if err := yaml.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("cannot parse provenance: %v", err)
+	}
+	expected, ok := p.Files[filepath.Base(filename)]
+	if !ok {
+		return nil, fmt.Errorf("provenance does not cover %s", filepath.Base(filename))
+	}
+	if actual := digest.OfBuffer(data); actual != expected {
+		return nil, fmt.Errorf("digest mismatch for %s: provenance records %s, file is %s", filepath.Base(filename), expected, actual)
+	}
+	return signers, nil
+}
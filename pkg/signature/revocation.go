@@ -0,0 +1,147 @@
+package signature
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/deis/duffle/pkg/atomicfile"
+)
+
+// Revocation records that a key must no longer be trusted, whether or not its owner
+// published a revocation certificate for it
+type Revocation struct {
+	Fingerprint string    `json:"fingerprint"`
+	Identity    string    `json:"identity,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Date        time.Time `json:"date"`
+}
+
+// RevocationListPath returns the path of the revocation list applying to the keyring at
+// path: revoked.json, in the same directory
+func RevocationListPath(keyring string) string {
+	return filepath.Join(filepath.Dir(keyring), "revoked.json")
+}
+
+// LoadRevocations reads the revocation list at path. A missing file is an empty list.
+func LoadRevocations(path string) ([]Revocation, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Revocation
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	return list, nil
+}
+
+// WriteRevocations saves the revocation list to path
+func WriteRevocations(path string, list []Revocation) error {
+	if list == nil {
+		list = []Revocation{}
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// Expiry returns when the key e expires, according to the self-signature of its primary
+// identity, and false if it never expires
+func Expiry(e *openpgp.Entity) (time.Time, bool) {
+	var sig *packet.Signature
+	for name, ident := range e.Identities {
+		if ident.SelfSignature == nil {
+			continue
+		}
+		if sig == nil || name == Identity(e) {
+			sig = ident.SelfSignature
+		}
+	}
+	return lifetime(e.PrimaryKey, sig)
+}
+
+// lifetime returns when the key pk expires according to its self-signature sig
+func lifetime(pk *packet.PublicKey, sig *packet.Signature) (time.Time, bool) {
+	if sig == nil || sig.KeyLifetimeSecs == nil || *sig.KeyLifetimeSecs == 0 {
+		return time.Time{}, false
+	}
+	return pk.CreationTime.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second), true
+}
+
+// Revocation returns the local revocation of the key e, if it was revoked
+func (k *KeyRing) Revocation(e *openpgp.Entity) (Revocation, bool) {
+	r, ok := k.revoked[Fingerprint(e)]
+	return r, ok
+}
+
+// Check reports why the key e can no longer be trusted at time now: it was revoked, by
+// its owner or in the revocation list of the keyring, or it expired
+func (k *KeyRing) Check(e *openpgp.Entity, now time.Time) error {
+	msg, _ := k.problem(e, now)
+	if msg == "" {
+		return nil
+	}
+	return errors.New(msg)
+}
+
+// problem describes why the key e can no longer be trusted at time now, and whether it
+// was revoked rather than expired. The description is empty for trusted keys.
+func (k *KeyRing) problem(e *openpgp.Entity, now time.Time) (string, bool) {
+	if r, ok := k.Revocation(e); ok {
+		msg := fmt.Sprintf("key %s was revoked on %s", Info(e), r.Date.UTC().Format("2006-01-02"))
+		if r.Reason != "" {
+			msg += ": " + r.Reason
+		}
+		return msg, true
+	}
+	if len(e.Revocations) > 0 {
+		return fmt.Sprintf("key %s was revoked by its owner", Info(e)), true
+	}
+	if expiry, ok := Expiry(e); ok && now.After(expiry) {
+		return fmt.Sprintf("key %s expired on %s", Info(e), expiry.UTC().Format("2006-01-02")), false
+	}
+	return "", false
+}
+
+// checkSigningKey reports why a signature by the key of e with ID issuer, the primary key
+// or one of its subkeys, can no longer be trusted at time now, and what can be done
+func (k *KeyRing) checkSigningKey(e *openpgp.Entity, issuer uint64, now time.Time) error {
+	msg, revoked := k.problem(e, now)
+	for _, sk := range e.Subkeys {
+		if msg != "" {
+			break
+		}
+		if sk.PublicKey.KeyId != issuer || sk.Sig == nil {
+			continue
+		}
+		if sk.Sig.SigType == packet.SigTypeSubkeyRevocation {
+			msg, revoked = fmt.Sprintf("the signing subkey %X of key %s was revoked by its owner", issuer, Info(e)), true
+		} else if expiry, ok := lifetime(sk.PublicKey, sk.Sig); ok && now.After(expiry) {
+			msg = fmt.Sprintf("the signing subkey %X of key %s expired on %s", issuer, Info(e), expiry.UTC().Format("2006-01-02"))
+		}
+	}
+	switch {
+	case msg == "":
+		return nil
+	case revoked:
+		return fmt.Errorf("%s; ask the publisher for a bundle signed with a current key", msg)
+	default:
+		return fmt.Errorf("%s; import an updated copy of the key if its owner extended it, or ask the publisher for a bundle signed with a current key", msg)
+	}
+}
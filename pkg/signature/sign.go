@@ -0,0 +1,157 @@
+package signature
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+// Signer clearsigns documents with a private key
+type Signer struct {
+	entity *openpgp.Entity
+}
+
+// NewSigner returns a Signer for the given key, which must include its private key
+func NewSigner(e *openpgp.Entity) (*Signer, error) {
+	if e.PrivateKey == nil {
+		return nil, errors.New("key has no private key material")
+	}
+	return &Signer{entity: e}, nil
+}
+
+// Info describes the signer's key
+func (s *Signer) Info() *KeyInfo {
+	return Info(s.entity)
+}
+
+// Clearsign wraps data in a clearsigned OpenPGP message
+func (s *Signer) Clearsign(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := clearsign.Encode(buf, s.entity.PrivateKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DetachSign returns an ASCII-armored detached signature of data
+func (s *Signer) DetachSign(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := openpgp.ArmoredDetachSign(buf, s.entity, bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DetachSignBinary returns a binary detached signature of data, for formats carrying
+// signatures in their own encoding
+func (s *Signer) DetachSignBinary(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := openpgp.DetachSign(buf, s.entity, bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PublicKey returns the signer's public key, ASCII-armored
+func (s *Signer) PublicKey() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.entity.Serialize(w); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Verifier checks clearsigned documents against a keyring
+type Verifier struct {
+	keyring *KeyRing
+}
+
+// NewVerifier returns a Verifier trusting the keys in keyring
+func NewVerifier(keyring *KeyRing) *Verifier {
+	return &Verifier{keyring: keyring}
+}
+
+// Verify checks the signature on a clearsigned message, returning the signer and the signed content.
+// Signatures by revoked or expired keys are rejected. For messages signed by several
+// keys, the first one that verifies is returned; see VerifyAll.
+func (v *Verifier) Verify(clearsigned []byte) (*openpgp.Entity, []byte, error) {
+	signers, body, err := v.VerifyAll(clearsigned)
+	if err != nil {
+		return nil, nil, err
+	}
+	return signers[0], body, nil
+}
+
+// check verifies the binary signature sig of data and that the key that made it is still
+// trusted
+func (v *Verifier) check(data, sig []byte) (*openpgp.Entity, error) {
+	signer, err := openpgp.CheckDetachedSignature(v.keyring.entities, bytes.NewReader(data), bytes.NewReader(sig))
+	if err != nil {
+		return nil, err
+	}
+	issuer, _ := signatureInfo(sig)
+	if err := v.keyring.checkSigningKey(signer, issuer, time.Now()); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// Plaintext returns the content of a clearsigned message without verifying its signature
+func Plaintext(clearsigned []byte) ([]byte, error) {
+	block, _ := clearsign.Decode(clearsigned)
+	if block == nil {
+		return nil, errors.New("no clearsigned message found")
+	}
+	return block.Plaintext, nil
+}
+
+// VerifyDetached checks an ASCII-armored detached signature of data, returning the signer.
+// Signatures by revoked or expired keys are rejected.
+func (v *Verifier) VerifyDetached(data, sig []byte) (*openpgp.Entity, error) {
+	block, err := armor.Decode(bytes.NewReader(sig))
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != openpgp.SignatureType {
+		return nil, fmt.Errorf("expected an armored signature, got %q", block.Type)
+	}
+	raw, err := ioutil.ReadAll(block.Body)
+	if err != nil {
+		return nil, err
+	}
+	return v.check(data, raw)
+}
+
+// VerifyDetachedBinary checks a binary detached signature of data, returning the signer.
+// Signatures by revoked or expired keys are rejected.
+func (v *Verifier) VerifyDetachedBinary(data, sig []byte) (*openpgp.Entity, error) {
+	return v.check(data, sig)
+}
+
+// clearsignHeader starts every clearsigned OpenPGP message
+const clearsignHeader = "-----BEGIN PGP SIGNED MESSAGE-----"
+
+// IsClearsigned reports whether data is a clearsigned OpenPGP message
+func IsClearsigned(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(clearsignHeader))
+}
@@ -0,0 +1,102 @@
+// Package sigstore wraps the cosign CLI to sign and verify files without long-lived keys.
+//
+// Files are signed with a short-lived certificate issued by Fulcio for an OIDC identity,
+// such as the workload identity of a CI job, and the signature is recorded in the Rekor
+// transparency log. The certificate, signature and log entry are kept in a sigstore
+// bundle next to the file. Verification checks the log entry and that the certificate
+// was issued to an expected identity, instead of checking a keyring.
+package sigstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Command is the cosign executable invoked by this package
+var Command = "cosign"
+
+// BundlePath returns the path of the sigstore bundle of the file at path
+func BundlePath(path string) string {
+	return path + ".sigstore"
+}
+
+// Identity describes who may have signed a file: the subject of the signing certificate,
+// such as an email address or a CI workflow URL, and the OIDC issuer that vouched for it
+type Identity struct {
+	// Subject is the exact identity the certificate was issued to
+	Subject string `json:"subject,omitempty"`
+	// SubjectRegexp matches the identity the certificate was issued to, when Subject is empty
+	SubjectRegexp string `json:"subjectRegexp,omitempty"`
+	// Issuer is the URL of the OIDC issuer, such as https://token.actions.githubusercontent.com
+	Issuer string `json:"issuer"`
+}
+
+func (i Identity) String() string {
+	subject := i.Subject
+	if subject == "" {
+		subject = "/" + i.SubjectRegexp + "/"
+	}
+	return fmt.Sprintf("%s (%s)", subject, i.Issuer)
+}
+
+// Validate checks that the identity names a subject and an issuer
+func (i Identity) Validate() error {
+	if (i.Subject == "") == (i.SubjectRegexp == "") {
+		return errors.New("an identity needs exactly one of a subject and a subject regexp")
+	}
+	if i.Issuer == "" {
+		return errors.New("an identity needs an OIDC issuer")
+	}
+	return nil
+}
+
+func run(args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(Command, args...)
+	// signing outside of CI opens a browser or prompts for the OIDC flow
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("cosign %s: %s", args[0], msg)
+	}
+	return nil
+}
+
+// Sign signs the file at path with a certificate for the ambient OIDC identity and
+// writes its sigstore bundle to BundlePath(path). cosign picks the identity up from the
+// CI environment, or from SIGSTORE_ID_TOKEN, and falls back to an interactive login.
+func Sign(path string) error {
+	return run("sign-blob", "--yes", "--bundle", BundlePath(path), path)
+}
+
+// Verify checks the sigstore bundle of the file at path against the transparency log, and
+// that the file was signed by one of ids, which it returns
+func Verify(path string, ids []Identity) (*Identity, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("no identities are trusted for keyless signatures")
+	}
+	var errs []string
+	for _, id := range ids {
+		args := []string{"verify-blob", "--bundle", BundlePath(path), "--certificate-oidc-issuer", id.Issuer}
+		if id.Subject != "" {
+			args = append(args, "--certificate-identity", id.Subject)
+		} else {
+			args = append(args, "--certificate-identity-regexp", id.SubjectRegexp)
+		}
+		err := run(append(args, path)...)
+		if err == nil {
+			return &id, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("not signed by a trusted identity: %s", strings.Join(errs, "; "))
+}
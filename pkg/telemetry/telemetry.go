@@ -0,0 +1,137 @@
+// Package telemetry reports anonymous usage of duffle, when the user opted in: which
+// commands run, how long they take and the class of the errors they fail with. Bundle
+// names, references, parameters, paths and error messages are never reported.
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultEndpoint receives usage reports unless another endpoint is configured
+const DefaultEndpoint = "https://telemetry.duffle.sh/v1/events"
+
+// Env names the environment variable turning reporting on or off, taking precedence over
+// the configuration
+const Env = "DUFFLE_TELEMETRY"
+
+// EndpointEnv names the environment variable overriding the endpoint reports are sent to,
+// such as a self-hosted collector
+const EndpointEnv = "DUFFLE_TELEMETRY_ENDPOINT"
+
+// Timeout bounds how long sending a report may delay the exit of duffle
+const Timeout = 2 * time.Second
+
+// Event reports one run of a command
+type Event struct {
+	// Command is the path of the command, such as "duffle repo add"
+	Command string `json:"command"`
+	// OS and Arch are the platform duffle runs on
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// DurationMS is how long the command ran, in milliseconds
+	DurationMS int64 `json:"durationMs"`
+	// Success is set when the command succeeded
+	Success bool `json:"success"`
+	// ErrorClass is the class of the error the command failed with, as Classify returns it
+	ErrorClass string `json:"errorClass,omitempty"`
+}
+
+// NewEvent returns the report of command, which ran for d and failed with err unless it is nil
+func NewEvent(command string, d time.Duration, err error) Event {
+	return Event{
+		Command:    command,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		DurationMS: int64(d / time.Millisecond),
+		Success:    err == nil,
+		ErrorClass: Classify(err),
+	}
+}
+
+// Classify returns the class of err, without any of its details: usage, network,
+// filesystem, process or other. It is empty when err is nil.
+func Classify(err error) string {
+	if err == nil {
+		return ""
+	}
+	var (
+		netErr  net.Error
+		urlErr  *url.Error
+		pathErr *os.PathError
+		linkErr *os.LinkError
+		exitErr *exec.ExitError
+	)
+	switch {
+	case isUsage(err):
+		return "usage"
+	case errors.As(err, &netErr), errors.As(err, &urlErr):
+		return "network"
+	case errors.As(err, &pathErr), errors.As(err, &linkErr):
+		return "filesystem"
+	case errors.As(err, &exitErr):
+		return "process"
+	default:
+		return "other"
+	}
+}
+
+// isUsage reports whether err is an error of cobra about the command line
+func isUsage(err error) bool {
+	msg := err.Error()
+	for _, prefix := range []string{"unknown command", "unknown flag", "unknown shorthand flag", "invalid argument", "flag needs an argument", "accepts ", "requires at least", "requires at most", "required flag"} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoint returns the endpoint reports are sent to: the one of the environment, else
+// configured, else DefaultEndpoint
+func Endpoint(configured string) string {
+	if e := os.Getenv(EndpointEnv); e != "" {
+		return e
+	}
+	if configured != "" {
+		return configured
+	}
+	return DefaultEndpoint
+}
+
+// Enabled reports whether reporting is on: as the environment says when it sets Env, and
+// as configured, on or off, otherwise. Reporting is off unless turned on.
+func Enabled(configured string) bool {
+	if e := os.Getenv(Env); e != "" {
+		configured = e
+	}
+	return strings.EqualFold(configured, "on")
+}
+
+// Send posts e to endpoint as JSON, giving up after Timeout
+func Send(endpoint string, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: Timeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("cannot report usage to %s: %s", endpoint, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+// Package version holds the version of duffle.
+package version
+
+// Version is the version of duffle, set at build time with
+// -ldflags "-X github.com/deis/duffle/pkg/version.Version=v1.2.3". Builds from source that
+// do not set it are "canary".
+var Version = "canary"